@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Azure OpenAI addresses deployments instead of models:
+// /openai/deployments/{deployment}/chat/completions?api-version=...
+// The body usually has no model, so the deployment name stands in until the
+// response reports the underlying model.
+
+// AzureConfig represents the [azure] section of the config file
+type AzureConfig struct {
+	// Deployment name -> model slug used for pricing, e.g. "prod-chat" = "gpt-4o"
+	Deployments map[string]string `toml:"deployments"`
+}
+
+var (
+	azureDeployments   map[string]string
+	azureDeploymentsMu sync.RWMutex
+)
+
+// SetAzureDeployments replaces the deployment -> pricing model mapping
+func SetAzureDeployments(deployments map[string]string) {
+	azureDeploymentsMu.Lock()
+	defer azureDeploymentsMu.Unlock()
+	azureDeployments = deployments
+}
+
+// azureDeploymentModel returns the configured pricing model for a deployment
+func azureDeploymentModel(deployment string) string {
+	azureDeploymentsMu.RLock()
+	defer azureDeploymentsMu.RUnlock()
+	return azureDeployments[deployment]
+}
+
+// azureDeploymentFromPath extracts the deployment name from an Azure OpenAI path
+func azureDeploymentFromPath(path string) string {
+	const marker = "/openai/deployments/"
+	idx := strings.Index(path, marker)
+	if idx < 0 {
+		return ""
+	}
+	deployment := path[idx+len(marker):]
+	if slash := strings.Index(deployment, "/"); slash >= 0 {
+		deployment = deployment[:slash]
+	}
+	return deployment
+}
+
+// responseModel returns the model reported in an OpenAI-style response or stream
+func responseModel(body []byte) string {
+	if isSSEData(body) {
+		if assembled := reassembleSSEResponse(body); assembled != nil {
+			return assembled.Model
+		}
+		return ""
+	}
+	var resp struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return ""
+	}
+	return resp.Model
+}
+
+// pricingModel returns the model slug to look up prices for. Azure
+// deployments can be mapped explicitly in the config; otherwise the model
+// reported by the response is used.
+func pricingModel(req *LLMRequest) string {
+	if req.Deployment != "" {
+		if model := azureDeploymentModel(req.Deployment); model != "" {
+			return model
+		}
+	}
+	return req.Model
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedModelsDB installs a small pricing database for the duration of a test
+func seedModelsDB(t *testing.T, providers map[string]Provider, global map[string]ModelCost) {
+	t.Helper()
+	modelsDB.mu.Lock()
+	prevProviders, prevGlobal, prevLoaded := modelsDB.providers, modelsDB.globalModels, modelsDB.loaded
+	modelsDB.providers, modelsDB.globalModels, modelsDB.loaded = providers, global, true
+	modelsDB.mu.Unlock()
+	t.Cleanup(func() {
+		modelsDB.mu.Lock()
+		modelsDB.providers, modelsDB.globalModels, modelsDB.loaded = prevProviders, prevGlobal, prevLoaded
+		modelsDB.mu.Unlock()
+	})
+}
+
+func TestAzureDeploymentFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/openai/deployments/prod-chat/chat/completions": "prod-chat",
+		"/openai/deployments/emb/embeddings":             "emb",
+		"/v1/chat/completions":                           "",
+	}
+	for path, want := range tests {
+		if got := azureDeploymentFromPath(path); got != want {
+			t.Errorf("azureDeploymentFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	seedModelsDB(t, map[string]Provider{"azure": {ID: "azure"}}, map[string]ModelCost{})
+	if id, _ := FindProviderByURL("https://my-resource.openai.azure.com/openai/deployments/x/chat/completions"); id != "azure" {
+		t.Errorf("FindProviderByURL = %q, want azure", id)
+	}
+}
+
+func TestAzureDeploymentProxyIntegration(t *testing.T) {
+	resetTestState()
+	seedModelsDB(t, map[string]Provider{}, map[string]ModelCost{"gpt-4o": {Input: 2.5, Output: 10}})
+	SetAzureDeployments(map[string]string{"prod-chat": "gpt-4o"})
+	t.Cleanup(func() { SetAzureDeployments(nil) })
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api-version") == "" {
+			t.Errorf("api-version query not forwarded: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o-2024-11-20","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100}}`))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-azure", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	url := fmt.Sprintf("http://localhost:%d/openai/deployments/prod-chat/chat/completions?api-version=2024-10-21", port)
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.Deployment != "prod-chat" {
+		t.Errorf("deployment = %q", captured.Deployment)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for captured.Cost == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if captured.Model != "gpt-4o-2024-11-20" {
+		t.Errorf("model = %q, want the model from the response", captured.Model)
+	}
+	if want := (1000*2.5 + 100*10) / 1_000_000; math.Abs(captured.Cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v from the deployment mapping", captured.Cost, want)
+	}
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bedrock runtime API: /model/{modelId}/invoke, invoke-with-response-stream,
+// converse and converse-stream. InvokeModel passes the vendor's own body
+// through (Anthropic models use the Messages format); Converse has its own
+// message schema, which is mapped onto the chat completions types for display
+// like the Responses API.
+
+var bedrockActions = []string{"invoke", "invoke-with-response-stream", "converse", "converse-stream"}
+
+// bedrockPathParts splits a Bedrock runtime path into model ID and action
+func bedrockPathParts(path string) (modelID, action string) {
+	idx := strings.Index(path, "/model/")
+	if idx < 0 {
+		return "", ""
+	}
+	rest := path[idx+len("/model/"):]
+	slash := strings.LastIndex(rest, "/")
+	if slash <= 0 {
+		return "", ""
+	}
+	action = rest[slash+1:]
+	for _, a := range bedrockActions {
+		if action == a {
+			// Model IDs may be URL-encoded ARNs
+			modelID = rest[:slash]
+			if unescaped, err := url.PathUnescape(modelID); err == nil {
+				modelID = unescaped
+			}
+			return modelID, action
+		}
+	}
+	return "", ""
+}
+
+// isBedrockRuntimeEndpoint returns true for the Bedrock runtime model endpoints
+func isBedrockRuntimeEndpoint(path string) bool {
+	_, action := bedrockPathParts(path)
+	return action != ""
+}
+
+// isBedrockConverseEndpoint returns true for converse and converse-stream
+func isBedrockConverseEndpoint(path string) bool {
+	_, action := bedrockPathParts(path)
+	return action == "converse" || action == "converse-stream"
+}
+
+// isBedrockStreamEndpoint returns true for the streaming actions, which
+// stream regardless of the request body
+func isBedrockStreamEndpoint(path string) bool {
+	_, action := bedrockPathParts(path)
+	return action == "invoke-with-response-stream" || action == "converse-stream"
+}
+
+// isBedrockAnthropicInvoke returns true when InvokeModel targets an Anthropic
+// model, whose body is the Anthropic Messages format
+func isBedrockAnthropicInvoke(path string) bool {
+	modelID, action := bedrockPathParts(path)
+	return strings.HasPrefix(action, "invoke") && strings.Contains(modelID, "anthropic.")
+}
+
+// bedrockModelFromPath returns the model ID from a Bedrock runtime path
+func bedrockModelFromPath(path string) string {
+	modelID, _ := bedrockPathParts(path)
+	return modelID
+}
+
+// Cross-region inference profile prefixes on Bedrock model IDs
+var bedrockRegionPrefixes = map[string]bool{"us": true, "eu": true, "apac": true, "us-gov": true, "global": true, "jp": true, "au": true, "ca": true}
+
+// bedrockBaseModel strips the cross-region prefix, vendor and version from a
+// Bedrock model ID: "us.anthropic.claude-3-5-sonnet-20241022-v2:0" becomes
+// "claude-3-5-sonnet-20241022". Returns "" for other model names.
+func bedrockBaseModel(modelID string) string {
+	if idx := strings.LastIndex(modelID, "/"); idx >= 0 {
+		modelID = modelID[idx+1:] // inference profile ARN
+	}
+	if prefix, rest, ok := strings.Cut(modelID, "."); ok && bedrockRegionPrefixes[prefix] {
+		modelID = rest
+	}
+	_, name, ok := strings.Cut(modelID, ".")
+	if !ok {
+		return ""
+	}
+	if idx := strings.LastIndex(name, "-v"); idx > 0 && strings.Contains(name[idx:], ":") {
+		name = name[:idx]
+	}
+	return name
+}
+
+// --- AWS event stream framing ---
+
+// eventStreamMessage is one frame of an application/vnd.amazon.eventstream body
+type eventStreamMessage struct {
+	EventType   string // :event-type header (e.g. contentBlockDelta, chunk)
+	MessageType string // :message-type header (event or exception)
+	Payload     []byte
+}
+
+// isAWSEventStream returns true if the data starts with a valid event stream prelude
+func isAWSEventStream(data []byte) bool {
+	if len(data) < 16 {
+		return false
+	}
+	total := binary.BigEndian.Uint32(data[0:4])
+	headers := binary.BigEndian.Uint32(data[4:8])
+	return total >= 16 && headers <= total-16 && crc32.ChecksumIEEE(data[0:8]) == binary.BigEndian.Uint32(data[8:12])
+}
+
+// parseEventStream splits an AWS event stream into messages. A stream that is
+// still being received yields the messages that are complete so far.
+func parseEventStream(data []byte) []eventStreamMessage {
+	var messages []eventStreamMessage
+	for isAWSEventStream(data) {
+		total := int(binary.BigEndian.Uint32(data[0:4]))
+		headersLen := int(binary.BigEndian.Uint32(data[4:8]))
+		if total > len(data) {
+			break
+		}
+		msg := eventStreamMessage{Payload: data[12+headersLen : total-4]}
+		for name, value := range parseEventStreamHeaders(data[12 : 12+headersLen]) {
+			switch name {
+			case ":event-type":
+				msg.EventType = value
+			case ":message-type":
+				msg.MessageType = value
+			}
+		}
+		messages = append(messages, msg)
+		data = data[total:]
+	}
+	return messages
+}
+
+// parseEventStreamHeaders decodes the string-valued headers of a frame
+func parseEventStreamHeaders(data []byte) map[string]string {
+	headers := make(map[string]string)
+	// Value sizes by header type; -1 means a 2-byte length prefix follows
+	sizes := map[byte]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 4, 5: 8, 6: -1, 7: -1, 8: 8, 9: 16}
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			break
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		size, ok := sizes[valueType]
+		if !ok {
+			break
+		}
+		if size < 0 {
+			if len(data) < 2 {
+				break
+			}
+			size = int(binary.BigEndian.Uint16(data[0:2]))
+			data = data[2:]
+			if len(data) < size {
+				break
+			}
+			if valueType == 7 {
+				headers[name] = string(data[:size])
+			}
+		}
+		if len(data) < size {
+			break
+		}
+		data = data[size:]
+	}
+	return headers
+}
+
+// --- Converse API ---
+
+type ConverseToolUse struct {
+	ToolUseID string `json:"toolUseId"`
+	Name      string `json:"name"`
+	Input     any    `json:"input,omitempty"`
+}
+
+type ConverseToolResult struct {
+	ToolUseID string                 `json:"toolUseId"`
+	Content   []ConverseContentBlock `json:"content"`
+	Status    string                 `json:"status,omitempty"`
+}
+
+type ConverseReasoning struct {
+	ReasoningText struct {
+		Text      string `json:"text"`
+		Signature string `json:"signature,omitempty"`
+	} `json:"reasoningText"`
+}
+
+type ConverseImage struct {
+	Format string `json:"format"`
+	Source struct {
+		Bytes string `json:"bytes,omitempty"` // base64 in JSON
+	} `json:"source"`
+}
+
+// ConverseContentBlock is one entry of a Converse message's content; exactly
+// one field is set
+type ConverseContentBlock struct {
+	Text             string              `json:"text,omitempty"`
+	Image            *ConverseImage      `json:"image,omitempty"`
+	ToolUse          *ConverseToolUse    `json:"toolUse,omitempty"`
+	ToolResult       *ConverseToolResult `json:"toolResult,omitempty"`
+	ReasoningContent *ConverseReasoning  `json:"reasoningContent,omitempty"`
+	JSON             any                 `json:"json,omitempty"` // tool result content
+	Document         any                 `json:"document,omitempty"`
+	CachePoint       any                 `json:"cachePoint,omitempty"`
+}
+
+type ConverseMessage struct {
+	Role    string                 `json:"role"`
+	Content []ConverseContentBlock `json:"content"`
+}
+
+type ConverseRequest struct {
+	Messages        []ConverseMessage      `json:"messages"`
+	System          []ConverseContentBlock `json:"system,omitempty"`
+	InferenceConfig struct {
+		MaxTokens   int     `json:"maxTokens,omitempty"`
+		Temperature float64 `json:"temperature,omitempty"`
+	} `json:"inferenceConfig"`
+}
+
+type ConverseUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens,omitempty"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens,omitempty"`
+}
+
+type ConverseResponse struct {
+	Output struct {
+		Message *ConverseMessage `json:"message"`
+	} `json:"output"`
+	StopReason string        `json:"stopReason"`
+	Usage      ConverseUsage `json:"usage"`
+	Metrics    struct {
+		LatencyMs int64 `json:"latencyMs"`
+	} `json:"metrics"`
+}
+
+// converseBlocksToOpenAI maps content blocks onto chat message content, tool
+// calls and reasoning. Tool results are returned as separate tool messages.
+func converseBlocksToOpenAI(role string, blocks []ConverseContentBlock) (OpenAIMessage, []OpenAIMessage) {
+	msg := OpenAIMessage{Role: role}
+	var parts []any
+	var texts, reasoning []string
+	hasImage := false
+	var toolMessages []OpenAIMessage
+
+	for _, block := range blocks {
+		switch {
+		case block.ToolUse != nil:
+			args, _ := json.Marshal(block.ToolUse.Input)
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       block.ToolUse.ToolUseID,
+				Type:     "function",
+				Index:    len(msg.ToolCalls),
+				Function: ToolCallFunction{Name: block.ToolUse.Name, Arguments: string(args)},
+			})
+		case block.ToolResult != nil:
+			toolMessages = append(toolMessages, OpenAIMessage{
+				Role:       "tool",
+				ToolCallID: block.ToolResult.ToolUseID,
+				Content:    converseBlocksText(block.ToolResult.Content),
+			})
+		case block.ReasoningContent != nil:
+			reasoning = append(reasoning, block.ReasoningContent.ReasoningText.Text)
+		case block.Image != nil:
+			hasImage = true
+			url := fmt.Sprintf("data:image/%s;base64,%s", block.Image.Format, block.Image.Source.Bytes)
+			parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]any{"url": url}})
+		case block.Document != nil:
+			texts = append(texts, "[document]")
+			parts = append(parts, map[string]any{"type": "text", "text": "[document]"})
+		case block.Text != "":
+			texts = append(texts, block.Text)
+			parts = append(parts, map[string]any{"type": "text", "text": block.Text})
+		}
+	}
+
+	if hasImage {
+		msg.Content = parts
+	} else {
+		msg.Content = strings.Join(texts, "\n")
+	}
+	msg.ReasoningContent = strings.Join(reasoning, "\n\n")
+	return msg, toolMessages
+}
+
+// converseBlocksText joins the text and JSON blocks of a tool result
+func converseBlocksText(blocks []ConverseContentBlock) string {
+	var texts []string
+	for _, block := range blocks {
+		if block.Text != "" {
+			texts = append(texts, block.Text)
+		} else if block.JSON != nil {
+			data, _ := json.Marshal(block.JSON)
+			texts = append(texts, string(data))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// converseRequestToOpenAI maps a Converse request onto chat messages. The
+// model comes from the path since Converse bodies don't carry one.
+func converseRequestToOpenAI(path string, body []byte) (*OpenAIRequest, error) {
+	var req ConverseRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	out := &OpenAIRequest{
+		Model:       bedrockModelFromPath(path),
+		Temperature: req.InferenceConfig.Temperature,
+		MaxTokens:   req.InferenceConfig.MaxTokens,
+		Stream:      isBedrockStreamEndpoint(path),
+	}
+	if len(req.System) > 0 {
+		out.Messages = append(out.Messages, OpenAIMessage{Role: "system", Content: converseBlocksText(req.System)})
+	}
+	for _, m := range req.Messages {
+		msg, toolMessages := converseBlocksToOpenAI(m.Role, m.Content)
+		// A user turn carrying only tool results becomes just the tool messages
+		if len(toolMessages) == 0 || msg.Content != "" || len(msg.ToolCalls) > 0 {
+			out.Messages = append(out.Messages, msg)
+		}
+		out.Messages = append(out.Messages, toolMessages...)
+	}
+	return out, nil
+}
+
+// converseResponseToOpenAI maps a Converse response onto a single assistant choice
+func converseResponseToOpenAI(path string, resp *ConverseResponse) *OpenAIResponse {
+	msg := OpenAIMessage{Role: "assistant"}
+	if resp.Output.Message != nil {
+		msg, _ = converseBlocksToOpenAI("assistant", resp.Output.Message.Content)
+	}
+	finishReason := resp.StopReason
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	return &OpenAIResponse{
+		Model:   bedrockModelFromPath(path),
+		Choices: []OpenAIChoice{{Index: 0, Message: msg, FinishReason: finishReason}},
+		Usage: OpenAIUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+}
+
+// parseConverseResponse parses a Converse response, reassembling
+// converse-stream event streams. Returns nil for other bodies.
+func parseConverseResponse(data []byte) *ConverseResponse {
+	if isAWSEventStream(data) {
+		return reassembleConverseStream(data)
+	}
+	var resp ConverseResponse
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Output.Message == nil {
+		return nil
+	}
+	return &resp
+}
+
+// reassembleConverseStream rebuilds a Converse response from converse-stream
+// events. Text and reasoning deltas are merged per content block and tool
+// input JSON is concatenated until the block is complete.
+func reassembleConverseStream(data []byte) *ConverseResponse {
+	messages := parseEventStream(data)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	type blockState struct {
+		block     ConverseContentBlock
+		text      strings.Builder
+		reasoning strings.Builder
+		toolInput strings.Builder
+	}
+	blocks := make(map[int]*blockState)
+	getBlock := func(idx int) *blockState {
+		if blocks[idx] == nil {
+			blocks[idx] = &blockState{}
+		}
+		return blocks[idx]
+	}
+
+	resp := &ConverseResponse{}
+	role := "assistant"
+	for _, msg := range messages {
+		if msg.MessageType == "exception" {
+			continue
+		}
+		var event struct {
+			Role              string `json:"role"`
+			ContentBlockIndex int    `json:"contentBlockIndex"`
+			Start             struct {
+				ToolUse *ConverseToolUse `json:"toolUse"`
+			} `json:"start"`
+			Delta struct {
+				Text             *string `json:"text"`
+				ReasoningContent *struct {
+					Text      string `json:"text"`
+					Signature string `json:"signature"`
+				} `json:"reasoningContent"`
+				ToolUse *struct {
+					Input string `json:"input"`
+				} `json:"toolUse"`
+			} `json:"delta"`
+			StopReason string        `json:"stopReason"`
+			Usage      ConverseUsage `json:"usage"`
+		}
+		if json.Unmarshal(msg.Payload, &event) != nil {
+			continue
+		}
+
+		switch msg.EventType {
+		case "messageStart":
+			if event.Role != "" {
+				role = event.Role
+			}
+		case "contentBlockStart":
+			if event.Start.ToolUse != nil {
+				getBlock(event.ContentBlockIndex).block.ToolUse = event.Start.ToolUse
+			}
+		case "contentBlockDelta":
+			b := getBlock(event.ContentBlockIndex)
+			if event.Delta.Text != nil {
+				b.text.WriteString(*event.Delta.Text)
+			}
+			if event.Delta.ReasoningContent != nil {
+				b.reasoning.WriteString(event.Delta.ReasoningContent.Text)
+			}
+			if event.Delta.ToolUse != nil {
+				b.toolInput.WriteString(event.Delta.ToolUse.Input)
+			}
+		case "messageStop":
+			resp.StopReason = event.StopReason
+		case "metadata":
+			resp.Usage = event.Usage
+		}
+	}
+
+	indices := make([]int, 0, len(blocks))
+	for idx := range blocks {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	out := &ConverseMessage{Role: role}
+	for _, idx := range indices {
+		b := blocks[idx]
+		block := b.block
+		switch {
+		case block.ToolUse != nil:
+			var input any
+			if json.Unmarshal([]byte(b.toolInput.String()), &input) == nil {
+				block.ToolUse.Input = input
+			} else if b.toolInput.Len() > 0 {
+				block.ToolUse.Input = b.toolInput.String() // still streaming
+			}
+		case b.reasoning.Len() > 0:
+			block.ReasoningContent = &ConverseReasoning{}
+			block.ReasoningContent.ReasoningText.Text = b.reasoning.String()
+		default:
+			block.Text = b.text.String()
+		}
+		out.Content = append(out.Content, block)
+	}
+	resp.Output.Message = out
+	return resp
+}
+
+// applyBedrockTokenHeaders reads the token counts InvokeModel returns in
+// response headers, which works for models whose bodies report no usage
+func applyBedrockTokenHeaders(req *LLMRequest) {
+	header := http.Header(req.ResponseHeaders)
+	if n, err := strconv.Atoi(header.Get("X-Amzn-Bedrock-Input-Token-Count")); err == nil {
+		req.InputTokens = n
+	}
+	if n, err := strconv.Atoi(header.Get("X-Amzn-Bedrock-Output-Token-Count")); err == nil {
+		req.OutputTokens = n
+	}
+}
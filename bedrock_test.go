@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encodeEventStreamFrame builds one AWS event stream message with string headers
+func encodeEventStreamFrame(eventType, payload string) []byte {
+	var headers bytes.Buffer
+	for _, h := range [][2]string{{":event-type", eventType}, {":content-type", "application/json"}, {":message-type", "event"}} {
+		headers.WriteByte(byte(len(h[0])))
+		headers.WriteString(h[0])
+		headers.WriteByte(7)
+		binary.Write(&headers, binary.BigEndian, uint16(len(h[1])))
+		headers.WriteString(h[1])
+	}
+
+	total := 12 + headers.Len() + len(payload) + 4
+	var frame bytes.Buffer
+	binary.Write(&frame, binary.BigEndian, uint32(total))
+	binary.Write(&frame, binary.BigEndian, uint32(headers.Len()))
+	binary.Write(&frame, binary.BigEndian, crc32.ChecksumIEEE(frame.Bytes()))
+	frame.Write(headers.Bytes())
+	frame.WriteString(payload)
+	binary.Write(&frame, binary.BigEndian, crc32.ChecksumIEEE(frame.Bytes()))
+	return frame.Bytes()
+}
+
+func converseStreamFixture() []byte {
+	var stream bytes.Buffer
+	for _, e := range [][2]string{
+		{"messageStart", `{"role":"assistant"}`},
+		{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"reasoningContent":{"text":"Thinking"}}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"Hello "}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"world"}}`},
+		{"contentBlockStart", `{"contentBlockIndex":2,"start":{"toolUse":{"toolUseId":"t1","name":"lookup"}}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"{\"q\":"}}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"\"x\"}"}}}`},
+		{"messageStop", `{"stopReason":"tool_use"}`},
+		{"metadata", `{"usage":{"inputTokens":30,"outputTokens":12,"totalTokens":52,"cacheReadInputTokens":10},"metrics":{"latencyMs":400}}`},
+	} {
+		stream.Write(encodeEventStreamFrame(e[0], e[1]))
+	}
+	return stream.Bytes()
+}
+
+func TestBedrockPathParsing(t *testing.T) {
+	tests := []struct {
+		path, model, action string
+	}{
+		{"/model/anthropic.claude-3-5-sonnet-20241022-v2:0/invoke", "anthropic.claude-3-5-sonnet-20241022-v2:0", "invoke"},
+		{"/model/us.amazon.nova-pro-v1:0/converse-stream", "us.amazon.nova-pro-v1:0", "converse-stream"},
+		{"/model/arn:aws:bedrock:us-east-1:123:inference-profile/us.meta.llama3-1-8b-instruct-v1:0/converse", "arn:aws:bedrock:us-east-1:123:inference-profile/us.meta.llama3-1-8b-instruct-v1:0", "converse"},
+		{"/v1/models/gpt-4o", "", ""},
+	}
+	for _, tt := range tests {
+		if model, action := bedrockPathParts(tt.path); model != tt.model || action != tt.action {
+			t.Errorf("bedrockPathParts(%q) = %q, %q", tt.path, model, action)
+		}
+	}
+
+	if !isAnthropicEndpoint("/model/anthropic.claude-3-haiku-20240307-v1:0/invoke-with-response-stream") {
+		t.Error("Anthropic InvokeModel should use the Anthropic format")
+	}
+	if isAnthropicEndpoint("/model/anthropic.claude-3-haiku-20240307-v1:0/converse") {
+		t.Error("Converse uses its own format")
+	}
+
+	bases := map[string]string{
+		"us.anthropic.claude-3-5-sonnet-20241022-v2:0": "claude-3-5-sonnet-20241022",
+		"meta.llama3-1-70b-instruct-v1:0":              "llama3-1-70b-instruct",
+		"gpt-4o":                                       "",
+	}
+	for id, want := range bases {
+		if got := bedrockBaseModel(id); got != want {
+			t.Errorf("bedrockBaseModel(%q) = %q, want %q", id, got, want)
+		}
+	}
+
+	seedModelsDB(t, map[string]Provider{"amazon-bedrock": {ID: "amazon-bedrock"}}, map[string]ModelCost{"claude-3-5-sonnet-20241022": {Input: 3, Output: 15}})
+	if id, _ := FindProviderByURL("https://bedrock-runtime.us-east-1.amazonaws.com/model/x/converse"); id != "amazon-bedrock" {
+		t.Errorf("FindProviderByURL = %q, want amazon-bedrock", id)
+	}
+	if cost := GetModelCost("amazon-bedrock", "us.anthropic.claude-3-5-sonnet-20241022-v2:0"); cost == nil || cost.Input != 3 {
+		t.Errorf("GetModelCost for a Bedrock ID = %+v", cost)
+	}
+}
+
+func TestReassembleConverseStream(t *testing.T) {
+	data := converseStreamFixture()
+	if !isAWSEventStream(data) {
+		t.Fatal("fixture not detected as an event stream")
+	}
+
+	resp := decodeOpenAIResponse("/model/us.amazon.nova-pro-v1:0/converse-stream", data)
+	if resp == nil {
+		t.Fatal("decodeOpenAIResponse returned nil")
+	}
+	msg := resp.Choices[0].Message
+	if msg.Content != "Hello world" || msg.ReasoningContent != "Thinking" {
+		t.Errorf("message = %+v", msg)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"q":"x"}` || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("tool calls = %+v finish = %q", msg.ToolCalls, resp.Choices[0].FinishReason)
+	}
+
+	// A stream cut off mid-frame yields the complete frames so far
+	if partial := reassembleConverseStream(data[:len(data)-20]); partial == nil || partial.Usage.InputTokens != 0 {
+		t.Errorf("partial stream = %+v", partial)
+	}
+
+	req := &LLMRequest{Path: "/model/us.amazon.nova-pro-v1:0/converse-stream"}
+	extractTokenUsage(req, data)
+	if req.InputTokens != 40 || req.OutputTokens != 12 || req.CachedInputTokens != 10 {
+		t.Errorf("tokens = in %d out %d cached %d, want 40/12/10", req.InputTokens, req.OutputTokens, req.CachedInputTokens)
+	}
+}
+
+func TestConverseRequestMapping(t *testing.T) {
+	body := `{"system":[{"text":"Be brief"}],"inferenceConfig":{"maxTokens":256},"messages":[
+		{"role":"user","content":[{"text":"Weather?"}]},
+		{"role":"assistant","content":[{"toolUse":{"toolUseId":"t1","name":"weather","input":{"city":"SF"}}}]},
+		{"role":"user","content":[{"toolResult":{"toolUseId":"t1","content":[{"json":{"temp":60}}]}}]}]}`
+	req, err := decodeOpenAIRequest("/model/meta.llama3-1-8b-instruct-v1:0/converse", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "meta.llama3-1-8b-instruct-v1:0" || req.MaxTokens != 256 {
+		t.Errorf("model = %q max tokens = %d", req.Model, req.MaxTokens)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("got %d messages, want system, user, assistant, tool: %+v", len(req.Messages), req.Messages)
+	}
+	if tool := req.Messages[3]; tool.Role != "tool" || tool.ToolCallID != "t1" || tool.Content != `{"temp":60}` {
+		t.Errorf("tool message = %+v", tool)
+	}
+
+	// Non-Anthropic InvokeModel bodies have no usage; Bedrock sends it in headers
+	invoke := &LLMRequest{ResponseHeaders: map[string][]string{
+		"X-Amzn-Bedrock-Input-Token-Count":  {"17"},
+		"X-Amzn-Bedrock-Output-Token-Count": {"5"},
+	}}
+	extractTokenUsage(invoke, []byte(`{"generation":"hi","stop_reason":"stop"}`))
+	if invoke.InputTokens != 17 || invoke.OutputTokens != 5 {
+		t.Errorf("header tokens = %d/%d, want 17/5", invoke.InputTokens, invoke.OutputTokens)
+	}
+}
+
+func TestBedrockConverseStreamProxyIntegration(t *testing.T) {
+	resetTestState()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(converseStreamFixture())
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-bedrock", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	url := fmt.Sprintf("http://localhost:%d/model/us.amazon.nova-pro-v1:0/converse-stream", port)
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"messages":[{"role":"user","content":[{"text":"hi"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.Model != "us.amazon.nova-pro-v1:0" || !captured.IsStreaming {
+		t.Errorf("captured model = %q streaming = %v", captured.Model, captured.IsStreaming)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for captured.OutputTokens == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if captured.InputTokens != 40 || captured.OutputTokens != 12 {
+		t.Errorf("tokens = %d/%d, want 40/12", captured.InputTokens, captured.OutputTokens)
+	}
+	if snippet := extractStreamingResponsePreviewSnippet(captured.ResponseBody); snippet != "Hello world" {
+		t.Errorf("preview = %q", snippet)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	budgetStateFile    = "budget.json"
+	budgetDirEnv       = "LLMPROXY_BUDGET_DIR"
+	budgetPersistDelay = time.Second // Spend recorded within this long is written together
+)
+
+// BudgetConfig is a spend cap in USD. Model and proxy narrow the scope;
+// window turns it into a rolling cap (e.g., "24h", "7d").
+type BudgetConfig struct {
+	Name   string  `toml:"name"`   // Display name (defaults to a description of the scope)
+	Model  string  `toml:"model"`  // Glob matched against the request model (empty = all)
+	Proxy  string  `toml:"proxy"`  // Glob matched against the proxy name (empty = all)
+	Window string  `toml:"window"` // Rolling window (empty = all recorded spend)
+	Soft   float64 `toml:"soft"`   // Warn in the TUI once spend reaches this amount
+	Hard   float64 `toml:"hard"`   // Reject new requests once spend reaches this amount
+}
+
+// budgetRule is the parsed form of BudgetConfig
+type budgetRule struct {
+	cfg    BudgetConfig
+	window time.Duration
+}
+
+// budgetEntry is one recorded spend
+type budgetEntry struct {
+	Time  time.Time `json:"time"`
+	Model string    `json:"model"`
+	Proxy string    `json:"proxy"`
+	Cost  float64   `json:"cost"`
+}
+
+// budgetState is the persisted form of the tracker
+type budgetState struct {
+	UpdatedAt time.Time     `json:"updated_at"`
+	Entries   []budgetEntry `json:"entries"`
+	Totals    []budgetEntry `json:"totals,omitempty"`
+}
+
+// BudgetStatus reports spend against one budget rule
+type BudgetStatus struct {
+	Name         string
+	Spent        float64
+	Soft         float64
+	Hard         float64
+	SoftExceeded bool
+	HardExceeded bool
+}
+
+// BudgetTracker tracks spend against the configured budget rules
+type BudgetTracker struct {
+	mu       sync.Mutex
+	rules    []*budgetRule
+	entries  []budgetEntry
+	totals   []budgetEntry           // Spend older than every window, one per model and proxy
+	recorded map[*LLMRequest]float64 // Cost already counted per request, until it's final
+	path     string                  // Persistence file, empty = in-memory only
+	pending  bool                    // A write of the state is scheduled
+
+	persistMu sync.Mutex // Serializes writes to path
+}
+
+// activeBudget is the budget tracker for the running proxy, nil when no budgets are set
+var activeBudget *BudgetTracker
+
+// parseBudgetRules validates budget configs
+func parseBudgetRules(configs []BudgetConfig) ([]*budgetRule, error) {
+	var rules []*budgetRule
+	for i, cfg := range configs {
+		rule := &budgetRule{cfg: cfg}
+		if cfg.Window != "" {
+			d, err := ParseTTL(cfg.Window)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("budget #%d: invalid window %q", i+1, cfg.Window)
+			}
+			rule.window = d
+		}
+		if cfg.Soft < 0 || cfg.Hard < 0 {
+			return nil, fmt.Errorf("budget #%d: caps cannot be negative", i+1)
+		}
+		if cfg.Soft == 0 && cfg.Hard == 0 {
+			return nil, fmt.Errorf("budget #%d: soft or hard cap is required", i+1)
+		}
+		if rule.cfg.Name == "" {
+			rule.cfg.Name = rule.describe()
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// describe builds a default name from the rule's scope, e.g. "model gpt-4*/24h"
+func (r *budgetRule) describe() string {
+	var parts []string
+	if r.cfg.Model != "" {
+		parts = append(parts, "model "+r.cfg.Model)
+	}
+	if r.cfg.Proxy != "" {
+		parts = append(parts, "proxy "+r.cfg.Proxy)
+	}
+	name := "total"
+	if len(parts) > 0 {
+		name = strings.Join(parts, ", ")
+	}
+	if r.cfg.Window != "" {
+		name += "/" + r.cfg.Window
+	}
+	return name
+}
+
+// applies reports whether the rule covers a request for the model and proxy
+func (r *budgetRule) applies(model, proxy string) bool {
+	if r.cfg.Model != "" && !globMatchFold(r.cfg.Model, model) {
+		return false
+	}
+	if r.cfg.Proxy != "" && !globMatchFold(r.cfg.Proxy, proxy) {
+		return false
+	}
+	return true
+}
+
+// NewBudgetTracker creates a tracker, loading persisted state from path if set
+func NewBudgetTracker(configs []BudgetConfig, path string) (*BudgetTracker, error) {
+	rules, err := parseBudgetRules(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BudgetTracker{
+		rules:    rules,
+		recorded: make(map[*LLMRequest]float64),
+		path:     path,
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var state budgetState
+			if err := json.Unmarshal(data, &state); err != nil {
+				return nil, fmt.Errorf("failed to parse budget state %s: %w", path, err)
+			}
+			b.entries = state.Entries
+			b.totals = state.Totals
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read budget state: %w", err)
+		}
+	}
+
+	return b, nil
+}
+
+// InitBudget sets up the global budget tracker, with its state persisted in
+// the budget directory
+func InitBudget(configs []BudgetConfig) error {
+	if len(configs) == 0 {
+		activeBudget = nil
+		return nil
+	}
+
+	tracker, err := NewBudgetTracker(configs, filepath.Join(budgetDirectory(), budgetStateFile))
+	if err != nil {
+		return err
+	}
+	activeBudget = tracker
+	return nil
+}
+
+// budgetDirectory returns where budget state is stored. It's kept out of the
+// cache dir, which belongs to Badger, so spend survives moving the cache or
+// switching cache modes.
+func budgetDirectory() string {
+	if dir := strings.TrimSpace(os.Getenv(budgetDirEnv)); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "llmproxy-go")
+	}
+	return filepath.Join(home, ".llmproxy-go")
+}
+
+// spentLocked sums recorded spend covered by the rule
+func (b *BudgetTracker) spentLocked(rule *budgetRule, now time.Time) float64 {
+	var total float64
+	if rule.window == 0 {
+		for _, t := range b.totals {
+			if rule.applies(t.Model, t.Proxy) {
+				total += t.Cost
+			}
+		}
+	}
+	for _, e := range b.entries {
+		if rule.window > 0 && now.Sub(e.Time) > rule.window {
+			continue
+		}
+		if rule.applies(e.Model, e.Proxy) {
+			total += e.Cost
+		}
+	}
+	return total
+}
+
+// Check returns an error describing the exceeded hard cap if a new request
+// for the model and proxy must be rejected
+func (b *BudgetTracker) Check(model, proxy string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for _, rule := range b.rules {
+		if rule.cfg.Hard <= 0 || !rule.applies(model, proxy) {
+			continue
+		}
+		if spent := b.spentLocked(rule, now); spent >= rule.cfg.Hard {
+			return fmt.Errorf("budget %q exceeded: spent %s of %s hard cap", rule.cfg.Name, formatCost(spent), formatCost(rule.cfg.Hard))
+		}
+	}
+	return nil
+}
+
+// Record adds the request's cost to the tracked spend. Safe to call more
+// than once per request; only the change in cost is counted. Call Finish
+// once the cost can't change anymore.
+func (b *BudgetTracker) Record(req *LLMRequest) {
+	if b == nil || req.CachedResponse {
+		return
+	}
+
+	b.mu.Lock()
+	delta := req.Cost - b.recorded[req]
+	if delta == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.recorded[req] = req.Cost
+	b.entries = append(b.entries, budgetEntry{
+		Time:  time.Now(),
+		Model: req.Model,
+		Proxy: req.ProxyName,
+		Cost:  delta,
+	})
+	b.pruneLocked()
+	b.schedulePersistLocked()
+	b.mu.Unlock()
+}
+
+// Finish forgets a request whose cost is final
+func (b *BudgetTracker) Finish(req *LLMRequest) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	delete(b.recorded, req)
+	b.mu.Unlock()
+}
+
+// pruneLocked drops entries older than every rule's window. Rules without a
+// window still count them, so then they're folded into the totals instead.
+func (b *BudgetTracker) pruneLocked() {
+	var maxWindow time.Duration
+	unbounded := false
+	for _, rule := range b.rules {
+		if rule.window == 0 {
+			unbounded = true
+		}
+		if rule.window > maxWindow {
+			maxWindow = rule.window
+		}
+	}
+	cutoff := time.Now().Add(-maxWindow)
+	kept := b.entries[:0]
+	for _, e := range b.entries {
+		switch {
+		case e.Time.After(cutoff):
+			kept = append(kept, e)
+		case unbounded:
+			b.foldLocked(e)
+		}
+	}
+	b.entries = kept
+}
+
+// foldLocked adds an entry to the running total for its model and proxy
+func (b *BudgetTracker) foldLocked(e budgetEntry) {
+	for i := range b.totals {
+		t := &b.totals[i]
+		if t.Model == e.Model && t.Proxy == e.Proxy {
+			t.Cost += e.Cost
+			if e.Time.After(t.Time) {
+				t.Time = e.Time
+			}
+			return
+		}
+	}
+	b.totals = append(b.totals, e)
+}
+
+// schedulePersistLocked writes the state shortly, so a burst of completed
+// requests costs one write instead of one each
+func (b *BudgetTracker) schedulePersistLocked() {
+	if b.path == "" || b.pending {
+		return
+	}
+	b.pending = true
+	// Persistence is best-effort; enforcement still works in memory
+	time.AfterFunc(budgetPersistDelay, func() { _ = b.Flush() })
+}
+
+// Flush writes the state atomically via a temp file
+func (b *BudgetTracker) Flush() error {
+	if b == nil || b.path == "" {
+		return nil
+	}
+	b.persistMu.Lock()
+	defer b.persistMu.Unlock()
+
+	b.mu.Lock()
+	b.pending = false
+	data, err := json.MarshalIndent(budgetState{UpdatedAt: time.Now(), Entries: b.entries, Totals: b.totals}, "", "  ")
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	tempPath := b.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, b.path)
+}
+
+// Status reports spend against every rule
+func (b *BudgetTracker) Status() []BudgetStatus {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]BudgetStatus, 0, len(b.rules))
+	for _, rule := range b.rules {
+		spent := b.spentLocked(rule, now)
+		statuses = append(statuses, BudgetStatus{
+			Name:         rule.cfg.Name,
+			Spent:        spent,
+			Soft:         rule.cfg.Soft,
+			Hard:         rule.cfg.Hard,
+			SoftExceeded: rule.cfg.Soft > 0 && spent >= rule.cfg.Soft,
+			HardExceeded: rule.cfg.Hard > 0 && spent >= rule.cfg.Hard,
+		})
+	}
+	return statuses
+}
+
+// recordBudgetSpend feeds a finished request's cost into the active budget
+// tracker
+func recordBudgetSpend(req *LLMRequest) {
+	activeBudget.Record(req)
+	activeBudget.Finish(req)
+}
+
+// formatBudgetBanner summarizes exceeded budgets for the TUI, or "" if none
+func formatBudgetBanner(statuses []BudgetStatus) (banner string, hard bool) {
+	var parts []string
+	for _, s := range statuses {
+		switch {
+		case s.HardExceeded:
+			hard = true
+			parts = append(parts, fmt.Sprintf("%s %s/%s (hard cap, rejecting)", s.Name, formatCost(s.Spent), formatCost(s.Hard)))
+		case s.SoftExceeded:
+			part := fmt.Sprintf("%s %s/%s", s.Name, formatCost(s.Spent), formatCost(s.Soft))
+			if s.Hard > 0 {
+				part += fmt.Sprintf(" (hard %s)", formatCost(s.Hard))
+			}
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return "Budget: " + strings.Join(parts, " • "), hard
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetTrackerCapsAndScopes(t *testing.T) {
+	tracker, err := NewBudgetTracker([]BudgetConfig{
+		{Name: "total", Soft: 1.0, Hard: 2.0},
+		{Model: "gpt-4*", Hard: 0.5},
+	}, "")
+	if err != nil {
+		t.Fatalf("NewBudgetTracker error: %v", err)
+	}
+
+	tracker.Record(&LLMRequest{Model: "gpt-4o", ProxyName: "openai", Cost: 0.6})
+	if err := tracker.Check("gpt-4o", "openai"); err == nil {
+		t.Error("gpt-4o should be blocked by the model budget")
+	}
+	if err := tracker.Check("claude-sonnet-4", "anthropic"); err != nil {
+		t.Errorf("claude should not be blocked: %v", err)
+	}
+
+	req := &LLMRequest{Model: "claude-sonnet-4", Cost: 0.3}
+	tracker.Record(req)
+	req.Cost = 0.5 // Recording again only counts the difference
+	tracker.Record(req)
+	tracker.Record(&LLMRequest{Model: "claude-sonnet-4", Cost: 5, CachedResponse: true})
+
+	statuses := tracker.Status()
+	if math.Abs(statuses[0].Spent-1.1) > 1e-9 {
+		t.Errorf("total spent = %v, want 1.1", statuses[0].Spent)
+	}
+	if !statuses[0].SoftExceeded || statuses[0].HardExceeded {
+		t.Errorf("total status = %+v, want soft exceeded only", statuses[0])
+	}
+	if statuses[1].Name != "model gpt-4*" {
+		t.Errorf("default name = %q, want %q", statuses[1].Name, "model gpt-4*")
+	}
+
+	banner, hard := formatBudgetBanner(statuses)
+	if !hard || !strings.Contains(banner, "total") {
+		t.Errorf("banner = %q hard=%v", banner, hard)
+	}
+}
+
+func TestBudgetTrackerWindowAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), budgetStateFile)
+	configs := []BudgetConfig{{Proxy: "openai", Window: "1h", Hard: 1.0}}
+
+	tracker, err := NewBudgetTracker(configs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Record(&LLMRequest{Model: "gpt-4o", ProxyName: "openai", Cost: 1.5})
+	// Simulate spend from before the window
+	tracker.entries = append(tracker.entries, budgetEntry{Time: time.Now().Add(-2 * time.Hour), Proxy: "openai", Cost: 10})
+
+	if err := tracker.Check("gpt-4o", "openai"); err == nil {
+		t.Error("expected hard cap to block openai")
+	}
+	if err := tracker.Check("gpt-4o", "other"); err != nil {
+		t.Errorf("other proxy should not be blocked: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("state was written before the persist delay")
+	}
+	if err := tracker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewBudgetTracker(configs, path)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if got := reloaded.Status()[0].Spent; got != 1.5 {
+		t.Errorf("reloaded spent = %v, want 1.5", got)
+	}
+}
+
+func TestBudgetTrackerFoldsOldSpend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), budgetStateFile)
+	configs := []BudgetConfig{{Name: "all time", Hard: 100}, {Name: "daily", Window: "24h", Hard: 10}}
+	tracker, err := NewBudgetTracker(configs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for i := 0; i < 50; i++ {
+		tracker.entries = append(tracker.entries, budgetEntry{Time: old, Model: "gpt-4o", Proxy: "openai", Cost: 1})
+	}
+	tracker.entries = append(tracker.entries, budgetEntry{Time: old, Model: "claude-sonnet-4", Proxy: "anthropic", Cost: 2})
+
+	req := &LLMRequest{Model: "gpt-4o", ProxyName: "openai", Cost: 0.5}
+	tracker.Record(req)
+	tracker.Finish(req)
+	if len(tracker.entries) != 1 || len(tracker.totals) != 2 || len(tracker.recorded) != 0 {
+		t.Fatalf("entries = %d, totals = %+v, recorded = %d", len(tracker.entries), tracker.totals, len(tracker.recorded))
+	}
+	if err := tracker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewBudgetTracker(configs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := reloaded.Status()
+	if math.Abs(statuses[0].Spent-52.5) > 1e-9 || statuses[1].Spent != 0.5 {
+		t.Errorf("all time = %v, daily = %v; want 52.5 and 0.5", statuses[0].Spent, statuses[1].Spent)
+	}
+}
+
+func TestBudgetStateWrittenOnceAfterDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), budgetStateFile)
+	tracker, err := NewBudgetTracker([]BudgetConfig{{Hard: 10}}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		req := &LLMRequest{Model: "gpt-4o", Cost: 1}
+		tracker.Record(req)
+		tracker.Finish(req)
+	}
+
+	deadline := time.Now().Add(budgetPersistDelay + time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	reloaded, err := NewBudgetTracker([]BudgetConfig{{Hard: 10}}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Status()[0].Spent; got != 3 {
+		t.Errorf("reloaded spent = %v, want 3", got)
+	}
+}
+
+func TestParseBudgetRulesRejectsInvalid(t *testing.T) {
+	if _, err := parseBudgetRules([]BudgetConfig{{Window: "1h"}}); err == nil {
+		t.Error("expected error for budget without caps")
+	}
+	if _, err := parseBudgetRules([]BudgetConfig{{Hard: 1, Window: "soon"}}); err == nil {
+		t.Error("expected error for invalid window")
+	}
+}
+
+func TestBudgetHardCapRejectsRequests(t *testing.T) {
+	resetTestState()
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("upstream should not be called when the budget is exhausted")
+	}))
+	defer mockServer.Close()
+
+	tracker, err := NewBudgetTracker([]BudgetConfig{{Hard: 0.01}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Record(&LLMRequest{Model: "gpt-4o", Cost: 0.02})
+	activeBudget = tracker
+	defer func() { activeBudget = nil }()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("budget", fmt.Sprintf(":%d", port), mockServer.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Errorf("status = %d, want 402", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "insufficient_quota") {
+		t.Errorf("body = %s, want OpenAI-shaped quota error", body)
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.BudgetExceeded == "" {
+		t.Error("BudgetExceeded should be set on the rejected request")
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	caDirEnv      = "LLMPROXY_CA_DIR"
+	caCertFile    = "llmproxy-ca.pem"
+	caKeyFile     = "llmproxy-ca-key.pem"
+	caValidFor    = 10 * 365 * 24 * time.Hour
+	leafValidFor  = 30 * 24 * time.Hour
+	leafClockSkew = time.Hour // Backdate certificates to tolerate clock drift
+)
+
+// CertAuthority is the local CA used to mint leaf certificates for intercepted hosts
+type CertAuthority struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certPath string
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate // Host -> minted leaf certificate
+}
+
+// caDirectory returns where the local CA is stored
+func caDirectory() string {
+	if dir := strings.TrimSpace(os.Getenv(caDirEnv)); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "llmproxy-go", "ca")
+	}
+	return filepath.Join(home, ".llmproxy-go", "ca")
+}
+
+// LoadOrCreateCA loads the CA from dir, generating and persisting a new one if missing
+func LoadOrCreateCA(dir string) (*CertAuthority, error) {
+	certPath := filepath.Join(dir, caCertFile)
+	keyPath := filepath.Join(dir, caKeyFile)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		return parseCA(certPEM, keyPEM, certPath)
+	}
+	if (certErr != nil && !os.IsNotExist(certErr)) || (keyErr != nil && !os.IsNotExist(keyErr)) {
+		return nil, fmt.Errorf("failed to read CA from %s: %v %v", dir, certErr, keyErr)
+	}
+
+	certPEM, keyPEM, err := generateCA()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create CA directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write CA key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	return parseCA(certPEM, keyPEM, certPath)
+}
+
+// generateCA creates a self-signed CA certificate and key in PEM form
+func generateCA() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject: pkix.Name{
+			CommonName:   "llmproxy-go local CA",
+			Organization: []string{"llmproxy-go"},
+			// Make the CA recognizable when several machines share a trust store
+			OrganizationalUnit: []string{hostname},
+		},
+		NotBefore:             now.Add(-leafClockSkew),
+		NotAfter:              now.Add(caValidFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// parseCA decodes a PEM certificate and EC key pair
+func parseCA(certPEM, keyPEM []byte, certPath string) (*CertAuthority, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("invalid CA certificate in %s", certPath)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("invalid CA key next to %s", certPath)
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+
+	return &CertAuthority{
+		cert:     cert,
+		key:      key,
+		certPath: certPath,
+		leaves:   make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// CertPath returns the path of the CA certificate clients need to trust
+func (ca *CertAuthority) CertPath() string {
+	return ca.certPath
+}
+
+// LeafCertificate returns a certificate for host signed by the CA, minting
+// and caching one on first use
+func (ca *CertAuthority) LeafCertificate(host string) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	if leaf, ok := ca.leaves[host]; ok && time.Now().Before(leaf.Leaf.NotAfter) {
+		return leaf, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-leafClockSkew),
+		NotAfter:     now.Add(leafValidFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint certificate for %s: %w", host, err)
+	}
+	leafCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := &tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leafCert,
+	}
+	ca.leaves[host] = leaf
+	return leaf, nil
+}
+
+// randomSerial returns a random 128-bit certificate serial number
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
+
+// caTrustInstructions explains how to trust the CA for common clients
+func caTrustInstructions(certPath string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CA certificate: %s\n\n", certPath)
+	sb.WriteString("Point clients at the forward proxy:\n")
+	sb.WriteString("  export HTTPS_PROXY=http://localhost:<port>\n\n")
+	sb.WriteString("Then trust the CA in the client:\n")
+	fmt.Fprintf(&sb, "  Node.js:         export NODE_EXTRA_CA_CERTS=%s\n", certPath)
+	fmt.Fprintf(&sb, "  Python requests: export REQUESTS_CA_BUNDLE=%s\n", certPath)
+	fmt.Fprintf(&sb, "  Python httpx:    export SSL_CERT_FILE=%s\n", certPath)
+	fmt.Fprintf(&sb, "  curl:            curl --cacert %s ...\n\n", certPath)
+	sb.WriteString("Or trust it system-wide:\n")
+	fmt.Fprintf(&sb, "  macOS:           sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain %s\n", certPath)
+	fmt.Fprintf(&sb, "  Debian/Ubuntu:   sudo cp %s /usr/local/share/ca-certificates/llmproxy-ca.crt && sudo update-ca-certificates\n", certPath)
+	fmt.Fprintf(&sb, "  Fedora/RHEL:     sudo cp %s /etc/pki/ca-trust/source/anchors/ && sudo update-ca-trust\n\n", certPath)
+	sb.WriteString("Only hosts known to serve LLM APIs (plus any intercept globs in the config)\n")
+	sb.WriteString("are decrypted; all other HTTPS traffic is tunneled untouched.\n")
+	return sb.String()
+}
+
+// RunCACommand prints the CA certificate path and trust instructions,
+// creating the CA on first use
+func RunCACommand() error {
+	ca, err := LoadOrCreateCA(caDirectory())
+	if err != nil {
+		return err
+	}
+	fmt.Print(caTrustInstructions(ca.CertPath()))
+	return nil
+}
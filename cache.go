@@ -1,10 +1,12 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
+	"container/list"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strconv"
 	"sync"
 	"time"
 
@@ -18,8 +20,42 @@ const (
 	CacheModeNone   CacheMode = "none"
 	CacheModeMemory CacheMode = "memory"
 	CacheModeGlobal CacheMode = "global"
+
+	// Modes for fixture-based test runs, all backed by the global cache
+	CacheModeRecord        CacheMode = "record"         // Always call upstream and overwrite entries
+	CacheModeReplayOnly    CacheMode = "replay-only"    // Never call upstream; misses get an error
+	CacheModeRecordMissing CacheMode = "record-missing" // Serve hits, record misses, report both
 )
 
+// parseCacheMode validates a cache mode from flags or config
+func parseCacheMode(raw string) (CacheMode, error) {
+	switch mode := CacheMode(raw); mode {
+	case "":
+		return CacheModeNone, nil
+	case CacheModeNone, CacheModeMemory, CacheModeGlobal, CacheModeRecord, CacheModeReplayOnly, CacheModeRecordMissing:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown cache mode %q (want none, memory, global, record, replay-only or record-missing)", raw)
+}
+
+// entryTTL is how long entries written in the mode live. Fixtures are
+// recorded once and replayed from then on, so they never expire.
+func (m CacheMode) entryTTL(configured time.Duration) time.Duration {
+	if m.storage() == CacheModeGlobal && m != CacheModeGlobal {
+		return 0
+	}
+	return configured
+}
+
+// storage returns the backend a mode keeps its entries in
+func (m CacheMode) storage() CacheMode {
+	switch m {
+	case CacheModeRecord, CacheModeReplayOnly, CacheModeRecordMissing:
+		return CacheModeGlobal
+	}
+	return m
+}
+
 // CacheEntry represents a cached response
 type CacheEntry struct {
 	ResponseBody    []byte              `json:"response_body"`
@@ -27,6 +63,16 @@ type CacheEntry struct {
 	StatusCode      int                 `json:"status_code"`
 	Duration        time.Duration       `json:"duration"` // Original response duration for latency simulation
 	CreatedAt       time.Time           `json:"created_at"`
+
+	// Streamed responses are replayed chunk by chunk with their original timing
+	Streaming bool          `json:"streaming,omitempty"`
+	TTFT      time.Duration `json:"ttft,omitempty"`
+	Chunks    []CacheChunk  `json:"chunks,omitempty"`
+
+	// Shown by the cache command
+	Model       string `json:"model,omitempty"`
+	RequestBody []byte `json:"request_body,omitempty"`
+	Hits        int    `json:"hits,omitempty"`
 }
 
 // Cache is the interface for request/response caching
@@ -35,64 +81,18 @@ type Cache interface {
 	Get(key string) (*CacheEntry, bool)
 	// Set stores a response in the cache
 	Set(key string, entry *CacheEntry) error
+	// Delete removes a cached response
+	Delete(key string) error
+	// Range calls fn for every live entry until it returns false
+	Range(fn func(key string, entry *CacheEntry) bool) error
+	// RecordHit counts a lookup answered by the entry for key
+	RecordHit(key string)
+	// Usage reports the cache's size against its limits
+	Usage() CacheUsage
 	// Close cleans up cache resources
 	Close() error
 }
 
-// GenerateCacheKey creates a cache key from the request
-// It hashes the request body to create a unique identifier
-func GenerateCacheKey(path string, requestBody []byte) string {
-	// Use Anthropic-specific normalization for /v1/messages endpoints
-	if isAnthropicEndpoint(path) {
-		var req AnthropicRequest
-		if err := json.Unmarshal(requestBody, &req); err != nil {
-			hash := sha256.Sum256(requestBody)
-			return path + ":" + hex.EncodeToString(hash[:])
-		}
-		normalized := struct {
-			Model     string             `json:"model"`
-			Messages  []AnthropicMessage `json:"messages"`
-			System    interface{}        `json:"system,omitempty"`
-			MaxTokens int                `json:"max_tokens"`
-			Stream    bool               `json:"stream"`
-		}{
-			Model:     req.Model,
-			Messages:  req.Messages,
-			System:    req.System,
-			MaxTokens: req.MaxTokens,
-			Stream:    req.Stream,
-		}
-		data, _ := json.Marshal(normalized)
-		hash := sha256.Sum256(data)
-		return path + ":" + hex.EncodeToString(hash[:])
-	}
-
-	// Default: OpenAI format normalization
-	var req OpenAIRequest
-	if err := json.Unmarshal(requestBody, &req); err != nil {
-		hash := sha256.Sum256(requestBody)
-		return path + ":" + hex.EncodeToString(hash[:])
-	}
-
-	normalized := struct {
-		Model       string          `json:"model"`
-		Messages    []OpenAIMessage `json:"messages"`
-		Temperature float64         `json:"temperature"`
-		MaxTokens   int             `json:"max_tokens"`
-		Stream      bool            `json:"stream"`
-	}{
-		Model:       req.Model,
-		Messages:    req.Messages,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		Stream:      req.Stream,
-	}
-
-	data, _ := json.Marshal(normalized)
-	hash := sha256.Sum256(data)
-	return path + ":" + hex.EncodeToString(hash[:])
-}
-
 // --- NoopCache: Does nothing, used when caching is disabled ---
 
 type NoopCache struct{}
@@ -109,25 +109,53 @@ func (c *NoopCache) Set(key string, entry *CacheEntry) error {
 	return nil
 }
 
+func (c *NoopCache) Delete(key string) error {
+	return nil
+}
+
+func (c *NoopCache) Range(fn func(key string, entry *CacheEntry) bool) error {
+	return nil
+}
+
+func (c *NoopCache) RecordHit(key string) {}
+
+func (c *NoopCache) Usage() CacheUsage {
+	return CacheUsage{}
+}
+
 func (c *NoopCache) Close() error {
 	return nil
 }
 
-// --- MemoryCache: In-memory cache with TTL ---
+// --- MemoryCache: In-memory cache with TTL and LRU eviction ---
 
 type memoryCacheEntry struct {
+	key       string
 	entry     *CacheEntry
-	expiresAt time.Time
+	expiresAt time.Time // Zero when the cache has no TTL
+	size      int64
+}
+
+func (e *memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
 }
 
 type MemoryCache struct {
-	data     sync.Map
-	ttl      time.Duration
-	stopChan chan struct{}
+	mu        sync.Mutex
+	items     map[string]*list.Element // Values are *memoryCacheEntry
+	lru       *list.List               // Most recently used first
+	bytes     int64
+	evictions int64
+	limits    CacheLimits
+	ttl       time.Duration // 0 = entries never expire
+	stopChan  chan struct{}
 }
 
-func NewMemoryCache(ttl time.Duration) *MemoryCache {
+func NewMemoryCache(ttl time.Duration, limits CacheLimits) *MemoryCache {
 	c := &MemoryCache{
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		limits:   limits,
 		ttl:      ttl,
 		stopChan: make(chan struct{}),
 	}
@@ -139,34 +167,128 @@ func NewMemoryCache(ttl time.Duration) *MemoryCache {
 }
 
 func (c *MemoryCache) Get(key string) (*CacheEntry, bool) {
-	val, ok := c.data.Load(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
 	if !ok {
 		return nil, false
 	}
 
-	entry := val.(*memoryCacheEntry)
-	if time.Now().After(entry.expiresAt) {
+	item := elem.Value.(*memoryCacheEntry)
+	if item.expired(time.Now()) {
 		// Entry expired, delete and return miss
-		c.data.Delete(key)
+		c.removeLocked(elem)
 		return nil, false
 	}
 
-	return entry.entry, true
+	c.lru.MoveToFront(elem)
+	return item.entry, true
 }
 
 func (c *MemoryCache) Set(key string, entry *CacheEntry) error {
-	c.data.Store(key, &memoryCacheEntry{
-		entry:     entry,
-		expiresAt: time.Now().Add(c.ttl),
-	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeLocked(elem)
+	}
+	item := &memoryCacheEntry{
+		key:   key,
+		entry: entry,
+		size:  int64(entry.size()),
+	}
+	if c.ttl > 0 {
+		item.expiresAt = time.Now().Add(c.ttl)
+	}
+	c.items[key] = c.lru.PushFront(item)
+	c.bytes += item.size
+	c.evictLocked()
+	return nil
+}
+
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeLocked(elem)
+	}
+	return nil
+}
+
+// Range visits a snapshot of the entries, most recently used first, so fn
+// may change the cache
+func (c *MemoryCache) Range(fn func(key string, entry *CacheEntry) bool) error {
+	type pair struct {
+		key   string
+		entry *CacheEntry
+	}
+	c.mu.Lock()
+	now := time.Now()
+	live := make([]pair, 0, len(c.items))
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		if item := elem.Value.(*memoryCacheEntry); !item.expired(now) {
+			live = append(live, pair{item.key, item.entry})
+		}
+	}
+	c.mu.Unlock()
+
+	for _, p := range live {
+		if !fn(p.key, p.entry) {
+			break
+		}
+	}
 	return nil
 }
 
+// RecordHit swaps in a copy with the count bumped, so readers holding the
+// old entry never see it change
+func (c *MemoryCache) RecordHit(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		item := elem.Value.(*memoryCacheEntry)
+		updated := *item.entry
+		updated.Hits++
+		item.entry = &updated
+	}
+}
+
+// SetLimits changes the size limits, evicting right away if the cache is
+// now over them
+func (c *MemoryCache) SetLimits(limits CacheLimits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits = limits
+	c.evictLocked()
+}
+
+func (c *MemoryCache) Usage() CacheUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheUsage{Entries: len(c.items), Bytes: c.bytes, Evictions: c.evictions, Limits: c.limits}
+}
+
 func (c *MemoryCache) Close() error {
 	close(c.stopChan)
 	return nil
 }
 
+// evictLocked drops least recently used entries until the cache is within
+// its limits
+func (c *MemoryCache) evictLocked() {
+	for c.lru.Len() > 0 && c.limits.exceeded(len(c.items), c.bytes) {
+		c.removeLocked(c.lru.Back())
+		c.evictions++
+	}
+}
+
+func (c *MemoryCache) removeLocked(elem *list.Element) {
+	item := c.lru.Remove(elem).(*memoryCacheEntry)
+	delete(c.items, item.key)
+	c.bytes -= item.size
+}
+
 // cleanup periodically removes expired entries
 func (c *MemoryCache) cleanup() {
 	ticker := time.NewTicker(1 * time.Minute)
@@ -176,13 +298,15 @@ func (c *MemoryCache) cleanup() {
 		select {
 		case <-ticker.C:
 			now := time.Now()
-			c.data.Range(func(key, value interface{}) bool {
-				entry := value.(*memoryCacheEntry)
-				if now.After(entry.expiresAt) {
-					c.data.Delete(key)
+			c.mu.Lock()
+			for elem := c.lru.Front(); elem != nil; {
+				next := elem.Next()
+				if elem.Value.(*memoryCacheEntry).expired(now) {
+					c.removeLocked(elem)
 				}
-				return true
-			})
+				elem = next
+			}
+			c.mu.Unlock()
 		case <-c.stopChan:
 			return
 		}
@@ -193,10 +317,22 @@ func (c *MemoryCache) cleanup() {
 
 type BadgerCache struct {
 	db  *badger.DB
-	ttl time.Duration
+	ttl time.Duration // 0 = entries never expire
+
+	mu        sync.Mutex
+	limits    CacheLimits
+	entries   int   // Counted by the last prune, plus writes since
+	bytes     int64 // Same, from badger's size estimates
+	evictions int64 // Kept in the database across runs
+
+	pruneMu   sync.Mutex // One prune pass at a time
+	pruneCh   chan struct{}
+	stopChan  chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewBadgerCache(path string, ttl time.Duration) (*BadgerCache, error) {
+func NewBadgerCache(path string, ttl time.Duration, limits CacheLimits) (*BadgerCache, error) {
 	opts := badger.DefaultOptions(path)
 	opts.Logger = nil // Disable badger's default logging
 	opts.SyncWrites = false // Async writes for performance
@@ -208,11 +344,19 @@ func NewBadgerCache(path string, ttl time.Duration) (*BadgerCache, error) {
 	}
 
 	c := &BadgerCache{
-		db:  db,
-		ttl: ttl,
+		db:       db,
+		ttl:      ttl,
+		limits:   limits,
+		pruneCh:  make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 
-	// Start background garbage collection
+	// Count what's there and apply limits lowered since the last run
+	c.loadEvictions()
+	c.prune()
+
+	// Start background pruning and garbage collection
 	go c.runGC()
 
 	return c, nil
@@ -227,9 +371,13 @@ func (c *BadgerCache) Get(key string) (*CacheEntry, bool) {
 			return err
 		}
 
-		return item.Value(func(val []byte) error {
+		if err := item.Value(func(val []byte) error {
 			return json.Unmarshal(val, &entry)
-		})
+		}); err != nil {
+			return err
+		}
+		entry.Hits += badgerHits(txn, key)
+		return nil
 	})
 
 	if err != nil {
@@ -245,28 +393,135 @@ func (c *BadgerCache) Set(key string, entry *CacheEntry) error {
 		return err
 	}
 
+	err = c.db.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry([]byte(key), data)
+		if c.ttl > 0 {
+			e = e.WithTTL(c.ttl)
+		}
+		if err := txn.SetEntry(e); err != nil {
+			return err
+		}
+		return txn.Delete(badgerHitsKey(key)) // A new recording starts counting again
+	})
+	if err != nil {
+		return err
+	}
+
+	// Overwrites are counted too; the prune pass recounts
+	c.mu.Lock()
+	c.entries++
+	c.bytes += int64(len(key) + len(data))
+	over := c.limits.exceeded(c.entries, c.bytes)
+	c.mu.Unlock()
+	if over {
+		c.requestPrune()
+	}
+	return nil
+}
+
+func (c *BadgerCache) Delete(key string) error {
 	return c.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry([]byte(key), data).WithTTL(c.ttl)
-		return txn.SetEntry(e)
+		if err := txn.Delete([]byte(key)); err != nil {
+			return err
+		}
+		return txn.Delete(badgerHitsKey(key))
 	})
 }
 
+func (c *BadgerCache) Range(fn func(key string, entry *CacheEntry) bool) error {
+	return c.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if bytes.HasPrefix(item.Key(), []byte(badgerMetaPrefix)) {
+				continue
+			}
+			var entry CacheEntry
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &entry)
+			}); err != nil {
+				continue // Not a cache entry
+			}
+			key := string(item.KeyCopy(nil))
+			entry.Hits += badgerHits(txn, key)
+			if !fn(key, &entry) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// RecordHit bumps the entry's hit counter, a small key of its own that
+// expires with the entry, so hits don't rewrite large entries. Counting is
+// best effort: a conflicting concurrent hit is retried a few times and then
+// dropped.
+func (c *BadgerCache) RecordHit(key string) {
+	for attempt := 0; attempt < 3; attempt++ {
+		err := c.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get([]byte(key)) // Only the key is read, not the entry
+			if err != nil {
+				return err
+			}
+			hits := strconv.Itoa(badgerHits(txn, key) + 1)
+			e := badger.NewEntry(badgerHitsKey(key), []byte(hits))
+			e.ExpiresAt = item.ExpiresAt()
+			return txn.SetEntry(e)
+		})
+		if err != badger.ErrConflict {
+			return
+		}
+	}
+}
+
+// DiskUsage returns the bytes the database takes up on disk
+func (c *BadgerCache) DiskUsage() int64 {
+	lsm, vlog := c.db.Size()
+	return lsm + vlog
+}
+
+// SetLimits changes the size limits and prunes to them in the background
+func (c *BadgerCache) SetLimits(limits CacheLimits) {
+	c.mu.Lock()
+	c.limits = limits
+	c.mu.Unlock()
+	c.requestPrune()
+}
+
+func (c *BadgerCache) Usage() CacheUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheUsage{Entries: c.entries, Bytes: c.bytes, Evictions: c.evictions, Limits: c.limits}
+}
+
 func (c *BadgerCache) Close() error {
-	return c.db.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stopChan)
+		<-c.done
+		err = c.db.Close()
+	})
+	return err
 }
 
-// runGC periodically runs garbage collection on the database
+// runGC prunes to the size limits as soon as writes go over them, and
+// periodically prunes and runs value log garbage collection
 func (c *BadgerCache) runGC() {
+	defer close(c.done)
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		// Run value log GC until no more rewrite occurs
-		for {
-			err := c.db.RunValueLogGC(0.5)
-			if err != nil {
-				break
-			}
+	for {
+		select {
+		case <-c.pruneCh:
+			c.prune()
+		case <-ticker.C:
+			c.prune()
+			c.runValueLogGC()
+		case <-c.stopChan:
+			return
 		}
 	}
 }
@@ -278,41 +533,103 @@ type CacheConfig struct {
 	TTL             time.Duration
 	SimulateLatency bool
 	BadgerPath      string
+	Replay          CacheReplayMode // Pacing of cache hits; defaults from SimulateLatency
+	ReplaySpeed     float64         // Speed-up for CacheReplayScaled (2 = twice as fast)
+	FailOnMiss      bool            // Exit non-zero after a replay-only run that had misses
+	Limits          CacheLimits     // Size limits, enforced by LRU (memory) or oldest-first pruning (global)
 }
 
 var (
 	globalCache Cache
 	cacheConfig CacheConfig
+	cacheMu     sync.RWMutex
 )
 
-// InitCache initializes the global cache based on configuration
-func InitCache(config CacheConfig) error {
-	cacheConfig = config
-
-	switch config.Mode {
+// newCacheFromConfig creates the cache for a configuration
+func newCacheFromConfig(config CacheConfig) (Cache, error) {
+	ttl := config.Mode.entryTTL(config.TTL)
+	ttlText := "never expires"
+	if ttl > 0 {
+		ttlText = ttl.String()
+	}
+	switch config.Mode.storage() {
 	case CacheModeNone:
-		globalCache = NewNoopCache()
 		log.Printf("Cache: disabled")
+		return NewNoopCache(), nil
 	case CacheModeMemory:
-		globalCache = NewMemoryCache(config.TTL)
-		log.Printf("Cache: in-memory (TTL: %v, simulate latency: %v)", config.TTL, config.SimulateLatency)
+		log.Printf("Cache: in-memory (TTL: %s, simulate latency: %v)", ttlText, config.SimulateLatency)
+		return NewMemoryCache(ttl, config.Limits), nil
 	case CacheModeGlobal:
-		var err error
-		cache, err := NewBadgerCache(config.BadgerPath, config.TTL)
+		cache, err := NewBadgerCache(config.BadgerPath, ttl, config.Limits)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		globalCache = cache
-		log.Printf("Cache: global @ %s (TTL: %v, simulate latency: %v)", config.BadgerPath, config.TTL, config.SimulateLatency)
+		log.Printf("Cache: %s @ %s (TTL: %s, simulate latency: %v)", config.Mode, config.BadgerPath, ttlText, config.SimulateLatency)
+		return cache, nil
 	default:
-		globalCache = NewNoopCache()
+		return NewNoopCache(), nil
+	}
+}
+
+// InitCache initializes the global cache based on configuration
+func InitCache(config CacheConfig) error {
+	cache, err := newCacheFromConfig(config)
+	if err != nil {
+		return err
 	}
 
+	cacheMu.Lock()
+	globalCache = cache
+	cacheConfig = config
+	cacheMu.Unlock()
+	return nil
+}
+
+// ReloadCache switches the global cache to a new configuration and closes
+// the old one. Changing only simulate_latency, replay settings or size
+// limits, or moving between modes on the same storage, keeps the existing
+// entries.
+func ReloadCache(config CacheConfig) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	old := cacheConfig
+	sameTTL := old.Mode.entryTTL(old.TTL) == config.Mode.entryTTL(config.TTL)
+	if globalCache != nil && old.Mode.storage() == config.Mode.storage() && sameTTL && old.BadgerPath == config.BadgerPath {
+		if limited, ok := globalCache.(limitedCache); ok && old.Limits != config.Limits {
+			limited.SetLimits(config.Limits)
+		}
+		cacheConfig = config
+		return nil
+	}
+
+	// Badger holds a lock on its directory, so release it before reopening
+	if globalCache != nil && old.Mode.storage() == CacheModeGlobal && config.Mode.storage() == CacheModeGlobal && old.BadgerPath == config.BadgerPath {
+		globalCache.Close()
+		globalCache = nil
+	}
+
+	cache, err := newCacheFromConfig(config)
+	if err != nil {
+		if globalCache == nil {
+			globalCache = NewNoopCache()
+			cacheConfig = CacheConfig{Mode: CacheModeNone}
+		}
+		return err
+	}
+
+	if globalCache != nil {
+		globalCache.Close()
+	}
+	globalCache = cache
+	cacheConfig = config
 	return nil
 }
 
 // GetCache returns the global cache instance
 func GetCache() Cache {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
 	if globalCache == nil {
 		return NewNoopCache()
 	}
@@ -321,14 +638,17 @@ func GetCache() Cache {
 
 // GetCacheConfig returns the cache configuration
 func GetCacheConfig() CacheConfig {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
 	return cacheConfig
 }
 
 // CloseCache closes the global cache
 func CloseCache() error {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
 	if globalCache != nil {
 		return globalCache.Close()
 	}
 	return nil
 }
-
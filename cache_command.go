@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultCacheDir is where the global cache lives unless configured otherwise
+func defaultCacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".llmproxy-cache")
+}
+
+// withCacheDir opens the badger cache in dir for the duration of fn. Badger
+// locks its directory, so this fails while a proxy is using the cache.
+func withCacheDir(dir string, create bool, ttl time.Duration, fn func(c *BadgerCache) error) error {
+	if dir == "" {
+		dir = defaultCacheDir()
+	}
+	if _, err := os.Stat(dir); err != nil && !create {
+		return fmt.Errorf("no cache at %s", dir)
+	}
+	c, err := NewBadgerCache(dir, ttl, CacheLimits{})
+	if err != nil {
+		return fmt.Errorf("open cache %s: %w (is a proxy using it?)", dir, err)
+	}
+	defer c.Close()
+	return fn(c)
+}
+
+// CacheFilter selects entries for the cache command. Empty fields match
+// everything.
+type CacheFilter struct {
+	Model     string        // Model substring, case-insensitive
+	Prefix    string        // Prefix of the full key or of its hash
+	OlderThan time.Duration // Only entries created at least this long ago
+}
+
+func (f CacheFilter) empty() bool {
+	return f.Model == "" && f.Prefix == "" && f.OlderThan == 0
+}
+
+func (f CacheFilter) matches(key string, entry *CacheEntry, now time.Time) bool {
+	if f.Model != "" && !containsFold(entry.Model, f.Model) {
+		return false
+	}
+	if f.Prefix != "" {
+		_, hash := splitCacheKey(key)
+		if !strings.HasPrefix(key, f.Prefix) && !strings.HasPrefix(hash, f.Prefix) {
+			return false
+		}
+	}
+	if f.OlderThan > 0 && now.Sub(entry.CreatedAt) < f.OlderThan {
+		return false
+	}
+	return true
+}
+
+// splitCacheKey splits a key made by cacheKeyFor into its path and hash
+func splitCacheKey(key string) (path, hash string) {
+	i := strings.LastIndex(key, ":")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// cacheExportRecord is one entry in the cache command's listings and one
+// line of an export file
+type cacheExportRecord struct {
+	Key   string      `json:"key"`
+	Entry *CacheEntry `json:"entry"`
+}
+
+// collectCacheEntries returns the entries matching f, newest first
+func collectCacheEntries(c Cache, f CacheFilter) ([]cacheExportRecord, error) {
+	now := time.Now()
+	var records []cacheExportRecord
+	err := c.Range(func(key string, entry *CacheEntry) bool {
+		if f.matches(key, entry, now) {
+			records = append(records, cacheExportRecord{Key: key, Entry: entry})
+		}
+		return true
+	})
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Entry.CreatedAt.After(records[j].Entry.CreatedAt)
+	})
+	return records, err
+}
+
+// formatCacheAge formats how long ago an entry was stored
+func formatCacheAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// RunCacheList prints the entries matching f, newest first
+func RunCacheList(out io.Writer, c Cache, f CacheFilter, limit int, asJSON bool) error {
+	records, err := collectCacheEntries(c, f)
+	if err != nil {
+		return err
+	}
+	matched := len(records)
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+
+	if asJSON {
+		type listing struct {
+			Key        string    `json:"key"`
+			Model      string    `json:"model"`
+			Path       string    `json:"path"`
+			StatusCode int       `json:"status_code"`
+			Size       int       `json:"size"`
+			Streaming  bool      `json:"streaming"`
+			Hits       int       `json:"hits"`
+			CreatedAt  time.Time `json:"created_at"`
+		}
+		listings := make([]listing, 0, len(records))
+		for _, r := range records {
+			path, _ := splitCacheKey(r.Key)
+			listings = append(listings, listing{
+				Key:        r.Key,
+				Model:      r.Entry.Model,
+				Path:       path,
+				StatusCode: r.Entry.StatusCode,
+				Size:       r.Entry.size(),
+				Streaming:  r.Entry.Streaming,
+				Hits:       r.Entry.Hits,
+				CreatedAt:  r.Entry.CreatedAt,
+			})
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	}
+
+	fmt.Fprintf(out, "Showing %d of %d entries\n\n", len(records), matched)
+	if len(records) == 0 {
+		fmt.Fprintln(out, "(no cached responses)")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tMODEL\tPATH\tSIZE\tAGE\tHITS")
+	for _, r := range records {
+		path, hash := splitCacheKey(r.Key)
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		model := r.Entry.Model
+		if model == "" {
+			model = "-"
+		}
+		size := formatBytes(r.Entry.size())
+		if r.Entry.Streaming {
+			size += " (stream)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", hash, model, path, size, formatCacheAge(now.Sub(r.Entry.CreatedAt)), r.Entry.Hits)
+	}
+	return w.Flush()
+}
+
+// findCacheEntry resolves a full key, or a unique key or hash prefix
+func findCacheEntry(c Cache, ref string) (cacheExportRecord, error) {
+	if entry, ok := c.Get(ref); ok {
+		return cacheExportRecord{Key: ref, Entry: entry}, nil
+	}
+	records, err := collectCacheEntries(c, CacheFilter{Prefix: ref})
+	if err != nil {
+		return cacheExportRecord{}, err
+	}
+	switch len(records) {
+	case 0:
+		return cacheExportRecord{}, fmt.Errorf("no cache entry matches %q", ref)
+	case 1:
+		return records[0], nil
+	}
+	return cacheExportRecord{}, fmt.Errorf("%q matches %d entries, use a longer prefix", ref, len(records))
+}
+
+// RunCacheShow prints one entry's request and response
+func RunCacheShow(out io.Writer, c Cache, ref string, asJSON bool) error {
+	record, err := findCacheEntry(c, ref)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(record)
+	}
+
+	entry := record.Entry
+	path, _ := splitCacheKey(record.Key)
+	fmt.Fprintf(out, "Key:       %s\n", record.Key)
+	if entry.Model != "" {
+		fmt.Fprintf(out, "Model:     %s\n", entry.Model)
+	}
+	fmt.Fprintf(out, "Path:      %s\n", path)
+	fmt.Fprintf(out, "Status:    %d\n", entry.StatusCode)
+	fmt.Fprintf(out, "Created:   %s (%s ago)\n", entry.CreatedAt.Format(time.RFC3339), formatCacheAge(time.Since(entry.CreatedAt)))
+	fmt.Fprintf(out, "Hits:      %d\n", entry.Hits)
+	fmt.Fprintf(out, "Size:      %s\n", formatBytes(record.Entry.size()))
+	fmt.Fprintf(out, "Duration:  %s\n", formatDuration(entry.Duration))
+	if entry.Streaming {
+		fmt.Fprintf(out, "TTFT:      %s\n", formatDuration(entry.TTFT))
+		fmt.Fprintf(out, "Stream:    %d chunks\n", len(entry.replayChunks()))
+	}
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "Response headers:")
+	if len(entry.ResponseHeaders) == 0 {
+		fmt.Fprintln(out, "  (none)")
+	}
+	keys := make([]string, 0, len(entry.ResponseHeaders))
+	for k := range entry.ResponseHeaders {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(out, "  %s: %s\n", k, strings.Join(entry.ResponseHeaders[k], ", "))
+	}
+	fmt.Fprintln(out)
+
+	writeBody := func(label string, body []byte, missing string) {
+		fmt.Fprintf(out, "%s:\n", label)
+		var pretty bytes.Buffer
+		switch {
+		case len(body) == 0:
+			fmt.Fprintln(out, missing)
+		case json.Indent(&pretty, body, "", "  ") == nil:
+			fmt.Fprintln(out, pretty.String())
+		default:
+			fmt.Fprintln(out, strings.TrimRight(string(body), "\n"))
+		}
+		fmt.Fprintln(out)
+	}
+	writeBody("Request body", entry.RequestBody, "(not stored)")
+	writeBody("Response body", entry.ResponseBody, "(empty)")
+	return nil
+}
+
+// RunCachePurge deletes the entries matching f. With dryRun it only lists them.
+func RunCachePurge(out io.Writer, c Cache, f CacheFilter, dryRun bool) error {
+	records, err := collectCacheEntries(c, f)
+	if err != nil {
+		return err
+	}
+	size := 0
+	for _, r := range records {
+		size += r.Entry.size()
+		if dryRun {
+			fmt.Fprintln(out, r.Key)
+			continue
+		}
+		if err := c.Delete(r.Key); err != nil {
+			return fmt.Errorf("delete %s: %w", r.Key, err)
+		}
+	}
+	verb := "Purged"
+	if dryRun {
+		verb = "Would purge"
+	}
+	fmt.Fprintf(out, "%s %d entries (%s)\n", verb, len(records), formatBytes(size))
+	return nil
+}
+
+// CacheStats summarizes a cache's contents
+type CacheStats struct {
+	Entries   int
+	Streaming int
+	Bytes     int   // Stored entries
+	DiskBytes int64 // Database size, for the global cache
+	Hits      int
+	Evictions int64 // Entries dropped to stay within the size limits
+	Limits    CacheLimits
+	Oldest    time.Time
+	Newest    time.Time
+	Models    []CacheModelStats
+}
+
+// CacheModelStats is one model's share of the cache
+type CacheModelStats struct {
+	Model   string
+	Entries int
+	Bytes   int
+	Hits    int
+}
+
+// HitRate is the share of lookups answered from the cache. Each entry was
+// stored after a miss, so lookups are its hits plus one.
+func (s CacheStats) HitRate() float64 {
+	if s.Entries+s.Hits == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Entries+s.Hits)
+}
+
+// cacheStats walks the cache and totals its entries
+func cacheStats(c Cache) (CacheStats, error) {
+	var stats CacheStats
+	models := map[string]*CacheModelStats{}
+	err := c.Range(func(key string, entry *CacheEntry) bool {
+		size := entry.size()
+		stats.Entries++
+		stats.Bytes += size
+		stats.Hits += entry.Hits
+		if entry.Streaming {
+			stats.Streaming++
+		}
+		if stats.Oldest.IsZero() || entry.CreatedAt.Before(stats.Oldest) {
+			stats.Oldest = entry.CreatedAt
+		}
+		if entry.CreatedAt.After(stats.Newest) {
+			stats.Newest = entry.CreatedAt
+		}
+
+		model := entry.Model
+		if model == "" {
+			model = "(unknown)"
+		}
+		m := models[model]
+		if m == nil {
+			m = &CacheModelStats{Model: model}
+			models[model] = m
+		}
+		m.Entries++
+		m.Bytes += size
+		m.Hits += entry.Hits
+		return true
+	})
+	for _, m := range models {
+		stats.Models = append(stats.Models, *m)
+	}
+	sort.Slice(stats.Models, func(i, j int) bool {
+		if stats.Models[i].Bytes != stats.Models[j].Bytes {
+			return stats.Models[i].Bytes > stats.Models[j].Bytes
+		}
+		return stats.Models[i].Model < stats.Models[j].Model
+	})
+	if b, ok := c.(*BadgerCache); ok {
+		stats.DiskBytes = b.DiskUsage()
+	}
+	usage := c.Usage()
+	stats.Evictions, stats.Limits = usage.Evictions, usage.Limits
+	return stats, err
+}
+
+// RunCacheStats prints entry counts, sizes and the hit rate
+func RunCacheStats(out io.Writer, c Cache, location string) error {
+	stats, err := cacheStats(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Cache:     %s\n", location)
+	fmt.Fprintf(out, "Entries:   %d (%d streamed)\n", stats.Entries, stats.Streaming)
+	fmt.Fprintf(out, "Size:      %s\n", formatBytes(stats.Bytes))
+	if stats.DiskBytes > 0 {
+		fmt.Fprintf(out, "On disk:   %s\n", formatBytes(int(stats.DiskBytes)))
+	}
+	if stats.Limits.MaxEntries > 0 || stats.Limits.MaxBytes > 0 {
+		fmt.Fprintf(out, "Limits:    %s\n", formatCacheLimits(stats.Limits))
+	}
+	fmt.Fprintf(out, "Hits:      %d (%.1f%% hit rate)\n", stats.Hits, stats.HitRate()*100)
+	fmt.Fprintf(out, "Evicted:   %d\n", stats.Evictions)
+	if stats.Entries > 0 {
+		now := time.Now()
+		fmt.Fprintf(out, "Oldest:    %s ago\n", formatCacheAge(now.Sub(stats.Oldest)))
+		fmt.Fprintf(out, "Newest:    %s ago\n", formatCacheAge(now.Sub(stats.Newest)))
+
+		fmt.Fprintln(out)
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tENTRIES\tSIZE\tHITS")
+		for _, m := range stats.Models {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", m.Model, m.Entries, formatBytes(m.Bytes), m.Hits)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// ExportCache writes the entries matching f as JSON lines
+func ExportCache(w io.Writer, c Cache, f CacheFilter) (int, error) {
+	records, err := collectCacheEntries(c, f)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	for i, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return i, err
+		}
+	}
+	return len(records), nil
+}
+
+// ImportCache stores the entries from an export, replacing any with the
+// same key. Entries keep their creation time and hits but expire on the
+// destination cache's TTL.
+func ImportCache(r io.Reader, c Cache) (int, error) {
+	dec := json.NewDecoder(r)
+	count := 0
+	for {
+		var record cacheExportRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("entry %d: %w", count+1, err)
+		}
+		if record.Key == "" || record.Entry == nil {
+			return count, fmt.Errorf("entry %d: missing key or entry", count+1)
+		}
+		if err := c.Set(record.Key, record.Entry); err != nil {
+			return count, fmt.Errorf("store %s: %w", record.Key, err)
+		}
+		count++
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestCacheIterationDeleteAndHits(t *testing.T) {
+	badgerCache, err := NewBadgerCache(t.TempDir(), time.Hour, CacheLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerCache.Close()
+	memoryCache := NewMemoryCache(time.Hour, CacheLimits{})
+	defer memoryCache.Close()
+
+	for name, c := range map[string]Cache{"memory": memoryCache, "badger": badgerCache} {
+		for _, key := range []string{"/v1/a:1", "/v1/b:2", "/v1/c:3"} {
+			if err := c.Set(key, &CacheEntry{ResponseBody: []byte(key), StatusCode: 200, CreatedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c.RecordHit("/v1/b:2")
+		c.RecordHit("/v1/b:2")
+		c.RecordHit("/v1/missing:0")
+		if err := c.Delete("/v1/a:1"); err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		hits := map[string]int{}
+		c.Range(func(key string, entry *CacheEntry) bool {
+			keys = append(keys, key)
+			hits[key] = entry.Hits
+			return true
+		})
+		sort.Strings(keys)
+		if strings.Join(keys, ",") != "/v1/b:2,/v1/c:3" || hits["/v1/b:2"] != 2 || hits["/v1/c:3"] != 0 {
+			t.Errorf("%s: keys = %v, hits = %v", name, keys, hits)
+		}
+		if _, ok := c.Get("/v1/missing:0"); ok {
+			t.Errorf("%s: RecordHit created an entry", name)
+		}
+	}
+}
+
+func TestBadgerHitsDontRewriteEntries(t *testing.T) {
+	c, err := NewBadgerCache(t.TempDir(), time.Hour, CacheLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	const key = "/v1/a:1"
+	version := func(key []byte) (v uint64) {
+		c.db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err == nil {
+				v = item.Version()
+			}
+			return err
+		})
+		return v
+	}
+
+	c.Set(key, &CacheEntry{ResponseBody: bytes.Repeat([]byte("x"), 1<<20), Hits: 1})
+	written := version([]byte(key))
+	c.RecordHit(key)
+	c.RecordHit(key)
+	if entry, _ := c.Get(key); entry.Hits != 3 || version([]byte(key)) != written {
+		t.Errorf("hits = %d, entry rewritten: %v", entry.Hits, version([]byte(key)) != written)
+	}
+
+	c.Set(key, &CacheEntry{ResponseBody: []byte("new")})
+	if entry, _ := c.Get(key); entry.Hits != 0 {
+		t.Errorf("re-recorded entry kept %d hits", entry.Hits)
+	}
+	c.RecordHit(key)
+	c.Delete(key)
+	if version(badgerHitsKey(key)) != 0 {
+		t.Error("deleting the entry left its hit count behind")
+	}
+}
+
+func TestCacheCommandFilterExportImport(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	src := NewMemoryCache(time.Hour, CacheLimits{})
+	defer src.Close()
+	src.Set("/v1/chat/completions:aaa111", &CacheEntry{Model: "gpt-4o", ResponseBody: []byte(`{}`), CreatedAt: old, Hits: 3})
+	src.Set("/v1/chat/completions:bbb222", &CacheEntry{Model: "gpt-4o-mini", ResponseBody: []byte(`{}`), CreatedAt: now})
+	src.Set("/v1/messages:ccc333", &CacheEntry{Model: "claude-sonnet-4", ResponseBody: []byte("data: x\n\n"), Streaming: true, CreatedAt: now.Add(-time.Hour)})
+
+	for _, tc := range []struct {
+		filter CacheFilter
+		want   string
+	}{
+		{CacheFilter{Model: "GPT-4O"}, "/v1/chat/completions:bbb222,/v1/chat/completions:aaa111"},
+		{CacheFilter{Prefix: "ccc"}, "/v1/messages:ccc333"},
+		{CacheFilter{Prefix: "/v1/chat"}, "/v1/chat/completions:bbb222,/v1/chat/completions:aaa111"},
+		{CacheFilter{OlderThan: 30 * time.Minute}, "/v1/messages:ccc333,/v1/chat/completions:aaa111"},
+		{CacheFilter{Model: "gpt", OlderThan: 24 * time.Hour}, "/v1/chat/completions:aaa111"},
+	} {
+		records, _ := collectCacheEntries(src, tc.filter)
+		var keys []string
+		for _, r := range records {
+			keys = append(keys, r.Key)
+		}
+		if got := strings.Join(keys, ","); got != tc.want {
+			t.Errorf("%+v matched %s, want %s", tc.filter, got, tc.want)
+		}
+	}
+
+	if _, err := findCacheEntry(src, "/v1/chat"); err == nil {
+		t.Error("ambiguous prefix resolved to one entry")
+	}
+
+	stats, _ := cacheStats(src)
+	if stats.Entries != 3 || stats.Streaming != 1 || stats.HitRate() != 0.5 {
+		t.Errorf("stats = %+v, hit rate %v", stats, stats.HitRate())
+	}
+
+	var exported bytes.Buffer
+	if n, err := ExportCache(&exported, src, CacheFilter{Model: "gpt"}); err != nil || n != 2 {
+		t.Fatalf("exported %d entries: %v", n, err)
+	}
+	dst, err := NewBadgerCache(t.TempDir(), time.Hour, CacheLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if n, err := ImportCache(&exported, dst); err != nil || n != 2 {
+		t.Fatalf("imported %d entries: %v", n, err)
+	}
+	entry, ok := dst.Get("/v1/chat/completions:aaa111")
+	if !ok || entry.Model != "gpt-4o" || entry.Hits != 3 || !entry.CreatedAt.Equal(old) {
+		t.Errorf("imported entry = %+v", entry)
+	}
+
+	var out bytes.Buffer
+	if err := RunCachePurge(&out, dst, CacheFilter{OlderThan: 24 * time.Hour}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dst.Get("/v1/chat/completions:aaa111"); ok || !strings.Contains(out.String(), "Purged 1 entries") {
+		t.Errorf("purge output = %s", out.String())
+	}
+	if _, ok := dst.Get("/v1/chat/completions:bbb222"); !ok {
+		t.Error("purge removed a newer entry")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// CacheLimits bounds a cache's size. Zero means unlimited.
+type CacheLimits struct {
+	MaxEntries int
+	MaxBytes   int64
+}
+
+func (l CacheLimits) exceeded(entries int, bytes int64) bool {
+	return (l.MaxEntries > 0 && entries > l.MaxEntries) || (l.MaxBytes > 0 && bytes > l.MaxBytes)
+}
+
+// CacheUsage is a cache's current size and what it evicted to stay within
+// its limits
+type CacheUsage struct {
+	Entries   int
+	Bytes     int64
+	Evictions int64
+	Limits    CacheLimits
+}
+
+// limitedCache is a cache whose limits can change without reopening it
+type limitedCache interface {
+	SetLimits(limits CacheLimits)
+}
+
+// size approximates the memory an entry takes up
+func (e *CacheEntry) size() int {
+	n := len(e.ResponseBody) + len(e.RequestBody) + len(e.Model) + 16*len(e.Chunks)
+	for k, vals := range e.ResponseHeaders {
+		n += len(k)
+		for _, v := range vals {
+			n += len(v)
+		}
+	}
+	return n
+}
+
+// parseByteSize parses sizes like "512MB", "1.5GB" or "4096" (bytes). Units
+// are powers of 1024, as formatBytes prints them.
+func parseByteSize(raw string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	if s == "" {
+		return 0, nil
+	}
+	multipliers := []struct {
+		suffix string
+		n      int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	mult := int64(1)
+	for _, m := range multipliers {
+		if strings.HasSuffix(s, m.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, m.suffix)), m.n
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 512MB, 2GB)", raw)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// formatCacheUsage summarizes a cache's size for the status bar, or returns
+// "" while there is nothing to report
+func formatCacheUsage(u CacheUsage) string {
+	if u.Entries == 0 && u.Evictions == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("cache %d", u.Entries)
+	if u.Limits.MaxEntries > 0 {
+		s += fmt.Sprintf("/%d", u.Limits.MaxEntries)
+	}
+	s += " · " + formatBytes(int(u.Bytes))
+	if u.Limits.MaxBytes > 0 {
+		s += "/" + formatBytes(int(u.Limits.MaxBytes))
+	}
+	if u.Evictions > 0 {
+		s += fmt.Sprintf(" · %d evicted", u.Evictions)
+	}
+	return s
+}
+
+// Badger keys under this prefix hold the cache's own bookkeeping
+const (
+	badgerMetaPrefix   = "!llmproxy:"
+	badgerEvictionsKey = badgerMetaPrefix + "evictions"
+	badgerHitsPrefix   = badgerMetaPrefix + "hits:" // Followed by the entry's key
+)
+
+// badgerHitsKey is where an entry's hit count is kept
+func badgerHitsKey(key string) []byte {
+	return []byte(badgerHitsPrefix + key)
+}
+
+// badgerHits reads an entry's hit count, 0 if it has none
+func badgerHits(txn *badger.Txn, key string) int {
+	item, err := txn.Get(badgerHitsKey(key))
+	if err != nil {
+		return 0
+	}
+	var hits int
+	item.Value(func(val []byte) error {
+		hits, err = strconv.Atoi(string(val))
+		return err
+	})
+	return hits
+}
+
+// requestPrune asks the background goroutine for a prune pass
+func (c *BadgerCache) requestPrune() {
+	select {
+	case c.pruneCh <- struct{}{}:
+	default: // One is already pending
+	}
+}
+
+// prune recounts the entries and, while the cache is over a limit, deletes
+// the least recently used ones: an entry's last use is its last write or the
+// last write of its hit count. Space freed in the value log is reclaimed by GC.
+func (c *BadgerCache) prune() {
+	c.pruneMu.Lock()
+	defer c.pruneMu.Unlock()
+
+	type storedItem struct {
+		key     []byte
+		version uint64
+		size    int64
+	}
+	var items []storedItem
+	var total int64
+	lastHit := make(map[string]uint64) // Entry key -> version of its hit count
+	err := c.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false // Sizes and versions are in the keys
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if bytes.HasPrefix(item.Key(), []byte(badgerHitsPrefix)) {
+				lastHit[string(item.Key()[len(badgerHitsPrefix):])] = item.Version()
+				continue
+			}
+			if bytes.HasPrefix(item.Key(), []byte(badgerMetaPrefix)) {
+				continue
+			}
+			items = append(items, storedItem{key: item.KeyCopy(nil), version: item.Version(), size: item.EstimatedSize()})
+			total += item.EstimatedSize()
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	limits := c.limits
+	c.mu.Unlock()
+
+	var victims [][]byte
+	entries := len(items)
+	if limits.exceeded(entries, total) {
+		for i := range items {
+			if v := lastHit[string(items[i].key)]; v > items[i].version {
+				items[i].version = v
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].version < items[j].version })
+		for _, item := range items {
+			if !limits.exceeded(entries, total) {
+				break
+			}
+			victims = append(victims, item.key)
+			entries--
+			total -= item.size
+		}
+		wb := c.db.NewWriteBatch()
+		for _, key := range victims {
+			wb.Delete(key)
+			wb.Delete(badgerHitsKey(string(key)))
+		}
+		if err := wb.Flush(); err != nil {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	c.entries, c.bytes = entries, total
+	c.evictions += int64(len(victims))
+	evictions := c.evictions
+	c.mu.Unlock()
+
+	if len(victims) > 0 {
+		c.db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(badgerEvictionsKey), []byte(strconv.FormatInt(evictions, 10)))
+		})
+		c.runValueLogGC()
+	}
+}
+
+// loadEvictions reads the eviction count kept from earlier runs
+func (c *BadgerCache) loadEvictions() {
+	c.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(badgerEvictionsKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			c.evictions, err = strconv.ParseInt(string(val), 10, 64)
+			return err
+		})
+	})
+}
+
+// runValueLogGC rewrites value log files until no more space can be freed
+func (c *BadgerCache) runValueLogGC() {
+	for c.db.RunValueLogGC(0.5) == nil {
+	}
+}
+
+// formatCacheLimits describes the limits that are set
+func formatCacheLimits(l CacheLimits) string {
+	var parts []string
+	if l.MaxEntries > 0 {
+		parts = append(parts, fmt.Sprintf("%d entries", l.MaxEntries))
+	}
+	if l.MaxBytes > 0 {
+		parts = append(parts, formatBytes(int(l.MaxBytes)))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheLRUEviction(t *testing.T) {
+	c := NewMemoryCache(time.Hour, CacheLimits{MaxEntries: 3})
+	defer c.Close()
+	for i := 1; i <= 3; i++ {
+		c.Set(fmt.Sprintf("k%d", i), &CacheEntry{ResponseBody: []byte("0123456789")})
+	}
+	c.Get("k1") // Now the most recently used
+	c.Set("k4", &CacheEntry{ResponseBody: []byte("0123456789")})
+
+	if _, ok := c.Get("k2"); ok {
+		t.Error("least recently used entry survived")
+	}
+	for _, key := range []string{"k1", "k3", "k4"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if u := c.Usage(); u.Entries != 3 || u.Bytes != 30 || u.Evictions != 1 {
+		t.Errorf("usage = %+v", u)
+	}
+
+	// Tightening the limits evicts straight away, by size as well as count
+	c.SetLimits(CacheLimits{MaxBytes: 25})
+	if u := c.Usage(); u.Entries != 2 || u.Bytes != 20 || u.Evictions != 2 {
+		t.Errorf("usage after SetLimits = %+v", u)
+	}
+	if _, ok := c.Get("k1"); ok {
+		t.Error("k1 survived although k3 and k4 were used more recently")
+	}
+
+	// An entry bigger than the whole cache doesn't stay
+	c.Set("big", &CacheEntry{ResponseBody: make([]byte, 100)})
+	if _, ok := c.Get("big"); ok {
+		t.Error("oversized entry was kept")
+	}
+}
+
+func TestBadgerCachePrunesOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewBadgerCache(dir, time.Hour, CacheLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		c.Set(fmt.Sprintf("/v1/a:%d", i), &CacheEntry{ResponseBody: []byte("body"), StatusCode: 200})
+	}
+	c.RecordHit("/v1/a:1") // Rewritten, so it counts as recent
+	c.SetLimits(CacheLimits{MaxEntries: 3})
+	c.prune()
+
+	var keys []string
+	c.Range(func(key string, entry *CacheEntry) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	if got := strings.Join(keys, ","); got != "/v1/a:1,/v1/a:4,/v1/a:5" {
+		t.Errorf("kept %s", got)
+	}
+	if u := c.Usage(); u.Entries != 3 || u.Evictions != 2 {
+		t.Errorf("usage = %+v", u)
+	}
+	c.Close()
+
+	// The eviction count outlives the process
+	c, err = NewBadgerCache(dir, time.Hour, CacheLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if u := c.Usage(); u.Entries != 3 || u.Evictions != 2 {
+		t.Errorf("usage after reopening = %+v", u)
+	}
+	stats, _ := cacheStats(c)
+	if stats.Entries != 3 || stats.Evictions != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for raw, want := range map[string]int64{
+		"":      0,
+		"4096":  4096,
+		"512MB": 512 << 20,
+		"1.5gb": 3 << 29,
+		"64 KB": 64 << 10,
+		"2G":    2 << 30,
+		"100 b": 100,
+	} {
+		if got, err := parseByteSize(raw); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"MB", "-1MB", "lots"} {
+		if _, err := parseByteSize(raw); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestFormatCacheUsage(t *testing.T) {
+	if s := formatCacheUsage(CacheUsage{}); s != "" {
+		t.Errorf("empty cache shows %q", s)
+	}
+	u := CacheUsage{Entries: 812, Bytes: 2 << 20, Evictions: 97, Limits: CacheLimits{MaxEntries: 1000, MaxBytes: 64 << 20}}
+	if s := formatCacheUsage(u); s != "cache 812/1000 · 2.0 MB/64.0 MB · 97 evicted" {
+		t.Errorf("formatCacheUsage = %q", s)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cacheMiss is a request that had no cached response in replay-only or
+// record-missing mode
+type cacheMiss struct {
+	Key      string
+	Path     string
+	Model    string
+	Count    int      // Identical requests that missed
+	Recorded bool     // Stored by record-missing
+	Nearest  string   // Closest cached key on the same path, if any
+	Diff     []string // How the request differs from Nearest
+
+	body    []byte // Kept until compared with the cache or recorded
+	ignore  []string
+	compare sync.Once
+}
+
+var (
+	cacheMissesMu  sync.Mutex
+	cacheMisses    []*cacheMiss // In the order they first missed
+	cacheMissByKey = map[string]*cacheMiss{}
+	cacheRecorded  int // Responses written in record mode
+)
+
+// resetCacheMisses clears the run summary
+func resetCacheMisses() {
+	cacheMissesMu.Lock()
+	defer cacheMissesMu.Unlock()
+	cacheMisses = nil
+	cacheMissByKey = map[string]*cacheMiss{}
+	cacheRecorded = 0
+}
+
+// noteCacheMiss records a miss for the run summary. The request body is
+// kept so the miss can be compared with the cache later: scanning the cache
+// is slow, so only replay-only does it while handling the request.
+func noteCacheMiss(key, path, model string, body []byte, ignore []string) *cacheMiss {
+	cacheMissesMu.Lock()
+	defer cacheMissesMu.Unlock()
+	if miss, ok := cacheMissByKey[key]; ok {
+		miss.Count++
+		return miss
+	}
+	miss := &cacheMiss{Key: key, Path: path, Model: model, Count: 1, body: body, ignore: ignore}
+	cacheMisses = append(cacheMisses, miss)
+	cacheMissByKey[key] = miss
+	return miss
+}
+
+// compareWith fills in Nearest and Diff the first time it's called. A miss
+// that record-missing stored has nothing left to compare.
+func (m *cacheMiss) compareWith(c Cache) {
+	m.compare.Do(func() {
+		cacheMissesMu.Lock()
+		body, ignore := m.body, m.ignore
+		m.body, m.ignore = nil, nil
+		cacheMissesMu.Unlock()
+		if c != nil && body != nil {
+			m.Nearest, m.Diff = nearestCacheEntry(c, m.Path, body, ignore, m.Key)
+		}
+	})
+}
+
+// markCacheRecorded notes that a request was stored: a record-missing miss
+// now has a fixture, so its body is no longer needed for the summary, and
+// record mode counts what it wrote
+func markCacheRecorded(key string) {
+	cacheMissesMu.Lock()
+	defer cacheMissesMu.Unlock()
+	cacheRecorded++
+	if miss, ok := cacheMissByKey[key]; ok {
+		miss.Recorded = true
+		miss.body, miss.ignore = nil, nil
+	}
+}
+
+// replayMissMessage explains a replay-only miss in the error sent to the client
+func replayMissMessage(miss *cacheMiss) string {
+	msg := fmt.Sprintf("llmproxy: replay-only cache has no recorded response for this request (key %s)", shortCacheKey(miss.Key))
+	if miss.Nearest == "" {
+		return msg + "; nothing is recorded for " + miss.Path
+	}
+	var fields []string
+	for _, line := range miss.Diff {
+		field, _, _ := strings.Cut(line[2:], ":")
+		fields = append(fields, field)
+		if len(fields) == 3 {
+			break
+		}
+	}
+	if len(fields) == 0 {
+		return fmt.Sprintf("%s; nearest recorded request %s has the same fields", msg, shortCacheKey(miss.Nearest))
+	}
+	if len(miss.Diff) > len(fields) {
+		fields = append(fields, fmt.Sprintf("%d more", len(miss.Diff)-len(fields)))
+	}
+	return fmt.Sprintf("%s; nearest recorded request %s differs in %s", msg, shortCacheKey(miss.Nearest), strings.Join(fields, ", "))
+}
+
+// shortCacheKey abbreviates a key's hash for messages
+func shortCacheKey(key string) string {
+	path, hash := splitCacheKey(key)
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	if path == "" {
+		return hash
+	}
+	return path + ":" + hash
+}
+
+// nearestCacheEntry finds the cached request on the same path that differs
+// from body in the fewest fields, and lists those differences. Entries
+// without a stored request body can't be compared, and skip is never chosen.
+func nearestCacheEntry(c Cache, path string, body []byte, ignore []string, skip string) (string, []string) {
+	want := flattenRequest(body, ignore)
+	if want == nil {
+		return "", nil
+	}
+	var nearest string
+	var nearestDiff []string
+	c.Range(func(key string, entry *CacheEntry) bool {
+		if p, _ := splitCacheKey(key); p != path || key == skip {
+			return true
+		}
+		have := flattenRequest(entry.RequestBody, ignore)
+		if have == nil {
+			return true
+		}
+		diff := diffFlattened(have, want)
+		if nearest == "" || len(diff) < len(nearestDiff) || (len(diff) == len(nearestDiff) && key < nearest) {
+			nearest, nearestDiff = key, diff
+		}
+		return true
+	})
+	return nearest, nearestDiff
+}
+
+// flattenRequest turns a JSON request into leaf paths and their canonical
+// values ("messages[1].content" -> "\"hi\""), without ignored fields. It
+// returns nil for bodies that aren't JSON.
+func flattenRequest(body []byte, ignore []string) map[string]string {
+	if len(body) == 0 {
+		return nil
+	}
+	root, err := decodeCacheJSON(body)
+	if err != nil {
+		return nil
+	}
+	if obj, ok := root.(map[string]interface{}); ok {
+		for _, field := range ignore {
+			removeCacheField(obj, strings.Split(field, "."))
+		}
+	}
+
+	out := map[string]string{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if len(t) == 0 {
+				out[prefix] = "{}"
+			}
+			for k, val := range t {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, val)
+			}
+		case []interface{}:
+			if len(t) == 0 {
+				out[prefix] = "[]"
+			}
+			for i, val := range t {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), val)
+			}
+		default:
+			data, _ := json.Marshal(t)
+			out[prefix] = string(data)
+		}
+	}
+	walk("", root)
+	return out
+}
+
+// diffFlattened lists how req differs from cached, one line per field:
+// "~ field: old → new", "+ field: new" or "- field: old"
+func diffFlattened(cached, req map[string]string) []string {
+	paths := map[string]bool{}
+	for p := range cached {
+		paths[p] = true
+	}
+	for p := range req {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var diff []string
+	for _, p := range sorted {
+		old, inCached := cached[p]
+		cur, inReq := req[p]
+		switch {
+		case inCached && inReq && old != cur:
+			diff = append(diff, fmt.Sprintf("~ %s: %s → %s", p, truncateDiffValue(old), truncateDiffValue(cur)))
+		case inReq && !inCached:
+			diff = append(diff, fmt.Sprintf("+ %s: %s", p, truncateDiffValue(cur)))
+		case inCached && !inReq:
+			diff = append(diff, fmt.Sprintf("- %s: %s", p, truncateDiffValue(old)))
+		}
+	}
+	return diff
+}
+
+func truncateDiffValue(v string) string {
+	const max = 60
+	if r := []rune(v); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return v
+}
+
+// PrintCacheSummary reports what a record, replay-only or record-missing run
+// did with the cache and returns the number of requests left without a
+// cached response
+func PrintCacheSummary(out io.Writer, mode CacheMode) int {
+	if mode == CacheModeReplayOnly || mode == CacheModeRecordMissing {
+		cacheMissesMu.Lock()
+		misses := append([]*cacheMiss(nil), cacheMisses...)
+		cacheMissesMu.Unlock()
+		c := GetCache()
+		for _, miss := range misses {
+			miss.compareWith(c)
+		}
+	}
+
+	cacheMissesMu.Lock()
+	defer cacheMissesMu.Unlock()
+
+	switch mode {
+	case CacheModeRecord:
+		fmt.Fprintf(out, "Cache (record): wrote %d response(s)\n", cacheRecorded)
+		return 0
+	case CacheModeReplayOnly, CacheModeRecordMissing:
+	default:
+		return 0
+	}
+
+	unanswered := 0
+	for _, miss := range cacheMisses {
+		if !miss.Recorded {
+			unanswered++
+		}
+	}
+	if len(cacheMisses) == 0 {
+		fmt.Fprintf(out, "Cache (%s): every request was answered from the cache\n", mode)
+		return 0
+	}
+	if mode == CacheModeReplayOnly {
+		fmt.Fprintf(out, "Cache (replay-only): %d request(s) had no recorded response\n", len(cacheMisses))
+	} else {
+		fmt.Fprintf(out, "Cache (record-missing): %d request(s) weren't cached, %d recorded\n", len(cacheMisses), len(cacheMisses)-unanswered)
+	}
+
+	const maxDiffLines = 8
+	for _, miss := range cacheMisses {
+		fmt.Fprintf(out, "\n  %s", shortCacheKey(miss.Key))
+		if miss.Model != "" {
+			fmt.Fprintf(out, "  %s", miss.Model)
+		}
+		if miss.Count > 1 {
+			fmt.Fprintf(out, "  (x%d)", miss.Count)
+		}
+		if mode == CacheModeRecordMissing && !miss.Recorded {
+			fmt.Fprint(out, "  (not recorded)")
+		}
+		fmt.Fprintln(out)
+
+		switch {
+		case miss.Recorded:
+		case miss.Nearest == "":
+			fmt.Fprintf(out, "    no recorded request on %s to compare with\n", miss.Path)
+		case len(miss.Diff) == 0:
+			fmt.Fprintf(out, "    nearest %s has the same fields (recorded with other cache_ignore_fields?)\n", shortCacheKey(miss.Nearest))
+		default:
+			fmt.Fprintf(out, "    nearest %s differs in %d field(s):\n", shortCacheKey(miss.Nearest), len(miss.Diff))
+			for i, line := range miss.Diff {
+				if i == maxDiffLines {
+					fmt.Fprintf(out, "      … and %d more\n", len(miss.Diff)-maxDiffLines)
+					break
+				}
+				fmt.Fprintf(out, "      %s\n", line)
+			}
+		}
+	}
+	return unanswered
+}
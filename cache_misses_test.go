@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNearestCacheEntryDiff(t *testing.T) {
+	c := NewMemoryCache(time.Hour, CacheLimits{})
+	defer c.Close()
+	c.Set("/v1/chat/completions:aaa", &CacheEntry{RequestBody: []byte(`{"model":"gpt-4o","user":"a","messages":[{"role":"user","content":"What's 2+2?"}],"temperature":0}`)})
+	c.Set("/v1/chat/completions:bbb", &CacheEntry{RequestBody: []byte(`{"model":"gpt-4o-mini","messages":[]}`)})
+	c.Set("/v1/embeddings:ccc", &CacheEntry{RequestBody: []byte(`{"model":"gpt-4o","user":"b","messages":[{"role":"user","content":"What is 2+2?"}]}`)})
+	c.Set("/v1/chat/completions:ddd", &CacheEntry{}) // Recorded before request bodies were kept
+
+	body := []byte(`{"model":"gpt-4o","user":"b","messages":[{"role":"user","content":"What is 2+2?"}],"seed":1}`)
+	nearest, diff := nearestCacheEntry(c, "/v1/chat/completions", body, []string{"user"}, "")
+	want := []string{
+		`~ messages[0].content: "What's 2+2?" → "What is 2+2?"`,
+		`+ seed: 1`,
+		`- temperature: 0`,
+	}
+	if nearest != "/v1/chat/completions:aaa" || strings.Join(diff, "\n") != strings.Join(want, "\n") {
+		t.Errorf("nearest %s, diff:\n%s", nearest, strings.Join(diff, "\n"))
+	}
+
+	msg := replayMissMessage(&cacheMiss{Key: "/v1/chat/completions:0123456789abcdef", Path: "/v1/chat/completions", Nearest: nearest, Diff: diff})
+	if !strings.Contains(msg, "key /v1/chat/completions:0123456789ab)") || !strings.HasSuffix(msg, "differs in messages[0].content, seed, temperature") {
+		t.Errorf("message = %s", msg)
+	}
+
+	if nearest, _ := nearestCacheEntry(c, "/v1/responses", body, nil, ""); nearest != "" {
+		t.Errorf("matched %s on another path", nearest)
+	}
+}
+
+func TestRecordMissingSummaryDiffsOnlyUnrecorded(t *testing.T) {
+	resetCacheMisses()
+	defer resetCacheMisses()
+	if err := InitCache(CacheConfig{Mode: CacheModeMemory, TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+	const path = "/v1/chat/completions"
+	GetCache().Set(path+":aaa", &CacheEntry{RequestBody: []byte(`{"model":"gpt-4o","seed":1}`)})
+
+	noteCacheMiss(path+":bbb", path, "gpt-4o", []byte(`{"model":"gpt-4o","seed":2}`), nil)
+	noteCacheMiss(path+":bbb", path, "gpt-4o", []byte(`{"model":"gpt-4o","seed":2}`), nil)
+	noteCacheMiss(path+":ccc", path, "gpt-4o", []byte(`{"model":"gpt-4o","seed":3}`), nil)
+	markCacheRecorded(path + ":ccc")
+	if len(cacheMisses) != 2 || cacheMisses[0].Count != 2 || cacheMissByKey[path+":ccc"].body != nil {
+		t.Fatalf("misses = %+v", cacheMisses)
+	}
+
+	var summary bytes.Buffer
+	if misses := PrintCacheSummary(&summary, CacheModeRecordMissing); misses != 1 {
+		t.Errorf("summary counted %d unanswered", misses)
+	}
+	if strings.Count(summary.String(), "nearest") != 1 || !strings.Contains(summary.String(), "~ seed: 1 → 2") {
+		t.Errorf("summary:\n%s", summary.String())
+	}
+}
+
+func TestCacheFixtureModesIntegration(t *testing.T) {
+	resetTestState()
+	resetCacheMisses()
+	t.Cleanup(resetCacheMisses)
+	dir := t.TempDir()
+	setMode := func(mode CacheMode) {
+		t.Helper()
+		if err := ReloadCache(CacheConfig{Mode: mode, TTL: time.Hour, BadgerPath: dir}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := InitCache(CacheConfig{Mode: CacheModeRecord, TTL: time.Hour, BadgerPath: dir}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+
+	upstreamCalls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"4"}}]}`))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-fixtures", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	send := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, fmt.Sprintf("http://localhost:%d%s", port, path), bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+	const path = "/v1/chat/completions"
+	recorded := `{"model":"gpt-4o","messages":[{"role":"user","content":"2+2?"}]}`
+
+	// Record always calls the upstream, even for requests it has stored
+	send("POST", path, recorded)
+	send("POST", path, recorded)
+	if upstreamCalls != 2 {
+		t.Fatalf("record mode made %d upstream calls, want 2", upstreamCalls)
+	}
+
+	setMode(CacheModeReplayOnly)
+	if code, _ := send("POST", path, recorded); code != http.StatusOK {
+		t.Errorf("recorded request got %d", code)
+	}
+	code, body := send("POST", path, `{"model":"gpt-4o","messages":[{"role":"user","content":"3+3?"}]}`)
+	if code != http.StatusNotFound || !strings.Contains(body, `"error"`) || !strings.Contains(body, "differs in messages[0].content") {
+		t.Errorf("miss got %d: %s", code, body)
+	}
+	if code, _ := send("GET", "/v1/models", ""); code != http.StatusNotFound {
+		t.Errorf("non-LLM request got %d in replay-only mode", code)
+	}
+	if upstreamCalls != 2 {
+		t.Errorf("replay-only made %d upstream calls", upstreamCalls-2)
+	}
+
+	var summary bytes.Buffer
+	if misses := PrintCacheSummary(&summary, CacheModeReplayOnly); misses != 2 {
+		t.Errorf("summary counted %d misses", misses)
+	}
+	if !strings.Contains(summary.String(), `~ messages[0].content: "2+2?" → "3+3?"`) || !strings.Contains(summary.String(), "no recorded request on /v1/models") {
+		t.Errorf("summary:\n%s", summary.String())
+	}
+
+	resetCacheMisses()
+	setMode(CacheModeRecordMissing)
+	send("POST", path, recorded)
+	send("POST", path, `{"model":"gpt-4o","messages":[{"role":"user","content":"3+3?"}]}`)
+	waitForRequest(t, 6, 2*time.Second)
+	cacheMissesMu.Lock()
+	if len(cacheMisses) != 1 || cacheMisses[0].Nearest != "" || cacheMisses[0].body != nil {
+		t.Errorf("record-missing kept or compared the body of a miss it recorded")
+	}
+	cacheMissesMu.Unlock()
+	summary.Reset()
+	if misses := PrintCacheSummary(&summary, CacheModeRecordMissing); misses != 0 || upstreamCalls != 3 {
+		t.Errorf("record-missing left %d misses after %d upstream calls", misses, upstreamCalls)
+	}
+	if !strings.Contains(summary.String(), "1 request(s) weren't cached, 1 recorded") || strings.Contains(summary.String(), "nearest") {
+		t.Errorf("summary:\n%s", summary.String())
+	}
+}
+
+func TestCacheFixturesOutliveTTL(t *testing.T) {
+	dir := t.TempDir()
+	if err := InitCache(CacheConfig{Mode: CacheModeRecord, TTL: time.Second, BadgerPath: dir}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+	GetCache().Set("/v1/chat/completions:fixture", &CacheEntry{ResponseBody: []byte(`{}`), StatusCode: 200})
+
+	// global mode keeps the TTL, as a control
+	if err := ReloadCache(CacheConfig{Mode: CacheModeGlobal, TTL: time.Second, BadgerPath: dir}); err != nil {
+		t.Fatal(err)
+	}
+	GetCache().Set("/v1/chat/completions:expiring", &CacheEntry{ResponseBody: []byte(`{}`), StatusCode: 200})
+
+	time.Sleep(1100 * time.Millisecond)
+	if err := ReloadCache(CacheConfig{Mode: CacheModeReplayOnly, TTL: time.Second, BadgerPath: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetCache().Get("/v1/chat/completions:fixture"); !ok {
+		t.Error("recorded fixture expired with the TTL")
+	}
+	if _, ok := GetCache().Get("/v1/chat/completions:expiring"); ok {
+		t.Error("global mode entry outlived its TTL")
+	}
+}
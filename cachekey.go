@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CacheKeyInfo explains how a request's cache key was derived, so a cache
+// hit (or miss) can be traced back to the fields that produced it
+type CacheKeyInfo struct {
+	Key     string
+	Fields  []string // Top-level request fields (or form fields) hashed into the key
+	Ignored []string // Fields present in the request but left out by cache_ignore_fields
+}
+
+// GenerateCacheKey creates a cache key from the full request body
+func GenerateCacheKey(path string, requestBody []byte) string {
+	return cacheKeyFor(path, "", requestBody, nil).Key
+}
+
+// cacheKeyFor keys a request on its path, its query string and its
+// canonicalized body: JSON object keys are sorted and whitespace dropped, so
+// only the content matters. Every field counts (tools, tool_choice,
+// response_format, sampling parameters, thinking, ...) except those named in
+// ignore, which may use dots for nested fields ("metadata.user_id").
+func cacheKeyFor(path, rawQuery string, requestBody []byte, ignore []string) CacheKeyInfo {
+	// The query picks the wire format (Gemini's alt=sse) or API version
+	// (Azure's api-version), so a reply recorded for one can't answer another
+	h := sha256.New()
+	if query := cacheKeyQuery(rawQuery); query != "" {
+		fmt.Fprintf(h, "%s\x00", query)
+	}
+
+	// Uploads are keyed on their fields and file contents; the multipart
+	// boundary is random per request
+	if parts, ok := parseMultipartBody(requestBody); ok {
+		var info CacheKeyInfo
+		for _, p := range parts {
+			if slices.Contains(ignore, p.Name) {
+				if !slices.Contains(info.Ignored, p.Name) {
+					info.Ignored = append(info.Ignored, p.Name)
+				}
+				continue
+			}
+			if !slices.Contains(info.Fields, p.Name) {
+				info.Fields = append(info.Fields, p.Name)
+			}
+			fmt.Fprintf(h, "%s\x00%s\x00%d\x00", p.Name, p.Filename, len(p.Data))
+			h.Write(p.Data)
+		}
+		info.Key = path + ":" + hex.EncodeToString(h.Sum(nil))
+		return info
+	}
+
+	body, err := decodeCacheJSON(requestBody)
+	if err != nil {
+		// Not JSON: the exact bytes are the key
+		h.Write(requestBody)
+		return CacheKeyInfo{Key: path + ":" + hex.EncodeToString(h.Sum(nil))}
+	}
+
+	var info CacheKeyInfo
+	if obj, ok := body.(map[string]interface{}); ok {
+		for _, field := range ignore {
+			if removeCacheField(obj, strings.Split(field, ".")) {
+				info.Ignored = append(info.Ignored, field)
+			}
+		}
+		for field := range obj {
+			info.Fields = append(info.Fields, field)
+		}
+		sort.Strings(info.Fields)
+	}
+
+	// encoding/json writes map keys in sorted order
+	data, _ := json.Marshal(body)
+	h.Write(data)
+	info.Key = path + ":" + hex.EncodeToString(h.Sum(nil))
+	return info
+}
+
+// decodeCacheJSON decodes a request body for keying and diffing. Integers
+// keep every digit, so seeds and IDs beyond 2^53 don't collide; other
+// numbers are floats, so 1.0 and 1 still key alike.
+func decodeCacheJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return normalizeCacheNumbers(v), nil
+}
+
+// normalizeCacheNumbers turns json.Numbers that aren't plain integers into
+// float64s
+func normalizeCacheNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = normalizeCacheNumbers(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = normalizeCacheNumbers(val)
+		}
+	case json.Number:
+		if !strings.ContainsAny(string(t), ".eE") {
+			return t
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// cacheKeyQuery sorts a query string's parameters and drops the API key
+// Gemini accepts as ?key=, which no more belongs in a cache key than the
+// Authorization header does
+func cacheKeyQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	values.Del("key")
+	return values.Encode()
+}
+
+// removeCacheField deletes the field at path from obj, reporting whether it
+// was there
+func removeCacheField(obj map[string]interface{}, path []string) bool {
+	val, ok := obj[path[0]]
+	if !ok {
+		return false
+	}
+	if len(path) == 1 {
+		delete(obj, path[0])
+		return true
+	}
+	nested, ok := val.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	return removeCacheField(nested, path[1:])
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheKeyCoversAllFields(t *testing.T) {
+	const path = "/v1/chat/completions"
+	base := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}],"temperature":0.2}`
+	baseKey := GenerateCacheKey(path, []byte(base))
+
+	// Formatting and key order don't matter
+	reordered := "{\n  \"temperature\": 0.2,\n  \"messages\": [{\"content\": \"Hi\", \"role\": \"user\"}],\n  \"model\": \"gpt-4o\"\n}"
+	if GenerateCacheKey(path, []byte(reordered)) != baseKey {
+		t.Error("reformatted request got a different key")
+	}
+
+	for _, extra := range []string{
+		`"tools":[{"type":"function","function":{"name":"ls"}}]`,
+		`"tool_choice":"required"`,
+		`"response_format":{"type":"json_object"}`,
+		`"top_p":0.5`,
+		`"seed":7`,
+		`"stop":["\n"]`,
+	} {
+		variant := strings.TrimSuffix(base, "}") + "," + extra + "}"
+		if GenerateCacheKey(path, []byte(variant)) == baseKey {
+			t.Errorf("adding %s didn't change the key", extra)
+		}
+	}
+
+	anthropic := `{"model":"claude-sonnet-4","max_tokens":1024,"messages":[{"role":"user","content":"Hi"}]}`
+	thinking := `{"model":"claude-sonnet-4","max_tokens":1024,"messages":[{"role":"user","content":"Hi"}],"thinking":{"type":"enabled","budget_tokens":512}}`
+	if GenerateCacheKey("/v1/messages", []byte(anthropic)) == GenerateCacheKey("/v1/messages", []byte(thinking)) {
+		t.Error("thinking settings didn't change the key")
+	}
+}
+
+func TestCacheKeyKeepsLargeIntegers(t *testing.T) {
+	const path = "/v1/chat/completions"
+	a := `{"model":"gpt-4o","messages":[],"seed":9007199254740993}`
+	b := `{"model":"gpt-4o","messages":[],"seed":9007199254740992}`
+	if GenerateCacheKey(path, []byte(a)) == GenerateCacheKey(path, []byte(b)) {
+		t.Error("seeds past 2^53 got the same key")
+	}
+	if GenerateCacheKey(path, []byte(`{"temperature":1.0}`)) != GenerateCacheKey(path, []byte(`{"temperature":1}`)) {
+		t.Error("1.0 and 1 got different keys")
+	}
+	if diff := diffFlattened(flattenRequest([]byte(b), nil), flattenRequest([]byte(a), nil)); len(diff) != 1 {
+		t.Errorf("diff = %v", diff)
+	}
+}
+
+func TestCacheKeyIgnoreFields(t *testing.T) {
+	const path = "/v1/chat/completions"
+	a := `{"model":"gpt-4o","messages":[],"user":"alice","metadata":{"user_id":"1","app":"x"}}`
+	b := `{"model":"gpt-4o","messages":[],"user":"bob","metadata":{"user_id":"2","app":"x"}}`
+	ignore := []string{"user", "metadata.user_id", "stream_options"}
+
+	infoA := cacheKeyFor(path, "", []byte(a), ignore)
+	infoB := cacheKeyFor(path, "", []byte(b), ignore)
+	if infoA.Key != infoB.Key {
+		t.Error("requests differing only in ignored fields got different keys")
+	}
+	if got := strings.Join(infoA.Fields, ","); got != "messages,metadata,model" {
+		t.Errorf("fields = %s", got)
+	}
+	// Only fields the request actually had are reported as ignored
+	if got := strings.Join(infoA.Ignored, ","); got != "user,metadata.user_id" {
+		t.Errorf("ignored = %s", got)
+	}
+
+	c := `{"model":"gpt-4o","messages":[],"user":"alice","metadata":{"user_id":"1","app":"y"}}`
+	if cacheKeyFor(path, "", []byte(c), ignore).Key == infoA.Key {
+		t.Error("a field that isn't ignored didn't change the key")
+	}
+}
+
+func TestCacheKeyProxyIntegration(t *testing.T) {
+	resetTestState()
+	if err := InitCache(CacheConfig{Mode: CacheModeMemory, TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+
+	upstreamCalls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	cfg := ProxyConfig{Name: "test-cachekey", Listen: fmt.Sprintf(":%d", port), Target: upstream.URL, CacheIgnoreFields: []string{"user"}}
+	if err := StartProxyFromConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	send := func(body string) {
+		t.Helper()
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json", bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	send(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"user":"alice"}`)
+	first := waitForRequest(t, 1, 2*time.Second)
+	send(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"user":"bob"}`)
+	second := waitForRequest(t, 2, 2*time.Second)
+	send(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"tool_choice":"none"}`)
+	third := waitForRequest(t, 3, 2*time.Second)
+
+	if !second.CachedResponse || second.CacheKey != first.CacheKey {
+		t.Errorf("ignored field caused a miss: cached=%v", second.CachedResponse)
+	}
+	if third.CachedResponse || upstreamCalls != 2 {
+		t.Errorf("tool_choice change was served from cache (upstream calls %d)", upstreamCalls)
+	}
+	if strings.Join(second.CacheIgnoredFields, ",") != "user" || strings.Join(third.CacheKeyFields, ",") != "messages,model,tool_choice" {
+		t.Errorf("key fields = %v, ignored = %v", third.CacheKeyFields, second.CacheIgnoredFields)
+	}
+	if entry, ok := GetCache().Get(first.CacheKey); !ok || entry.Hits != 1 || entry.Model != "gpt-4o" || len(entry.RequestBody) == 0 {
+		t.Errorf("cache entry = %+v", entry)
+	}
+}
+
+func TestCacheKeyQueryPicksWireFormat(t *testing.T) {
+	resetTestState()
+	if err := InitCache(CacheConfig{Mode: CacheModeMemory, TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+
+	upstreamCalls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		if r.URL.Query().Get("alt") == "sse" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"hi\"}]}}]}\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}]`))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-cachekey-query", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	send := func(query string) string {
+		t.Helper()
+		url := fmt.Sprintf("http://localhost:%d/v1beta/models/gemini-2.5-flash:streamGenerateContent%s", port, query)
+		resp, err := http.Post(url, "application/json", strings.NewReader(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	send("?alt=sse&key=one")
+	waitForRequest(t, 1, 2*time.Second)
+	if body := send(""); !strings.HasPrefix(body, "[") {
+		t.Errorf("request without alt=sse got %q", body)
+	}
+	second := waitForRequest(t, 2, 2*time.Second)
+	if second.CachedResponse || upstreamCalls != 2 {
+		t.Errorf("request without alt=sse was answered from the alt=sse entry")
+	}
+
+	// The API key isn't part of the key
+	if body := send("?key=two&alt=sse"); !strings.HasPrefix(body, "data: ") {
+		t.Errorf("alt=sse request got %q", body)
+	}
+	if third := waitForRequest(t, 3, 2*time.Second); !third.CachedResponse || upstreamCalls != 2 {
+		t.Errorf("request with another API key missed the cache")
+	}
+}
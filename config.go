@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -15,14 +14,43 @@ type ProxyConfig struct {
 	Listen   string   `toml:"listen"`    // Address to listen on (e.g., ":8080")
 	Target   string   `toml:"target"`    // Target URL to proxy to
 	LLMPaths []string `toml:"llm_paths"` // Extra path substrings to treat as LLM endpoints
+
+	// "reverse" (default) or "forward". Forward proxies are used via
+	// HTTPS_PROXY and need no target.
+	Mode      string   `toml:"mode"`
+	Intercept []string `toml:"intercept"` // Extra host globs to decrypt in forward mode
+
+	// Routing rules evaluated in order; the first match picks the target.
+	// Requests that match no route go to Target.
+	Routes []RouteConfig `toml:"route"`
+
+	// Retry and failover policy for upstream errors
+	Retry RetryConfig `toml:"retry"`
+
+	// Fault-injection rules for resilience testing
+	Faults []FaultConfig `toml:"fault"`
+
+	// Request fields left out of cache keys, e.g. "user" or
+	// "metadata.user_id". All other fields are part of the key.
+	CacheIgnoreFields []string `toml:"cache_ignore_fields"`
 }
 
 // CacheConfigTOML represents cache configuration in TOML format
 type CacheConfigTOML struct {
-	Mode            string `toml:"mode"`             // "none", "memory", or "global"
+	Mode            string `toml:"mode"`             // "none", "memory", "global", "record", "replay-only" or "record-missing"
 	TTL             string `toml:"ttl"`              // Duration string (e.g., "24h", "7d")
 	SimulateLatency bool   `toml:"simulate_latency"` // Simulate original response latency
 	Dir             string `toml:"dir"`              // Directory for persistent cache
+
+	// Pacing of cache hits: "instant", "original" or "scaled"
+	Replay      string  `toml:"replay"`
+	ReplaySpeed float64 `toml:"replay_speed"` // Speed-up for "scaled", e.g. 4
+
+	FailOnMiss bool `toml:"fail_on_miss"` // Exit non-zero if replay-only had misses
+
+	// Size limits; the least recently used entries are evicted past them
+	MaxEntries int    `toml:"max_entries"`
+	MaxSize    string `toml:"max_size"` // e.g. "512MB"
 }
 
 // Config represents the full TOML configuration file
@@ -30,6 +58,11 @@ type Config struct {
 	Proxies  []ProxyConfig   `toml:"proxy"`
 	Cache    CacheConfigTOML `toml:"cache"`
 	SaveTape string          `toml:"save_tape"` // Auto-save session to tape file
+	Budgets  []BudgetConfig  `toml:"budget"`    // Spend caps shared across all proxies
+	Redact   RedactConfig    `toml:"redact"`    // Secret scrubbing before persistence
+	Azure    AzureConfig     `toml:"azure"`     // Azure OpenAI deployment pricing
+
+	Correlation CorrelationConfig `toml:"correlation"` // Request ID header for matching app logs
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -48,6 +81,7 @@ func DefaultConfig() *Config {
 			SimulateLatency: false,
 			Dir:             "",
 		},
+		Redact: RedactConfig{Enabled: true},
 	}
 }
 
@@ -79,9 +113,36 @@ func LoadConfig(path string) (*Config, error) {
 		if p.Listen == "" {
 			return nil, fmt.Errorf("proxy listen address cannot be empty")
 		}
-		if p.Target == "" {
-			return nil, fmt.Errorf("proxy target URL cannot be empty")
+		switch p.Mode {
+		case "", ProxyModeReverse:
+			if p.Target == "" && len(p.Routes) == 0 {
+				return nil, fmt.Errorf("proxy target URL cannot be empty")
+			}
+		case ProxyModeForward:
+			if len(p.Routes) > 0 {
+				return nil, fmt.Errorf("proxy %s: routes are not supported in forward mode", p.Listen)
+			}
+		default:
+			return nil, fmt.Errorf("proxy %s: invalid mode %q (expected reverse|forward)", p.Listen, p.Mode)
+		}
+		for _, r := range p.Routes {
+			if err := r.validate(); err != nil {
+				return nil, fmt.Errorf("proxy %s: %w", p.Listen, err)
+			}
+		}
+		if _, err := parseRetryPolicy(p.Retry, len(p.Retry.FallbackTargets)); err != nil {
+			return nil, fmt.Errorf("proxy %s: retry: %w", p.Listen, err)
 		}
+		if _, err := parseFaultRules(p.Faults); err != nil {
+			return nil, fmt.Errorf("proxy %s: %w", p.Listen, err)
+		}
+	}
+
+	if _, err := parseBudgetRules(config.Budgets); err != nil {
+		return nil, err
+	}
+	if _, err := NewRedactor(config.Redact); err != nil {
+		return nil, err
 	}
 
 	return config, nil
@@ -113,17 +174,41 @@ func (c *CacheConfigTOML) ToCacheConfig() (CacheConfig, error) {
 		return CacheConfig{}, fmt.Errorf("invalid TTL: %w", err)
 	}
 
+	mode, err := parseCacheMode(c.Mode)
+	if err != nil {
+		return CacheConfig{}, err
+	}
+
+	replay, err := parseCacheReplayMode(c.Replay)
+	if err != nil {
+		return CacheConfig{}, err
+	}
+	if c.ReplaySpeed < 0 {
+		return CacheConfig{}, fmt.Errorf("invalid replay_speed %v", c.ReplaySpeed)
+	}
+
+	if c.MaxEntries < 0 {
+		return CacheConfig{}, fmt.Errorf("invalid max_entries %d", c.MaxEntries)
+	}
+	maxBytes, err := parseByteSize(c.MaxSize)
+	if err != nil {
+		return CacheConfig{}, fmt.Errorf("invalid max_size: %w", err)
+	}
+
 	badgerPath := c.Dir
-	if badgerPath == "" && c.Mode == "global" {
-		home, _ := os.UserHomeDir()
-		badgerPath = filepath.Join(home, ".llmproxy-cache")
+	if badgerPath == "" && mode.storage() == CacheModeGlobal {
+		badgerPath = defaultCacheDir()
 	}
 
 	return CacheConfig{
-		Mode:            CacheMode(c.Mode),
+		Mode:            mode,
 		TTL:             ttl,
 		SimulateLatency: c.SimulateLatency,
 		BadgerPath:      badgerPath,
+		Replay:          replay,
+		ReplaySpeed:     c.ReplaySpeed,
+		FailOnMiss:      c.FailOnMiss,
+		Limits:          CacheLimits{MaxEntries: c.MaxEntries, MaxBytes: maxBytes},
 	}, nil
 }
 
@@ -158,22 +243,140 @@ target = "http://localhost:11434"
 # that uses custom paths (e.g. a platform proxy).
 # llm_paths = ["/proxy/anthropic/", "/proxy/openrouter"]
 
+# Cache keys cover the whole request body (messages, tools, sampling
+# parameters, ...). Fields that vary between otherwise identical requests
+# can be left out per proxy; use dots for nested fields.
+# cache_ignore_fields = ["user", "metadata", "stream_options"]
+
+# A single listener can route to several upstreams. Routes are checked in
+# order and the first match wins; unmatched requests go to target.
+# Each route can match on a model glob, a path prefix and/or a header.
+# [[proxy]]
+# name = "router"
+# listen = ":8083"
+# target = "https://api.openai.com"
+#
+# [[proxy.route]]
+# name = "anthropic"
+# model = "claude-*"
+# target = "https://api.anthropic.com"
+#
+# [[proxy.route]]
+# name = "ollama"
+# model = "llama*"
+# target = "http://localhost:11434"
+#
+# [[proxy.route]]
+# header = "X-Provider"
+# header_value = "openrouter"
+# target = "https://openrouter.ai/api"
+
+# Forward proxy for tools that can't change their base URL but honor
+# HTTPS_PROXY. Traffic to known LLM hosts is decrypted with a local CA
+# (see "llmproxy-go ca") and captured; other hosts are tunneled untouched.
+# [[proxy]]
+# name = "forward"
+# listen = ":8090"
+# mode = "forward"
+# intercept = ["llm.internal.example.com"]  # extra hosts to decrypt
+
+# Retry/failover policy (per proxy, optional). Retries 429/5xx answers and
+# connection errors with exponential backoff and jitter, honoring Retry-After.
+# Streaming requests are only retried before the first byte reaches the client.
+# [proxy.retry]
+# max_attempts = 3
+# statuses = [429, 500, 502, 503, 504, 529]
+# initial_backoff = "500ms"
+# max_backoff = "30s"
+# fallback_targets = ["https://backup.example.com"]
+
+# Fault injection (per proxy, optional) for testing clients against flaky
+# providers. Each rule fires with a percentage probability; the first rule
+# that fires wins. Types: latency, status, reset, stall, truncate.
+# Injected faults are flagged as FAULT in the TUI.
+# [[proxy.fault]]
+# name = "rate-limit"
+# type = "status"
+# status = 429
+# model = "gpt-*"
+# probability = 10
+#
+# [[proxy.fault]]
+# type = "truncate"          # cut SSE streams mid-response
+# path_prefix = "/v1/messages"
+# after_events = 5
+# probability = 5
+
 # Cache configuration
 [cache]
 # mode: "none" (disabled), "memory" (in-memory), or "global" (persistent BadgerDB)
+# For test fixtures, on the same persistent cache: "record" (always call the
+# upstream and overwrite), "replay-only" (never call the upstream; misses get
+# an error) or "record-missing" (record only what isn't cached yet)
 mode = "memory"
 
 # TTL for cached responses. Supports Go duration format (e.g., "1h", "24h")
 # and days with "d" suffix (e.g., "7d")
 ttl = "24h"
 
-# Whether to simulate the original response latency for cached responses
+# Whether to simulate the original response latency for cached responses.
+# Streamed responses are cached too and replayed chunk by chunk; with
+# simulate_latency they keep their original TTFT and gaps between chunks.
 simulate_latency = false
 
-# Directory for persistent cache (only used when mode = "global")
+# Replay pacing, overriding simulate_latency: "instant", "original", or
+# "scaled" (original timing sped up by replay_speed)
+# replay = "scaled"
+# replay_speed = 4.0
+
+# Exit with status 1 if a replay-only run had cache misses
+# fail_on_miss = true
+
+# Size limits. Past them the least recently used entries are evicted.
+# max_entries = 10000
+# max_size = "512MB"
+
+# Directory for persistent cache (used by "global" and the fixture modes)
 # Defaults to ~/.llmproxy-cache if not specified
 # dir = "/path/to/cache"
 
+# Secret redaction applied before requests are written to tapes and session
+# history (the live TUI still shows the original values). Enabled by default
+# for Authorization, x-api-key, x-goog-api-key and similar headers, ?key= URL
+# parameters, and API keys found in bodies.
+# [redact]
+# enabled = true
+# mode = "mask"              # "mask" -> [REDACTED], "hash" -> [REDACTED:<sha256 prefix>]
+# headers = ["X-Internal-Token"]
+# builtin = ["api_keys", "emails"]
+#
+# [[redact.rule]]
+# name = "customer-id"
+# pattern = "CUST-[0-9]{6}"
+
+# Every LLM response carries the proxy's request ID (session ID + request
+# number) so application logs can be matched to rows in the TUI. Look it up
+# with the : command or "llmproxy inspect --request <id>". Incoming
+# traceparent and X-Request-Id headers are recorded too.
+# [correlation]
+# header = "X-LLMProxy-Request-Id"   # "none" to disable
+# forward_upstream = false           # also send the header to the provider
+
+# Spend budgets in USD (optional). Spend is tracked from each request's cost.
+# A soft cap shows a warning banner in the TUI; a hard cap makes the proxy
+# reject new requests with a provider-shaped 402 error before forwarding.
+# Narrow a budget with model/proxy globs and make it rolling with window.
+# State is persisted in ~/.llmproxy-go/budget.json (or $LLMPROXY_BUDGET_DIR).
+# [[budget]]
+# hard = 50.0                # total spend
+#
+# [[budget]]
+# name = "daily-gpt4"
+# model = "gpt-4*"
+# window = "24h"
+# soft = 5.0
+# hard = 10.0
+
 # Auto-save session to a tape file (optional)
 # save_tape = "session.tape"
 `
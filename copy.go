@@ -51,9 +51,10 @@ func (m *model) copyInputOutput() {
 	if m.selected.Host != "" {
 		b.WriteString(fmt.Sprintf("Host: %s\n", m.selected.Host))
 	}
-	if len(m.selected.RequestHeaders) > 0 {
-		headerKeys := make([]string, 0, len(m.selected.RequestHeaders))
-		for k := range m.selected.RequestHeaders {
+	requestHeaders := currentRedactor().RedactHeaders(m.selected.RequestHeaders)
+	if len(requestHeaders) > 0 {
+		headerKeys := make([]string, 0, len(requestHeaders))
+		for k := range requestHeaders {
 			if k == "Host" {
 				continue
 			}
@@ -61,7 +62,7 @@ func (m *model) copyInputOutput() {
 		}
 		sort.Strings(headerKeys)
 		for _, k := range headerKeys {
-			values := m.selected.RequestHeaders[k]
+			values := requestHeaders[k]
 			headerValue := strings.Join(values, ", ")
 			if strings.ToLower(k) == "authorization" && len(headerValue) > 30 {
 				headerValue = headerValue[:20] + "..." + headerValue[len(headerValue)-10:]
@@ -85,14 +86,15 @@ func (m *model) copyInputOutput() {
 		statusText := http.StatusText(m.selected.StatusCode)
 		b.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\n", m.selected.StatusCode, statusText))
 	}
-	if len(m.selected.ResponseHeaders) > 0 {
-		headerKeys := make([]string, 0, len(m.selected.ResponseHeaders))
-		for k := range m.selected.ResponseHeaders {
+	responseHeaders := currentRedactor().RedactHeaders(m.selected.ResponseHeaders)
+	if len(responseHeaders) > 0 {
+		headerKeys := make([]string, 0, len(responseHeaders))
+		for k := range responseHeaders {
 			headerKeys = append(headerKeys, k)
 		}
 		sort.Strings(headerKeys)
 		for _, k := range headerKeys {
-			values := m.selected.ResponseHeaders[k]
+			values := responseHeaders[k]
 			b.WriteString(fmt.Sprintf("%s: %s\n", k, strings.Join(values, ", ")))
 		}
 	}
@@ -136,9 +138,11 @@ func (m *model) getCopyText() (string, string, error) {
 	case TabOutput:
 		return outputCopyText(m.selected)
 	case TabRawInput:
-		return rawBodyCopyText(m.selected.RequestBody, "raw input")
+		return rawBodyCopyText(displayBody(m.selected.RequestHeaders, m.selected.RequestBody), "raw input")
 	case TabRawOutput:
-		return rawBodyCopyText(m.selected.ResponseBody, "raw output")
+		return rawBodyCopyText(displayBody(m.selected.ResponseHeaders, m.selected.ResponseBody), "raw output")
+	case TabEvents:
+		return wsEventsCopyText(m.selected)
 	default:
 		return "", "", fmt.Errorf("nothing to copy")
 	}
@@ -175,7 +179,7 @@ func outputCopyText(req *LLMRequest) (string, string, error) {
 		return text, "output", nil
 	}
 
-	return rawBodyCopyText(req.ResponseBody, "output")
+	return rawBodyCopyText(displayBody(req.ResponseHeaders, req.ResponseBody), "output")
 }
 
 func extractLLMOutputText(req *LLMRequest) string {
@@ -183,12 +187,47 @@ func extractLLMOutputText(req *LLMRequest) string {
 		return ""
 	}
 
+	if isMediaEndpoint(req.Path) {
+		return extractMediaOutputText(req.ResponseBody)
+	}
 	if isAnthropicEndpoint(req.Path) {
 		return extractAnthropicOutputText(req.ResponseBody)
 	}
+	if isMappedChatEndpoint(req.Path) {
+		return extractMappedOutputText(req.Path, req.ResponseBody)
+	}
 	return extractOpenAIOutputText(req.ResponseBody)
 }
 
+// extractMappedOutputText copies the single choice that Responses API, Ollama
+// and Converse output is mapped onto
+func extractMappedOutputText(path string, responseBody []byte) string {
+	resp := decodeOpenAIResponse(path, responseBody)
+	if resp == nil || len(resp.Choices) == 0 {
+		return ""
+	}
+	return renderOpenAIChoiceCopyText(resp.Choices[0])
+}
+
+// extractMediaOutputText copies a transcript, or the revised prompts of
+// generated images
+func extractMediaOutputText(responseBody []byte) string {
+	resp := parseMediaResponse(responseBody)
+	if resp == nil {
+		return ""
+	}
+	if resp.Text != "" {
+		return strings.TrimSpace(resp.Text)
+	}
+	var prompts []string
+	for _, d := range resp.Data {
+		if d.RevisedPrompt != "" {
+			prompts = append(prompts, d.RevisedPrompt)
+		}
+	}
+	return strings.Join(prompts, "\n\n")
+}
+
 func extractOpenAIOutputText(responseBody []byte) string {
 	var resp OpenAIResponse
 	if err := json.Unmarshal(responseBody, &resp); err != nil {
@@ -298,3 +337,20 @@ func extractAnthropicOutputText(responseBody []byte) string {
 
 	return strings.TrimSpace(strings.Join(parts, "\n\n"))
 }
+
+// wsEventsCopyText renders a WebSocket session as one line per message:
+// offset, direction, then the payload
+func wsEventsCopyText(req *LLMRequest) (string, string, error) {
+	if req == nil || len(req.WSEvents) == 0 {
+		return "", "events", fmt.Errorf("no WebSocket messages")
+	}
+	var b strings.Builder
+	for _, event := range req.WSEvents {
+		payload := string(truncateLongBase64Strings(event.Data))
+		if event.Binary {
+			payload = fmt.Sprintf("<%d bytes binary>", len(event.Data))
+		}
+		fmt.Fprintf(&b, "+%.3fs %s %s\n", event.Time.Sub(req.StartTime).Seconds(), event.Direction, payload)
+	}
+	return b.String(), "events", nil
+}
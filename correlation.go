@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCorrelationHeader carries the proxy's request ID back to the client
+const DefaultCorrelationHeader = "X-LLMProxy-Request-Id"
+
+// CorrelationConfig controls the per-request ID that ties application logs
+// to captured requests
+type CorrelationConfig struct {
+	// Response header set on every LLM request; "none" turns it off.
+	// Defaults to X-LLMProxy-Request-Id.
+	Header string `toml:"header"`
+	// Also send the header on the upstream request
+	ForwardUpstream bool `toml:"forward_upstream"`
+}
+
+var (
+	correlation   CorrelationConfig
+	correlationMu sync.RWMutex
+
+	fallbackSessionOnce sync.Once
+	fallbackSessionID   string
+)
+
+// SetCorrelation replaces the correlation header settings
+func SetCorrelation(cfg CorrelationConfig) {
+	correlationMu.Lock()
+	defer correlationMu.Unlock()
+	correlation = cfg
+}
+
+// correlationHeader returns the header name to set, or "" when disabled
+func correlationHeader() (name string, forwardUpstream bool) {
+	correlationMu.RLock()
+	defer correlationMu.RUnlock()
+	name = strings.TrimSpace(correlation.Header)
+	if strings.EqualFold(name, "none") {
+		return "", false
+	}
+	if name == "" {
+		name = DefaultCorrelationHeader
+	}
+	return name, correlation.ForwardUpstream
+}
+
+// correlationSessionID is the session part of new correlation IDs. Without
+// session history (tests, embedding) a per-process ID stands in.
+func correlationSessionID() string {
+	if h := activeSessionHistory; h != nil {
+		return h.sessionID
+	}
+	fallbackSessionOnce.Do(func() { fallbackSessionID = GenerateSessionID() })
+	return fallbackSessionID
+}
+
+// formatCorrelationID joins a session and request ID: sess-0a1b2c3d4e5f-42
+func formatCorrelationID(sessionID string, id int) string {
+	return fmt.Sprintf("%s-%d", sessionID, id)
+}
+
+// parseCorrelationID splits a correlation ID into its session and request ID
+func parseCorrelationID(s string) (sessionID string, id int, ok bool) {
+	i := strings.LastIndex(s, "-")
+	if i <= 0 || !strings.HasPrefix(s, "sess-") {
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(s[i+1:])
+	if err != nil || id <= 0 || !isSafeSessionID(s[:i]) {
+		return "", 0, false
+	}
+	return s[:i], id, true
+}
+
+// traceIDFromTraceparent returns the trace-id of a W3C traceparent header
+// (00-<trace-id>-<parent-id>-<flags>), or "" if it's malformed
+func traceIDFromTraceparent(value string) string {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	for _, c := range parts[1] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return ""
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" {
+		return "" // all-zero trace IDs are invalid
+	}
+	return parts[1]
+}
+
+// stampCorrelation gives a new request its correlation ID, records the IDs
+// the client sent, and exposes the correlation ID on the response (and
+// upstream request, if configured). Must run before the response is written.
+func stampCorrelation(req *LLMRequest, r *http.Request, respHeader, upstreamHeader http.Header) {
+	req.CorrelationID = formatCorrelationID(correlationSessionID(), req.ID)
+	req.TraceID = traceIDFromTraceparent(r.Header.Get("Traceparent"))
+	req.ClientRequestID = strings.TrimSpace(r.Header.Get("X-Request-Id"))
+
+	name, forward := correlationHeader()
+	if name == "" {
+		return
+	}
+	if respHeader != nil {
+		respHeader.Set(name, req.CorrelationID)
+	}
+	if forward && upstreamHeader != nil {
+		upstreamHeader.Set(name, req.CorrelationID)
+	}
+}
+
+// matchesRequestRef reports whether ref names a request by its correlation
+// ID, trace ID or client request ID. A full traceparent matches its trace ID.
+func matchesRequestRef(ref, correlationID, traceID, clientRequestID string) bool {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return false
+	}
+	if t := traceIDFromTraceparent(ref); t != "" {
+		ref = t
+	}
+	for _, id := range []string{correlationID, traceID, clientRequestID} {
+		if id != "" && strings.EqualFold(id, ref) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorrelationIDs(t *testing.T) {
+	id := formatCorrelationID("sess-0a1b2c3d4e5f", 42)
+	if id != "sess-0a1b2c3d4e5f-42" {
+		t.Errorf("formatCorrelationID = %q", id)
+	}
+	if session, n, ok := parseCorrelationID(id); !ok || session != "sess-0a1b2c3d4e5f" || n != 42 {
+		t.Errorf("parseCorrelationID = %q %d %v", session, n, ok)
+	}
+	for _, bad := range []string{"42", "sess-", "sess-abc-x", "req-abc-1", "sess-a/b-1"} {
+		if _, _, ok := parseCorrelationID(bad); ok {
+			t.Errorf("parseCorrelationID(%q) accepted", bad)
+		}
+	}
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if got := traceIDFromTraceparent(traceparent); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("traceIDFromTraceparent = %q", got)
+	}
+	for _, bad := range []string{"", "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"} {
+		if got := traceIDFromTraceparent(bad); got != "" {
+			t.Errorf("traceIDFromTraceparent(%q) = %q", bad, got)
+		}
+	}
+
+	// A full traceparent finds the request by its trace ID
+	if !matchesRequestRef(traceparent, id, "4bf92f3577b34da6a3ce929d0e0e4736", "") {
+		t.Error("traceparent didn't match the trace ID")
+	}
+	if !matchesRequestRef("ABC-123", id, "", "abc-123") || matchesRequestRef("abc", id, "", "abc-123") {
+		t.Error("client request IDs should match whole and case-insensitively")
+	}
+}
+
+func TestCorrelationProxyIntegration(t *testing.T) {
+	resetTestState()
+	t.Cleanup(func() { SetCorrelation(CorrelationConfig{}) })
+	SetCorrelation(CorrelationConfig{Header: "X-Trace-Proxy", ForwardUpstream: true})
+	if err := InitCache(CacheConfig{Mode: CacheModeMemory, TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+
+	forwarded := make(chan string, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Get("X-Trace-Proxy")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-correlation", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	send := func(requestID string) *http.Response {
+		t.Helper()
+		httpReq, _ := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/v1/chat/completions", port),
+			bytes.NewReader([]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("X-Request-Id", requestID)
+		httpReq.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := send("app-1")
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if !strings.HasPrefix(captured.CorrelationID, "sess-") || !strings.HasSuffix(captured.CorrelationID, "-1") {
+		t.Fatalf("correlation ID = %q", captured.CorrelationID)
+	}
+	if got := resp.Header.Get("X-Trace-Proxy"); got != captured.CorrelationID {
+		t.Errorf("response header = %q, want %q", got, captured.CorrelationID)
+	}
+	if got := <-forwarded; got != captured.CorrelationID {
+		t.Errorf("forwarded header = %q", got)
+	}
+	if captured.ClientRequestID != "app-1" || captured.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("client IDs = %q %q", captured.ClientRequestID, captured.TraceID)
+	}
+
+	// A cache hit gets its own ID, not the one stored with the cached response
+	resp = send("app-2")
+	second := waitForRequest(t, 2, 2*time.Second)
+	if !second.CachedResponse {
+		t.Fatal("second request wasn't served from cache")
+	}
+	if got := resp.Header.Values("X-Trace-Proxy"); len(got) != 1 || got[0] != second.CorrelationID {
+		t.Errorf("cache hit header = %q, want %q", got, second.CorrelationID)
+	}
+}
+
+func TestInspectByCorrelationID(t *testing.T) {
+	t.Setenv(sessionHistoryDirEnv, t.TempDir())
+
+	history, err := NewSessionHistory("sess-corr", ":8080", "https://api.openai.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, clientID := range map[int]string{1: "app-1", 2: "app-2"} {
+		history.UpsertRequest(&LLMRequest{
+			ID:              id,
+			Method:          "POST",
+			Path:            "/v1/chat/completions",
+			Status:          StatusComplete,
+			StatusCode:      200,
+			StartTime:       time.Now(),
+			CorrelationID:   formatCorrelationID("sess-corr", id),
+			ClientRequestID: clientID,
+		})
+	}
+
+	// The correlation ID names the session, so --session can be left out
+	var out bytes.Buffer
+	if err := RunInspectCommand(&out, InspectOptions{RequestRef: "sess-corr-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Request:   #2") || !strings.Contains(out.String(), "Client ID: app-2") {
+		t.Errorf("detail = %s", out.String())
+	}
+
+	out.Reset()
+	if err := RunInspectCommand(&out, InspectOptions{SessionID: "sess-corr", RequestRef: "app-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Request:   #1") {
+		t.Errorf("detail = %s", out.String())
+	}
+	if err := RunInspectCommand(&out, InspectOptions{SessionID: "sess-corr", RequestRef: "missing"}); err == nil {
+		t.Error("expected an error for an unknown ID")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
@@ -18,9 +19,20 @@ type ModelCostSummary struct {
 	RequestCount int
 }
 
+// CostGroupSummary holds aggregated cost data for one tag, run or conversation
+type CostGroupSummary struct {
+	Group        string
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	RequestCount int
+}
+
 // TapeCostBreakdown holds the complete cost analysis
 type TapeCostBreakdown struct {
 	Models            map[string]*ModelCostSummary
+	GroupBy           CostGroupBy
+	Groups            []*CostGroupSummary // Sorted by cost; nil when grouped by model
 	TotalInputTokens  int
 	TotalOutputTokens int
 	TotalCost         float64
@@ -36,11 +48,14 @@ type ProxyCostSummary struct {
 	RequestCount int
 }
 
-// AnalyzeRequestsCosts processes a slice of requests and returns cost breakdown
-func AnalyzeRequestsCosts(requests []*LLMRequest) *TapeCostBreakdown {
+// AnalyzeRequestsCosts processes a slice of requests and returns cost breakdown.
+// Models is always filled; Groups is filled when groupBy is a client label.
+func AnalyzeRequestsCosts(requests []*LLMRequest, groupBy CostGroupBy) *TapeCostBreakdown {
 	breakdown := &TapeCostBreakdown{
-		Models: make(map[string]*ModelCostSummary),
+		Models:  make(map[string]*ModelCostSummary),
+		GroupBy: groupBy,
 	}
+	groupMap := make(map[string]*CostGroupSummary)
 
 	for _, req := range requests {
 		// Skip incomplete requests
@@ -69,14 +84,39 @@ func AnalyzeRequestsCosts(requests []*LLMRequest) *TapeCostBreakdown {
 		breakdown.TotalOutputTokens += req.OutputTokens
 		breakdown.TotalCost += req.Cost
 		breakdown.TotalRequests++
+
+		if groupBy == "" || groupBy == GroupByModel {
+			continue
+		}
+		for _, key := range costGroupKeys(req, groupBy) {
+			group, exists := groupMap[key]
+			if !exists {
+				group = &CostGroupSummary{Group: key}
+				groupMap[key] = group
+			}
+			group.InputTokens += req.InputTokens
+			group.OutputTokens += req.OutputTokens
+			group.Cost += req.Cost
+			group.RequestCount++
+		}
+	}
+
+	for _, group := range groupMap {
+		breakdown.Groups = append(breakdown.Groups, group)
 	}
+	sort.Slice(breakdown.Groups, func(i, j int) bool {
+		if breakdown.Groups[i].Cost != breakdown.Groups[j].Cost {
+			return breakdown.Groups[i].Cost > breakdown.Groups[j].Cost
+		}
+		return breakdown.Groups[i].Group < breakdown.Groups[j].Group
+	})
 
 	return breakdown
 }
 
 // AnalyzeTapeCosts processes a tape and returns cost breakdown
-func AnalyzeTapeCosts(tape *Tape) *TapeCostBreakdown {
-	return AnalyzeRequestsCosts(tape.Requests)
+func AnalyzeTapeCosts(tape *Tape, groupBy CostGroupBy) *TapeCostBreakdown {
+	return AnalyzeRequestsCosts(tape.Requests, groupBy)
 }
 
 // AnalyzeProxyCosts processes requests and returns cost breakdown by proxy
@@ -88,10 +128,7 @@ func AnalyzeProxyCosts(requests []*LLMRequest) []*ProxyCostSummary {
 			continue
 		}
 
-		proxy := req.ProxyName
-		if proxy == "" {
-			proxy = "default"
-		}
+		proxy := req.ProxyLabel()
 
 		if _, exists := proxyMap[proxy]; !exists {
 			proxyMap[proxy] = &ProxyCostSummary{Proxy: proxy}
@@ -158,25 +195,38 @@ func PrintCostBreakdown(breakdown *TapeCostBreakdown, tapePath string) {
 		Bold(true).
 		Padding(0, 1)
 
-	// Sort models by cost (descending)
-	var sortedModels []*ModelCostSummary
-	for _, summary := range breakdown.Models {
-		sortedModels = append(sortedModels, summary)
-	}
-	sort.Slice(sortedModels, func(i, j int) bool {
-		return sortedModels[i].Cost > sortedModels[j].Cost
-	})
-
-	// Build table rows
+	// Build table rows, by model unless another grouping was asked for
 	var rows [][]string
-	for _, summary := range sortedModels {
-		rows = append(rows, []string{
-			summary.Model,
-			fmt.Sprintf("%d", summary.RequestCount),
-			formatWithCommas(summary.InputTokens),
-			formatWithCommas(summary.OutputTokens),
-			formatCost(summary.Cost),
+	groupHeader := "MODEL"
+	if breakdown.GroupBy != "" && breakdown.GroupBy != GroupByModel {
+		groupHeader = strings.ToUpper(string(breakdown.GroupBy))
+		for _, summary := range breakdown.Groups {
+			rows = append(rows, []string{
+				summary.Group,
+				fmt.Sprintf("%d", summary.RequestCount),
+				formatWithCommas(summary.InputTokens),
+				formatWithCommas(summary.OutputTokens),
+				formatCost(summary.Cost),
+			})
+		}
+	} else {
+		// Sort models by cost (descending)
+		var sortedModels []*ModelCostSummary
+		for _, summary := range breakdown.Models {
+			sortedModels = append(sortedModels, summary)
+		}
+		sort.Slice(sortedModels, func(i, j int) bool {
+			return sortedModels[i].Cost > sortedModels[j].Cost
 		})
+		for _, summary := range sortedModels {
+			rows = append(rows, []string{
+				summary.Model,
+				fmt.Sprintf("%d", summary.RequestCount),
+				formatWithCommas(summary.InputTokens),
+				formatWithCommas(summary.OutputTokens),
+				formatCost(summary.Cost),
+			})
+		}
 	}
 
 	// Create the table
@@ -189,7 +239,7 @@ func PrintCostBreakdown(breakdown *TapeCostBreakdown, tapePath string) {
 			}
 			return cellStyle
 		}).
-		Headers("MODEL", "REQS", "INPUT TOKENS", "OUTPUT TOKENS", "COST").
+		Headers(groupHeader, "REQS", "INPUT TOKENS", "OUTPUT TOKENS", "COST").
 		Rows(rows...)
 
 	// Print the output
@@ -197,6 +247,9 @@ func PrintCostBreakdown(breakdown *TapeCostBreakdown, tapePath string) {
 	fmt.Println(titleStyle.Render(fmt.Sprintf("📊 Cost Breakdown: %s", tapePath)))
 	fmt.Println()
 	fmt.Println(t)
+	if breakdown.GroupBy == GroupByTag {
+		fmt.Println(cellStyle.Render("Requests with several tags count toward each of them."))
+	}
 	fmt.Println()
 
 	// Print totals
@@ -223,7 +276,13 @@ func PrintCostBreakdown(breakdown *TapeCostBreakdown, tapePath string) {
 }
 
 // RunCostCommand runs the cost breakdown command
-func RunCostCommand(tapePath string) {
+func RunCostCommand(tapePath string, groupBy string) {
+	group, ok := parseCostGroupBy(groupBy)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Invalid --group-by %q (use model, tag, run or conversation)\n", groupBy)
+		os.Exit(1)
+	}
+
 	tape, err := LoadTape(tapePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading tape: %v\n", err)
@@ -235,6 +294,6 @@ func RunCostCommand(tapePath string) {
 		os.Exit(1)
 	}
 
-	breakdown := AnalyzeTapeCosts(tape)
+	breakdown := AnalyzeTapeCosts(tape, group)
 	PrintCostBreakdown(breakdown, tapePath)
 }
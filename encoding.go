@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Captured bodies are stored decoded so parsing, search, the cache and
+// export see plain JSON. The client still receives the encoded bytes.
+
+// decodeBody reverses a body's Content-Encoding. Stacked encodings
+// ("gzip, br") are undone in reverse order. A stream that was cut short
+// decodes to its readable prefix; a body that can't be decoded at all is
+// returned as-is. Either way the error says which coding failed.
+func decodeBody(data []byte, contentEncoding string) ([]byte, error) {
+	if contentEncoding == "" || len(data) == 0 {
+		return data, nil
+	}
+	var firstErr error
+	decoded := data
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		out, err := decodeOne(decoded, coding)
+		if err != nil {
+			if len(out) == 0 {
+				return data, fmt.Errorf("%s: %w", coding, err)
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", coding, err)
+			}
+		}
+		decoded = out
+	}
+	return decoded, firstErr
+}
+
+// decodeOne removes a single content coding
+func decodeOne(data []byte, coding string) ([]byte, error) {
+	var reader io.Reader
+	switch coding {
+	case "", "identity":
+		return data, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(data))
+	case "zstd":
+		dec, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		reader = dec
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send raw
+		// deflate; fall back to that when the zlib header is missing
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			fr := flate.NewReader(bytes.NewReader(data))
+			defer fr.Close()
+			reader = fr
+		} else {
+			defer zr.Close()
+			reader = zr
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding")
+	}
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return decoded, err
+	}
+	return decoded, nil
+}
+
+// decompressIfNeeded decodes a body for display and parsing, falling back to
+// the raw bytes when it can't be decoded
+func decompressIfNeeded(data []byte, contentEncoding string) []byte {
+	decoded, _ := decodeBody(data, contentEncoding)
+	return decoded
+}
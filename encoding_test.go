@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encodeBody applies a single content coding
+func encodeBody(t *testing.T, data []byte, coding string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		t.Fatalf("unknown coding %q", coding)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	body := []byte(`{"id":"chatcmpl-1","usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+
+	for _, coding := range []string{"gzip", "br", "zstd", "deflate"} {
+		got, err := decodeBody(encodeBody(t, body, coding), coding)
+		if err != nil || !bytes.Equal(got, body) {
+			t.Errorf("%s: got %q, %v", coding, got, err)
+		}
+	}
+
+	// Servers that send raw deflate for "deflate"
+	if got, err := decodeBody(encodeBody(t, body, "raw-deflate"), "deflate"); err != nil || !bytes.Equal(got, body) {
+		t.Errorf("raw deflate: got %q, %v", got, err)
+	}
+
+	// Stacked: gzip applied first, then br
+	stacked := encodeBody(t, encodeBody(t, body, "gzip"), "br")
+	if got, err := decodeBody(stacked, "gzip, br"); err != nil || !bytes.Equal(got, body) {
+		t.Errorf("stacked: got %q, %v", got, err)
+	}
+
+	// A truncated stream decodes to its prefix and reports the error
+	long := bytes.Repeat([]byte("data: {\"choices\":[]}\n\n"), 500)
+	encoded := encodeBody(t, long, "gzip")
+	got, err := decodeBody(encoded[:len(encoded)/2], "gzip")
+	if err == nil || len(got) == 0 || !bytes.HasPrefix(long, got) {
+		t.Errorf("truncated: %d bytes, err %v", len(got), err)
+	}
+
+	// Unknown codings and garbage are left as-is with an error
+	if got, err := decodeBody(body, "compress"); err == nil || !bytes.Equal(got, body) {
+		t.Errorf("unsupported: got %q, %v", got, err)
+	}
+	if got, err := decodeBody([]byte("not brotli"), "br"); err == nil || string(got) != "not brotli" {
+		t.Errorf("garbage: got %q, %v", got, err)
+	}
+}
+
+func TestBrotliResponseProxyIntegration(t *testing.T) {
+	resetTestState()
+	seedModelsDB(t, map[string]Provider{}, map[string]ModelCost{})
+
+	body := []byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "broken") {
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write([]byte("definitely not zstd"))
+			return
+		}
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(encodeBody(t, body, "br"))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-encoding", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, path := range []string{"/v1/chat/completions", "/broken/v1/chat/completions"} {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d%s", port, path), strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+		req.Header.Set("Accept-Encoding", "br, zstd")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if !bytes.Equal(captured.ResponseBody, body) || captured.DecodeError != "" {
+		t.Errorf("body = %q, decode error %q", captured.ResponseBody, captured.DecodeError)
+	}
+	deadline := time.Now().Add(time.Second)
+	for captured.OutputTokens == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if captured.InputTokens != 12 || captured.OutputTokens != 3 {
+		t.Errorf("tokens = %d/%d, want 12/3", captured.InputTokens, captured.OutputTokens)
+	}
+
+	broken := waitForRequest(t, 2, 2*time.Second)
+	if !strings.HasPrefix(broken.DecodeError, "zstd:") || string(broken.ResponseBody) != "definitely not zstd" {
+		t.Errorf("broken body = %q, decode error %q", broken.ResponseBody, broken.DecodeError)
+	}
+}
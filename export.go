@@ -125,8 +125,8 @@ func (m *model) exportChat() {
 }
 
 func extractOpenAIExportMessages(req *LLMRequest, exportDir string, imageCounter int) ([]ExportMessage, int) {
-	var oaiReq OpenAIRequest
-	if err := json.Unmarshal(req.RequestBody, &oaiReq); err != nil {
+	oaiReq, err := decodeOpenAIRequest(req.Path, req.RequestBody)
+	if err != nil {
 		return nil, imageCounter
 	}
 
@@ -181,18 +181,9 @@ func extractOpenAIExportMessages(req *LLMRequest, exportDir string, imageCounter
 }
 
 func extractOpenAIResponseExportMessages(req *LLMRequest, exportDir string, imageCounter int) ([]ExportMessage, int) {
-	responseBody := req.ResponseBody
-	var resp OpenAIResponse
-	if err := json.Unmarshal(responseBody, &resp); err != nil {
-		if isSSEData(responseBody) {
-			if assembled := reassembleSSEResponse(responseBody); assembled != nil {
-				resp = *assembled
-			} else {
-				return nil, imageCounter
-			}
-		} else {
-			return nil, imageCounter
-		}
+	resp := decodeOpenAIResponse(req.Path, req.ResponseBody)
+	if resp == nil {
+		return nil, imageCounter
 	}
 
 	var messages []ExportMessage
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault types
+const (
+	FaultLatency  = "latency"  // Delay before forwarding upstream
+	FaultStatus   = "status"   // Answer with an error status and provider-shaped body
+	FaultReset    = "reset"    // Drop the client connection without a response
+	FaultStall    = "stall"    // Pause a stream after N events
+	FaultTruncate = "truncate" // Cut a stream after N events
+)
+
+// FaultConfig is a fault-injection rule for a proxy
+type FaultConfig struct {
+	Name        string  `toml:"name"`         // Rule name shown in the TUI
+	Type        string  `toml:"type"`         // latency, status, reset, stall, or truncate
+	Model       string  `toml:"model"`        // Glob matched against the request model (empty = any)
+	PathPrefix  string  `toml:"path_prefix"`  // Request path prefix (empty = any)
+	Probability float64 `toml:"probability"`  // Percent chance the rule fires (0-100)
+	Status      int     `toml:"status"`       // Status code for "status" faults (default 500)
+	Latency     string  `toml:"latency"`      // Delay for "latency", pause length for "stall" (empty = until the client gives up)
+	AfterEvents int     `toml:"after_events"` // SSE events sent before a stall or truncate (default 1)
+}
+
+// faultRule is the parsed form of FaultConfig
+type faultRule struct {
+	cfg     FaultConfig
+	latency time.Duration
+}
+
+// parseFaultRules validates fault rules from the config
+func parseFaultRules(configs []FaultConfig) ([]*faultRule, error) {
+	var rules []*faultRule
+	for i, cfg := range configs {
+		label := cfg.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+
+		rule := &faultRule{cfg: cfg}
+		switch cfg.Type {
+		case FaultLatency, FaultStall:
+			if cfg.Latency != "" {
+				d, err := time.ParseDuration(cfg.Latency)
+				if err != nil {
+					return nil, fmt.Errorf("fault %s: invalid latency: %w", label, err)
+				}
+				rule.latency = d
+			} else if cfg.Type == FaultLatency {
+				return nil, fmt.Errorf("fault %s: latency is required", label)
+			}
+		case FaultStatus:
+			if rule.cfg.Status == 0 {
+				rule.cfg.Status = http.StatusInternalServerError
+			}
+			if rule.cfg.Status < 400 || rule.cfg.Status > 599 {
+				return nil, fmt.Errorf("fault %s: status must be 4xx or 5xx", label)
+			}
+		case FaultReset, FaultTruncate:
+		default:
+			return nil, fmt.Errorf("fault %s: invalid type %q (expected latency|status|reset|stall|truncate)", label, cfg.Type)
+		}
+		if cfg.Probability < 0 || cfg.Probability > 100 {
+			return nil, fmt.Errorf("fault %s: probability must be between 0 and 100", label)
+		}
+		if rule.cfg.AfterEvents <= 0 {
+			rule.cfg.AfterEvents = 1
+		}
+		if rule.cfg.Name == "" {
+			rule.cfg.Name = cfg.Type
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matches reports whether the rule applies to the request (ignoring probability)
+func (f *faultRule) matches(path, model string, streaming bool) bool {
+	if f.cfg.Model != "" && !globMatchFold(f.cfg.Model, model) {
+		return false
+	}
+	if f.cfg.PathPrefix != "" && !strings.HasPrefix(path, f.cfg.PathPrefix) {
+		return false
+	}
+	// Stream faults only make sense for streaming responses
+	if (f.cfg.Type == FaultStall || f.cfg.Type == FaultTruncate) && !streaming {
+		return false
+	}
+	return true
+}
+
+// description is the label recorded on the request, e.g. "status 429 (flaky)"
+func (f *faultRule) description() string {
+	var what string
+	switch f.cfg.Type {
+	case FaultLatency:
+		what = "latency " + formatDuration(f.latency)
+	case FaultStatus:
+		what = fmt.Sprintf("status %d", f.cfg.Status)
+	case FaultStall:
+		if f.latency > 0 {
+			what = fmt.Sprintf("stall %s after %d events", formatDuration(f.latency), f.cfg.AfterEvents)
+		} else {
+			what = fmt.Sprintf("stall after %d events", f.cfg.AfterEvents)
+		}
+	case FaultTruncate:
+		what = fmt.Sprintf("truncate after %d events", f.cfg.AfterEvents)
+	default:
+		what = f.cfg.Type
+	}
+	if f.cfg.Name != "" && f.cfg.Name != f.cfg.Type {
+		what += " (" + f.cfg.Name + ")"
+	}
+	return what
+}
+
+// pickFault returns the first matching rule that fires, or nil
+func pickFault(rules []*faultRule, path, model string, streaming bool) *faultRule {
+	for _, rule := range rules {
+		if !rule.matches(path, model, streaming) {
+			continue
+		}
+		if rand.Float64()*100 < rule.cfg.Probability {
+			return rule
+		}
+	}
+	return nil
+}
+
+// resetConnection drops the client connection, using a TCP reset when possible
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// errFaultTruncated is returned by faultWriter once a truncate fault fires
+var errFaultTruncated = errors.New("stream truncated by injected fault")
+
+// faultWriter applies stall and truncate faults to a streaming response.
+// It counts SSE events (blank-line separated) as they pass through.
+type faultWriter struct {
+	http.ResponseWriter
+	rule      *faultRule
+	done      <-chan struct{} // Client context, ends an open-ended stall
+	mu        sync.Mutex
+	events    int
+	fired     bool
+	truncated bool
+}
+
+func (fw *faultWriter) Write(b []byte) (int, error) {
+	fw.mu.Lock()
+	if fw.truncated {
+		fw.mu.Unlock()
+		return 0, errFaultTruncated
+	}
+	if fw.fired {
+		fw.mu.Unlock()
+		return fw.ResponseWriter.Write(b)
+	}
+
+	// Write up to the event boundary that triggers the fault
+	remaining := fw.rule.cfg.AfterEvents - fw.events
+	cut := -1
+	offset := 0
+	for remaining > 0 {
+		idx := bytes.Index(b[offset:], []byte("\n\n"))
+		if idx < 0 {
+			break
+		}
+		offset += idx + 2
+		remaining--
+		fw.events++
+		if remaining == 0 {
+			cut = offset
+		}
+	}
+	if cut < 0 {
+		fw.mu.Unlock()
+		return fw.ResponseWriter.Write(b)
+	}
+	fw.fired = true
+	fw.mu.Unlock()
+
+	n, err := fw.ResponseWriter.Write(b[:cut])
+	if err != nil {
+		return n, err
+	}
+	fw.Flush()
+
+	if fw.rule.cfg.Type == FaultTruncate {
+		fw.mu.Lock()
+		fw.truncated = true
+		fw.mu.Unlock()
+		return n, errFaultTruncated
+	}
+
+	// Stall: hold the stream, then continue with the rest of the chunk
+	if fw.rule.latency > 0 {
+		timer := time.NewTimer(fw.rule.latency)
+		select {
+		case <-timer.C:
+		case <-fw.done:
+			timer.Stop()
+		}
+	} else {
+		<-fw.done
+	}
+	m, err := fw.ResponseWriter.Write(b[cut:])
+	return n + m, err
+}
+
+// Flush implements http.Flusher so SSE keeps streaming through the fault layer
+func (fw *faultWriter) Flush() {
+	if f, ok := fw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// isTruncated reports whether a truncate fault cut the stream
+func (fw *faultWriter) isTruncated() bool {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.truncated
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Proxy modes
+const (
+	ProxyModeReverse = "reverse" // Clients point their base URL at the proxy (default)
+	ProxyModeForward = "forward" // Clients use the proxy via HTTPS_PROXY / HTTP CONNECT
+)
+
+// knownLLMHosts are decrypted by forward proxies; everything else is tunneled
+var knownLLMHosts = []string{
+	"api.openai.com",
+	"*.openai.azure.com",
+	"api.anthropic.com",
+	"generativelanguage.googleapis.com",
+	"aiplatform.googleapis.com",
+	"*-aiplatform.googleapis.com",
+	"bedrock-runtime.*.amazonaws.com",
+	"api.mistral.ai",
+	"api.groq.com",
+	"api.together.xyz",
+	"api.fireworks.ai",
+	"api.deepseek.com",
+	"api.x.ai",
+	"api.perplexity.ai",
+	"api.cohere.com",
+	"api.cohere.ai",
+	"openrouter.ai",
+}
+
+// forwardTransport dials upstreams directly, so a forward proxy started from a
+// shell with HTTPS_PROXY set never sends traffic back through itself
+var forwardTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	return t
+}()
+
+// forwardProxy handles HTTP CONNECT and absolute-form requests. Connections to
+// LLM hosts are decrypted with certificates minted by the local CA and passed
+// through the regular capture handler.
+type forwardProxy struct {
+	name        string
+	listen      string
+	ca          *CertAuthority
+	intercept   []string // Extra host globs to decrypt
+	retry       RetryConfig
+	faults      []*faultRule
+	cacheIgnore []string // Request fields left out of cache keys
+
+	mu       sync.Mutex
+	handlers map[string]http.Handler // Upstream base URL -> capture handler
+}
+
+// newForwardProxy loads (or creates) the local CA and builds a forward proxy
+func newForwardProxy(cfg ProxyConfig, name string, faults []*faultRule) (*forwardProxy, error) {
+	ca, err := LoadOrCreateCA(caDirectory())
+	if err != nil {
+		return nil, err
+	}
+	return &forwardProxy{
+		name:        name,
+		listen:      cfg.Listen,
+		ca:          ca,
+		intercept:   cfg.Intercept,
+		retry:       cfg.Retry,
+		faults:      faults,
+		cacheIgnore: cfg.CacheIgnoreFields,
+		handlers:    make(map[string]http.Handler),
+	}, nil
+}
+
+// shouldIntercept reports whether CONNECT traffic to host should be decrypted
+func (fp *forwardProxy) shouldIntercept(host string) bool {
+	for _, pattern := range knownLLMHosts {
+		if globMatchFold(pattern, host) {
+			return true
+		}
+	}
+	for _, pattern := range fp.intercept {
+		if globMatchFold(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlerFor returns the capture handler for an upstream, creating it on first use
+func (fp *forwardProxy) handlerFor(scheme, host, port string) (http.Handler, error) {
+	base := scheme + "://" + host
+	if port != "" && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+		base = scheme + "://" + net.JoinHostPort(host, port)
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	if h, ok := fp.handlers[base]; ok {
+		return h, nil
+	}
+
+	up, err := newUpstream(host, base, fp.retry, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rt, ok := up.proxy.Transport.(*retryTransport); ok {
+		rt.base = forwardTransport
+	} else {
+		up.proxy.Transport = forwardTransport
+	}
+
+	h := createProxyHandler(&proxyRuntime{
+		name:        fp.name,
+		listen:      fp.listen,
+		router:      &router{fallback: up},
+		faults:      fp.faults,
+		cacheIgnore: fp.cacheIgnore,
+	})
+	fp.handlers[base] = h
+	return h, nil
+}
+
+func (fp *forwardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		fp.handleConnect(w, r)
+		return
+	}
+
+	// Plain HTTP through the proxy arrives in absolute form
+	if !r.URL.IsAbs() || r.URL.Scheme != "http" {
+		http.Error(w, "llmproxy: forward proxy expects CONNECT or absolute http:// requests", http.StatusBadRequest)
+		return
+	}
+	handler, err := fp.handlerFor("http", r.URL.Hostname(), r.URL.Port())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// handleConnect either decrypts the tunnel (LLM hosts) or splices it to the
+// upstream untouched
+func (fp *forwardProxy) handleConnect(w http.ResponseWriter, r *http.Request) {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, "443"
+	}
+	intercept := fp.shouldIntercept(host)
+
+	// Dial opaque tunnels before answering so failures surface as a 502
+	var upstreamConn net.Conn
+	if !intercept {
+		upstreamConn, err = net.DialTimeout("tcp", net.JoinHostPort(host, port), 10*time.Second)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("llmproxy: failed to connect to %s: %v", r.Host, err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		if upstreamConn != nil {
+			upstreamConn.Close()
+		}
+		http.Error(w, "llmproxy: connection cannot be hijacked", http.StatusInternalServerError)
+		return
+	}
+	clientConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		if upstreamConn != nil {
+			upstreamConn.Close()
+		}
+		return
+	}
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		clientConn.Close()
+		if upstreamConn != nil {
+			upstreamConn.Close()
+		}
+		return
+	}
+	conn := &bufferedConn{Conn: clientConn, r: brw.Reader}
+
+	if !intercept {
+		tunnel(conn, upstreamConn)
+		return
+	}
+	fp.serveIntercepted(conn, host, port)
+}
+
+// serveIntercepted terminates TLS with a minted certificate and serves the
+// decrypted HTTP/1.1 requests through the capture handler
+func (fp *forwardProxy) serveIntercepted(conn net.Conn, host, port string) {
+	handler, err := fp.handlerFor("https", host, port)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = host
+			}
+			return fp.ca.LeafCertificate(name)
+		},
+		NextProtos: []string{"http/1.1"},
+	})
+	tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		// Usually the client does not trust the CA yet
+		log.Printf("[%s] TLS handshake with client for %s failed: %v", fp.name, host, err)
+		tlsConn.Close()
+		return
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	listener := newSingleConnListener(tlsConn)
+	server := &http.Server{
+		Handler:  handler,
+		ErrorLog: log.New(io.Discard, "", 0),
+		ConnState: func(c net.Conn, state http.ConnState) {
+			if state == http.StateClosed || state == http.StateHijacked {
+				listener.Close()
+			}
+		},
+	}
+	server.Serve(listener)
+}
+
+// tunnel copies bytes in both directions until either side closes
+func tunnel(client, upstream net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		if tcp, ok := upstream.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	client.Close()
+	upstream.Close()
+}
+
+// bufferedConn reads through the bufio.Reader left over from hijacking, so
+// bytes the client sent early are not lost
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// singleConnListener hands one connection to http.Server.Serve, then blocks
+// until it is closed so Serve returns once the connection is done
+type singleConnListener struct {
+	conn      net.Conn
+	accepted  bool
+	mu        sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	return &singleConnListener{conn: conn, done: make(chan struct{})}
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if !l.accepted {
+		l.accepted = true
+		l.mu.Unlock()
+		return l.conn, nil
+	}
+	l.mu.Unlock()
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
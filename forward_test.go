@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestForwardProxy starts a forward proxy with its CA in a temp dir and
+// returns the proxy URL and a cert pool trusting the CA
+func startTestForwardProxy(t *testing.T, intercept ...string) (*url.URL, *x509.CertPool) {
+	t.Helper()
+	caDir := t.TempDir()
+	t.Setenv(caDirEnv, caDir)
+
+	port := getFreePort(t)
+	cfg := ProxyConfig{Name: "fwd", Listen: fmt.Sprintf(":%d", port), Mode: ProxyModeForward, Intercept: intercept}
+	if err := StartProxyFromConfig(cfg); err != nil {
+		t.Fatalf("StartProxyFromConfig error: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	caPEM, err := os.ReadFile(filepath.Join(caDir, caCertFile))
+	if err != nil {
+		t.Fatalf("CA certificate was not written: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatal("failed to parse generated CA certificate")
+	}
+
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://localhost:%d", port))
+	return proxyURL, pool
+}
+
+func TestForwardProxyInterceptsLLMHosts(t *testing.T) {
+	resetTestState()
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	// Trust the test upstream's self-signed certificate for the proxy's outbound leg
+	original := forwardTransport
+	forwardTransport = upstream.Client().Transport.(*http.Transport).Clone()
+	forwardTransport.Proxy = nil
+	defer func() { forwardTransport = original }()
+
+	proxyURL, pool := startTestForwardProxy(t, "127.0.0.1")
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}}
+
+	resp, err := client.Post(upstream.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request through forward proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"content":"hi"`) {
+		t.Fatalf("response = %d %s", resp.StatusCode, body)
+	}
+	if resp.TLS == nil || resp.TLS.PeerCertificates[0].Issuer.CommonName != "llmproxy-go local CA" {
+		t.Error("client should see a certificate minted by the local CA")
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.ProxyName != "fwd" || captured.Model != "gpt-4o" {
+		t.Errorf("captured proxy/model = %s/%s", captured.ProxyName, captured.Model)
+	}
+	if !strings.HasPrefix(captured.URL, "https://127.0.0.1:") {
+		t.Errorf("captured URL = %s, want https upstream", captured.URL)
+	}
+	if captured.InputTokens != 3 {
+		t.Errorf("InputTokens = %d, want 3", captured.InputTokens)
+	}
+}
+
+func TestForwardProxyTunnelsOtherHosts(t *testing.T) {
+	resetTestState()
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer upstream.Close()
+
+	proxyURL, _ := startTestForwardProxy(t)
+	transport := upstream.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+
+	resp, err := (&http.Client{Transport: transport}).Get(upstream.URL + "/v1/chat/completions")
+	if err != nil {
+		t.Fatalf("Tunneled request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// The upstream's own certificate proves the tunnel was not decrypted
+	if string(body) != "direct" || resp.TLS.PeerCertificates[0].Issuer.CommonName == "llmproxy-go local CA" {
+		t.Errorf("tunnel response = %q, issuer %q", body, resp.TLS.PeerCertificates[0].Issuer.CommonName)
+	}
+
+	requestsMu.RLock()
+	captured := len(requests)
+	requestsMu.RUnlock()
+	if captured != 0 {
+		t.Errorf("tunneled traffic should not be captured, got %d requests", captured)
+	}
+}
+
+func TestLoadOrCreateCAPersists(t *testing.T) {
+	dir := t.TempDir()
+	first, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.cert.Equal(second.cert) {
+		t.Error("second load should reuse the persisted CA")
+	}
+
+	leaf, err := second.LeafCertificate("api.openai.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(first.cert)
+	if _, err := leaf.Leaf.Verify(x509.VerifyOptions{DNSName: "api.openai.com", Roots: pool}); err != nil {
+		t.Errorf("leaf does not verify against the CA: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Native Gemini API methods, e.g. /v1beta/models/gemini-2.5-flash:generateContent.
+// Vertex AI uses the same methods under /publishers/google/models/.
+const (
+	geminiGenerateMethod = ":generateContent"
+	geminiStreamMethod   = ":streamGenerateContent"
+)
+
+// geminiUsageMetadata is Gemini's usage block. Thinking tokens are billed as
+// output but reported separately from candidatesTokenCount, and cached tokens
+// are a subset of promptTokenCount.
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+}
+
+// apply copies the non-zero counts onto req
+func (u geminiUsageMetadata) apply(req *LLMRequest) {
+	if u.PromptTokenCount > 0 {
+		req.InputTokens = u.PromptTokenCount
+	}
+	if output := u.CandidatesTokenCount + u.ThoughtsTokenCount; output > 0 {
+		req.OutputTokens = output
+	}
+	if u.CachedContentTokenCount > 0 {
+		req.CachedInputTokens = u.CachedContentTokenCount
+	}
+}
+
+// isGeminiNativeEndpoint returns true for generateContent/streamGenerateContent paths
+func isGeminiNativeEndpoint(path string) bool {
+	return strings.Contains(path, geminiGenerateMethod) || strings.Contains(path, geminiStreamMethod)
+}
+
+// isGeminiStreamEndpoint returns true for streamGenerateContent, which streams
+// regardless of the request body
+func isGeminiStreamEndpoint(path string) bool {
+	return strings.Contains(path, geminiStreamMethod)
+}
+
+// geminiModelFromPath extracts the model from a native Gemini path. Gemini
+// requests don't carry a model field in the body.
+func geminiModelFromPath(path string) string {
+	idx := strings.LastIndex(path, "/models/")
+	if idx < 0 {
+		return ""
+	}
+	model := path[idx+len("/models/"):]
+	if colon := strings.Index(model, ":"); colon >= 0 {
+		model = model[:colon]
+	}
+	return model
+}
+
+// isJSONArrayStream returns true if the data is a JSON array, which is how
+// streamGenerateContent responds without ?alt=sse
+func isJSONArrayStream(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// isGeminiStreamData returns true if the data is an SSE or JSON array stream
+func isGeminiStreamData(data []byte) bool {
+	return isSSEData(data) || isJSONArrayStream(data)
+}
+
+// geminiStreamChunks splits a Gemini stream into its JSON chunks. A JSON array
+// that is still being received yields the chunks that are complete so far.
+func geminiStreamChunks(data []byte) []json.RawMessage {
+	var chunks []json.RawMessage
+
+	if isJSONArrayStream(data) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if _, err := dec.Token(); err != nil {
+			return nil
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				break
+			}
+			chunks = append(chunks, raw)
+		}
+		return chunks
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		jsonData := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if jsonData == "" || jsonData == "[DONE]" {
+			continue
+		}
+		chunks = append(chunks, json.RawMessage(jsonData))
+	}
+	return chunks
+}
+
+// reassembleGeminiSSE reassembles Gemini streaming chunks (SSE or JSON array)
+// into a single JSON response. Text is merged per candidate while thoughts and
+// function calls stay separate parts; usage comes from the last chunk.
+func reassembleGeminiSSE(data []byte) []byte {
+	var final map[string]interface{}
+	parts := make(map[int][]map[string]interface{})
+	finishReasons := make(map[int]interface{})
+	maxIdx := -1
+
+	for _, raw := range geminiStreamChunks(data) {
+		var chunk map[string]interface{}
+		if json.Unmarshal(raw, &chunk) != nil {
+			continue
+		}
+		final = chunk
+
+		candidates, _ := chunk["candidates"].([]interface{})
+		for pos, c := range candidates {
+			candidate, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			idx := pos
+			if i, ok := candidate["index"].(float64); ok {
+				idx = int(i)
+			}
+			if idx > maxIdx {
+				maxIdx = idx
+			}
+			if reason, ok := candidate["finishReason"]; ok {
+				finishReasons[idx] = reason
+			}
+			content, _ := candidate["content"].(map[string]interface{})
+			chunkParts, _ := content["parts"].([]interface{})
+			for _, p := range chunkParts {
+				if part, ok := p.(map[string]interface{}); ok {
+					parts[idx] = appendGeminiPart(parts[idx], part)
+				}
+			}
+		}
+	}
+
+	if final == nil {
+		return nil
+	}
+
+	candidates := make([]interface{}, 0, maxIdx+1)
+	for idx := 0; idx <= maxIdx; idx++ {
+		candidate := map[string]interface{}{
+			"index":   idx,
+			"content": map[string]interface{}{"role": "model", "parts": parts[idx]},
+		}
+		if reason, ok := finishReasons[idx]; ok {
+			candidate["finishReason"] = reason
+		}
+		candidates = append(candidates, candidate)
+	}
+	final["candidates"] = candidates
+
+	result, err := json.Marshal(final)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// appendGeminiPart merges a text part into the previous one when both are
+// plain text or both are thoughts
+func appendGeminiPart(parts []map[string]interface{}, part map[string]interface{}) []map[string]interface{} {
+	text, isText := part["text"].(string)
+	if isText && len(parts) > 0 {
+		last := parts[len(parts)-1]
+		if lastText, ok := last["text"].(string); ok && last["thought"] == part["thought"] {
+			last["text"] = lastText + text
+			if signature, ok := part["thoughtSignature"]; ok {
+				last["thoughtSignature"] = signature
+			}
+			return parts
+		}
+	}
+	return append(parts, part)
+}
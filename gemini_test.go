@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const geminiArrayStreamFixture = `[{"candidates":[{"content":{"role":"model","parts":[{"text":"Let me think","thought":true}]},"index":0}]}
+,
+{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello "}]},"index":0}]}
+,
+{"candidates":[{"content":{"role":"model","parts":[{"text":"world"},{"functionCall":{"name":"lookup","args":{"q":"x"}}}]},"finishReason":"STOP","index":0}],
+ "usageMetadata":{"promptTokenCount":100,"candidatesTokenCount":20,"thoughtsTokenCount":30,"cachedContentTokenCount":60,"totalTokenCount":150},"modelVersion":"gemini-2.5-flash"}
+]`
+
+func TestGeminiModelFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/v1beta/models/gemini-2.5-flash:generateContent":                                          "gemini-2.5-flash",
+		"/v1beta/models/gemini-2.5-pro:streamGenerateContent":                                      "gemini-2.5-pro",
+		"/v1/projects/p/locations/us-central1/publishers/google/models/gemini-2.0:generateContent": "gemini-2.0",
+		"/v1/chat/completions": "",
+	}
+	for path, want := range tests {
+		if got := geminiModelFromPath(path); got != want {
+			t.Errorf("geminiModelFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestReassembleGeminiJSONArrayStream(t *testing.T) {
+	merged := reassembleGeminiSSE([]byte(geminiArrayStreamFixture))
+	var resp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []map[string]any `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		UsageMetadata geminiUsageMetadata `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(merged, &resp); err != nil {
+		t.Fatalf("merged response is not JSON: %v", err)
+	}
+	if len(resp.Candidates) != 1 {
+		t.Fatalf("got %d candidates, want 1", len(resp.Candidates))
+	}
+	parts := resp.Candidates[0].Content.Parts
+	if len(parts) != 3 || parts[0]["thought"] != true || parts[1]["text"] != "Hello world" || parts[2]["functionCall"] == nil {
+		t.Errorf("merged parts = %v", parts)
+	}
+	if resp.Candidates[0].FinishReason != "STOP" || resp.UsageMetadata.PromptTokenCount != 100 {
+		t.Errorf("finish/usage not taken from the last chunk: %+v", resp)
+	}
+
+	// An array still being received yields the complete chunks so far
+	partial := geminiArrayStreamFixture[:strings.Index(geminiArrayStreamFixture, `{"text":"world"}`)]
+	if chunks := geminiStreamChunks([]byte(partial)); len(chunks) != 2 {
+		t.Errorf("partial stream chunks = %d, want 2", len(chunks))
+	}
+}
+
+func TestGeminiJSONArrayOnlyOnGeminiPaths(t *testing.T) {
+	other := &LLMRequest{Path: "/v1/batches/results", Model: "gpt-4o"}
+	extractTokenUsage(other, []byte(geminiArrayStreamFixture))
+	if other.InputTokens != 0 || other.OutputTokens != 0 {
+		t.Errorf("JSON array from another endpoint was read as Gemini: %d/%d tokens", other.InputTokens, other.OutputTokens)
+	}
+
+	gemini := &LLMRequest{Path: "/v1beta/models/gemini-2.5-flash:streamGenerateContent", Model: "gemini-2.5-flash"}
+	extractTokenUsage(gemini, []byte(geminiArrayStreamFixture))
+	if gemini.InputTokens != 100 || gemini.OutputTokens != 50 {
+		t.Errorf("Gemini tokens = %d/%d, want 100/50", gemini.InputTokens, gemini.OutputTokens)
+	}
+}
+
+func TestGeminiUsageIncludesThoughtsAndCache(t *testing.T) {
+	req := &LLMRequest{Path: "/v1beta/models/gemini-2.5-flash:streamGenerateContent"}
+	extractTokenUsage(req, []byte(geminiArrayStreamFixture))
+	if req.InputTokens != 100 || req.OutputTokens != 50 || req.CachedInputTokens != 60 {
+		t.Errorf("tokens = in %d out %d cached %d, want 100/50/60", req.InputTokens, req.OutputTokens, req.CachedInputTokens)
+	}
+
+	sse := "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"hi\"}]}}],\"usageMetadata\":{\"promptTokenCount\":10,\"candidatesTokenCount\":2,\"thoughtsTokenCount\":5}}\n\n"
+	req = &LLMRequest{}
+	extractTokenUsageFromSSE(req, []byte(sse))
+	if req.InputTokens != 10 || req.OutputTokens != 7 {
+		t.Errorf("SSE tokens = %d/%d, want 10/7", req.InputTokens, req.OutputTokens)
+	}
+
+	cost := &ModelCost{Input: 1, Output: 4, CacheRead: 0.25}
+	got := CalculateCostWithCache(cost, 1_000_000, 600_000, 0)
+	if want := 0.4 + 0.15; math.Abs(got-want) > 1e-9 {
+		t.Errorf("CalculateCostWithCache = %v, want %v", got, want)
+	}
+	if got := CalculateCostWithCache(&ModelCost{Input: 1}, 1_000_000, 600_000, 0); math.Abs(got-1) > 1e-9 {
+		t.Errorf("without a cache price all input is billed normally, got %v", got)
+	}
+}
+
+func TestGeminiNativeProxyIntegration(t *testing.T) {
+	resetTestState()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(geminiArrayStreamFixture))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-gemini", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	url := fmt.Sprintf("http://localhost:%d/v1beta/models/gemini-2.5-flash:streamGenerateContent", port)
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.Model != "gemini-2.5-flash" || !captured.IsStreaming {
+		t.Errorf("captured model = %q streaming = %v", captured.Model, captured.IsStreaming)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for captured.OutputTokens == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if captured.InputTokens != 100 || captured.OutputTokens != 50 {
+		t.Errorf("tokens = %d/%d, want 100/50", captured.InputTokens, captured.OutputTokens)
+	}
+	if snippet := extractStreamingResponsePreviewSnippet(captured.ResponseBody); snippet != "Let me think" {
+		t.Errorf("preview = %q", snippet)
+	}
+}
@@ -15,16 +15,32 @@ type InspectOptions struct {
 	SessionID string
 	Limit     int
 	RequestID int
-	JSON      bool
-	Search    string
-	Model     string
-	Path      string
-	Status    string
-	Code      int
+	// Request number, correlation ID, trace ID or client request ID; a
+	// correlation ID also names the session
+	RequestRef string
+	JSON       bool
+	Search     string
+	Model      string
+	Path       string
+	Status     string
+	Code       int
+	// Client labels (X-LLMProxy-Tag/Run/Conversation), matched exactly
+	Tag          string
+	Run          string
+	Conversation string
 }
 
 // RunInspectCommand prints recent request history for a session.
 func RunInspectCommand(out io.Writer, opts InspectOptions) error {
+	ref := strings.TrimSpace(opts.RequestRef)
+	if ref != "" {
+		if n, err := strconv.Atoi(ref); err == nil {
+			opts.RequestID = n
+			ref = ""
+		} else if sessionID, _, ok := parseCorrelationID(ref); ok && opts.SessionID == "" {
+			opts.SessionID = sessionID
+		}
+	}
 	if strings.TrimSpace(opts.SessionID) == "" {
 		return fmt.Errorf("session ID is required")
 	}
@@ -34,9 +50,18 @@ func RunInspectCommand(out io.Writer, opts InspectOptions) error {
 		return err
 	}
 
-	if opts.RequestID > 0 {
-		req, ok := snapshot.FindRequest(opts.RequestID)
+	if opts.RequestID > 0 || ref != "" {
+		var req SessionHistoryRequest
+		var ok bool
+		if ref != "" {
+			req, ok = snapshot.FindRequestByRef(ref)
+		} else {
+			req, ok = snapshot.FindRequest(opts.RequestID)
+		}
 		if !ok {
+			if ref != "" {
+				return fmt.Errorf("request %q not found in session %s", ref, opts.SessionID)
+			}
 			return fmt.Errorf("request %d not found in session %s", opts.RequestID, opts.SessionID)
 		}
 		if opts.JSON {
@@ -62,6 +87,7 @@ func RunInspectCommand(out io.Writer, opts InspectOptions) error {
 			PID             int                     `json:"pid"`
 			StartedAt       time.Time               `json:"started_at"`
 			UpdatedAt       time.Time               `json:"updated_at"`
+			EndedAt         *time.Time              `json:"ended_at,omitempty"`
 			TotalRequests   int                     `json:"total_requests"`
 			MatchedRequests int                     `json:"matched_requests"`
 			ShownRequests   int                     `json:"shown_requests"`
@@ -74,6 +100,7 @@ func RunInspectCommand(out io.Writer, opts InspectOptions) error {
 			PID:             snapshot.PID,
 			StartedAt:       snapshot.StartedAt,
 			UpdatedAt:       snapshot.UpdatedAt,
+			EndedAt:         snapshot.EndedAt,
 			TotalRequests:   snapshot.RequestCount,
 			MatchedRequests: len(filtered),
 			ShownRequests:   len(recent),
@@ -105,6 +132,9 @@ func renderSummaryTable(
 	fmt.Fprintf(out, "Proxy:   %s -> %s\n", snapshot.ListenAddr, snapshot.TargetURL)
 	fmt.Fprintf(out, "PID:     %d\n", snapshot.PID)
 	fmt.Fprintf(out, "Updated: %s ago\n", age)
+	if snapshot.EndedAt != nil {
+		fmt.Fprintf(out, "Ended:   %s\n", snapshot.EndedAt.Format(time.RFC3339))
+	}
 	if len(filters) > 0 {
 		fmt.Fprintf(out, "Filters: %s\n", formatFilterMap(filters))
 	}
@@ -122,7 +152,7 @@ func renderSummaryTable(
 	}
 
 	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tSTATUS\tCODE\tMODEL\tPATH\tDURATION\tTOKENS\tCOST\tPROXY")
+	fmt.Fprintln(w, "ID\tSTATUS\tCODE\tMODEL\tPATH\tDURATION\tTOKENS\tCOST\tPROXY\tTAGS")
 	for _, req := range requests {
 		duration := "-"
 		if req.DurationMs > 0 {
@@ -150,10 +180,18 @@ func renderSummaryTable(
 		if proxy == "" {
 			proxy = "-"
 		}
+		if req.RouteName != "" {
+			proxy += "/" + req.RouteName
+		}
+
+		tags := formatTagLabel(req.Tags, req.Run, req.Conversation)
+		if tags == "" {
+			tags = "-"
+		}
 
 		fmt.Fprintf(
 			w,
-			"%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			"%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			req.ID,
 			req.StatusText,
 			code,
@@ -163,6 +201,7 @@ func renderSummaryTable(
 			tokens,
 			cost,
 			proxy,
+			tags,
 		)
 	}
 	_ = w.Flush()
@@ -171,10 +210,35 @@ func renderSummaryTable(
 func renderRequestDetail(out io.Writer, snapshot *SessionHistorySnapshot, req SessionHistoryRequest) {
 	fmt.Fprintf(out, "Session:   %s\n", snapshot.SessionID)
 	fmt.Fprintf(out, "Request:   #%d\n", req.ID)
+	if req.CorrelationID != "" {
+		fmt.Fprintf(out, "ID:        %s\n", req.CorrelationID)
+	}
+	if req.TraceID != "" {
+		fmt.Fprintf(out, "Trace:     %s\n", req.TraceID)
+	}
+	if req.ClientRequestID != "" {
+		fmt.Fprintf(out, "Client ID: %s\n", req.ClientRequestID)
+	}
 	fmt.Fprintf(out, "Endpoint:  %s %s\n", req.Method, req.Path)
 	fmt.Fprintf(out, "Model:     %s\n", req.Model)
 	fmt.Fprintf(out, "Status:    %s (%d)\n", req.StatusText, req.StatusCode)
 	fmt.Fprintf(out, "Start:     %s\n", req.StartTime.Format(time.RFC3339))
+	if label := formatTagLabel(req.Tags, req.Run, req.Conversation); label != "" {
+		fmt.Fprintf(out, "Tags:      %s\n", label)
+	}
+	if req.CacheKey != "" {
+		cached := ""
+		if req.CachedResponse {
+			cached = " (hit)"
+		}
+		fmt.Fprintf(out, "Cache Key: %s%s\n", req.CacheKey, cached)
+		if len(req.CacheKeyFields) > 0 {
+			fmt.Fprintf(out, "Keyed On:  %s\n", strings.Join(req.CacheKeyFields, ", "))
+		}
+		if len(req.CacheIgnoredFields) > 0 {
+			fmt.Fprintf(out, "Ignored:   %s\n", strings.Join(req.CacheIgnoredFields, ", "))
+		}
+	}
 
 	if req.DurationMs > 0 {
 		fmt.Fprintf(out, "Duration:  %s\n", formatDuration(time.Duration(req.DurationMs)*time.Millisecond))
@@ -183,7 +247,14 @@ func renderRequestDetail(out io.Writer, snapshot *SessionHistorySnapshot, req Se
 		fmt.Fprintf(out, "TTFT:      %s\n", formatDuration(time.Duration(req.TTFTMs)*time.Millisecond))
 	}
 	if req.InputTokens > 0 || req.OutputTokens > 0 {
-		fmt.Fprintf(out, "Tokens:    in=%d out=%d\n", req.InputTokens, req.OutputTokens)
+		if req.CachedInputTokens > 0 {
+			fmt.Fprintf(out, "Tokens:    in=%d (cached=%d) out=%d\n", req.InputTokens, req.CachedInputTokens, req.OutputTokens)
+		} else {
+			fmt.Fprintf(out, "Tokens:    in=%d out=%d\n", req.InputTokens, req.OutputTokens)
+		}
+	}
+	if req.GenerationMs > 0 && req.OutputTokens > 0 {
+		fmt.Fprintf(out, "Speed:     %.1f tok/s\n", float64(req.OutputTokens)/(float64(req.GenerationMs)/1000))
 	}
 	if req.Cost > 0 {
 		fmt.Fprintf(out, "Cost:      %s\n", formatCost(req.Cost))
@@ -191,9 +262,30 @@ func renderRequestDetail(out io.Writer, snapshot *SessionHistorySnapshot, req Se
 	if req.CancelReason != "" {
 		fmt.Fprintf(out, "Cancel:    %s\n", req.CancelReason)
 	}
+	if req.DecodeError != "" {
+		fmt.Fprintf(out, "Decode:    response body not decoded (%s)\n", req.DecodeError)
+	}
 	if req.ProxyName != "" {
 		fmt.Fprintf(out, "Proxy:     %s (%s)\n", req.ProxyName, req.ProxyListen)
 	}
+	if req.RouteName != "" {
+		fmt.Fprintf(out, "Route:     %s -> %s\n", req.RouteName, req.TargetURL)
+	}
+	if req.WSMessages > 0 {
+		fmt.Fprintf(out, "WebSocket: %d messages\n", req.WSMessages)
+	}
+	if req.Deployment != "" {
+		fmt.Fprintf(out, "Deploy:    %s (Azure)\n", req.Deployment)
+	}
+	if req.BudgetExceeded != "" {
+		fmt.Fprintf(out, "Budget:    rejected, %s\n", req.BudgetExceeded)
+	}
+	if req.InjectedFault != "" {
+		fmt.Fprintf(out, "Fault:     %s (injected)\n", req.InjectedFault)
+	}
+	if len(req.Attempts) > 1 {
+		fmt.Fprintf(out, "Attempts:  %s\n", formatAttempts(req.Attempts))
+	}
 	fmt.Fprintln(out)
 
 	writeHeaders := func(label string, headers map[string][]string) {
@@ -244,6 +336,9 @@ func filterSessionRequests(requests []SessionHistoryRequest, opts InspectOptions
 	model := strings.TrimSpace(opts.Model)
 	path := strings.TrimSpace(opts.Path)
 	code := opts.Code
+	tag := strings.TrimSpace(opts.Tag)
+	run := strings.TrimSpace(opts.Run)
+	conversation := strings.TrimSpace(opts.Conversation)
 
 	statusFilter, hasStatusFilter, err := parseStatusFilter(opts.Status)
 	if err != nil {
@@ -264,6 +359,15 @@ func filterSessionRequests(requests []SessionHistoryRequest, opts InspectOptions
 		if code > 0 && req.StatusCode != code {
 			continue
 		}
+		if tag != "" && !hasTagFold(req.Tags, tag) {
+			continue
+		}
+		if run != "" && !strings.EqualFold(req.Run, run) {
+			continue
+		}
+		if conversation != "" && !strings.EqualFold(req.Conversation, conversation) {
+			continue
+		}
 		if search != "" && !containsFold(requestSearchText(req), search) {
 			continue
 		}
@@ -315,7 +419,9 @@ func requestSearchText(req SessionHistoryRequest) string {
 			strconv.Itoa(req.StatusCode),
 			req.ProxyName,
 			req.ProxyListen,
+			req.RouteName,
 			req.ProviderID,
+			tagSearchText(req.Tags, req.Run, req.Conversation),
 			req.RequestBody,
 			req.ResponseBody,
 		},
@@ -344,6 +450,15 @@ func filterMap(opts InspectOptions) map[string]string {
 	if search := strings.TrimSpace(opts.Search); search != "" {
 		filters["search"] = search
 	}
+	if tag := strings.TrimSpace(opts.Tag); tag != "" {
+		filters["tag"] = tag
+	}
+	if run := strings.TrimSpace(opts.Run); run != "" {
+		filters["run"] = run
+	}
+	if conversation := strings.TrimSpace(opts.Conversation); conversation != "" {
+		filters["conversation"] = conversation
+	}
 	return filters
 }
 
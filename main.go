@@ -5,7 +5,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,16 +22,47 @@ var (
 	cacheTTL             time.Duration
 	cacheSimulateLatency bool
 	cacheDir             string
+	cacheIgnoreFields    []string
+	cacheReplay          string
+	cacheReplaySpeed     float64
+	cacheFailOnMiss      bool
+	cacheMaxEntries      int
+	cacheMaxSize         string
 	inspectSessionID     string
 	inspectLimit         int
-	inspectRequestID     int
+	inspectRequestRef    string
 	inspectJSON          bool
 	inspectSearch        string
 	inspectModel         string
 	inspectPath          string
 	inspectStatus        string
 	inspectCode          int
+	inspectTag           string
+	inspectRun           string
+	inspectConversation  string
+	costGroupBy          string
 	useBase16Theme       bool
+	budgetHard           float64
+	budgetSoft           float64
+	forwardMode          bool
+	noRedact             bool
+	requestIDHeader      string
+	forwardRequestID     bool
+	redactOutput         string
+	redactConfigFile     string
+	redactMode           string
+	serveTapeListen      string
+	serveTapeMatch       string
+	serveTapeMissStatus  int
+	serveTapeMissBody    string
+	cacheCmdModel        string
+	cacheCmdPrefix       string
+	cacheCmdOlderThan    string
+	cacheCmdLimit        int
+	cacheCmdJSON         bool
+	cacheCmdAll          bool
+	cacheCmdDryRun       bool
+	cacheCmdTTL          string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -46,8 +76,10 @@ Examples:
   llmproxy-go                              Start with defaults (:115 → api.openai.com)
   llmproxy-go -p 9000 -t http://api.com    Proxy from :9000 to api.com
   llmproxy-go -c config.toml               Start with configuration file
+  llmproxy-go --forward -p 8090           Forward proxy for HTTPS_PROXY clients
   llmproxy-go replay session.tape          Replay a recorded tape file
-  llmproxy-go cost session.tape            Show cost breakdown for a tape`,
+  llmproxy-go cost session.tape            Show cost breakdown for a tape
+  llmproxy-go cache stats                  Show what the global cache holds`,
 	Run: func(cmd *cobra.Command, args []string) {
 		initThemeFromFlag()
 
@@ -99,10 +131,43 @@ Tape files contain recorded API requests and responses.`,
 var costCmd = &cobra.Command{
 	Use:   "cost <tape-file>",
 	Short: "Print cost breakdown for a tape file",
-	Long:  `Analyze a tape file and print a detailed cost breakdown of all API calls.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Analyze a tape file and print a detailed cost breakdown of all API calls.
+Use --group-by to break costs down by the tag, run or conversation clients set
+with the X-LLMProxy-Tag, X-LLMProxy-Run and X-LLMProxy-Conversation headers.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		RunCostCommand(args[0], costGroupBy)
+	},
+}
+
+// caCmd represents the ca command
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Print the local CA certificate path and trust instructions",
+	Long: `Print where the local certificate authority used by forward proxies is stored,
+creating it on first use, along with instructions for trusting it.
+Set LLMPROXY_CA_DIR to use a different directory.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		RunCostCommand(args[0])
+		if err := RunCACommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// redactCmd represents the redact command
+var redactCmd = &cobra.Command{
+	Use:   "redact <tape-file>",
+	Short: "Scrub secrets from an existing tape file",
+	Long: `Redact auth headers, API keys in URLs and bodies, and any configured patterns
+from a tape file so it can be shared. The tape is rewritten in place unless
+--output is given. Use --config to apply the [redact] rules from a config file.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RunRedactCommand(args[0], redactOutput, redactConfigFile, redactMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	},
 }
 
@@ -118,21 +183,26 @@ var genConfigCmd = &cobra.Command{
 
 // inspectCmd represents the inspect command
 var inspectCmd = &cobra.Command{
-	Use:   "inspect --session <session-id>",
+	Use:   "inspect [--session <session-id>] [--request <id>]",
 	Short: "Inspect recent requests for a live session",
 	Long: `Inspect recent LLM requests captured by a running llmproxy session.
-Supports search/filtering by model/path/status/code, request detail by ID, and JSON output.`,
+Supports search/filtering by model/path/status/code, request detail by ID, and JSON output.
+--request also takes the ID from a response's X-LLMProxy-Request-Id header, which
+names the session too, or a traceparent / X-Request-Id value the client sent.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		opts := InspectOptions{
-			SessionID: inspectSessionID,
-			Limit:     inspectLimit,
-			RequestID: inspectRequestID,
-			JSON:      inspectJSON,
-			Search:    inspectSearch,
-			Model:     inspectModel,
-			Path:      inspectPath,
-			Status:    inspectStatus,
-			Code:      inspectCode,
+			SessionID:    inspectSessionID,
+			Limit:        inspectLimit,
+			RequestRef:   inspectRequestRef,
+			JSON:         inspectJSON,
+			Search:       inspectSearch,
+			Model:        inspectModel,
+			Path:         inspectPath,
+			Status:       inspectStatus,
+			Code:         inspectCode,
+			Tag:          inspectTag,
+			Run:          inspectRun,
+			Conversation: inspectConversation,
 		}
 		if err := RunInspectCommand(os.Stdout, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -141,17 +211,190 @@ Supports search/filtering by model/path/status/code, request detail by ID, and J
 	},
 }
 
+// serveTapeCmd represents the serve-tape command
+var serveTapeCmd = &cobra.Command{
+	Use:   "serve-tape <tape-file>",
+	Short: "Serve recorded responses from a tape file as a mock upstream",
+	Long: `Start a fake LLM provider that answers requests from a recorded tape file.
+Point your client (or another llmproxy) at it to run tests without network access.
+
+Match modes:
+  hash      exact method + path + request body
+  key       normalized cache key (model, messages, etc.)
+  sequence  recorded order, ignoring request content`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := ServeTapeOptions{
+			Listen:     serveTapeListen,
+			Match:      serveTapeMatch,
+			MissStatus: serveTapeMissStatus,
+			MissBody:   serveTapeMissBody,
+		}
+		if err := RunServeTapeCommand(args[0], opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// cacheCmd groups the cache management commands
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "List, inspect, purge and move entries in the global response cache",
+	Long: `Manage the persistent response cache used with --cache global.
+The cache directory is locked while a proxy uses it, so stop the proxy first.
+
+Entries are named by their key (path:hash); commands taking a key also
+accept a prefix of the key or of its hash.`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached responses, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheCmd(false, func(c *BadgerCache, filter CacheFilter) error {
+			return RunCacheList(os.Stdout, c, filter, cacheCmdLimit, cacheCmdJSON)
+		})
+	},
+}
+
+var cacheShowCmd = &cobra.Command{
+	Use:   "show <key>",
+	Short: "Show one entry's request and response",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheCmd(false, func(c *BadgerCache, _ CacheFilter) error {
+			return RunCacheShow(os.Stdout, c, args[0], cacheCmdJSON)
+		})
+	},
+}
+
+var cachePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete entries by age, model or key prefix",
+	Long: `Delete the cache entries matching every given filter.
+Use --all to empty the cache and --dry-run to see what would go.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheCmd(false, func(c *BadgerCache, filter CacheFilter) error {
+			if filter.empty() && !cacheCmdAll {
+				return fmt.Errorf("give --older-than, --model or --prefix, or --all to purge everything")
+			}
+			return RunCachePurge(os.Stdout, c, filter, cacheCmdDryRun)
+		})
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print entry count, disk usage and hit rate",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheCmd(false, func(c *BadgerCache, _ CacheFilter) error {
+			location := cacheDir
+			if location == "" {
+				location = defaultCacheDir()
+			}
+			return RunCacheStats(os.Stdout, c, location)
+		})
+	},
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Write entries to a portable JSON lines file (- for stdout)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheCmd(false, func(c *BadgerCache, filter CacheFilter) error {
+			out := io.Writer(os.Stdout)
+			if args[0] != "-" {
+				f, err := os.Create(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			n, err := ExportCache(out, c, filter)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d entries\n", n)
+			return nil
+		})
+	},
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Load entries from an export file (- for stdin)",
+	Long: `Store the entries from a cache export, replacing entries with the same key.
+Imported entries keep their creation time and hit count and expire after --ttl.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheCmd(true, func(c *BadgerCache, _ CacheFilter) error {
+			in := io.Reader(os.Stdin)
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			n, err := ImportCache(in, c)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Imported %d entries\n", n)
+			return nil
+		})
+	},
+}
+
+// runCacheCmd opens the cache directory and runs a cache subcommand with
+// the filter flags, exiting on error
+func runCacheCmd(create bool, fn func(c *BadgerCache, filter CacheFilter) error) {
+	filter := CacheFilter{Model: cacheCmdModel, Prefix: cacheCmdPrefix}
+	ttl, err := ParseTTL(cacheCmdTTL)
+	if err == nil && cacheCmdOlderThan != "" {
+		filter.OlderThan, err = ParseTTL(cacheCmdOlderThan)
+	}
+	if err == nil {
+		err = withCacheDir(cacheDir, create, ttl, func(c *BadgerCache) error {
+			return fn(c, filter)
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func init() {
 	// Root command flags
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to TOML config file for multi-proxy configuration")
 	rootCmd.Flags().IntVarP(&port, "port", "p", 115, "Port to listen on")
 	rootCmd.Flags().StringVarP(&targetURL, "target", "t", "https://api.openai.com", "Target URL to proxy to")
 	rootCmd.Flags().StringVarP(&saveTape, "save-tape", "s", "", "Auto-save session to tape file")
-	rootCmd.Flags().StringVarP(&cacheMode, "cache", "m", "none", "Cache mode: none, memory, global")
+	rootCmd.Flags().StringVarP(&cacheMode, "cache", "m", "none", "Cache mode: none, memory, global, or for test fixtures record, replay-only, record-missing")
 	rootCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 24*time.Hour, "Cache TTL duration (e.g., 1h, 24h)")
-	rootCmd.Flags().BoolVar(&cacheSimulateLatency, "cache-simulate-latency", false, "Simulate original response latency for cached responses")
+	rootCmd.Flags().BoolVar(&cacheSimulateLatency, "cache-simulate-latency", false, "Replay cached responses with their original latency (TTFT and chunk gaps for streams)")
 	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for badger cache (default: ~/.llmproxy-cache)")
+	rootCmd.Flags().StringVar(&cacheReplay, "cache-replay", "", "Pacing of cache hits: instant, original, or scaled (default: original with --cache-simulate-latency, else instant)")
+	rootCmd.Flags().Float64Var(&cacheReplaySpeed, "cache-replay-speed", 1, "Speed-up for --cache-replay scaled (e.g. 4 = four times faster)")
+	rootCmd.Flags().BoolVar(&cacheFailOnMiss, "cache-fail-on-miss", false, "Exit with status 1 if a --cache replay-only run had misses")
+	rootCmd.Flags().IntVar(&cacheMaxEntries, "cache-max-entries", 0, "Evict the least recently used cache entries past this many (0 = unlimited)")
+	rootCmd.Flags().StringVar(&cacheMaxSize, "cache-max-size", "", "Evict the least recently used cache entries past this size (e.g. 512MB)")
+	rootCmd.Flags().StringSliceVar(&cacheIgnoreFields, "cache-ignore-fields", nil, "Request fields to leave out of cache keys (e.g. user,metadata,stream_options)")
 	rootCmd.Flags().BoolVar(&useBase16Theme, "base16", true, "Derive 256-color palette from terminal's base16 theme (disable with --base16=false)")
+	rootCmd.Flags().Float64Var(&budgetHard, "budget", 0, "Hard spend cap in USD; new requests are rejected once reached (0 = off)")
+	rootCmd.Flags().Float64Var(&budgetSoft, "budget-soft", 0, "Soft spend cap in USD; shows a warning in the TUI once reached (0 = off)")
+	rootCmd.Flags().BoolVar(&forwardMode, "forward", false, "Run as an HTTPS forward proxy (use via HTTPS_PROXY) instead of proxying to --target")
+	rootCmd.Flags().BoolVar(&noRedact, "no-redact", false, "Store API keys and auth headers verbatim in tapes and session history")
+	rootCmd.Flags().StringVar(&requestIDHeader, "request-id-header", DefaultCorrelationHeader, "Response header carrying the proxy's request ID (\"none\" to disable)")
+	rootCmd.Flags().BoolVar(&forwardRequestID, "forward-request-id", false, "Also send the request ID header to the upstream")
 
 	// Also add --base16 to the replay command so tape playback can use it
 	replayCmd.Flags().BoolVar(&useBase16Theme, "base16", true, "Derive 256-color palette from terminal's base16 theme (disable with --base16=false)")
@@ -159,20 +402,55 @@ func init() {
 	// Inspect command flags
 	inspectCmd.Flags().StringVar(&inspectSessionID, "session", "", "Session ID to inspect")
 	inspectCmd.Flags().IntVar(&inspectLimit, "limit", 20, "Number of most recent requests to show (0 = all)")
-	inspectCmd.Flags().IntVar(&inspectRequestID, "request", 0, "Inspect one request ID in detail")
+	inspectCmd.Flags().StringVar(&inspectRequestRef, "request", "", "Inspect one request in detail: request number, correlation ID (X-LLMProxy-Request-Id), trace ID or X-Request-Id")
 	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Print JSON output")
 	inspectCmd.Flags().StringVar(&inspectSearch, "search", "", "Full-text search across model/path/body/response")
 	inspectCmd.Flags().StringVar(&inspectModel, "model", "", "Filter by model substring (case-insensitive)")
 	inspectCmd.Flags().StringVar(&inspectPath, "path", "", "Filter by path substring (case-insensitive)")
 	inspectCmd.Flags().StringVar(&inspectStatus, "status", "", "Filter by status: pending, complete, error")
 	inspectCmd.Flags().IntVar(&inspectCode, "code", 0, "Filter by exact HTTP status code")
-	_ = inspectCmd.MarkFlagRequired("session")
+	inspectCmd.Flags().StringVar(&inspectTag, "tag", "", "Filter by client tag (X-LLMProxy-Tag)")
+	inspectCmd.Flags().StringVar(&inspectRun, "run", "", "Filter by client run (X-LLMProxy-Run)")
+	inspectCmd.Flags().StringVar(&inspectConversation, "conversation", "", "Filter by client conversation (X-LLMProxy-Conversation)")
+
+	// Cost command flags
+	costCmd.Flags().StringVar(&costGroupBy, "group-by", "model", "Group costs by: model, tag, run, conversation")
+
+	// Serve-tape command flags
+	serveTapeCmd.Flags().StringVarP(&serveTapeListen, "listen", "l", ":8080", "Address to listen on")
+	serveTapeCmd.Flags().StringVar(&serveTapeMatch, "match", TapeMatchHash, "Match mode: hash, key, sequence")
+	serveTapeCmd.Flags().IntVar(&serveTapeMissStatus, "miss-status", 404, "HTTP status returned when no recorded response matches")
+	serveTapeCmd.Flags().StringVar(&serveTapeMissBody, "miss-body", "", "Response body for misses (default: provider-shaped error JSON)")
+
+	// Redact command flags
+	redactCmd.Flags().StringVarP(&redactOutput, "output", "o", "", "Write the scrubbed tape here instead of rewriting in place")
+	redactCmd.Flags().StringVarP(&redactConfigFile, "config", "c", "", "Config file with [redact] rules (default: built-in rules)")
+	redactCmd.Flags().StringVar(&redactMode, "mode", "", "Redaction mode: mask or hash (overrides config)")
+
+	// Cache command flags
+	cacheCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory of the badger cache (default: ~/.llmproxy-cache)")
+	for _, cmd := range []*cobra.Command{cacheListCmd, cachePurgeCmd, cacheExportCmd} {
+		cmd.Flags().StringVar(&cacheCmdModel, "model", "", "Only entries whose model contains this (case-insensitive)")
+		cmd.Flags().StringVar(&cacheCmdPrefix, "prefix", "", "Only entries whose key or key hash starts with this")
+		cmd.Flags().StringVar(&cacheCmdOlderThan, "older-than", "", "Only entries stored at least this long ago (e.g. 12h, 7d)")
+	}
+	cacheListCmd.Flags().IntVar(&cacheCmdLimit, "limit", 50, "Number of entries to show (0 = all)")
+	cacheListCmd.Flags().BoolVar(&cacheCmdJSON, "json", false, "Print JSON output")
+	cacheShowCmd.Flags().BoolVar(&cacheCmdJSON, "json", false, "Print the entry as JSON")
+	cachePurgeCmd.Flags().BoolVar(&cacheCmdAll, "all", false, "Purge every entry")
+	cachePurgeCmd.Flags().BoolVar(&cacheCmdDryRun, "dry-run", false, "List the entries that would be purged without deleting them")
+	cacheImportCmd.Flags().StringVar(&cacheCmdTTL, "ttl", "24h", "How long imported entries are kept (e.g. 24h, 7d)")
+	cacheCmd.AddCommand(cacheListCmd, cacheShowCmd, cachePurgeCmd, cacheStatsCmd, cacheExportCmd, cacheImportCmd)
 
 	// Add subcommands
 	rootCmd.AddCommand(replayCmd)
 	rootCmd.AddCommand(costCmd)
 	rootCmd.AddCommand(genConfigCmd)
 	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(serveTapeCmd)
+	rootCmd.AddCommand(caCmd)
+	rootCmd.AddCommand(redactCmd)
+	rootCmd.AddCommand(cacheCmd)
 }
 
 // initThemeFromFlag initializes the theme based on the --base16 flag.
@@ -194,7 +472,16 @@ func init() {
 	initStyles()
 }
 
+// exitCode is set by runs that finish normally but should still fail, such
+// as a replay-only run with cache misses
+var exitCode int
+
 func main() {
+	os.Exit(run())
+}
+
+// run executes the command line, cleaning up before main exits
+func run() int {
 	// Clean up any temp images/audio on exit
 	defer cleanupTempImages()
 	defer cleanupTempAudio()
@@ -202,7 +489,17 @@ func main() {
 	defer restoreTerminalPalette()
 
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		return 1
+	}
+	return exitCode
+}
+
+// finishCacheRun prints what a fixture-mode run did with the cache and
+// fails the process for replay-only misses when asked to
+func finishCacheRun() {
+	cfg := GetCacheConfig()
+	if misses := PrintCacheSummary(os.Stderr, cfg.Mode); misses > 0 && cfg.FailOnMiss && cfg.Mode == CacheModeReplayOnly {
+		exitCode = 1
 	}
 }
 
@@ -225,7 +522,19 @@ func runWithConfig(configPath string) {
 		fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
 		os.Exit(1)
 	}
-	defer CloseCache()
+
+	if err := InitBudget(config.Budgets); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing budget: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := InitRedaction(config.Redact); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing redaction: %v\n", err)
+		os.Exit(1)
+	}
+
+	SetAzureDeployments(config.Azure.Deployments)
+	SetCorrelation(config.Correlation)
 
 	// Build display strings for TUI and session history metadata
 	listenAddrs := formatListenAddrs(config.Proxies)
@@ -240,7 +549,6 @@ func runWithConfig(configPath string) {
 		fmt.Fprintf(os.Stderr, "Error creating session history: %v\n", err)
 		os.Exit(1)
 	}
-	defer StopSessionHistory()
 
 	// Initialize tape writer if specified in config
 	saveTapeFile := config.SaveTape
@@ -254,11 +562,6 @@ func runWithConfig(configPath string) {
 		// For multi-proxy, write session start with all proxies info
 		proxySummary := formatProxySummary(config.Proxies)
 		tapeWriter.WriteSessionStart(proxySummary, "multi-proxy")
-		defer func() {
-			if tapeWriter != nil {
-				tapeWriter.Close()
-			}
-		}()
 	}
 
 	// Register extra LLM path patterns from config
@@ -270,6 +573,11 @@ func runWithConfig(configPath string) {
 		os.Exit(1)
 	}
 
+	// Apply config file edits (or SIGHUP) without restarting
+	reloader := NewConfigReloader(configPath, config)
+	stopReload := make(chan struct{})
+	go reloader.Watch(stopReload)
+
 	// Suppress log output during TUI operation to prevent layout issues
 	log.SetOutput(io.Discard)
 
@@ -280,7 +588,11 @@ func runWithConfig(configPath string) {
 		tea.WithMouseCellMotion(),
 	)
 
-	if _, err := program.Run(); err != nil {
+	_, err = program.Run()
+	close(stopReload)
+	ShutdownSession()
+	finishCacheRun()
+	if err != nil {
 		log.Fatalf("Error running TUI: %v", err)
 	}
 }
@@ -290,32 +602,73 @@ func runSingleProxy() {
 	// Initialize cache
 	badgerPath := cacheDir
 	if badgerPath == "" {
-		home, _ := os.UserHomeDir()
-		badgerPath = filepath.Join(home, ".llmproxy-cache")
+		badgerPath = defaultCacheDir()
+	}
+
+	mode, err := parseCacheMode(cacheMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	replay, err := parseCacheReplayMode(cacheReplay)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	maxBytes, err := parseByteSize(cacheMaxSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --cache-max-size: %v\n", err)
+		os.Exit(1)
 	}
 
 	cacheConfig := CacheConfig{
-		Mode:            CacheMode(cacheMode),
+		Mode:            mode,
 		TTL:             cacheTTL,
 		SimulateLatency: cacheSimulateLatency,
 		BadgerPath:      badgerPath,
+		Replay:          replay,
+		ReplaySpeed:     cacheReplaySpeed,
+		FailOnMiss:      cacheFailOnMiss,
+		Limits:          CacheLimits{MaxEntries: cacheMaxEntries, MaxBytes: maxBytes},
 	}
 
 	if err := InitCache(cacheConfig); err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
 		os.Exit(1)
 	}
-	defer CloseCache()
+
+	var budgets []BudgetConfig
+	if budgetHard > 0 || budgetSoft > 0 {
+		budgets = append(budgets, BudgetConfig{Soft: budgetSoft, Hard: budgetHard})
+	}
+	if err := InitBudget(budgets); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing budget: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := InitRedaction(RedactConfig{Enabled: !noRedact}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing redaction: %v\n", err)
+		os.Exit(1)
+	}
+
+	SetCorrelation(CorrelationConfig{Header: requestIDHeader, ForwardUpstream: forwardRequestID})
 
 	// Format listen address from port
 	listenAddr := fmt.Sprintf(":%d", port)
 
-	sessionID, err := StartSessionHistory(listenAddr, targetURL)
+	// In forward mode there is no fixed target
+	displayTarget := targetURL
+	if forwardMode {
+		displayTarget = "forward proxy (HTTPS_PROXY)"
+	}
+
+	sessionID, err := StartSessionHistory(listenAddr, displayTarget)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating session history: %v\n", err)
 		os.Exit(1)
 	}
-	defer StopSessionHistory()
 
 	// Initialize tape writer if save-tape is specified
 	if saveTape != "" {
@@ -325,28 +678,34 @@ func runSingleProxy() {
 			os.Exit(1)
 		}
 		tapeWriter = writer
-		tapeWriter.WriteSessionStart(listenAddr, targetURL)
-		defer func() {
-			if tapeWriter != nil {
-				tapeWriter.Close()
-			}
-		}()
+		tapeWriter.WriteSessionStart(listenAddr, displayTarget)
 	}
 
 	// Start the proxy server
-	startProxy(listenAddr, targetURL)
+	if forwardMode {
+		if err := StartProxyFromConfig(ProxyConfig{Name: "default", Listen: listenAddr, Mode: ProxyModeForward, CacheIgnoreFields: cacheIgnoreFields}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting forward proxy: %v\n", err)
+			os.Exit(1)
+		}
+	} else if err := StartProxyFromConfig(ProxyConfig{Name: "default", Listen: listenAddr, Target: targetURL, CacheIgnoreFields: cacheIgnoreFields}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting proxy: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Suppress log output during TUI operation to prevent layout issues
 	log.SetOutput(io.Discard)
 
 	// Start the TUI
 	program = tea.NewProgram(
-		initialModel(listenAddr, targetURL, saveTape, sessionID),
+		initialModel(listenAddr, displayTarget, saveTape, sessionID),
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
 
-	if _, err := program.Run(); err != nil {
+	_, err = program.Run()
+	ShutdownSession()
+	finishCacheRun()
+	if err != nil {
 		log.Fatalf("Error running TUI: %v", err)
 	}
 }
@@ -365,9 +724,10 @@ func formatProxySummary(proxies []ProxyConfig) string {
 }
 
 // formatListenAddrs creates a display string of all listen addresses
-// In multi-proxy mode, returns "multi" to signal the view to use a different format
+// In multi-proxy mode (or when a proxy has routes), returns "multi" to signal
+// the view to use a different format
 func formatListenAddrs(proxies []ProxyConfig) string {
-	if len(proxies) == 1 {
+	if len(proxies) == 1 && len(proxies[0].Routes) == 0 {
 		return proxies[0].Listen
 	}
 	return "multi"
@@ -376,7 +736,10 @@ func formatListenAddrs(proxies []ProxyConfig) string {
 // formatTargetURLs creates a display string of all target URLs
 // In multi-proxy mode, returns a formatted string showing each proxy as name(port→host)
 func formatTargetURLs(proxies []ProxyConfig) string {
-	if len(proxies) == 1 {
+	if len(proxies) == 1 && len(proxies[0].Routes) == 0 {
+		if proxies[0].Mode == ProxyModeForward {
+			return "forward proxy (HTTPS_PROXY)"
+		}
 		return proxies[0].Target
 	}
 	// In multi-proxy mode, show each proxy as a complete unit: name(port→host)
@@ -396,6 +759,15 @@ func formatTargetURLs(proxies []ProxyConfig) string {
 		if name == "" {
 			name = p.Listen
 		}
+		if p.Mode == ProxyModeForward {
+			target = "forward"
+		}
+		if len(p.Routes) > 0 {
+			if target == "" {
+				target = "-"
+			}
+			target = fmt.Sprintf("%s +%d routes", target, len(p.Routes))
+		}
 		parts = append(parts, fmt.Sprintf("%s(%s→%s)", name, p.Listen, target))
 	}
 	return strings.Join(parts, " ")
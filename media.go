@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Audio and image endpoints: multipart uploads, binary responses, and the
+// per-image, per-minute and per-character prices these models are billed at.
+
+var mediaPaths = []string{
+	"/audio/transcriptions",
+	"/audio/translations",
+	"/audio/speech",
+	"/images/generations",
+	"/images/edits",
+	"/images/variations",
+}
+
+// isMediaEndpoint returns true for OpenAI-style audio and image endpoints
+func isMediaEndpoint(path string) bool {
+	for _, p := range mediaPaths {
+		if strings.HasSuffix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormPart is one field of a multipart/form-data body, or one top-level
+// field of a JSON body
+type FormPart struct {
+	Name        string
+	Filename    string // Set for uploaded files
+	ContentType string
+	Data        []byte
+}
+
+// IsFile returns true if the part is an uploaded file
+func (p FormPart) IsFile() bool {
+	return p.Filename != ""
+}
+
+// multipartBoundary sniffs the boundary from the first line of a
+// multipart/form-data body, so bodies can be read without their headers
+func multipartBoundary(body []byte) string {
+	if !bytes.HasPrefix(body, []byte("--")) {
+		return ""
+	}
+	end := bytes.IndexByte(body, '\n')
+	if end < 0 {
+		return ""
+	}
+	boundary := strings.TrimRight(string(body[2:end]), "\r")
+	if boundary == "" || len(boundary) > 70 || !bytes.Contains(body[:min(len(body), end+200)], []byte("form-data")) {
+		return ""
+	}
+	return boundary
+}
+
+// isMultipartBody returns true if body is multipart/form-data
+func isMultipartBody(body []byte) bool {
+	return multipartBoundary(body) != ""
+}
+
+// parseMultipartBody splits a multipart/form-data body into its parts
+func parseMultipartBody(body []byte) ([]FormPart, bool) {
+	boundary := multipartBoundary(body)
+	if boundary == "" {
+		return nil, false
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []FormPart
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Keep what parsed before a truncated or malformed part
+			return parts, len(parts) > 0
+		}
+		data, _ := io.ReadAll(part)
+		parts = append(parts, FormPart{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Data:        data,
+		})
+	}
+	return parts, true
+}
+
+// parseMediaRequest returns the fields of a media request body, whether it
+// was sent as multipart/form-data (uploads) or JSON (speech, generations)
+func parseMediaRequest(body []byte) []FormPart {
+	if parts, ok := parseMultipartBody(body); ok {
+		return parts
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]FormPart, 0, len(names))
+	for _, name := range names {
+		value := []byte(fields[name])
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			value = []byte(s)
+		}
+		parts = append(parts, FormPart{Name: name, Data: value})
+	}
+	return parts
+}
+
+// formField returns the value of a non-file field
+func formField(parts []FormPart, name string) string {
+	for _, p := range parts {
+		if p.Name == name && !p.IsFile() {
+			return string(p.Data)
+		}
+	}
+	return ""
+}
+
+// formText joins the text fields of a form, for token estimates and search
+func formText(parts []FormPart) string {
+	var texts []string
+	for _, p := range parts {
+		if !p.IsFile() {
+			texts = append(texts, string(p.Data))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// filePartMimeType guesses an upload's type from its extension, then its
+// bytes; SDKs usually send application/octet-stream
+func filePartMimeType(p FormPart) string {
+	if p.ContentType != "" && p.ContentType != "application/octet-stream" {
+		return p.ContentType
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(p.Filename))); byExt != "" {
+		return byExt
+	}
+	return http.DetectContentType(p.Data)
+}
+
+// audioFormatFromMime maps an audio MIME type or file name to the format
+// names AudioRef uses
+func audioFormatFromMime(mimeType, filename string) string {
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), "."); ext != "" {
+		if ext == "mpeg" || ext == "mpga" {
+			return "mp3"
+		}
+		return ext
+	}
+	switch mimeType = strings.ToLower(strings.Split(mimeType, ";")[0]); mimeType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mp4", "audio/x-m4a":
+		return "m4a"
+	case "audio/pcm", "audio/l16":
+		return "pcm16"
+	}
+	return strings.TrimPrefix(mimeType, "audio/")
+}
+
+// formFileRefs turns uploaded images and audio into refs that can be opened,
+// numbering them from the given counters
+func formFileRefs(parts []FormPart, imageIndex, audioIndex int) ([]ImageRef, []AudioRef) {
+	var images []ImageRef
+	var audio []AudioRef
+	for _, p := range parts {
+		if !p.IsFile() {
+			continue
+		}
+		mimeType := filePartMimeType(p)
+		switch {
+		case strings.HasPrefix(mimeType, "image/"):
+			imageIndex++
+			images = append(images, ImageRef{
+				Index:    imageIndex,
+				URL:      "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(p.Data),
+				IsBase64: true,
+			})
+		case strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "video/"):
+			audioIndex++
+			audio = append(audio, AudioRef{
+				Index:  audioIndex,
+				Data:   base64.StdEncoding.EncodeToString(p.Data),
+				Format: audioFormatFromMime(mimeType, p.Filename),
+			})
+		}
+	}
+	return images, audio
+}
+
+// isBinaryBody returns true for bodies that aren't text, such as audio
+func isBinaryBody(body []byte) bool {
+	sniff := http.DetectContentType(body)
+	return !strings.HasPrefix(sniff, "text/") && !strings.Contains(sniff, "json")
+}
+
+// displayBody returns a readable stand-in for multipart and binary bodies:
+// form fields with file contents summarized, or a one-line description.
+// Other bodies are returned unchanged.
+func displayBody(headers map[string][]string, body []byte) []byte {
+	if parts, ok := parseMultipartBody(body); ok {
+		var b bytes.Buffer
+		for _, p := range parts {
+			if p.IsFile() {
+				fmt.Fprintf(&b, "%s: <file %q, %s, %s>\n", p.Name, p.Filename, filePartMimeType(p), formatBytes(len(p.Data)))
+			} else {
+				fmt.Fprintf(&b, "%s: %s\n", p.Name, p.Data)
+			}
+		}
+		return b.Bytes()
+	}
+	if len(body) > 0 && isBinaryBody(body) {
+		contentType := http.Header(headers).Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		return []byte(fmt.Sprintf("<binary %s, %s>", contentType, formatBytes(len(body))))
+	}
+	return body
+}
+
+// mediaImage is one generated or edited image
+type mediaImage struct {
+	URL           string `json:"url"`
+	B64JSON       string `json:"b64_json"`
+	RevisedPrompt string `json:"revised_prompt"`
+}
+
+// mediaResponse is the JSON returned by image and transcription endpoints
+type mediaResponse struct {
+	// Transcriptions and translations
+	Text     string  `json:"text"`
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"` // verbose_json only
+	// Images
+	Data         []mediaImage `json:"data"`
+	OutputFormat string       `json:"output_format"`
+	Size         string       `json:"size"`
+	Quality      string       `json:"quality"`
+	Usage        struct {
+		Type         string  `json:"type"` // "tokens" or "duration" for transcriptions
+		Seconds      float64 `json:"seconds"`
+		InputTokens  int     `json:"input_tokens"`
+		OutputTokens int     `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// parseMediaResponse decodes a media response. Streamed transcriptions are
+// folded into their final text and usage; plain text formats (text, srt,
+// vtt) become Text.
+func parseMediaResponse(body []byte) *mediaResponse {
+	resp := &mediaResponse{}
+	if isSSEData(body) {
+		var text strings.Builder
+		for _, line := range strings.Split(string(body), "\n") {
+			data := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "data:"))
+			if !strings.HasPrefix(data, "{") {
+				continue
+			}
+			var event struct {
+				Type    string `json:"type"`
+				Delta   string `json:"delta"`
+				B64JSON string `json:"b64_json"` // Streamed images
+				mediaResponse
+			}
+			if json.Unmarshal([]byte(data), &event) != nil {
+				continue
+			}
+			switch event.Type {
+			case "transcript.text.delta":
+				text.WriteString(event.Delta)
+			case "transcript.text.done":
+				text.Reset()
+				text.WriteString(event.Text)
+				resp.Usage = event.Usage
+			case "image_generation.completed", "image_edit.completed":
+				if event.B64JSON != "" {
+					resp.Data = append(resp.Data, mediaImage{B64JSON: event.B64JSON})
+				}
+				resp.OutputFormat, resp.Size, resp.Quality = event.OutputFormat, event.Size, event.Quality
+				resp.Usage = event.Usage
+			}
+		}
+		resp.Text = text.String()
+		return resp
+	}
+	if json.Unmarshal(body, resp) == nil {
+		return resp
+	}
+	if !isBinaryBody(body) {
+		return &mediaResponse{Text: string(body)}
+	}
+	return nil
+}
+
+// mediaResponseRefs returns playable audio for binary speech responses and
+// openable images for image responses
+func mediaResponseRefs(req *LLMRequest) ([]ImageRef, []AudioRef) {
+	body := req.ResponseBody
+	contentType := http.Header(req.ResponseHeaders).Get("Content-Type")
+	if strings.HasPrefix(contentType, "audio/") || (strings.HasSuffix(req.Path, "/audio/speech") && isBinaryBody(body)) {
+		format := formField(parseMediaRequest(req.RequestBody), "response_format")
+		if format == "" {
+			format = audioFormatFromMime(contentType, "")
+		}
+		data := body
+		if format == "pcm" {
+			// Speech PCM is 24kHz 16-bit mono without a header
+			data, format = pcm16ToWAV(body, 24000), "wav"
+		}
+		return nil, []AudioRef{{Index: 1, Data: base64.StdEncoding.EncodeToString(data), Format: format, IsOutput: true}}
+	}
+
+	resp := parseMediaResponse(body)
+	if resp == nil {
+		return nil, nil
+	}
+	format := resp.OutputFormat
+	if format == "" {
+		format = "png"
+	}
+	var images []ImageRef
+	for _, d := range resp.Data {
+		switch {
+		case d.B64JSON != "":
+			images = append(images, ImageRef{Index: len(images) + 1, URL: "data:image/" + format + ";base64," + d.B64JSON, IsBase64: true})
+		case d.URL != "":
+			images = append(images, ImageRef{Index: len(images) + 1, URL: d.URL})
+		}
+	}
+	return images, nil
+}
+
+// Prices for models billed per unit rather than per token, in USD
+var (
+	// model/size/quality -> price per image
+	imagePrices = map[string]float64{
+		"dall-e-2/256x256":             0.016,
+		"dall-e-2/512x512":             0.018,
+		"dall-e-2/1024x1024":           0.020,
+		"dall-e-3/1024x1024/standard":  0.040,
+		"dall-e-3/1024x1792/standard":  0.080,
+		"dall-e-3/1792x1024/standard":  0.080,
+		"dall-e-3/1024x1024/hd":        0.080,
+		"dall-e-3/1024x1792/hd":        0.120,
+		"dall-e-3/1792x1024/hd":        0.120,
+		"gpt-image-1/1024x1024/low":    0.011,
+		"gpt-image-1/1024x1536/low":    0.016,
+		"gpt-image-1/1536x1024/low":    0.016,
+		"gpt-image-1/1024x1024/medium": 0.042,
+		"gpt-image-1/1024x1536/medium": 0.063,
+		"gpt-image-1/1536x1024/medium": 0.063,
+		"gpt-image-1/1024x1024/high":   0.167,
+		"gpt-image-1/1024x1536/high":   0.250,
+		"gpt-image-1/1536x1024/high":   0.250,
+	}
+	// Price per minute of input audio
+	perMinutePrices = map[string]float64{
+		"whisper-1":              0.006,
+		"gpt-4o-transcribe":      0.006,
+		"gpt-4o-mini-transcribe": 0.003,
+	}
+	// Price per input character
+	perCharacterPrices = map[string]float64{
+		"tts-1":    15.0 / 1_000_000,
+		"tts-1-hd": 30.0 / 1_000_000,
+	}
+)
+
+// imagePrice returns the per-image price for a model, size and quality,
+// using each model's defaults for anything unset
+func imagePrice(model, size, quality string) float64 {
+	if size == "" || size == "auto" {
+		size = "1024x1024"
+	}
+	switch model {
+	case "dall-e-2":
+		return imagePrices[model+"/"+size]
+	case "dall-e-3":
+		if quality == "" || quality == "auto" {
+			quality = "standard"
+		}
+	default:
+		if quality == "" || quality == "auto" {
+			quality = "medium"
+		}
+	}
+	return imagePrices[model+"/"+size+"/"+quality]
+}
+
+// wavDuration returns the length of a WAV file in seconds, or 0 if it
+// can't be read
+func wavDuration(data []byte) float64 {
+	if len(data) < 44 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0
+	}
+	var byteRate uint32
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		switch id {
+		case "fmt ":
+			if pos+20 <= len(data) {
+				byteRate = binary.LittleEndian.Uint32(data[pos+16:])
+			}
+		case "data":
+			if byteRate == 0 {
+				return 0
+			}
+			return float64(min(size, len(data)-pos-8)) / float64(byteRate)
+		}
+		pos += 8 + size + size%2
+	}
+	return 0
+}
+
+// applyMediaUsage sets tokens and cost for an audio or image request.
+// Token usage is priced through models.dev when it's reported; otherwise
+// the per-image, per-minute or per-character price applies.
+func applyMediaUsage(req *LLMRequest, responseBody []byte) {
+	form := parseMediaRequest(req.RequestBody)
+	model := pricingModel(req)
+	if model == "" || model == "unknown" {
+		// The API defaults
+		switch {
+		case strings.Contains(req.Path, "/images/"):
+			model = "dall-e-2"
+		case strings.HasSuffix(req.Path, "/audio/speech"):
+			model = "tts-1"
+		default:
+			model = "whisper-1"
+		}
+	}
+
+	resp := parseMediaResponse(responseBody)
+	if resp != nil && (resp.Usage.InputTokens > 0 || resp.Usage.OutputTokens > 0) {
+		req.InputTokens = resp.Usage.InputTokens
+		req.OutputTokens = resp.Usage.OutputTokens
+		if cost := GetModelCost(req.ProviderID, model); cost != nil {
+			req.Cost = CalculateCostWithCache(cost, req.InputTokens, 0, req.OutputTokens)
+			return
+		}
+	}
+
+	switch {
+	case strings.Contains(req.Path, "/images/"):
+		images := 0
+		if resp != nil {
+			images = len(resp.Data)
+		}
+		size, quality := formField(form, "size"), formField(form, "quality")
+		if resp != nil && resp.Size != "" {
+			size, quality = resp.Size, resp.Quality
+		}
+		req.Cost = float64(images) * imagePrice(model, size, quality)
+
+	case strings.HasSuffix(req.Path, "/audio/speech"):
+		req.Cost = float64(len([]rune(formField(form, "input")))) * perCharacterPrices[model]
+
+	default:
+		seconds := 0.0
+		if resp != nil {
+			seconds = resp.Duration
+			if resp.Usage.Type == "duration" {
+				seconds = resp.Usage.Seconds
+			}
+		}
+		if seconds == 0 {
+			for _, p := range form {
+				if p.IsFile() {
+					seconds += wavDuration(p.Data)
+				}
+			}
+		}
+		req.Cost = seconds / 60 * perMinutePrices[model]
+	}
+}
+
+// mediaDurationLabel formats an audio length in seconds for display
+func mediaDurationLabel(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(seconds, 'f', 1, 64) + "s"
+}
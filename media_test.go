@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildTranscriptionForm returns a multipart body with a one-second 16kHz WAV
+func buildTranscriptionForm(t *testing.T, boundary string) ([]byte, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteField("model", "whisper-1")
+	w.WriteField("response_format", "json")
+	file, _ := w.CreateFormFile("file", "clip.wav")
+	file.Write(pcm16ToWAV(make([]byte, 32000), 16000))
+	w.Close()
+	return body.Bytes(), w.FormDataContentType()
+}
+
+func TestParseMultipartBody(t *testing.T) {
+	body, _ := buildTranscriptionForm(t, "boundary-one")
+	parts, ok := parseMultipartBody(body)
+	if !ok || len(parts) != 3 {
+		t.Fatalf("parts = %d ok = %v", len(parts), ok)
+	}
+	if formField(parts, "model") != "whisper-1" || !parts[2].IsFile() || parts[2].Filename != "clip.wav" {
+		t.Errorf("parts = %+v", parts[:2])
+	}
+	if seconds := wavDuration(parts[2].Data); math.Abs(seconds-1) > 1e-9 {
+		t.Errorf("wavDuration = %v, want 1s", seconds)
+	}
+	// A JUNK chunk followed by a fmt chunk cut off inside its byte rate
+	truncated := append([]byte("RIFF\x2c\x00\x00\x00WAVEJUNK\x0e\x00\x00\x00"), make([]byte, 14)...)
+	truncated = append(append(truncated, "fmt \x10\x00\x00\x00"...), make([]byte, 10)...)
+	if seconds := wavDuration(truncated); len(truncated) != 52 || seconds != 0 {
+		t.Errorf("wavDuration of a truncated %d-byte WAV = %v, want 0", len(truncated), seconds)
+	}
+
+	images, audio := formFileRefs(parts, 0, 0)
+	if len(images) != 0 || len(audio) != 1 || audio[0].Format != "wav" {
+		t.Errorf("refs = %+v %+v", images, audio)
+	}
+
+	shown := string(displayBody(nil, body))
+	if !strings.Contains(shown, "model: whisper-1") || !strings.Contains(shown, `file: <file "clip.wav", audio/`) {
+		t.Errorf("displayBody = %q", shown)
+	}
+	if isMultipartBody([]byte(`{"model":"gpt-4o"}`)) {
+		t.Error("JSON detected as multipart")
+	}
+
+	// The random boundary doesn't change the cache key
+	other, _ := buildTranscriptionForm(t, "boundary-two")
+	if GenerateCacheKey("/v1/audio/transcriptions", body) != GenerateCacheKey("/v1/audio/transcriptions", other) {
+		t.Error("cache key depends on the multipart boundary")
+	}
+	if GenerateCacheKey("/v1/images/generations", []byte(`{"prompt":"a cat"}`)) == GenerateCacheKey("/v1/images/generations", []byte(`{"prompt":"a dog"}`)) {
+		t.Error("different image prompts share a cache key")
+	}
+}
+
+func TestMediaCost(t *testing.T) {
+	seedModelsDB(t, map[string]Provider{}, map[string]ModelCost{"gpt-image-1": {Input: 10, Output: 40}})
+
+	tests := []struct {
+		name     string
+		path     string
+		model    string
+		request  string
+		response string
+		want     float64
+	}{
+		{"dall-e-3 hd", "/v1/images/generations", "dall-e-3", `{"prompt":"x","size":"1024x1792","quality":"hd","n":2}`,
+			`{"data":[{"url":"https://a"},{"url":"https://b"}]}`, 0.24},
+		{"dall-e-2 default", "/v1/images/generations", "unknown", `{"prompt":"x"}`, `{"data":[{"b64_json":"AAAA"}]}`, 0.02},
+		{"gpt-image-1 tokens", "/v1/images/generations", "gpt-image-1", `{"prompt":"x"}`,
+			`{"data":[{"b64_json":"AAAA"}],"usage":{"input_tokens":100,"output_tokens":1000}}`, (100*10 + 1000*40) / 1_000_000.0},
+		{"tts-1 characters", "/v1/audio/speech", "tts-1", `{"input":"` + strings.Repeat("a", 1000) + `","voice":"alloy"}`, "ID3binary", 0.015},
+		{"whisper verbose", "/v1/audio/transcriptions", "whisper-1", "", `{"text":"hi","duration":120}`, 0.012},
+		{"transcribe duration usage", "/v1/audio/transcriptions", "gpt-4o-mini-transcribe", "", `{"text":"hi","usage":{"type":"duration","seconds":60}}`, 0.003},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &LLMRequest{Path: tt.path, Model: tt.model, RequestBody: []byte(tt.request)}
+			applyMediaUsage(req, []byte(tt.response))
+			if math.Abs(req.Cost-tt.want) > 1e-12 {
+				t.Errorf("cost = %v, want %v", req.Cost, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaResponseRefs(t *testing.T) {
+	speech := &LLMRequest{
+		Path:            "/v1/audio/speech",
+		RequestBody:     []byte(`{"model":"tts-1","input":"hi","response_format":"pcm"}`),
+		ResponseHeaders: map[string][]string{"Content-Type": {"audio/pcm"}},
+		ResponseBody:    []byte{1, 0, 2, 0},
+	}
+	if _, audio := mediaResponseRefs(speech); len(audio) != 1 || audio[0].Format != "wav" || !audio[0].IsOutput {
+		t.Errorf("speech refs = %+v", audio)
+	}
+
+	images := &LLMRequest{
+		Path:         "/v1/images/edits",
+		ResponseBody: []byte(`{"data":[{"b64_json":"iVBORw0KGgo="},{"url":"https://example.com/a.png"}],"output_format":"webp"}`),
+	}
+	refs, _ := mediaResponseRefs(images)
+	if len(refs) != 2 || refs[0].URL != "data:image/webp;base64,iVBORw0KGgo=" || !refs[0].IsBase64 || refs[1].IsBase64 {
+		t.Errorf("image refs = %+v", refs)
+	}
+}
+
+func TestTranscriptionProxyIntegration(t *testing.T) {
+	resetTestState()
+	seedModelsDB(t, map[string]Provider{}, map[string]ModelCost{})
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("upstream couldn't parse the form: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"Hello from the recording."}`))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-media", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	body, contentType := buildTranscriptionForm(t, "proxy-boundary")
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/audio/transcriptions", port), contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.Model != "whisper-1" {
+		t.Errorf("model = %q, want the form field", captured.Model)
+	}
+	deadline := time.Now().Add(time.Second)
+	for captured.Cost == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// One second of audio at $0.006/min
+	if want := 0.006 / 60; math.Abs(captured.Cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", captured.Cost, want)
+	}
+	if text := extractMediaOutputText(captured.ResponseBody); text != "Hello from the recording." {
+		t.Errorf("transcript = %q", text)
+	}
+}
@@ -16,6 +16,9 @@ type ModelCost struct {
 	Reasoning  float64 `json:"reasoning,omitempty"`
 	CacheRead  float64 `json:"cache_read,omitempty"`
 	CacheWrite float64 `json:"cache_write,omitempty"`
+	// Audio token rates for realtime/audio models
+	InputAudio  float64 `json:"input_audio,omitempty"`
+	OutputAudio float64 `json:"output_audio,omitempty"`
 }
 
 type ModelInfo struct {
@@ -129,17 +132,19 @@ func FindProviderByURL(routedURL string) (string, *Provider) {
 
 	// Common provider patterns
 	providerPatterns := map[string][]string{
-		"openai":     {"api.openai.com"},
-		"anthropic":  {"api.anthropic.com"},
-		"google":     {"generativelanguage.googleapis.com"},
-		"openrouter": {"openrouter.ai"},
-		"groq":       {"api.groq.com"},
-		"together":   {"api.together.xyz", "together.ai"},
-		"fireworks":  {"fireworks.ai"},
-		"mistral":    {"api.mistral.ai"},
-		"cohere":     {"api.cohere.ai", "cohere.ai"},
-		"deepseek":   {"api.deepseek.com"},
-		"xai":        {"api.x.ai"},
+		"openai":         {"api.openai.com"},
+		"anthropic":      {"api.anthropic.com"},
+		"google":         {"generativelanguage.googleapis.com"},
+		"openrouter":     {"openrouter.ai"},
+		"groq":           {"api.groq.com"},
+		"together":       {"api.together.xyz", "together.ai"},
+		"fireworks":      {"fireworks.ai"},
+		"mistral":        {"api.mistral.ai"},
+		"cohere":         {"api.cohere.ai", "cohere.ai"},
+		"deepseek":       {"api.deepseek.com"},
+		"xai":            {"api.x.ai"},
+		"azure":          {".openai.azure.com", ".cognitiveservices.azure.com"},
+		"amazon-bedrock": {"bedrock-runtime."},
 	}
 
 	for providerID, patterns := range providerPatterns {
@@ -237,6 +242,14 @@ func lookupGlobalModelCost(modelSlug string) *ModelCost {
 		}
 	}
 
+	// Bedrock model IDs without a Bedrock listing: fall back to the bare model
+	// name (e.g. "anthropic.claude-3-5-sonnet-20241022-v2:0" -> "claude-3-5-sonnet-20241022")
+	if base := bedrockBaseModel(modelSlug); base != "" {
+		if cost, ok := modelsDB.globalModels[base]; ok {
+			return &cost
+		}
+	}
+
 	return nil
 }
 
@@ -253,6 +266,17 @@ func CalculateCost(cost *ModelCost, inputTokens, outputTokens int) float64 {
 	return inputCost + outputCost
 }
 
+// CalculateCostWithCache is CalculateCost with cachedTokens (a subset of
+// inputTokens) billed at the model's cache read price when it has one
+func CalculateCostWithCache(cost *ModelCost, inputTokens, cachedTokens, outputTokens int) float64 {
+	if cost == nil || cachedTokens <= 0 || cost.CacheRead <= 0 || cachedTokens > inputTokens {
+		return CalculateCost(cost, inputTokens, outputTokens)
+	}
+
+	cachedCost := (float64(cachedTokens) / 1_000_000) * cost.CacheRead
+	return CalculateCost(cost, inputTokens-cachedTokens, outputTokens) + cachedCost
+}
+
 // EstimateInputTokens estimates token count from string length
 // Rule of thumb: ~4 characters per token for English text
 func EstimateInputTokens(content string) int {
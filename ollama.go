@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ollama native API (/api/chat, /api/generate). Streaming is on by default
+// and uses newline-delimited JSON instead of SSE. Like the Responses API,
+// requests and responses are mapped onto the chat completions types for display.
+
+type OllamaToolCall struct {
+	Function struct {
+		Name      string `json:"name"`
+		Arguments any    `json:"arguments"` // JSON object, not a string
+	} `json:"function"`
+}
+
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type OllamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"` // /api/chat
+	Prompt   string          `json:"prompt"`   // /api/generate
+	System   string          `json:"system,omitempty"`
+	Stream   *bool           `json:"stream,omitempty"` // Defaults to true
+	Options  struct {
+		Temperature float64 `json:"temperature"`
+		NumPredict  int     `json:"num_predict"`
+	} `json:"options"`
+}
+
+// OllamaResponse is a full response or one NDJSON stream chunk. Timing fields
+// are only set on the final (done) chunk and are in nanoseconds.
+type OllamaResponse struct {
+	Model              string         `json:"model"`
+	CreatedAt          string         `json:"created_at"`
+	Message            *OllamaMessage `json:"message,omitempty"`  // /api/chat
+	Response           string         `json:"response,omitempty"` // /api/generate
+	Thinking           string         `json:"thinking,omitempty"` // /api/generate
+	Done               bool           `json:"done"`
+	DoneReason         string         `json:"done_reason,omitempty"`
+	TotalDuration      int64          `json:"total_duration,omitempty"`
+	LoadDuration       int64          `json:"load_duration,omitempty"`
+	PromptEvalCount    int            `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64          `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int            `json:"eval_count,omitempty"`
+	EvalDuration       int64          `json:"eval_duration,omitempty"`
+}
+
+// isOllamaEndpoint returns true for Ollama's native chat and generate endpoints
+func isOllamaEndpoint(path string) bool {
+	return strings.HasSuffix(path, "/api/chat") || strings.HasSuffix(path, "/api/generate")
+}
+
+// ollamaStreamRequested reports whether an Ollama request streams, which it
+// does unless "stream": false is set
+func ollamaStreamRequested(body []byte) bool {
+	var req OllamaRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	return req.Stream == nil || *req.Stream
+}
+
+// isOllamaNDJSON returns true if the data is a newline-delimited stream of
+// Ollama chunks (each line carries a "done" field)
+func isOllamaNDJSON(data []byte) bool {
+	first, _, found := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	if !found || len(first) == 0 || first[0] != '{' {
+		return false
+	}
+	var probe struct {
+		Done *bool `json:"done"`
+	}
+	return json.Unmarshal(first, &probe) == nil && probe.Done != nil
+}
+
+// parseOllamaResponse parses a full Ollama response or reassembles an NDJSON
+// stream. Returns nil when the body is not an Ollama response.
+func parseOllamaResponse(data []byte) *OllamaResponse {
+	if !isOllamaNDJSON(data) {
+		var resp OllamaResponse
+		if err := json.Unmarshal(data, &resp); err != nil || (resp.Message == nil && resp.Response == "" && !resp.Done) {
+			return nil
+		}
+		return &resp
+	}
+
+	resp := &OllamaResponse{}
+	var content, thinking strings.Builder
+	var toolCalls []OllamaToolCall
+	isChat := false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var chunk OllamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			continue
+		}
+		if chunk.Message != nil {
+			isChat = true
+			content.WriteString(chunk.Message.Content)
+			thinking.WriteString(chunk.Message.Thinking)
+			toolCalls = append(toolCalls, chunk.Message.ToolCalls...)
+		}
+		content.WriteString(chunk.Response)
+		thinking.WriteString(chunk.Thinking)
+		if chunk.Done {
+			// The final chunk carries the stats; keep the accumulated text
+			chunk.Message, chunk.Response, chunk.Thinking = nil, "", ""
+			*resp = chunk
+		} else if resp.Model == "" {
+			resp.Model = chunk.Model
+			resp.CreatedAt = chunk.CreatedAt
+		}
+	}
+
+	if isChat {
+		resp.Message = &OllamaMessage{Role: "assistant", Content: content.String(), Thinking: thinking.String(), ToolCalls: toolCalls}
+	} else {
+		resp.Response = content.String()
+		resp.Thinking = thinking.String()
+	}
+	return resp
+}
+
+// ollamaToolCalls converts tool calls, encoding the argument objects as strings
+func ollamaToolCalls(calls []OllamaToolCall) []ToolCall {
+	var out []ToolCall
+	for i, call := range calls {
+		args, _ := json.Marshal(call.Function.Arguments)
+		out = append(out, ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Index:    i,
+			Function: ToolCallFunction{Name: call.Function.Name, Arguments: string(args)},
+		})
+	}
+	return out
+}
+
+// ollamaRequestToOpenAI maps /api/chat messages or an /api/generate prompt onto chat messages
+func ollamaRequestToOpenAI(body []byte) (*OpenAIRequest, error) {
+	var req OllamaRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	out := &OpenAIRequest{
+		Model:       req.Model,
+		Temperature: req.Options.Temperature,
+		MaxTokens:   req.Options.NumPredict,
+		Stream:      req.Stream == nil || *req.Stream,
+	}
+	if req.System != "" {
+		out.Messages = append(out.Messages, OpenAIMessage{Role: "system", Content: req.System})
+	}
+	for _, msg := range req.Messages {
+		out.Messages = append(out.Messages, OpenAIMessage{
+			Role:             msg.Role,
+			Content:          msg.Content,
+			Name:             msg.ToolName,
+			ReasoningContent: msg.Thinking,
+			ToolCalls:        ollamaToolCalls(msg.ToolCalls),
+		})
+	}
+	if req.Prompt != "" {
+		out.Messages = append(out.Messages, OpenAIMessage{Role: "user", Content: req.Prompt})
+	}
+	return out, nil
+}
+
+// ollamaResponseToOpenAI maps an Ollama response onto a single assistant choice
+func ollamaResponseToOpenAI(resp *OllamaResponse) *OpenAIResponse {
+	msg := OpenAIMessage{Role: "assistant", Content: resp.Response, ReasoningContent: resp.Thinking}
+	if resp.Message != nil {
+		msg.Content = resp.Message.Content
+		msg.ReasoningContent = resp.Message.Thinking
+		msg.ToolCalls = ollamaToolCalls(resp.Message.ToolCalls)
+	}
+
+	finishReason := resp.DoneReason
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	return &OpenAIResponse{
+		Model:   resp.Model,
+		Choices: []OpenAIChoice{{Index: 0, Message: msg, FinishReason: finishReason}},
+		Usage: OpenAIUsage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}
+}
+
+// ollamaTimingSummary formats Ollama's reported timings, e.g.
+// "load 1.2s • prompt 120ms • eval 3.4s"
+func ollamaTimingSummary(resp *OllamaResponse) string {
+	var parts []string
+	if resp.LoadDuration > 0 {
+		parts = append(parts, "load "+formatDuration(time.Duration(resp.LoadDuration)))
+	}
+	if resp.PromptEvalDuration > 0 {
+		parts = append(parts, "prompt "+formatDuration(time.Duration(resp.PromptEvalDuration)))
+	}
+	if resp.EvalDuration > 0 {
+		parts = append(parts, "eval "+formatDuration(time.Duration(resp.EvalDuration)))
+	}
+	return strings.Join(parts, " • ")
+}
+
+// tokensPerSecond returns the generation speed when the provider reported how
+// long generation took (Ollama's eval_duration)
+func tokensPerSecond(req *LLMRequest) float64 {
+	if req.GenerationDuration <= 0 || req.OutputTokens <= 0 {
+		return 0
+	}
+	return float64(req.OutputTokens) / req.GenerationDuration.Seconds()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ollamaChatStreamFixture = `{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"","thinking":"Hmm"},"done":false}
+{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"Hello "},"done":false}
+{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"world"},"done":false}
+{"model":"llama3.2","created_at":"2025-01-01T00:00:01Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","total_duration":3000000000,"load_duration":1000000000,"prompt_eval_count":12,"prompt_eval_duration":100000000,"eval_count":40,"eval_duration":2000000000}
+`
+
+func TestParseOllamaNDJSONStream(t *testing.T) {
+	if !isOllamaNDJSON([]byte(ollamaChatStreamFixture)) {
+		t.Fatal("fixture not detected as an Ollama NDJSON stream")
+	}
+	resp := parseOllamaResponse([]byte(ollamaChatStreamFixture))
+	if resp == nil || resp.Message == nil {
+		t.Fatalf("parseOllamaResponse = %+v", resp)
+	}
+	if resp.Message.Content != "Hello world" || resp.Message.Thinking != "Hmm" {
+		t.Errorf("message = %+v", resp.Message)
+	}
+	if resp.PromptEvalCount != 12 || resp.EvalCount != 40 || resp.DoneReason != "stop" {
+		t.Errorf("stats not taken from the done chunk: %+v", resp)
+	}
+	if got := ollamaTimingSummary(resp); got != "load 1.0s • prompt 100ms • eval 2.0s" {
+		t.Errorf("timing summary = %q", got)
+	}
+
+	generate := `{"model":"llama3.2","response":"Hi","done":false}
+{"model":"llama3.2","response":" there","done":false}
+{"model":"llama3.2","response":"","done":true,"eval_count":2}
+`
+	out := decodeOpenAIResponse("/api/generate", []byte(generate))
+	if out == nil || out.Choices[0].Message.Content != "Hi there" || out.Usage.CompletionTokens != 2 {
+		t.Errorf("generate response = %+v", out)
+	}
+}
+
+func TestOllamaNDJSONOnlyOnOllamaPaths(t *testing.T) {
+	other := &LLMRequest{Path: "/v1/batches/results", Model: "llama3.2"}
+	extractTokenUsage(other, []byte(ollamaChatStreamFixture))
+	if other.OutputTokens != 0 || other.GenerationDuration != 0 {
+		t.Errorf("NDJSON from another endpoint was read as Ollama: %d tokens, %s", other.OutputTokens, other.GenerationDuration)
+	}
+
+	chat := &LLMRequest{Path: "/api/chat", Model: "llama3.2"}
+	extractTokenUsage(chat, []byte(ollamaChatStreamFixture))
+	if chat.InputTokens != 12 || chat.OutputTokens != 40 || chat.GenerationDuration != 2*time.Second {
+		t.Errorf("Ollama chat usage = %d in, %d out, %s", chat.InputTokens, chat.OutputTokens, chat.GenerationDuration)
+	}
+}
+
+func TestOllamaRequestMapping(t *testing.T) {
+	req, err := decodeOpenAIRequest("/api/generate", []byte(`{"model":"llama3.2","system":"Be brief","prompt":"Hi","options":{"temperature":0.2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.Stream || req.Temperature != 0.2 || len(req.Messages) != 2 || req.Messages[1].Content != "Hi" {
+		t.Errorf("mapped request = %+v", req)
+	}
+	if ollamaStreamRequested([]byte(`{"model":"llama3.2","stream":false}`)) {
+		t.Error("stream:false should disable streaming")
+	}
+
+	// /api/generate keys must depend on the prompt and options, not only messages
+	a := GenerateCacheKey("/api/generate", []byte(`{"model":"m","prompt":"a"}`))
+	b := GenerateCacheKey("/api/generate", []byte(`{"model":"m","prompt":"b"}`))
+	c := GenerateCacheKey("/api/generate", []byte(`{"prompt":"a","model":"m"}`))
+	if a == b || a != c {
+		t.Errorf("cache keys: a=%s b=%s c=%s", a, b, c)
+	}
+}
+
+func TestOllamaProxyIntegration(t *testing.T) {
+	resetTestState()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(ollamaChatStreamFixture))
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-ollama", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	url := fmt.Sprintf("http://localhost:%d/api/chat", port)
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"model":"llama3.2","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.Model != "llama3.2" || !captured.IsStreaming {
+		t.Errorf("captured model = %q streaming = %v", captured.Model, captured.IsStreaming)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for captured.OutputTokens == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if captured.InputTokens != 12 || captured.OutputTokens != 40 {
+		t.Errorf("tokens = %d/%d, want 12/40", captured.InputTokens, captured.OutputTokens)
+	}
+	if tps := tokensPerSecond(captured); tps != 20 {
+		t.Errorf("tokensPerSecond = %v, want 20", tps)
+	}
+	if text := extractLLMOutputText(captured); !strings.Contains(text, "Hello world") {
+		t.Errorf("copied output = %q", text)
+	}
+}
@@ -15,7 +15,12 @@ func (m *model) getRequestPreviewSnippet(req *LLMRequest) string {
 	if req == nil {
 		return ""
 	}
-	if len(req.ResponseBody) > 0 {
+	if len(req.ResponseBody) > 0 && isMediaEndpoint(req.Path) {
+		// Transcripts; binary audio and images fall back to the request
+		if snippet := normalizePreviewSnippet(extractMediaOutputText(req.ResponseBody)); snippet != "" {
+			return snippet
+		}
+	} else if len(req.ResponseBody) > 0 {
 		if snippet := extractStreamingResponsePreviewSnippet(req.ResponseBody); snippet != "" {
 			return snippet
 		}
@@ -36,13 +41,33 @@ func extractRequestPreviewSnippet(path string, requestBody []byte) string {
 		return ""
 	}
 
+	if isMediaEndpoint(path) {
+		return extractMediaRequestPreviewSnippet(requestBody)
+	}
 	if isAnthropicEndpoint(path) {
 		return extractAnthropicRequestPreviewSnippet(requestBody)
 	}
 	if isGeminiEndpoint(path) {
 		return extractGeminiRequestPreviewSnippet(requestBody)
 	}
-	return extractOpenAIRequestPreviewSnippet(requestBody)
+	return extractOpenAIRequestPreviewSnippet(path, requestBody)
+}
+
+// extractMediaRequestPreviewSnippet shows the prompt, the text to speak, or
+// the uploaded file name
+func extractMediaRequestPreviewSnippet(requestBody []byte) string {
+	parts := parseMediaRequest(requestBody)
+	for _, name := range []string{"prompt", "input"} {
+		if text := formField(parts, name); text != "" {
+			return normalizePreviewSnippet(text)
+		}
+	}
+	for _, p := range parts {
+		if p.IsFile() {
+			return normalizePreviewSnippet(p.Filename)
+		}
+	}
+	return ""
 }
 
 func extractAnthropicRequestPreviewSnippet(requestBody []byte) string {
@@ -89,9 +114,9 @@ func extractGeminiRequestPreviewSnippet(requestBody []byte) string {
 	return ""
 }
 
-func extractOpenAIRequestPreviewSnippet(requestBody []byte) string {
-	var req OpenAIRequest
-	if err := json.Unmarshal(requestBody, &req); err != nil {
+func extractOpenAIRequestPreviewSnippet(path string, requestBody []byte) string {
+	req, err := decodeOpenAIRequest(path, requestBody)
+	if err != nil {
 		return ""
 	}
 
@@ -164,6 +189,48 @@ func extractStreamingResponsePreviewSnippet(responseBody []byte) string {
 		}
 	}
 
+	// Gemini streams (SSE or a JSON array of chunks)
+	if isGeminiStreamData(responseBody) {
+		if merged := reassembleGeminiSSE(responseBody); merged != nil {
+			if snippet := extractGeminiResponsePreviewSnippet(merged); snippet != "" {
+				return snippet
+			}
+		}
+	}
+
+	// Ollama (NDJSON stream or a single object)
+	if assembled := parseOllamaResponse(responseBody); assembled != nil {
+		choice := ollamaResponseToOpenAI(assembled).Choices[0]
+		if snippet := normalizePreviewSnippet(extractOpenAITextContent(choice.Message.Content)); snippet != "" {
+			return snippet
+		}
+		if snippet := normalizePreviewSnippet(choice.Message.ReasoningContent); snippet != "" {
+			return snippet
+		}
+	}
+
+	// Bedrock Converse (event stream or output message)
+	if assembled := parseConverseResponse(responseBody); assembled != nil {
+		choice := converseResponseToOpenAI("", assembled).Choices[0]
+		if snippet := normalizePreviewSnippet(extractOpenAITextContent(choice.Message.Content)); snippet != "" {
+			return snippet
+		}
+		if snippet := normalizePreviewSnippet(choice.Message.ReasoningContent); snippet != "" {
+			return snippet
+		}
+	}
+
+	// OpenAI Responses API (typed SSE events or output items)
+	if assembled := parseResponsesResponse(responseBody); assembled != nil {
+		choice := responsesResponseToOpenAI(assembled).Choices[0]
+		if snippet := normalizePreviewSnippet(extractOpenAITextContent(choice.Message.Content)); snippet != "" {
+			return snippet
+		}
+		if snippet := normalizePreviewSnippet(choice.Message.ReasoningContent); snippet != "" {
+			return snippet
+		}
+	}
+
 	if isSSEData(responseBody) {
 		// Try OpenAI SSE format
 		if assembled := reassembleSSEResponse(responseBody); assembled != nil {
@@ -209,7 +276,15 @@ func extractStreamingResponsePreviewSnippet(responseBody []byte) string {
 		}
 	}
 
-	// Gemini format: candidates[].content.parts[].text
+	if snippet := extractGeminiResponsePreviewSnippet(responseBody); snippet != "" {
+		return snippet
+	}
+
+	return normalizePreviewSnippet(string(responseBody))
+}
+
+// extractGeminiResponsePreviewSnippet returns the first candidate text (candidates[].content.parts[].text)
+func extractGeminiResponsePreviewSnippet(responseBody []byte) string {
 	var geminiResp struct {
 		Candidates []struct {
 			Content struct {
@@ -228,8 +303,7 @@ func extractStreamingResponsePreviewSnippet(responseBody []byte) string {
 			}
 		}
 	}
-
-	return normalizePreviewSnippet(string(responseBody))
+	return ""
 }
 
 func normalizePreviewSnippet(text string) string {
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// providerErrorType maps an HTTP status to the error type names providers use
+func providerErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusPaymentRequired:
+		return "insufficient_quota"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	case http.StatusServiceUnavailable:
+		return "service_unavailable"
+	default:
+		if status >= 500 {
+			return "api_error"
+		}
+		return "invalid_request_error"
+	}
+}
+
+// providerErrorBody builds an error body shaped like the provider for the path,
+// so SDK clients parse it the same way they would a real upstream error
+func providerErrorBody(path string, status int, message string) []byte {
+	errType := providerErrorType(status)
+
+	var payload any
+	switch {
+	case isAnthropicEndpoint(path):
+		payload = map[string]any{
+			"type": "error",
+			"error": map[string]any{
+				"type":    errType,
+				"message": message,
+			},
+		}
+	case isGeminiEndpoint(path):
+		statusName := http.StatusText(status)
+		if statusName == "" {
+			statusName = "Unavailable"
+		}
+		payload = map[string]any{
+			"error": map[string]any{
+				"code":    status,
+				"message": message,
+				"status":  strings.ToUpper(strings.ReplaceAll(statusName, " ", "_")),
+			},
+		}
+	default:
+		payload = map[string]any{
+			"error": map[string]any{
+				"message": message,
+				"type":    errType,
+				"param":   nil,
+				"code":    errType,
+			},
+		}
+	}
+
+	body, _ := json.Marshal(payload)
+	return body
+}
+
+// writeProviderError writes a provider-shaped JSON error response
+func writeProviderError(w http.ResponseWriter, path string, status int, message string) []byte {
+	body := providerErrorBody(path, status, message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+	return body
+}
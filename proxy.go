@@ -2,16 +2,14 @@ package main
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +34,7 @@ type responseRecorder struct {
 	body           *bytes.Buffer
 	wroteHeader    bool
 	firstWriteTime time.Time // Time of first Write() call (TTFT proxy)
+	writes         []recordedWrite
 	onWrite        func()
 }
 
@@ -61,6 +60,7 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 		r.firstWriteTime = time.Now()
 	}
 	r.body.Write(b)
+	r.writes = append(r.writes, recordedWrite{at: time.Now(), size: len(b)})
 	onWrite := r.onWrite
 	r.mu.Unlock()
 
@@ -93,14 +93,21 @@ func (r *responseRecorder) Flush() {
 }
 
 // extraLLMPaths holds additional path substrings configured via llm_paths in TOML.
-// Populated by RegisterExtraLLMPaths before proxies start.
-var extraLLMPaths []string
+// Populated by RegisterExtraLLMPaths before proxies start and on config reload.
+var (
+	extraLLMPaths   []string
+	extraLLMPathsMu sync.RWMutex
+)
 
-// RegisterExtraLLMPaths collects llm_paths from all proxy configs into the global set.
+// RegisterExtraLLMPaths replaces the global set with llm_paths from all proxy configs.
 func RegisterExtraLLMPaths(proxies []ProxyConfig) {
+	var paths []string
 	for _, p := range proxies {
-		extraLLMPaths = append(extraLLMPaths, p.LLMPaths...)
+		paths = append(paths, p.LLMPaths...)
 	}
+	extraLLMPathsMu.Lock()
+	extraLLMPaths = paths
+	extraLLMPathsMu.Unlock()
 }
 
 func isLLMEndpoint(path string) bool {
@@ -109,14 +116,23 @@ func isLLMEndpoint(path string) bool {
 		"/v1/completions",
 		"/v1/embeddings",
 		"/v1/messages",
+		"/v1/responses",
 		"/chat/completions",
 		"/completions",
+		"/responses",
+		"/api/chat",     // Ollama
+		"/api/generate", // Ollama
 	}
 	for _, p := range llmPaths {
 		if strings.HasSuffix(path, p) {
 			return true
 		}
 	}
+	if isGeminiNativeEndpoint(path) || isBedrockRuntimeEndpoint(path) || isMediaEndpoint(path) {
+		return true
+	}
+	extraLLMPathsMu.RLock()
+	defer extraLLMPathsMu.RUnlock()
 	for _, p := range extraLLMPaths {
 		if strings.Contains(path, p) {
 			return true
@@ -127,13 +143,13 @@ func isLLMEndpoint(path string) bool {
 
 // isGeminiEndpoint returns true if the path is a Google Gemini API endpoint
 func isGeminiEndpoint(path string) bool {
-	return strings.Contains(path, "/proxy/google/") || strings.Contains(path, "generativelanguage.googleapis.com")
+	return strings.Contains(path, "/proxy/google/") || strings.Contains(path, "generativelanguage.googleapis.com") || isGeminiNativeEndpoint(path)
 }
 
 // isAnthropicEndpoint returns true if the path is an Anthropic Messages API endpoint
 // (including Bedrock, which uses the same request/response format)
 func isAnthropicEndpoint(path string) bool {
-	if strings.HasSuffix(path, "/v1/messages") {
+	if strings.HasSuffix(path, "/v1/messages") || isBedrockAnthropicInvoke(path) {
 		return true
 	}
 	// Platform proxy paths for Anthropic and Bedrock both use Anthropic format
@@ -160,94 +176,140 @@ func shouldSkipCache(r *http.Request) bool {
 	return false
 }
 
-// decompressIfNeeded decompresses gzip content if needed
-func decompressIfNeeded(data []byte, contentEncoding string) []byte {
-	if !strings.Contains(strings.ToLower(contentEncoding), "gzip") {
-		return data
-	}
-
-	reader, err := gzip.NewReader(bytes.NewReader(data))
-	if err != nil {
-		// Not actually gzip or corrupted, return as-is
-		return data
-	}
-	defer reader.Close()
-
-	decompressed, err := io.ReadAll(reader)
-	if err != nil {
-		// Decompression failed, return original
-		return data
-	}
-
-	return decompressed
-}
-
 // ProxyInstance represents a running proxy instance
 type ProxyInstance struct {
 	Name       string
 	ListenAddr string
 	TargetURL  string
+	Config     ProxyConfig // Config the instance was started with
 	server     *http.Server
 }
 
-// startProxy starts a single proxy instance (legacy function for backwards compatibility)
-func startProxy(listenAddr, targetURL string) {
-	StartProxyInstance("default", listenAddr, targetURL)
+// proxyInstances tracks running proxies by listen address
+var (
+	proxyInstances   = make(map[string]*ProxyInstance)
+	proxyInstancesMu sync.Mutex
+)
+
+// RunningProxies returns the running proxy instances
+func RunningProxies() []*ProxyInstance {
+	proxyInstancesMu.Lock()
+	defer proxyInstancesMu.Unlock()
+	instances := make([]*ProxyInstance, 0, len(proxyInstances))
+	for _, inst := range proxyInstances {
+		instances = append(instances, inst)
+	}
+	return instances
+}
+
+// StopProxyInstance stops accepting connections on listenAddr and waits up to
+// timeout for in-flight requests to finish
+func StopProxyInstance(listenAddr string, timeout time.Duration) error {
+	proxyInstancesMu.Lock()
+	inst, ok := proxyInstances[listenAddr]
+	delete(proxyInstances, listenAddr)
+	proxyInstancesMu.Unlock()
+	if !ok {
+		return fmt.Errorf("no proxy is listening on %s", listenAddr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := inst.server.Shutdown(ctx); err != nil {
+		// Timed out: drop the remaining connections
+		inst.server.Close()
+		return err
+	}
+	return nil
 }
 
 // StartProxyInstance starts a named proxy instance
 func StartProxyInstance(name, listenAddr, targetURL string) error {
+	return StartProxyFromConfig(ProxyConfig{Name: name, Listen: listenAddr, Target: targetURL})
+}
+
+// StartProxyFromConfig starts a proxy instance, including any routing rules
+func StartProxyFromConfig(cfg ProxyConfig) error {
 	// Load models.dev database in background (only once)
 	LoadModelsDB()
 
-	target, err := url.Parse(targetURL)
-	if err != nil {
-		return fmt.Errorf("invalid target URL: %w", err)
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Listen
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(target)
-
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		req.Host = target.Host
+	faults, err := parseFaultRules(cfg.Faults)
+	if err != nil {
+		return err
 	}
 
-	// Handle proxy errors (upstream unreachable, DNS failure, etc.)
-	// Write a 502 status so the request is properly marked as failed in the TUI.
-	// Don't log to stderr as it disrupts the TUI layout.
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		w.WriteHeader(http.StatusBadGateway)
+	var handler http.Handler
+	if cfg.Mode == ProxyModeForward {
+		// CONNECT requests must reach the handler as-is, so skip the ServeMux
+		fp, err := newForwardProxy(cfg, name, faults)
+		if err != nil {
+			return err
+		}
+		handler = fp
+	} else {
+		rt, err := newRouter(cfg)
+		if err != nil {
+			return err
+		}
+
+		// Create a new ServeMux for this proxy instance
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", createProxyHandler(&proxyRuntime{
+			name:        name,
+			listen:      cfg.Listen,
+			router:      rt,
+			faults:      faults,
+			cacheIgnore: cfg.CacheIgnoreFields,
+		}))
+		handler = mux
 	}
 
-	// Flush immediately for streaming responses (SSE)
-	proxy.FlushInterval = -1
-
-	// Modify response to capture and potentially decompress
-	proxy.ModifyResponse = func(resp *http.Response) error {
-		return nil
+	// Bind up front so a busy port is reported to the caller
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to listen on %s: %w", name, cfg.Listen, err)
 	}
 
-	// Create a new ServeMux for this proxy instance
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", createProxyHandler(name, listenAddr, target, proxy))
-
 	server := &http.Server{
-		Addr:    listenAddr,
-		Handler: mux,
+		Addr:    cfg.Listen,
+		Handler: handler,
 	}
 
+	proxyInstancesMu.Lock()
+	proxyInstances[cfg.Listen] = &ProxyInstance{
+		Name:       name,
+		ListenAddr: cfg.Listen,
+		TargetURL:  cfg.Target,
+		Config:     cfg,
+		server:     server,
+	}
+	proxyInstancesMu.Unlock()
+
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("[%s] Server error on %s: %v", name, listenAddr, err)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("[%s] Server error on %s: %v", name, cfg.Listen, err)
 		}
 	}()
 
 	return nil
 }
 
+// proxyRuntime holds the parsed per-proxy settings used by the handler
+type proxyRuntime struct {
+	name        string
+	listen      string
+	router      *router
+	faults      []*faultRule
+	cacheIgnore []string // Request fields left out of cache keys
+}
+
 // createProxyHandler creates an HTTP handler for a proxy instance
-func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *httputil.ReverseProxy) http.HandlerFunc {
+func createProxyHandler(p *proxyRuntime) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
 
@@ -258,21 +320,65 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 			r.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		}
 
-		isLLM := isLLMEndpoint(r.URL.Path)
-		if !isLLM {
-			proxy.ServeHTTP(w, r)
-			return
-		}
-
 		// Parse model from request
 		model := "unknown"
 		var openAIReq OpenAIRequest
-		if json.Unmarshal(requestBody, &openAIReq) == nil && openAIReq.Model != "" {
+		formParts, isForm := parseMultipartBody(requestBody)
+		if isForm {
+			// Uploads (transcriptions, image edits) send parameters as form fields
+			openAIReq.Model = formField(formParts, "model")
+			openAIReq.Stream = formField(formParts, "stream") == "true"
+		}
+		if (isForm || json.Unmarshal(requestBody, &openAIReq) == nil) && openAIReq.Model != "" {
 			model = openAIReq.Model
+		} else if pathModel := geminiModelFromPath(r.URL.Path); pathModel != "" && isGeminiNativeEndpoint(r.URL.Path) {
+			// Gemini puts the model in the path (models/gemini-2.5-flash:generateContent)
+			model = pathModel
+		} else if bedrockModel := bedrockModelFromPath(r.URL.Path); bedrockModel != "" {
+			// So does Bedrock (/model/{modelId}/converse)
+			model = bedrockModel
+		}
+		// Azure OpenAI names a deployment in the path; it stands in for the
+		// model until the response says which model served it
+		deployment := azureDeploymentFromPath(r.URL.Path)
+		if deployment != "" && model == "unknown" {
+			model = deployment
+		}
+
+		// Pick the upstream for this request
+		up := p.router.match(r, model)
+		if up == nil {
+			http.Error(w, "llmproxy: no route matches this request", http.StatusBadGateway)
+			return
+		}
+		target := up.target
+		proxy := up.proxy
+
+		// Client labels are for the proxy only; routes may still match on them above
+		clientTags := takeClientTags(r.Header)
+
+		isLLM := isLLMEndpoint(r.URL.Path)
+		if GetCacheConfig().Mode == CacheModeReplayOnly && (!isLLM || isWebSocketUpgrade(r)) {
+			// Nothing the cache can't answer may reach the upstream
+			noteCacheMiss(r.URL.Path+":"+r.Method, r.URL.Path, "", nil, nil)
+			writeProviderError(w, r.URL.Path, http.StatusNotFound, fmt.Sprintf("llmproxy: replay-only cache doesn't forward %s %s, which isn't a cacheable LLM request", r.Method, r.URL.Path))
+			return
+		}
+		if isWebSocketUpgrade(r) && (isLLM || isRealtimeEndpoint(r.URL.Path)) {
+			serveWebSocket(p, w, r, up, model, clientTags, startTime)
+			return
+		}
+		if !isLLM {
+			proxy.ServeHTTP(w, r)
+			return
 		}
 
 		// Check if streaming is requested
-		isStreaming := openAIReq.Stream
+		isStreaming := openAIReq.Stream || isGeminiStreamEndpoint(r.URL.Path) || isBedrockStreamEndpoint(r.URL.Path)
+		if isOllamaEndpoint(r.URL.Path) {
+			// Ollama streams unless "stream": false
+			isStreaming = ollamaStreamRequested(requestBody)
+		}
 
 		// Copy request headers
 		reqHeaders := make(map[string][]string)
@@ -294,16 +400,43 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 
 		// Estimate input tokens from request body size
 		estimatedTokens := EstimateInputTokens(string(requestBody))
+		if isForm {
+			// Uploaded files aren't prompt tokens
+			estimatedTokens = EstimateInputTokens(formText(formParts))
+		}
 
-		// Generate cache key and check cache (only for non-streaming requests without no-cache header)
+		// Generate cache key and check cache (skipped with a no-cache header,
+		// except in replay-only mode, which never calls the upstream)
 		cache := GetCache()
-		cacheKey := GenerateCacheKey(r.URL.Path, requestBody)
+		cacheMode := GetCacheConfig().Mode
+		keyInfo := cacheKeyFor(r.URL.Path, r.URL.RawQuery, requestBody, p.cacheIgnore)
+		cacheKey := keyInfo.Key
 		var cachedEntry *CacheEntry
 		var cacheHit bool
-		skipCache := shouldSkipCache(r)
+		skipCache := shouldSkipCache(r) && cacheMode != CacheModeReplayOnly
+
+		// Enforce hard budget caps before anything is forwarded
+		budgetErr := activeBudget.Check(model, p.name)
+		var budgetExceeded string
+		if budgetErr != nil {
+			budgetExceeded = budgetErr.Error()
+			skipCache = true
+		}
+
+		// Decide up front whether a fault fires, so it is visible from the start
+		fault := pickFault(p.faults, r.URL.Path, model, isStreaming)
+		var injectedFault string
+		if fault != nil {
+			injectedFault = fault.description()
+			skipCache = skipCache || cacheMode != CacheModeReplayOnly
+		}
 
-		if !isStreaming && !skipCache {
+		// Record mode refreshes every entry, so it never reads them
+		if !skipCache && cacheMode != CacheModeRecord {
 			cachedEntry, cacheHit = cache.Get(cacheKey)
+			if cacheHit {
+				cache.RecordHit(cacheKey)
+			}
 		}
 
 		// Create request entry
@@ -325,9 +458,19 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 			ProviderID:           providerID,
 			EstimatedInputTokens: estimatedTokens,
 			CachedResponse:       cacheHit,
-			ProxyName:            proxyName,
-			ProxyListen:          listenAddr,
-		}
+			CacheKey:             cacheKey,
+			CacheKeyFields:       keyInfo.Fields,
+			CacheIgnoredFields:   keyInfo.Ignored,
+			ProxyName:            p.name,
+			ProxyListen:          p.listen,
+			RouteName:            up.route,
+			TargetURL:            target.String(),
+			Deployment:           deployment,
+			InjectedFault:        injectedFault,
+			BudgetExceeded:       budgetExceeded,
+		}
+		applyClientTags(req, clientTags)
+		stampCorrelation(req, r, w.Header(), r.Header)
 		requests = append(requests, req)
 		requestsMu.Unlock()
 		RecordSessionRequest(req)
@@ -372,86 +515,125 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 			return strings.Join(parts, "; ")
 		}
 
-		var finalizeOnce sync.Once
+		// Write timings of a streamed response, stored with its cache entry
+		var streamChunks []CacheChunk
+
 		finalize := func(statusCode int, respHeaders map[string][]string, responseBody []byte, responseSize int) {
-			finalizeOnce.Do(func() {
-				req.Duration = time.Since(startTime)
-				req.StatusCode = statusCode
-				req.ResponseHeaders = respHeaders
-				req.ResponseBody = responseBody
-				req.ResponseSize = responseSize
-
-				if statusCode >= 200 && statusCode < 300 {
-					req.Status = StatusComplete
-
-					// Store successful response in cache (non-streaming only, respects no-cache header)
-					if !isStreaming && !skipCache {
-						cacheEntry := &CacheEntry{
-							ResponseBody:    responseBody,
-							ResponseHeaders: respHeaders,
-							StatusCode:      statusCode,
-							Duration:        req.Duration,
-							CreatedAt:       time.Now(),
-						}
-						cache.Set(cacheKey, cacheEntry)
-					}
-				} else {
-					req.Status = StatusError
-				}
+			if !claimFinalize(req) {
+				return // Shutdown already marked it as cut off
+			}
+			success := statusCode >= 200 && statusCode < 300
 
-				if len(responseBody) > 0 {
-					// Extract token usage from response (non-blocking)
-					go extractTokenUsage(req, responseBody)
+			requestsMu.Lock()
+			req.Duration = time.Since(startTime)
+			req.StatusCode = statusCode
+			req.ResponseHeaders = respHeaders
+			req.ResponseBody = responseBody
+			req.ResponseSize = responseSize
+			if success {
+				req.Status = StatusComplete
+			} else {
+				req.Status = StatusError
+			}
+			requestsMu.Unlock()
+
+			// Store successful response in cache (respects no-cache header).
+			// Bodies that couldn't be decoded are skipped: entries are replayed without Content-Encoding
+			if success && !cacheHit && !skipCache && req.DecodeError == "" {
+				cacheEntry := &CacheEntry{
+					ResponseBody:    responseBody,
+					ResponseHeaders: respHeaders,
+					StatusCode:      statusCode,
+					Duration:        req.Duration,
+					CreatedAt:       time.Now(),
+					Streaming:       isStreaming,
+					TTFT:            req.TTFT,
+					Chunks:          streamChunks,
+					Model:           model,
 				}
-
-				RecordSessionRequest(req)
-
-				// Write to tape if recording
-				if tapeWriter != nil {
-					tapeWriter.WriteRequestComplete(req)
+				if !isForm {
+					cacheEntry.RequestBody = requestBody // Uploads would double the entry's size
 				}
-
-				// Notify TUI
-				if program != nil {
-					program.Send(requestUpdatedMsg{req: req})
+				if cache.Set(cacheKey, cacheEntry) == nil {
+					markCacheRecorded(cacheKey)
 				}
-			})
-		}
+			}
 
-		// Handle cache hit
-		if cacheHit && cachedEntry != nil {
-			// Simulate latency if configured
-			config := GetCacheConfig()
-			if config.SimulateLatency && cachedEntry.Duration > 0 {
-				time.Sleep(cachedEntry.Duration)
+			if len(responseBody) > 0 {
+				// Extract token usage from response (non-blocking)
+				go extractTokenUsage(req, responseBody)
 			}
 
-			// Write cached response headers, but skip headers that don't apply
-			// to the decompressed cached body
-			skipHeaders := map[string]bool{
-				"Content-Encoding":  true, // Body is stored decompressed
-				"Content-Length":    true, // Will set correct length below
-				"Transfer-Encoding": true, // Not chunked anymore
-				"Connection":        true, // Let Go handle this
-				"Keep-Alive":        true, // Let Go handle this
+			RecordSessionRequest(req)
+
+			// Write to tape if recording
+			if tapeWriter != nil {
+				tapeWriter.WriteRequestComplete(req)
 			}
-			for k, v := range cachedEntry.ResponseHeaders {
-				if skipHeaders[k] {
-					continue
-				}
-				for _, val := range v {
-					w.Header().Add(k, val)
+
+			// Notify TUI
+			if program != nil {
+				program.Send(requestUpdatedMsg{req: req})
+			}
+		}
+
+		// Reject requests over a hard budget cap
+		if budgetErr != nil {
+			body := writeProviderError(w, r.URL.Path, http.StatusPaymentRequired, "llmproxy: "+budgetExceeded)
+			finalize(http.StatusPaymentRequired, map[string][]string{"Content-Type": {"application/json"}}, body, len(body))
+			return
+		}
+
+		// Apply faults that replace or delay the upstream call
+		if fault != nil {
+			switch fault.cfg.Type {
+			case FaultLatency:
+				timer := time.NewTimer(fault.latency)
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					timer.Stop()
 				}
+			case FaultStatus:
+				message := "llmproxy injected fault: " + injectedFault
+				body := writeProviderError(w, r.URL.Path, fault.cfg.Status, message)
+				finalize(fault.cfg.Status, map[string][]string{"Content-Type": {"application/json"}}, body, len(body))
+				return
+			case FaultReset:
+				finalize(0, nil, nil, 0)
+				resetConnection(w)
+				return
+			}
+		}
+
+		// Handle cache hit, replaying streams with their recorded pacing
+		if cacheHit && cachedEntry != nil {
+			written, ttft, complete := replayCachedResponse(w, r, cachedEntry, GetCacheConfig().replaySpeed())
+			req.TTFT = ttft
+			if !complete {
+				req.CancelReason = buildCancelReason(time.Since(startTime), written, ttft, cachedEntry.Streaming)
+				body := cachedEntry.ResponseBody[:written]
+				finalize(499, cachedEntry.ResponseHeaders, body, len(body))
+				return
 			}
-			// Set correct Content-Length for the decompressed body
-			w.Header().Set("Content-Length", strconv.Itoa(len(cachedEntry.ResponseBody)))
-			w.WriteHeader(cachedEntry.StatusCode)
-			w.Write(cachedEntry.ResponseBody)
 			finalize(cachedEntry.StatusCode, cachedEntry.ResponseHeaders, cachedEntry.ResponseBody, len(cachedEntry.ResponseBody))
 			return
 		}
 
+		// Fixture modes report misses, and replay-only answers them itself
+		switch {
+		case cacheMode == CacheModeReplayOnly:
+			miss := noteCacheMiss(cacheKey, r.URL.Path, model, requestBody, p.cacheIgnore)
+			miss.compareWith(cache)
+			body := writeProviderError(w, r.URL.Path, http.StatusNotFound, replayMissMessage(miss))
+			finalize(http.StatusNotFound, map[string][]string{"Content-Type": {"application/json"}}, body, len(body))
+			return
+		case cacheMode == CacheModeRecordMissing && !skipCache:
+			noteCacheMiss(cacheKey, r.URL.Path, model, requestBody, p.cacheIgnore) // Compared in the summary
+		}
+
 		// Proxy the request
+		r = r.WithContext(withLLMRequest(r.Context(), req))
 		recorder := newResponseRecorder(w)
 
 		var liveUpdateMu sync.Mutex
@@ -523,7 +705,16 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 			}
 
 			// Decompress if needed for storage (we still pass compressed data to client)
-			decompressedBody := decompressIfNeeded(responseBody, contentEncoding)
+			decompressedBody, decodeErr := decodeBody(responseBody, contentEncoding)
+			if decodeErr != nil {
+				req.DecodeError = decodeErr.Error()
+			}
+
+			// Only complete streams are cached, and write sizes only line up
+			// with the stored body when it wasn't compressed
+			if isStreaming && overrideStatusCode == 0 && (contentEncoding == "" || strings.EqualFold(contentEncoding, "identity")) {
+				streamChunks = recorder.chunkTimings(startTime)
+			}
 
 			statusCode := recorderStatusCode
 			if overrideStatusCode > 0 {
@@ -537,12 +728,23 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 				if !firstWriteTime.IsZero() {
 					ttft = firstWriteTime.Sub(startTime)
 				}
-				req.CancelReason = buildCancelReason(elapsed, len(responseBody), ttft, isStreaming)
+				if proxyShuttingDown.Load() {
+					req.CancelReason = shutdownCancelReason
+				} else {
+					req.CancelReason = buildCancelReason(elapsed, len(responseBody), ttft, isStreaming)
+				}
 			}
 
 			finalize(statusCode, respHeaders, decompressedBody, len(responseBody))
 		}
 
+		// Stall/truncate faults sit in front of the recorder so it only
+		// captures what the client actually received
+		var fw *faultWriter
+		if fault != nil && (fault.cfg.Type == FaultStall || fault.cfg.Type == FaultTruncate) {
+			fw = &faultWriter{ResponseWriter: recorder, rule: fault, done: r.Context().Done()}
+		}
+
 		cancelDone := make(chan struct{})
 		go func() {
 			select {
@@ -553,7 +755,19 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 			}
 		}()
 
-		proxy.ServeHTTP(recorder, r)
+		if fw == nil {
+			proxy.ServeHTTP(recorder, r)
+		} else {
+			func() {
+				// ReverseProxy aborts with ErrAbortHandler when a truncate fault fails a write
+				defer func() {
+					if rec := recover(); rec != nil && !(rec == http.ErrAbortHandler && fw.isTruncated()) {
+						panic(rec)
+					}
+				}()
+				proxy.ServeHTTP(fw, r)
+			}()
+		}
 		close(cancelDone)
 		if r.Context().Err() != nil {
 			finalizeFromRecorder(499)
@@ -561,6 +775,11 @@ func createProxyHandler(proxyName, listenAddr string, target *url.URL, proxy *ht
 		}
 
 		finalizeFromRecorder(0)
+
+		// Drop the client connection mid-stream for truncate faults
+		if fw != nil && fw.isTruncated() {
+			panic(http.ErrAbortHandler)
+		}
 	}
 }
 
@@ -571,10 +790,10 @@ func StartMultipleProxies(proxies []ProxyConfig) error {
 		if name == "" {
 			name = p.Listen
 		}
-		if err := StartProxyInstance(name, p.Listen, p.Target); err != nil {
+		if err := StartProxyFromConfig(p); err != nil {
 			return fmt.Errorf("failed to start proxy %s: %w", name, err)
 		}
-		log.Printf("Started proxy [%s]: %s -> %s", name, p.Listen, p.Target)
+		log.Printf("Started proxy [%s]: %s -> %s (%d routes)", name, p.Listen, p.Target, len(p.Routes))
 	}
 	return nil
 }
@@ -593,6 +812,45 @@ func extractTokenUsage(req *LLMRequest, responseBody []byte) {
 		return
 	}
 
+	// Azure requests show the deployment until the response names the model
+	if req.Deployment != "" {
+		if model := responseModel(responseBody); model != "" {
+			req.Model = model
+		}
+	}
+
+	// Audio and image endpoints are billed per image, minute or character
+	if isMediaEndpoint(req.Path) {
+		applyMediaUsage(req, responseBody)
+		recordBudgetSpend(req)
+		RecordSessionRequest(req)
+		if program != nil {
+			program.Send(requestUpdatedMsg{req: req})
+		}
+		return
+	}
+
+	// Ollama streams NDJSON; fold it into one response with the final counts
+	if isOllamaEndpoint(req.Path) && isOllamaNDJSON(responseBody) {
+		if merged := parseOllamaResponse(responseBody); merged != nil {
+			responseBody, _ = json.Marshal(merged)
+		}
+	}
+
+	// Bedrock converse-stream is a binary event stream; fold it into a Converse response
+	if isBedrockConverseEndpoint(req.Path) && isAWSEventStream(responseBody) {
+		if merged := reassembleConverseStream(responseBody); merged != nil {
+			responseBody, _ = json.Marshal(merged)
+		}
+	}
+
+	// Gemini streamGenerateContent without alt=sse returns a JSON array of chunks
+	if isGeminiEndpoint(req.Path) && isJSONArrayStream(responseBody) {
+		if merged := reassembleGeminiSSE(responseBody); merged != nil {
+			responseBody = merged
+		}
+	}
+
 	// Check for SSE data (streaming response)
 	// SSE streams may start with comment lines (": comment"), event lines, or data lines
 	sseData := responseBody
@@ -612,11 +870,12 @@ func extractTokenUsage(req *LLMRequest, responseBody []byte) {
 			// Prefer provider-reported cost (e.g., OpenRouter)
 			req.Cost = providerCost
 		} else if req.Model != "" && (req.InputTokens > 0 || req.OutputTokens > 0) {
-			cost := GetModelCost(req.ProviderID, req.Model)
+			cost := GetModelCost(req.ProviderID, pricingModel(req))
 			if cost != nil {
-				req.Cost = CalculateCost(cost, req.InputTokens, req.OutputTokens)
+				req.Cost = CalculateCostWithCache(cost, req.InputTokens, req.CachedInputTokens, req.OutputTokens)
 			}
 		}
+		recordBudgetSpend(req)
 		RecordSessionRequest(req)
 		if program != nil && (req.InputTokens > 0 || req.OutputTokens > 0) {
 			program.Send(requestUpdatedMsg{req: req})
@@ -633,13 +892,17 @@ func extractTokenUsage(req *LLMRequest, responseBody []byte) {
 			InputTokens      int     `json:"input_tokens"`
 			OutputTokens     int     `json:"output_tokens"`
 			Cost             float64 `json:"cost"`
+			// Bedrock Converse format
+			ConverseInputTokens  int `json:"inputTokens"`
+			ConverseOutputTokens int `json:"outputTokens"`
+			CacheReadInputTokens int `json:"cacheReadInputTokens"`
 		} `json:"usage"`
 		// Gemini format
-		UsageMetadata struct {
-			PromptTokenCount     int `json:"promptTokenCount"`
-			CandidatesTokenCount int `json:"candidatesTokenCount"`
-			TotalTokenCount      int `json:"totalTokenCount"`
-		} `json:"usageMetadata"`
+		UsageMetadata geminiUsageMetadata `json:"usageMetadata"`
+		// Ollama format (durations in nanoseconds)
+		PromptEvalCount int   `json:"prompt_eval_count"`
+		EvalCount       int   `json:"eval_count"`
+		EvalDuration    int64 `json:"eval_duration"`
 	}
 
 	if err := json.Unmarshal(responseBody, &resp); err != nil {
@@ -659,22 +922,39 @@ func extractTokenUsage(req *LLMRequest, responseBody []byte) {
 	}
 
 	// Gemini format fallback (usageMetadata)
-	if req.InputTokens == 0 && resp.UsageMetadata.PromptTokenCount > 0 {
-		req.InputTokens = resp.UsageMetadata.PromptTokenCount
+	if req.InputTokens == 0 && req.OutputTokens == 0 {
+		resp.UsageMetadata.apply(req)
 	}
-	if req.OutputTokens == 0 && resp.UsageMetadata.CandidatesTokenCount > 0 {
-		req.OutputTokens = resp.UsageMetadata.CandidatesTokenCount
+
+	// Ollama format fallback (prompt_eval_count, eval_count)
+	if req.InputTokens == 0 && req.OutputTokens == 0 && (resp.PromptEvalCount > 0 || resp.EvalCount > 0) {
+		req.InputTokens = resp.PromptEvalCount
+		req.OutputTokens = resp.EvalCount
+		req.GenerationDuration = time.Duration(resp.EvalDuration)
+	}
+
+	// Bedrock Converse format fallback (inputTokens excludes cache reads)
+	if req.InputTokens == 0 && req.OutputTokens == 0 && (resp.Usage.ConverseInputTokens > 0 || resp.Usage.ConverseOutputTokens > 0) {
+		req.InputTokens = resp.Usage.ConverseInputTokens + resp.Usage.CacheReadInputTokens
+		req.OutputTokens = resp.Usage.ConverseOutputTokens
+		req.CachedInputTokens = resp.Usage.CacheReadInputTokens
+	}
+
+	// Bedrock InvokeModel reports counts in headers for every model vendor
+	if req.InputTokens == 0 && req.OutputTokens == 0 {
+		applyBedrockTokenHeaders(req)
 	}
 
 	// Calculate cost: prefer provider-reported cost, fallback to model DB lookup
 	if resp.Usage.Cost > 0 {
 		req.Cost = resp.Usage.Cost
 	} else if req.Model != "" {
-		cost := GetModelCost(req.ProviderID, req.Model)
+		cost := GetModelCost(req.ProviderID, pricingModel(req))
 		if cost != nil {
-			req.Cost = CalculateCost(cost, req.InputTokens, req.OutputTokens)
+			req.Cost = CalculateCostWithCache(cost, req.InputTokens, req.CachedInputTokens, req.OutputTokens)
 		}
 	}
+	recordBudgetSpend(req)
 	RecordSessionRequest(req)
 
 	// Notify TUI of the update (if tokens were extracted)
@@ -962,16 +1242,30 @@ func extractTokenUsageFromSSE(req *LLMRequest, data []byte) (providerCost float6
 				Cost             float64 `json:"cost"`
 			} `json:"usage"`
 			// Gemini format
-			UsageMetadata struct {
-				PromptTokenCount     int `json:"promptTokenCount"`
-				CandidatesTokenCount int `json:"candidatesTokenCount"`
-			} `json:"usageMetadata"`
+			UsageMetadata geminiUsageMetadata `json:"usageMetadata"`
+			// OpenAI Responses API: usage in response.completed
+			Response struct {
+				Usage ResponsesUsage `json:"usage"`
+			} `json:"response"`
+			// Bedrock InvokeModel streams: final chunk of every model vendor
+			InvocationMetrics struct {
+				InputTokenCount  int `json:"inputTokenCount"`
+				OutputTokenCount int `json:"outputTokenCount"`
+			} `json:"amazon-bedrock-invocationMetrics"`
 		}
 
 		if err := json.Unmarshal([]byte(jsonData), &event); err != nil {
 			continue
 		}
 
+		// OpenAI Responses API: response.completed/incomplete carries the final usage
+		if event.Response.Usage.InputTokens > 0 {
+			req.InputTokens = event.Response.Usage.InputTokens
+		}
+		if event.Response.Usage.OutputTokens > 0 {
+			req.OutputTokens = event.Response.Usage.OutputTokens
+		}
+
 		// Anthropic message_start: input_tokens in message.usage
 		if event.Type == "message_start" && event.Message.Usage.InputTokens > 0 {
 			req.InputTokens = event.Message.Usage.InputTokens
@@ -991,11 +1285,14 @@ func extractTokenUsageFromSSE(req *LLMRequest, data []byte) (providerCost float6
 		}
 
 		// Gemini: usageMetadata in each SSE chunk (last chunk has final counts)
-		if event.UsageMetadata.PromptTokenCount > 0 {
-			req.InputTokens = event.UsageMetadata.PromptTokenCount
+		event.UsageMetadata.apply(req)
+
+		// Bedrock: invocation metrics on the last chunk (fills in non-Anthropic models)
+		if req.InputTokens == 0 && event.InvocationMetrics.InputTokenCount > 0 {
+			req.InputTokens = event.InvocationMetrics.InputTokenCount
 		}
-		if event.UsageMetadata.CandidatesTokenCount > 0 {
-			req.OutputTokens = event.UsageMetadata.CandidatesTokenCount
+		if req.OutputTokens == 0 && event.InvocationMetrics.OutputTokenCount > 0 {
+			req.OutputTokens = event.InvocationMetrics.OutputTokenCount
 		}
 
 		// Provider-reported cost (e.g., OpenRouter includes cost in usage)
@@ -9,7 +9,9 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -67,6 +69,15 @@ func TestIsLLMEndpoint(t *testing.T) {
 		{"/v1/embeddings", true},
 		{"/chat/completions", true},
 		{"/completions", true},
+		{"/v1/responses", true},
+		{"/v1beta/models/gemini-2.5-flash:generateContent", true},
+		{"/v1beta/models/gemini-2.5-flash:streamGenerateContent", true},
+		{"/api/chat", true},
+		{"/api/generate", true},
+		{"/v1/audio/transcriptions", true},
+		{"/v1/audio/speech", true},
+		{"/v1/images/edits", true},
+		{"/v1/images/generations", true},
 		{"/v1/models", false},
 		{"/health", false},
 		{"/other", false},
@@ -1206,6 +1217,332 @@ func TestNonLLMEndpointPassthrough(t *testing.T) {
 	}
 }
 
+func TestModelRoutingSelectsTarget(t *testing.T) {
+	resetTestState()
+
+	newUpstreamServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":"resp","choices":[{"message":{"role":"assistant","content":%q}}]}`, name)
+		}))
+	}
+	openaiServer := newUpstreamServer("openai")
+	defer openaiServer.Close()
+	anthropicServer := newUpstreamServer("anthropic")
+	defer anthropicServer.Close()
+
+	port := getFreePort(t)
+	err := StartProxyFromConfig(ProxyConfig{
+		Name:   "router",
+		Listen: fmt.Sprintf(":%d", port),
+		Target: openaiServer.URL,
+		Routes: []RouteConfig{
+			{Name: "claude", Model: "claude-*", Target: anthropicServer.URL},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	send := func(model string) string {
+		body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"hi"}]}`, model)
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	if got := send("claude-sonnet-4"); !strings.Contains(got, "anthropic") {
+		t.Errorf("claude request reached wrong upstream: %s", got)
+	}
+	if got := send("gpt-4o"); !strings.Contains(got, "openai") {
+		t.Errorf("gpt request reached wrong upstream: %s", got)
+	}
+
+	routed := waitForRequest(t, 1, 2*time.Second)
+	if routed.RouteName != "claude" {
+		t.Errorf("RouteName = %q, want claude", routed.RouteName)
+	}
+	if routed.TargetURL != anthropicServer.URL {
+		t.Errorf("TargetURL = %q, want %q", routed.TargetURL, anthropicServer.URL)
+	}
+	if routed.ProxyLabel() != "router/claude" {
+		t.Errorf("ProxyLabel() = %q, want router/claude", routed.ProxyLabel())
+	}
+
+	fallback := waitForRequest(t, 2, 2*time.Second)
+	if fallback.RouteName != "" {
+		t.Errorf("fallback RouteName = %q, want empty", fallback.RouteName)
+	}
+	if fallback.ProxyLabel() != "router" {
+		t.Errorf("fallback ProxyLabel() = %q, want router", fallback.ProxyLabel())
+	}
+}
+
+func TestRetryPolicyRetriesThenSucceeds(t *testing.T) {
+	resetTestState()
+
+	var calls int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"gpt-4o"`) {
+			t.Errorf("retried request lost its body: %q", body)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer mockServer.Close()
+
+	port := getFreePort(t)
+	err := StartProxyFromConfig(ProxyConfig{
+		Name:   "retry",
+		Listen: fmt.Sprintf(":%d", port),
+		Target: mockServer.URL,
+		Retry:  RetryConfig{MaxAttempts: 3, InitialBackoff: "1ms"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200 after retry", resp.StatusCode)
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if len(captured.Attempts) != 2 {
+		t.Fatalf("len(Attempts) = %d, want 2", len(captured.Attempts))
+	}
+	if captured.Attempts[0].StatusCode != 429 || captured.Attempts[1].StatusCode != 200 {
+		t.Errorf("attempt codes = %d,%d, want 429,200", captured.Attempts[0].StatusCode, captured.Attempts[1].StatusCode)
+	}
+}
+
+func TestRetryPolicyFailsOverToFallback(t *testing.T) {
+	resetTestState()
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("fallback path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"fallback"}}]}`))
+	}))
+	defer fallback.Close()
+
+	port := getFreePort(t)
+	err := StartProxyFromConfig(ProxyConfig{
+		Name:   "failover",
+		Listen: fmt.Sprintf(":%d", port),
+		Target: primary.URL,
+		Retry:  RetryConfig{FallbackTargets: []string{fallback.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "fallback") {
+		t.Fatalf("got %d %s, want 200 from fallback", resp.StatusCode, body)
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if len(captured.Attempts) != 2 {
+		t.Fatalf("len(Attempts) = %d, want 2", len(captured.Attempts))
+	}
+	if captured.Attempts[1].Target != fallback.URL {
+		t.Errorf("second attempt target = %q, want %q", captured.Attempts[1].Target, fallback.URL)
+	}
+	if captured.Attempts[0].Backoff != 0 {
+		t.Errorf("failover should not back off, got %s", captured.Attempts[0].Backoff)
+	}
+	if fallbackURL, _ := url.Parse(fallback.URL); captured.TargetURL != fallback.URL || captured.Host != fallbackURL.Host {
+		t.Errorf("request target = %s (host %s), want the fallback %s", captured.TargetURL, captured.Host, fallback.URL)
+	}
+}
+
+func TestRetryPolicyFailsOverPastLongRetryAfter(t *testing.T) {
+	resetTestState()
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"fallback"}}]}`))
+	}))
+	defer fallback.Close()
+
+	port := getFreePort(t)
+	err := StartProxyFromConfig(ProxyConfig{
+		Name:   "failover-retry-after",
+		Listen: fmt.Sprintf(":%d", port),
+		Target: primary.URL,
+		Retry:  RetryConfig{FallbackTargets: []string{fallback.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "fallback") {
+		t.Fatalf("got %d %s, want 200 from fallback", resp.StatusCode, body)
+	}
+	if captured := waitForRequest(t, 1, 2*time.Second); len(captured.Attempts) != 2 || captured.Attempts[0].Backoff != 0 {
+		t.Errorf("attempts = %+v, want an immediate failover", captured.Attempts)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"5", 5 * time.Second, true},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v,%v, want %v,%v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFaultInjectionStatus(t *testing.T) {
+	resetTestState()
+
+	var upstreamCalls int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		w.Write([]byte(`{}`))
+	}))
+	defer mockServer.Close()
+
+	port := getFreePort(t)
+	err := StartProxyFromConfig(ProxyConfig{
+		Name:   "faulty",
+		Listen: fmt.Sprintf(":%d", port),
+		Target: mockServer.URL,
+		Faults: []FaultConfig{{Name: "rl", Type: FaultStatus, Status: 429, Model: "claude-*", Probability: 100}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/messages", port), "application/json",
+		strings.NewReader(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != 429 {
+		t.Fatalf("status = %d, want injected 429", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), `"rate_limit_error"`) || !strings.Contains(string(body), `"type":"error"`) {
+		t.Errorf("body is not an Anthropic-shaped error: %s", body)
+	}
+	if atomic.LoadInt32(&upstreamCalls) != 0 {
+		t.Errorf("upstream was called %d times, want 0", upstreamCalls)
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.InjectedFault != "status 429 (rl)" {
+		t.Errorf("InjectedFault = %q, want %q", captured.InjectedFault, "status 429 (rl)")
+	}
+}
+
+func TestFaultInjectionTruncatesStream(t *testing.T) {
+	resetTestState()
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: {\"n\":%d}\n\n", i)
+			flusher.Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer mockServer.Close()
+
+	port := getFreePort(t)
+	err := StartProxyFromConfig(ProxyConfig{
+		Listen: fmt.Sprintf(":%d", port),
+		Target: mockServer.URL,
+		Faults: []FaultConfig{{Type: FaultTruncate, AfterEvents: 1, Probability: 100}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+		strings.NewReader(`{"model":"gpt-4o","stream":true,"messages":[]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if readErr == nil {
+		t.Errorf("expected the client to see a broken stream")
+	}
+	if string(body) != "data: {\"n\":0}\n\n" {
+		t.Errorf("client body = %q, want only the first event", body)
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.InjectedFault == "" {
+		t.Error("InjectedFault should be set")
+	}
+	if string(captured.ResponseBody) != string(body) {
+		t.Errorf("captured body = %q, want what the client received %q", captured.ResponseBody, body)
+	}
+}
+
 // --- responseRecorder Tests ---
 
 func TestResponseRecorderImplementsFlusher(t *testing.T) {
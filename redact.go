@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redaction modes
+const (
+	RedactMask = "mask" // Replace secrets with [REDACTED]
+	RedactHash = "hash" // Replace secrets with a short hash so equal values stay correlatable
+)
+
+const redactedPrefix = "[REDACTED"
+
+// defaultSensitiveHeaders are always redacted when redaction is enabled
+var defaultSensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"X-Goog-Api-Key",
+	"Api-Key", // Azure OpenAI
+	"X-Amz-Security-Token",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// sensitiveQueryParams are redacted from captured URLs (e.g. Gemini's ?key=)
+var sensitiveQueryParams = []string{"key", "api_key", "api-key", "access_token", "token"}
+
+// builtinRedactRules are body patterns that can be enabled by name
+var builtinRedactRules = map[string][]string{
+	"api_keys": {
+		`sk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}`, // OpenAI / Anthropic
+		`AIza[0-9A-Za-z_\-]{35}`,                // Google
+		`AKIA[0-9A-Z]{16}`,                      // AWS access key ID
+		`gsk_[A-Za-z0-9]{20,}`,                  // Groq
+		`xai-[A-Za-z0-9]{20,}`,                  // xAI
+		`hf_[A-Za-z0-9]{20,}`,                   // Hugging Face
+	},
+	"emails": {
+		`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	},
+}
+
+// RedactRule is a custom body pattern to redact
+type RedactRule struct {
+	Name    string `toml:"name"`
+	Pattern string `toml:"pattern"` // Go regular expression
+}
+
+// RedactConfig controls what is scrubbed before requests are persisted
+type RedactConfig struct {
+	Enabled bool         `toml:"enabled"` // Defaults to true
+	Mode    string       `toml:"mode"`    // "mask" (default) or "hash"
+	Headers []string     `toml:"headers"` // Extra header names to redact
+	Builtin []string     `toml:"builtin"` // Built-in body rules: api_keys, emails (default: api_keys)
+	Rules   []RedactRule `toml:"rule"`    // Custom body patterns
+}
+
+// Redactor scrubs secrets from headers, URLs and bodies
+type Redactor struct {
+	mode     string
+	headers  map[string]bool // Canonical header names
+	patterns []*regexp.Regexp
+}
+
+// activeRedactor is applied to tapes, session history and copied headers.
+// It starts with the defaults so every code path is safe without setup.
+var (
+	activeRedactorMu sync.RWMutex
+	activeRedactor   = defaultRedactor()
+)
+
+// currentRedactor returns the redactor in effect; config reloads swap it
+func currentRedactor() *Redactor {
+	activeRedactorMu.RLock()
+	defer activeRedactorMu.RUnlock()
+	return activeRedactor
+}
+
+// setRedactor replaces the redactor in effect
+func setRedactor(r *Redactor) {
+	activeRedactorMu.Lock()
+	defer activeRedactorMu.Unlock()
+	activeRedactor = r
+}
+
+func defaultRedactor() *Redactor {
+	r, _ := NewRedactor(RedactConfig{Enabled: true})
+	return r
+}
+
+// NewRedactor compiles a redaction config. Returns nil (no redaction) when disabled.
+func NewRedactor(cfg RedactConfig) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	r := &Redactor{mode: cfg.Mode, headers: make(map[string]bool)}
+	switch r.mode {
+	case "":
+		r.mode = RedactMask
+	case RedactMask, RedactHash:
+	default:
+		return nil, fmt.Errorf("redact: invalid mode %q (expected mask|hash)", cfg.Mode)
+	}
+
+	for _, h := range append(append([]string{}, defaultSensitiveHeaders...), cfg.Headers...) {
+		r.headers[canonicalHeader(h)] = true
+	}
+
+	builtin := cfg.Builtin
+	if builtin == nil {
+		builtin = []string{"api_keys"}
+	}
+	for _, name := range builtin {
+		patterns, ok := builtinRedactRules[name]
+		if !ok {
+			return nil, fmt.Errorf("redact: unknown builtin rule %q (expected api_keys|emails)", name)
+		}
+		for _, p := range patterns {
+			r.patterns = append(r.patterns, regexp.MustCompile(p))
+		}
+	}
+	for i, rule := range cfg.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			label := rule.Name
+			if label == "" {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("redact rule %s: %w", label, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// InitRedaction sets the redactor used before requests are persisted
+func InitRedaction(cfg RedactConfig) error {
+	r, err := NewRedactor(cfg)
+	if err != nil {
+		return err
+	}
+	setRedactor(r)
+	return nil
+}
+
+// canonicalHeader normalizes a header name for lookups
+func canonicalHeader(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// replacement returns the stand-in text for a secret
+func (r *Redactor) replacement(secret string) string {
+	if r.mode == RedactHash {
+		sum := sha256.Sum256([]byte(secret))
+		return redactedPrefix + ":" + hex.EncodeToString(sum[:])[:12] + "]"
+	}
+	return redactedPrefix + "]"
+}
+
+// redactHeaderValue keeps the auth scheme (e.g. "Bearer") so the header stays readable
+func (r *Redactor) redactHeaderValue(value string) string {
+	if strings.Contains(value, redactedPrefix) {
+		return value
+	}
+	if scheme, secret, ok := strings.Cut(value, " "); ok && (strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "Basic")) {
+		return scheme + " " + r.replacement(secret)
+	}
+	return r.replacement(value)
+}
+
+// RedactHeaders returns a copy of headers with sensitive values replaced
+func (r *Redactor) RedactHeaders(headers map[string][]string) map[string][]string {
+	if r == nil || headers == nil {
+		return headers
+	}
+	out := make(map[string][]string, len(headers))
+	for k, values := range headers {
+		if !r.headers[canonicalHeader(k)] {
+			out[k] = values
+			continue
+		}
+		redacted := make([]string, len(values))
+		for i, v := range values {
+			redacted[i] = r.redactHeaderValue(v)
+		}
+		out[k] = redacted
+	}
+	return out
+}
+
+// RedactBody replaces every match of the body rules
+func (r *Redactor) RedactBody(body []byte) []byte {
+	if r == nil || len(body) == 0 || len(r.patterns) == 0 {
+		return body
+	}
+	for _, re := range r.patterns {
+		body = re.ReplaceAllFunc(body, func(match []byte) []byte {
+			return []byte(r.replacement(string(match)))
+		})
+	}
+	return body
+}
+
+// RedactURL scrubs sensitive query parameters
+func (r *Redactor) RedactURL(rawURL string) string {
+	if r == nil || !strings.Contains(rawURL, "?") {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	changed := false
+	for _, param := range sensitiveQueryParams {
+		values, ok := query[param]
+		if !ok {
+			continue
+		}
+		for i, v := range values {
+			if !strings.Contains(v, redactedPrefix) {
+				values[i] = r.replacement(v)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return rawURL
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// RedactRequest returns a shallow copy of req with secrets scrubbed. The
+// original is left untouched so the live TUI still shows what was sent.
+func (r *Redactor) RedactRequest(req *LLMRequest) *LLMRequest {
+	if r == nil || req == nil {
+		return req
+	}
+	redacted := *req
+	redacted.URL = r.RedactURL(req.URL)
+	redacted.RequestHeaders = r.RedactHeaders(req.RequestHeaders)
+	redacted.ResponseHeaders = r.RedactHeaders(req.ResponseHeaders)
+	redacted.RequestBody = r.RedactBody(req.RequestBody)
+	redacted.ResponseBody = r.RedactBody(req.ResponseBody)
+	return &redacted
+}
+
+// RedactTapeFile scrubs an existing tape. When outPath is empty the tape is
+// rewritten in place. Returns the number of request events rewritten.
+func RedactTapeFile(inPath, outPath string, r *Redactor) (int, error) {
+	if r == nil {
+		return 0, fmt.Errorf("redaction is disabled")
+	}
+	if outPath == "" {
+		outPath = inPath
+	}
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open tape file: %w", err)
+	}
+	defer in.Close()
+
+	tempPath := filepath.Join(filepath.Dir(outPath), "."+filepath.Base(outPath)+".redact.tmp")
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024) // Same limit as LoadTape
+	writer := bufio.NewWriter(out)
+
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event TapeEvent
+		if err := json.Unmarshal(line, &event); err == nil {
+			switch event.Type {
+			case EventRequestStart, EventRequestUpdate, EventRequestComplete:
+				var data TapeRequestData
+				if err := json.Unmarshal(event.Data, &data); err == nil {
+					req := r.RedactRequest(tapeDataToRequest(data))
+					data.URL = req.URL
+					data.RequestHeaders = req.RequestHeaders
+					data.ResponseHeaders = req.ResponseHeaders
+					data.RequestBody = req.RequestBody
+					data.ResponseBody = req.ResponseBody
+					event.Data, _ = json.Marshal(data)
+					line, _ = json.Marshal(event)
+					count++
+				}
+			}
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		out.Close()
+		return 0, fmt.Errorf("failed to read tape file: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return 0, err
+	}
+	if err := out.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tempPath, outPath); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	return count, nil
+}
+
+// RunRedactCommand scrubs a tape file using the [redact] settings from
+// configPath (or the defaults) with an optional mode override
+func RunRedactCommand(tapePath, outPath, configPath, mode string) error {
+	cfg := RedactConfig{Enabled: true}
+	if configPath != "" {
+		config, err := LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		cfg = config.Redact
+		// The command is an explicit request to redact
+		cfg.Enabled = true
+	}
+	if mode != "" {
+		cfg.Mode = mode
+	}
+
+	r, err := NewRedactor(cfg)
+	if err != nil {
+		return err
+	}
+	count, err := RedactTapeFile(tapePath, outPath, r)
+	if err != nil {
+		return err
+	}
+
+	dest := outPath
+	if dest == "" {
+		dest = tapePath
+	}
+	fmt.Printf("Redacted %d request events → %s\n", count, dest)
+	return nil
+}
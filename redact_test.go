@@ -0,0 +1,159 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactorHeadersAndURL(t *testing.T) {
+	r, err := NewRedactor(RedactConfig{Enabled: true, Headers: []string{"X-Internal-Token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := map[string][]string{
+		"Authorization":    {"Bearer sk-test-1234567890"},
+		"X-Api-Key":        {"secret"},
+		"X-Internal-Token": {"abc"},
+		"Content-Type":     {"application/json"},
+	}
+	got := r.RedactHeaders(headers)
+	if got["Authorization"][0] != "Bearer [REDACTED]" {
+		t.Errorf("Authorization = %q, want scheme kept", got["Authorization"][0])
+	}
+	if got["X-Api-Key"][0] != "[REDACTED]" || got["X-Internal-Token"][0] != "[REDACTED]" {
+		t.Errorf("sensitive headers not redacted: %v", got)
+	}
+	if got["Content-Type"][0] != "application/json" {
+		t.Errorf("Content-Type should be untouched")
+	}
+	if headers["X-Api-Key"][0] != "secret" {
+		t.Error("RedactHeaders must not modify its input")
+	}
+
+	gotURL := r.RedactURL("https://generativelanguage.googleapis.com/v1beta/models/gemini:generateContent?alt=sse&key=AIzaSecret")
+	if strings.Contains(gotURL, "AIzaSecret") || !strings.Contains(gotURL, "alt=sse") {
+		t.Errorf("RedactURL = %s", gotURL)
+	}
+}
+
+func TestRedactorBodyRulesAndHashMode(t *testing.T) {
+	r, err := NewRedactor(RedactConfig{
+		Enabled: true,
+		Mode:    RedactHash,
+		Builtin: []string{"api_keys", "emails"},
+		Rules:   []RedactRule{{Name: "customer", Pattern: `CUST-[0-9]{6}`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"key":"sk-ant-REDACTED","again":"sk-ant-REDACTED","email":"dev@example.com","id":"CUST-123456"}`)
+	got := string(r.RedactBody(body))
+	for _, secret := range []string{"sk-ant-", "dev@example.com", "CUST-123456"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("body still contains %q: %s", secret, got)
+		}
+	}
+	// Hashing keeps equal secrets correlatable
+	hashed := r.replacement("sk-ant-REDACTED")
+	if strings.Count(got, hashed) != 2 {
+		t.Errorf("expected the same hash twice in %s", got)
+	}
+
+	// Redacting twice is stable
+	headers := r.RedactHeaders(map[string][]string{"Authorization": {"Bearer secret"}})
+	again := r.RedactHeaders(headers)
+	if headers["Authorization"][0] != again["Authorization"][0] {
+		t.Errorf("re-redaction changed %q to %q", headers["Authorization"][0], again["Authorization"][0])
+	}
+}
+
+func TestNewRedactorValidation(t *testing.T) {
+	if r, err := NewRedactor(RedactConfig{Enabled: false}); r != nil || err != nil {
+		t.Errorf("disabled redactor = %v, %v; want nil, nil", r, err)
+	}
+	if _, err := NewRedactor(RedactConfig{Enabled: true, Mode: "scramble"}); err == nil {
+		t.Error("expected invalid mode error")
+	}
+	if _, err := NewRedactor(RedactConfig{Enabled: true, Builtin: []string{"phones"}}); err == nil {
+		t.Error("expected unknown builtin error")
+	}
+	if _, err := NewRedactor(RedactConfig{Enabled: true, Rules: []RedactRule{{Pattern: "("}}}); err == nil {
+		t.Error("expected invalid pattern error")
+	}
+}
+
+func TestTapeWriterRedactsAndRedactCommandScrubs(t *testing.T) {
+	dir := t.TempDir()
+	req := &LLMRequest{
+		ID:             1,
+		Method:         "POST",
+		Path:           "/v1/messages",
+		Status:         StatusComplete,
+		StatusCode:     200,
+		StartTime:      time.Now(),
+		RequestHeaders: map[string][]string{"X-Api-Key": {"sk-ant-REDACTED"}},
+		RequestBody:    []byte(`{"model":"claude","messages":[{"role":"user","content":"my key is sk-ant-REDACTED"}]}`),
+	}
+
+	// Live capture path: the default redactor scrubs before writing
+	livePath := filepath.Join(dir, "live.tape")
+	writer, err := NewTapeWriter(livePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteRequestComplete(req)
+	writer.Close()
+	if got := loadSingleTapeRequest(t, livePath); tapeRequestContains(got, "live-key") {
+		t.Errorf("tape contains the API key: %v %s", got.RequestHeaders, got.RequestBody)
+	}
+	if req.RequestHeaders["X-Api-Key"][0] != "sk-ant-REDACTED" {
+		t.Error("in-memory request should keep the original header")
+	}
+
+	// Old tape written without redaction, then scrubbed to a new file
+	setRedactor(nil)
+	defer setRedactor(defaultRedactor())
+	rawPath := filepath.Join(dir, "raw.tape")
+	writer, err = NewTapeWriter(rawPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteSessionStart(":8080", "https://api.anthropic.com")
+	writer.WriteRequestComplete(req)
+	writer.Close()
+
+	cleanPath := filepath.Join(dir, "clean.tape")
+	if err := RunRedactCommand(rawPath, cleanPath, "", RedactHash); err != nil {
+		t.Fatalf("RunRedactCommand error: %v", err)
+	}
+	if got := loadSingleTapeRequest(t, cleanPath); tapeRequestContains(got, "live-key") {
+		t.Errorf("scrubbed tape contains the API key: %v %s", got.RequestHeaders, got.RequestBody)
+	}
+	if got := loadSingleTapeRequest(t, rawPath); !tapeRequestContains(got, "live-key") {
+		t.Error("input tape should be untouched when --output is given")
+	}
+}
+
+func loadSingleTapeRequest(t *testing.T, path string) *LLMRequest {
+	t.Helper()
+	tape, err := LoadTape(path)
+	if err != nil || len(tape.Requests) != 1 {
+		t.Fatalf("LoadTape(%s) = %v requests, err %v", path, len(tape.Requests), err)
+	}
+	return tape.Requests[0]
+}
+
+func tapeRequestContains(req *LLMRequest, secret string) bool {
+	for _, values := range req.RequestHeaders {
+		for _, v := range values {
+			if strings.Contains(v, secret) {
+				return true
+			}
+		}
+	}
+	return strings.Contains(string(req.RequestBody), secret)
+}
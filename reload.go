@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	configPollInterval = time.Second
+	reloadDrainTimeout = 10 * time.Second // How long removed proxies may finish in-flight requests
+)
+
+// configReloadedMsg reports the outcome of a config reload to the TUI
+type configReloadedMsg struct {
+	summary     string
+	err         error
+	listenAddrs string
+	targetURLs  string
+}
+
+// ConfigReloader re-reads the config file and reconciles running proxies
+type ConfigReloader struct {
+	path    string
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+}
+
+// NewConfigReloader creates a reloader for the config the proxies were started with
+func NewConfigReloader(path string, current *Config) *ConfigReloader {
+	cr := &ConfigReloader{path: path, current: current}
+	if info, err := os.Stat(path); err == nil {
+		cr.modTime = info.ModTime()
+	}
+	return cr
+}
+
+// Watch reloads when the config file changes or on SIGHUP, until stop is closed
+func (cr *ConfigReloader) Watch(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			cr.reloadAndNotify()
+		case <-ticker.C:
+			info, err := os.Stat(cr.path)
+			if err != nil || info.ModTime().Equal(cr.modTime) {
+				continue
+			}
+			cr.modTime = info.ModTime()
+			cr.reloadAndNotify()
+		}
+	}
+}
+
+// reloadAndNotify reloads the config and sends the result to the TUI
+func (cr *ConfigReloader) reloadAndNotify() {
+	changes, err := cr.Reload()
+
+	msg := configReloadedMsg{err: err}
+	if len(changes) == 0 && err == nil {
+		msg.summary = "no changes"
+	} else {
+		msg.summary = strings.Join(changes, " • ")
+	}
+	cr.mu.Lock()
+	msg.listenAddrs = formatListenAddrs(cr.current.Proxies)
+	msg.targetURLs = formatTargetURLs(cr.current.Proxies)
+	cr.mu.Unlock()
+
+	if program != nil {
+		program.Send(msg)
+	}
+}
+
+// Reload re-reads the config file and applies the differences: new proxies
+// are started, removed ones are drained and stopped, and changed ones are
+// restarted. Cache, redaction and llm_paths settings are updated in place.
+// A config that fails validation leaves everything running as before. Errors
+// while applying are returned together with the changes that did succeed.
+func (cr *ConfigReloader) Reload() ([]string, error) {
+	cfg, err := LoadConfig(cr.path)
+	if err != nil {
+		return nil, err
+	}
+	cacheCfg, err := cfg.Cache.ToCacheConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache config: %w", err)
+	}
+	// Catch errors LoadConfig does not check (e.g. unparsable URLs) before stopping anything
+	for _, p := range cfg.Proxies {
+		if p.Mode != ProxyModeForward {
+			if _, err := newRouter(p); err != nil {
+				return nil, fmt.Errorf("proxy %s: %w", p.Listen, err)
+			}
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	old := make(map[string]ProxyConfig)
+	for _, p := range cr.current.Proxies {
+		old[p.Listen] = p
+	}
+	next := make(map[string]bool)
+	for _, p := range cfg.Proxies {
+		next[p.Listen] = true
+	}
+
+	var changes, errs []string
+	for _, p := range cr.current.Proxies {
+		if next[p.Listen] {
+			continue
+		}
+		StopProxyInstance(p.Listen, reloadDrainTimeout)
+		changes = append(changes, fmt.Sprintf("- %s", proxyDisplayName(p)))
+	}
+
+	// Track what is actually running so a failed start is retried next time
+	var applied []ProxyConfig
+	for _, p := range cfg.Proxies {
+		prev, existed := old[p.Listen]
+		if existed && reflect.DeepEqual(prev, p) {
+			applied = append(applied, p)
+			continue
+		}
+		if existed {
+			StopProxyInstance(p.Listen, reloadDrainTimeout)
+		}
+		if err := StartProxyFromConfig(p); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		applied = append(applied, p)
+		if existed {
+			changes = append(changes, fmt.Sprintf("~ %s: %s", proxyDisplayName(p), strings.Join(changedProxyFields(prev, p), ", ")))
+		} else {
+			changes = append(changes, fmt.Sprintf("+ %s", proxyDisplayName(p)))
+		}
+	}
+
+	if !reflect.DeepEqual(collectLLMPaths(cr.current.Proxies), collectLLMPaths(cfg.Proxies)) {
+		changes = append(changes, "~ llm_paths")
+	}
+	RegisterExtraLLMPaths(cfg.Proxies)
+
+	if cr.current.Cache != cfg.Cache {
+		if err := ReloadCache(cacheCfg); err != nil {
+			errs = append(errs, fmt.Sprintf("cache: %v", err))
+		} else {
+			changes = append(changes, fmt.Sprintf("~ cache: %s", cacheCfg.Mode))
+		}
+	}
+
+	if !reflect.DeepEqual(cr.current.Redact, cfg.Redact) {
+		if err := InitRedaction(cfg.Redact); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			changes = append(changes, "~ redact")
+		}
+	}
+
+	if !reflect.DeepEqual(cr.current.Azure, cfg.Azure) {
+		SetAzureDeployments(cfg.Azure.Deployments)
+		changes = append(changes, "~ azure deployments")
+	}
+
+	if cr.current.Correlation != cfg.Correlation {
+		SetCorrelation(cfg.Correlation)
+		changes = append(changes, "~ correlation header")
+	}
+
+	if cr.current.SaveTape != cfg.SaveTape {
+		changes = append(changes, "save_tape change ignored (restart required)")
+		cfg.SaveTape = cr.current.SaveTape
+	}
+	if !reflect.DeepEqual(cr.current.Budgets, cfg.Budgets) {
+		changes = append(changes, "budget changes ignored (restart required)")
+		cfg.Budgets = cr.current.Budgets
+	}
+
+	cfg.Proxies = applied
+	cr.current = cfg
+
+	if len(errs) > 0 {
+		return changes, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return changes, nil
+}
+
+// proxyDisplayName formats a proxy as name(listen)
+func proxyDisplayName(p ProxyConfig) string {
+	if p.Name == "" || p.Name == p.Listen {
+		return p.Listen
+	}
+	return fmt.Sprintf("%s(%s)", p.Name, p.Listen)
+}
+
+// changedProxyFields lists the TOML names of fields that differ between two proxy configs
+func changedProxyFields(a, b ProxyConfig) []string {
+	var fields []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			continue
+		}
+		name := t.Field(i).Tag.Get("toml")
+		if name == "" {
+			name = strings.ToLower(t.Field(i).Name)
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// collectLLMPaths returns the sorted llm_paths across proxies
+func collectLLMPaths(proxies []ProxyConfig) []string {
+	var paths []string
+	for _, p := range proxies {
+		paths = append(paths, p.LLMPaths...)
+	}
+	slices.Sort(paths)
+	return paths
+}
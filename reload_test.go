@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeReloadConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func proxyResponds(port int) bool {
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/health", port))
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return true
+}
+
+func TestConfigReloadReconcilesProxies(t *testing.T) {
+	resetTestState()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	keepPort, removePort, addPort := getFreePort(t), getFreePort(t), getFreePort(t)
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeReloadConfig(t, path, fmt.Sprintf(`
+[[proxy]]
+name = "keep"
+listen = ":%d"
+target = %q
+
+[[proxy]]
+name = "remove"
+listen = ":%d"
+target = %q
+`, keepPort, upstream.URL, removePort, upstream.URL))
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StartMultipleProxies(cfg.Proxies); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	reloader := NewConfigReloader(path, cfg)
+
+	writeReloadConfig(t, path, fmt.Sprintf(`
+[[proxy]]
+name = "keep"
+listen = ":%d"
+target = %q
+
+[[proxy]]
+name = "added"
+listen = ":%d"
+target = %q
+llm_paths = ["/reload-test/"]
+`, keepPort, upstream.URL, addPort, upstream.URL))
+
+	changes, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+	summary := strings.Join(changes, " • ")
+	for _, want := range []string{fmt.Sprintf("- remove(:%d)", removePort), fmt.Sprintf("+ added(:%d)", addPort), "~ llm_paths"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q missing %q", summary, want)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if !proxyResponds(keepPort) || !proxyResponds(addPort) {
+		t.Error("kept and added proxies should be serving")
+	}
+	if proxyResponds(removePort) {
+		t.Error("removed proxy should be shut down")
+	}
+	if !isLLMEndpoint("/reload-test/chat") {
+		t.Error("llm_paths should be updated on reload")
+	}
+
+	// An invalid config leaves the running proxies alone
+	writeReloadConfig(t, path, `[[proxy]]
+listen = ":1"
+mode = "sideways"
+`)
+	if _, err := reloader.Reload(); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !proxyResponds(keepPort) || !proxyResponds(addPort) {
+		t.Error("proxies should keep running after a failed reload")
+	}
+
+	StopProxyInstance(fmt.Sprintf(":%d", keepPort), time.Second)
+	StopProxyInstance(fmt.Sprintf(":%d", addPort), time.Second)
+	RegisterExtraLLMPaths(nil)
+}
+
+func TestChangedProxyFields(t *testing.T) {
+	a := ProxyConfig{Name: "p", Listen: ":8080", Target: "https://a.example.com"}
+	b := a
+	b.Target = "https://b.example.com"
+	b.LLMPaths = []string{"/x/"}
+
+	got := strings.Join(changedProxyFields(a, b), ",")
+	if got != "target,llm_paths" {
+		t.Errorf("changedProxyFields = %q, want target,llm_paths", got)
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OpenAI Responses API (/v1/responses) types. Requests carry `input` items
+// instead of `messages`, and responses carry `output` items instead of
+// `choices`. The rest of the app renders chat completions, so these are
+// mapped onto OpenAIRequest/OpenAIResponse for display, copy and export.
+
+// ResponsesSummaryPart is a reasoning summary or reasoning text part
+type ResponsesSummaryPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// ResponsesItem is an input or output item (message, reasoning, function_call, ...)
+type ResponsesItem struct {
+	Type      string                 `json:"type,omitempty"`
+	ID        string                 `json:"id,omitempty"`
+	Role      string                 `json:"role,omitempty"`
+	Status    string                 `json:"status,omitempty"`
+	Content   any                    `json:"content,omitempty"` // string or content parts
+	CallID    string                 `json:"call_id,omitempty"` // function_call, function_call_output
+	Name      string                 `json:"name,omitempty"`
+	Arguments string                 `json:"arguments,omitempty"`
+	Output    any                    `json:"output,omitempty"`  // function_call_output: string or content parts
+	Summary   []ResponsesSummaryPart `json:"summary,omitempty"` // reasoning
+	Action    any                    `json:"action,omitempty"`  // built-in tool calls (web_search_call, ...)
+}
+
+type ResponsesRequest struct {
+	Model              string          `json:"model"`
+	Instructions       string          `json:"instructions,omitempty"`
+	Input              json.RawMessage `json:"input"` // string or []ResponsesItem
+	Temperature        float64         `json:"temperature,omitempty"`
+	MaxOutputTokens    int             `json:"max_output_tokens,omitempty"`
+	Stream             bool            `json:"stream,omitempty"`
+	PreviousResponseID string          `json:"previous_response_id,omitempty"`
+}
+
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type ResponsesResponse struct {
+	ID     string          `json:"id"`
+	Object string          `json:"object"`
+	Model  string          `json:"model"`
+	Status string          `json:"status"`
+	Output []ResponsesItem `json:"output"`
+	Usage  ResponsesUsage  `json:"usage"`
+}
+
+// isResponsesEndpoint returns true if the path is an OpenAI Responses API endpoint
+func isResponsesEndpoint(path string) bool {
+	return strings.HasSuffix(path, "/responses")
+}
+
+// isMappedChatEndpoint returns true for APIs whose bodies are mapped onto the
+// chat completions types by decodeOpenAIRequest and decodeOpenAIResponse
+func isMappedChatEndpoint(path string) bool {
+	return isResponsesEndpoint(path) || isOllamaEndpoint(path) || isBedrockConverseEndpoint(path)
+}
+
+// decodeOpenAIRequest parses a chat completions request. Responses API,
+// Ollama and Bedrock Converse requests are mapped onto the same shape.
+func decodeOpenAIRequest(path string, body []byte) (*OpenAIRequest, error) {
+	if isResponsesEndpoint(path) {
+		return responsesRequestToOpenAI(body)
+	}
+	if isOllamaEndpoint(path) {
+		return ollamaRequestToOpenAI(body)
+	}
+	if isBedrockConverseEndpoint(path) {
+		return converseRequestToOpenAI(path, body)
+	}
+	var req OpenAIRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// decodeOpenAIResponse parses a chat completions response, reassembling SSE
+// streams. Responses API, Ollama and Converse output is mapped onto a single choice.
+// Returns nil when the body can't be parsed.
+func decodeOpenAIResponse(path string, body []byte) *OpenAIResponse {
+	if isResponsesEndpoint(path) {
+		if resp := parseResponsesResponse(body); resp != nil {
+			return responsesResponseToOpenAI(resp)
+		}
+		return nil
+	}
+	if isOllamaEndpoint(path) {
+		if resp := parseOllamaResponse(body); resp != nil {
+			return ollamaResponseToOpenAI(resp)
+		}
+		return nil
+	}
+	if isBedrockConverseEndpoint(path) {
+		if resp := parseConverseResponse(body); resp != nil {
+			return converseResponseToOpenAI(path, resp)
+		}
+		return nil
+	}
+	var resp OpenAIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		if isSSEData(body) {
+			return reassembleSSEResponse(body)
+		}
+		return nil
+	}
+	return &resp
+}
+
+// responsesRequestToOpenAI maps instructions and input items onto chat messages
+func responsesRequestToOpenAI(body []byte) (*OpenAIRequest, error) {
+	var req ResponsesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	out := &OpenAIRequest{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxOutputTokens,
+		Stream:      req.Stream,
+	}
+	if req.Instructions != "" {
+		out.Messages = append(out.Messages, OpenAIMessage{Role: "system", Content: req.Instructions})
+	}
+
+	var text string
+	if json.Unmarshal(req.Input, &text) == nil {
+		out.Messages = append(out.Messages, OpenAIMessage{Role: "user", Content: text})
+		return out, nil
+	}
+	var items []ResponsesItem
+	if len(req.Input) > 0 {
+		if err := json.Unmarshal(req.Input, &items); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+	}
+
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			out.Messages = append(out.Messages, OpenAIMessage{Role: role, Content: normalizeResponsesContent(item.Content)})
+		case "function_call":
+			call := ToolCall{ID: item.CallID, Type: "function", Function: ToolCallFunction{Name: item.Name, Arguments: item.Arguments}}
+			// Parallel calls arrive as consecutive items; keep them on one assistant turn
+			if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == "assistant" && len(out.Messages[n-1].ToolCalls) > 0 {
+				out.Messages[n-1].ToolCalls = append(out.Messages[n-1].ToolCalls, call)
+			} else {
+				out.Messages = append(out.Messages, OpenAIMessage{Role: "assistant", ToolCalls: []ToolCall{call}})
+			}
+		case "function_call_output":
+			out.Messages = append(out.Messages, OpenAIMessage{Role: "tool", ToolCallID: item.CallID, Content: normalizeResponsesContent(item.Output)})
+		case "reasoning":
+			out.Messages = append(out.Messages, OpenAIMessage{Role: "assistant", ReasoningContent: responsesSummaryText(item.Summary)})
+		default:
+			out.Messages = append(out.Messages, OpenAIMessage{Role: "assistant", Content: fmt.Sprintf("[%s]", item.Type)})
+		}
+	}
+	return out, nil
+}
+
+// normalizeResponsesContent rewrites input_image parts into the chat
+// completions image_url shape so image previews keep working
+func normalizeResponsesContent(content any) any {
+	parts, ok := content.([]any)
+	if !ok {
+		return content
+	}
+	out := make([]any, 0, len(parts))
+	for _, part := range parts {
+		block, ok := part.(map[string]any)
+		if ok && block["type"] == "input_image" {
+			if url, ok := block["image_url"].(string); ok {
+				out = append(out, map[string]any{"type": "image_url", "image_url": map[string]any{"url": url}})
+				continue
+			}
+		}
+		out = append(out, part)
+	}
+	return out
+}
+
+// responsesSummaryText joins reasoning summary parts
+func responsesSummaryText(parts []ResponsesSummaryPart) string {
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// parseResponsesResponse parses a Responses API body, reassembling SSE
+// streams. Returns nil when the body is not a Responses API response.
+func parseResponsesResponse(data []byte) *ResponsesResponse {
+	if isSSEData(data) {
+		return reassembleResponsesSSE(data)
+	}
+	var resp ResponsesResponse
+	if err := json.Unmarshal(data, &resp); err != nil || (resp.Object != "response" && len(resp.Output) == 0) {
+		return nil
+	}
+	return &resp
+}
+
+// reassembleResponsesSSE reconstructs a response from typed Responses API
+// events. The final response.completed event carries the full response; if
+// the stream was cut short, output items are rebuilt from the deltas.
+func reassembleResponsesSSE(data []byte) *ResponsesResponse {
+	lines := strings.Split(string(data), "\n")
+
+	var resp *ResponsesResponse
+	items := make(map[int]*ResponsesItem)
+	done := make(map[int]bool)
+	text := make(map[int]*strings.Builder)
+	reasoning := make(map[int]*strings.Builder)
+	args := make(map[int]*strings.Builder)
+	appendTo := func(m map[int]*strings.Builder, idx int, s string) {
+		if m[idx] == nil {
+			m[idx] = &strings.Builder{}
+		}
+		m[idx].WriteString(s)
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		jsonData := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if jsonData == "" || jsonData == "[DONE]" {
+			continue
+		}
+
+		var event struct {
+			Type        string             `json:"type"`
+			Response    *ResponsesResponse `json:"response"`
+			OutputIndex int                `json:"output_index"`
+			Item        *ResponsesItem     `json:"item"`
+			Delta       string             `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(jsonData), &event); err != nil || !strings.HasPrefix(event.Type, "response.") {
+			continue
+		}
+
+		switch event.Type {
+		case "response.created", "response.in_progress", "response.completed", "response.incomplete", "response.failed":
+			if event.Response != nil {
+				resp = event.Response
+			}
+		case "response.output_item.added":
+			if event.Item != nil && !done[event.OutputIndex] {
+				items[event.OutputIndex] = event.Item
+			}
+		case "response.output_item.done":
+			if event.Item != nil {
+				items[event.OutputIndex] = event.Item
+				done[event.OutputIndex] = true
+			}
+		case "response.output_text.delta", "response.refusal.delta":
+			appendTo(text, event.OutputIndex, event.Delta)
+		case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+			appendTo(reasoning, event.OutputIndex, event.Delta)
+		case "response.function_call_arguments.delta":
+			appendTo(args, event.OutputIndex, event.Delta)
+		}
+	}
+
+	if resp == nil {
+		if len(items) == 0 && len(text) == 0 {
+			return nil
+		}
+		resp = &ResponsesResponse{Object: "response"}
+	}
+	// The completed event already has every output item
+	if len(resp.Output) > 0 {
+		return resp
+	}
+
+	indexes := make(map[int]bool)
+	for _, m := range []map[int]*strings.Builder{text, reasoning, args} {
+		for idx := range m {
+			indexes[idx] = true
+		}
+	}
+	for idx := range items {
+		indexes[idx] = true
+	}
+	order := make([]int, 0, len(indexes))
+	for idx := range indexes {
+		order = append(order, idx)
+	}
+	sort.Ints(order)
+
+	for _, idx := range order {
+		item := items[idx]
+		if item == nil {
+			item = &ResponsesItem{Type: "message", Role: "assistant"}
+		}
+		if !done[idx] {
+			switch item.Type {
+			case "message":
+				if text[idx] != nil {
+					item.Content = []any{map[string]any{"type": "output_text", "text": text[idx].String()}}
+				}
+			case "reasoning":
+				if reasoning[idx] != nil {
+					item.Summary = []ResponsesSummaryPart{{Type: "summary_text", Text: reasoning[idx].String()}}
+				}
+			case "function_call":
+				if args[idx] != nil {
+					item.Arguments = args[idx].String()
+				}
+			}
+		}
+		resp.Output = append(resp.Output, *item)
+	}
+	return resp
+}
+
+// responsesResponseToOpenAI maps output items onto a single assistant choice
+func responsesResponseToOpenAI(resp *ResponsesResponse) *OpenAIResponse {
+	out := &OpenAIResponse{
+		ID:     resp.ID,
+		Object: resp.Object,
+		Model:  resp.Model,
+		Usage: OpenAIUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+
+	msg := OpenAIMessage{Role: "assistant"}
+	var texts, reasoning []string
+	for _, item := range resp.Output {
+		switch item.Type {
+		case "message":
+			if t := responsesOutputText(item.Content); t != "" {
+				texts = append(texts, t)
+			}
+		case "reasoning":
+			if t := responsesSummaryText(item.Summary); t != "" {
+				reasoning = append(reasoning, t)
+			}
+		case "function_call":
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: item.Name, Arguments: item.Arguments},
+			})
+		default:
+			// Built-in tools (web_search_call, file_search_call, ...) show up as tool calls
+			arguments := ""
+			if item.Action != nil {
+				actionJSON, _ := json.Marshal(item.Action)
+				arguments = string(actionJSON)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       item.ID,
+				Type:     item.Type,
+				Function: ToolCallFunction{Name: item.Type, Arguments: arguments},
+			})
+		}
+	}
+	msg.Content = strings.Join(texts, "\n\n")
+	msg.ReasoningContent = strings.Join(reasoning, "\n\n")
+
+	finishReason := resp.Status
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	out.Choices = []OpenAIChoice{{Index: 0, Message: msg, FinishReason: finishReason}}
+	return out
+}
+
+// responsesOutputText extracts output_text and refusal parts from message content
+func responsesOutputText(content any) string {
+	parts, ok := content.([]any)
+	if !ok {
+		return extractOpenAITextContent(content)
+	}
+	var texts []string
+	for _, part := range parts {
+		block, ok := part.(map[string]any)
+		if !ok {
+			continue
+		}
+		if text, _ := block["text"].(string); text != "" {
+			texts = append(texts, text)
+		} else if refusal, _ := block["refusal"].(string); refusal != "" {
+			texts = append(texts, "Refusal: "+refusal)
+		}
+	}
+	return strings.Join(texts, "")
+}
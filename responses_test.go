@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const responsesStreamFixture = "event: response.created\n" +
+	"data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_1\",\"object\":\"response\",\"model\":\"o4-mini\",\"status\":\"in_progress\",\"output\":[]}}\n\n" +
+	"event: response.output_item.added\n" +
+	"data: {\"type\":\"response.output_item.added\",\"output_index\":0,\"item\":{\"type\":\"reasoning\",\"id\":\"rs_1\",\"summary\":[]}}\n\n" +
+	"data: {\"type\":\"response.reasoning_summary_text.delta\",\"output_index\":0,\"delta\":\"Thinking \"}\n\n" +
+	"data: {\"type\":\"response.reasoning_summary_text.delta\",\"output_index\":0,\"delta\":\"hard\"}\n\n" +
+	"data: {\"type\":\"response.output_item.added\",\"output_index\":1,\"item\":{\"type\":\"message\",\"id\":\"msg_1\",\"role\":\"assistant\",\"content\":[]}}\n\n" +
+	"data: {\"type\":\"response.output_text.delta\",\"output_index\":1,\"content_index\":0,\"delta\":\"Hello \"}\n\n" +
+	"data: {\"type\":\"response.output_text.delta\",\"output_index\":1,\"content_index\":0,\"delta\":\"world\"}\n\n" +
+	"data: {\"type\":\"response.output_item.added\",\"output_index\":2,\"item\":{\"type\":\"function_call\",\"id\":\"fc_1\",\"call_id\":\"call_1\",\"name\":\"get_weather\",\"arguments\":\"\"}}\n\n" +
+	"data: {\"type\":\"response.function_call_arguments.delta\",\"output_index\":2,\"delta\":\"{\\\"city\\\":\"}\n\n" +
+	"data: {\"type\":\"response.function_call_arguments.delta\",\"output_index\":2,\"delta\":\"\\\"Paris\\\"}\"}\n\n"
+
+const responsesCompletedEvent = "data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_1\",\"object\":\"response\",\"model\":\"o4-mini\",\"status\":\"completed\"," +
+	"\"output\":[{\"type\":\"message\",\"role\":\"assistant\",\"content\":[{\"type\":\"output_text\",\"text\":\"Hello world\"}]}]," +
+	"\"usage\":{\"input_tokens\":12,\"output_tokens\":34,\"total_tokens\":46}}}\n\n"
+
+func TestResponsesRequestToOpenAI(t *testing.T) {
+	body := []byte(`{
+		"model": "gpt-4.1",
+		"instructions": "Be brief",
+		"max_output_tokens": 200,
+		"stream": true,
+		"input": [
+			{"role": "user", "content": [{"type": "input_text", "text": "Weather?"}, {"type": "input_image", "image_url": "data:image/png;base64,AAAA"}]},
+			{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{}"},
+			{"type": "function_call", "call_id": "call_2", "name": "get_time", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "sunny"}
+		]
+	}`)
+
+	req, err := decodeOpenAIRequest("/v1/responses", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "gpt-4.1" || req.MaxTokens != 200 || !req.Stream {
+		t.Errorf("request fields = %+v", req)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("got %d messages, want 4: %+v", len(req.Messages), req.Messages)
+	}
+	if req.Messages[0].Role != "system" || req.Messages[0].Content != "Be brief" {
+		t.Errorf("instructions message = %+v", req.Messages[0])
+	}
+	if got := extractOpenAITextContent(req.Messages[1].Content); got != "Weather?" {
+		t.Errorf("user text = %q", got)
+	}
+	parts := req.Messages[1].Content.([]any)
+	if url, _ := extractImageURL(parts[1].(map[string]any)); url != "data:image/png;base64,AAAA" {
+		t.Errorf("input_image not mapped to image_url: %+v", parts[1])
+	}
+	if len(req.Messages[2].ToolCalls) != 2 {
+		t.Errorf("consecutive function calls should share one assistant turn: %+v", req.Messages[2])
+	}
+	if req.Messages[3].Role != "tool" || req.Messages[3].ToolCallID != "call_1" {
+		t.Errorf("function_call_output message = %+v", req.Messages[3])
+	}
+
+	// A plain string input becomes one user message
+	req, err = decodeOpenAIRequest("/v1/responses", []byte(`{"model":"gpt-4.1","input":"hi"}`))
+	if err != nil || len(req.Messages) != 1 || req.Messages[0].Content != "hi" {
+		t.Errorf("string input = %+v, %v", req, err)
+	}
+}
+
+func TestReassembleResponsesSSE(t *testing.T) {
+	// A stream cut off before response.completed is rebuilt from the deltas
+	resp := decodeOpenAIResponse("/v1/responses", []byte(responsesStreamFixture))
+	if resp == nil || len(resp.Choices) != 1 {
+		t.Fatalf("decodeOpenAIResponse = %+v", resp)
+	}
+	msg := resp.Choices[0].Message
+	if resp.ID != "resp_1" || resp.Model != "o4-mini" {
+		t.Errorf("response metadata = %q %q", resp.ID, resp.Model)
+	}
+	if msg.Content != "Hello world" || msg.ReasoningContent != "Thinking hard" {
+		t.Errorf("content = %q, reasoning = %q", msg.Content, msg.ReasoningContent)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` || msg.ToolCalls[0].ID != "call_1" {
+		t.Errorf("tool calls = %+v", msg.ToolCalls)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %q", resp.Choices[0].FinishReason)
+	}
+
+	// The completed event wins once it arrives
+	resp = decodeOpenAIResponse("/v1/responses", []byte(responsesStreamFixture+responsesCompletedEvent))
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 34 || len(resp.Choices[0].Message.ToolCalls) != 0 {
+		t.Errorf("completed response = %+v", resp)
+	}
+
+	// Chat completions streams are not mistaken for Responses API streams
+	if parseResponsesResponse([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"x\"}}]}\n\n")) != nil {
+		t.Error("chat completions SSE should not parse as a Responses API stream")
+	}
+}
+
+func TestResponsesUsageCopyAndCacheKey(t *testing.T) {
+	if !isLLMEndpoint("/v1/responses") {
+		t.Error("/v1/responses should be captured")
+	}
+
+	req := &LLMRequest{Path: "/v1/responses"}
+	extractTokenUsageFromSSE(req, []byte(responsesStreamFixture+responsesCompletedEvent))
+	if req.InputTokens != 12 || req.OutputTokens != 34 {
+		t.Errorf("SSE usage = %d/%d, want 12/34", req.InputTokens, req.OutputTokens)
+	}
+
+	req = &LLMRequest{
+		Path: "/v1/responses",
+		ResponseBody: []byte(`{"id":"resp_2","object":"response","status":"completed","output":[` +
+			`{"type":"reasoning","summary":[{"type":"summary_text","text":"Plan"}]},` +
+			`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Done"}]}]}`),
+	}
+	got, _, err := outputCopyText(req)
+	if err != nil || got != "Done\n\nPlan" {
+		t.Errorf("outputCopyText = %q, %v", got, err)
+	}
+
+	a := GenerateCacheKey("/v1/responses", []byte(`{"model":"gpt-4.1","input":"one"}`))
+	b := GenerateCacheKey("/v1/responses", []byte(`{"model":"gpt-4.1","input":"two"}`))
+	c := GenerateCacheKey("/v1/responses", []byte(`{"input":"one", "model":"gpt-4.1"}`))
+	if a == b {
+		t.Error("different inputs should not share a cache key")
+	}
+	if a != c {
+		t.Error("field order should not change the cache key")
+	}
+	if !strings.HasPrefix(a, "/v1/responses:") {
+		t.Errorf("cache key = %q", a)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryConfig configures the retry and failover policy for a proxy
+type RetryConfig struct {
+	MaxAttempts     int      `toml:"max_attempts"`     // Total attempts including the first (0 or 1 = no retries)
+	Statuses        []int    `toml:"statuses"`         // Status codes that trigger a retry
+	InitialBackoff  string   `toml:"initial_backoff"`  // First backoff delay (default "500ms")
+	MaxBackoff      string   `toml:"max_backoff"`      // Upper bound for backoff and Retry-After (default "30s")
+	FallbackTargets []string `toml:"fallback_targets"` // Secondary targets tried after the primary
+}
+
+// defaultRetryStatuses are retried when statuses is not configured
+var defaultRetryStatuses = []int{429, 500, 502, 503, 504, 529}
+
+// RequestAttempt records one upstream attempt made for a request
+type RequestAttempt struct {
+	Number     int           `json:"number"`
+	Target     string        `json:"target"`
+	StartTime  time.Time     `json:"start_time"`
+	Duration   time.Duration `json:"duration"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Backoff    time.Duration `json:"backoff,omitempty"` // Wait before the next attempt
+}
+
+// retryPolicy is the parsed form of RetryConfig
+type retryPolicy struct {
+	maxAttempts    int
+	statuses       map[int]bool
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// parseRetryPolicy validates a RetryConfig. The fallback count is used to
+// default max_attempts so every fallback gets one try.
+func parseRetryPolicy(cfg RetryConfig, fallbacks int) (*retryPolicy, error) {
+	p := &retryPolicy{
+		maxAttempts:    cfg.MaxAttempts,
+		statuses:       make(map[int]bool),
+		initialBackoff: 500 * time.Millisecond,
+		maxBackoff:     30 * time.Second,
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = 1 + fallbacks
+	}
+
+	statuses := cfg.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	for _, code := range statuses {
+		p.statuses[code] = true
+	}
+
+	if cfg.InitialBackoff != "" {
+		d, err := time.ParseDuration(cfg.InitialBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid initial_backoff: %w", err)
+		}
+		p.initialBackoff = d
+	}
+	if cfg.MaxBackoff != "" {
+		d, err := time.ParseDuration(cfg.MaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_backoff: %w", err)
+		}
+		p.maxBackoff = d
+	}
+	for _, t := range cfg.FallbackTargets {
+		if _, err := url.Parse(t); err != nil {
+			return nil, fmt.Errorf("invalid fallback target %q: %w", t, err)
+		}
+	}
+	return p, nil
+}
+
+// backoff returns the jittered delay before the given retry (1 = first retry).
+// Uses "equal jitter": half the exponential delay plus a random half.
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	d := p.initialBackoff
+	for i := 1; i < retry && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header (seconds or HTTP date)
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// llmRequestKey is the context key for the LLMRequest being proxied
+type llmRequestKey struct{}
+
+// withLLMRequest attaches the captured request so the transport can record attempts
+func withLLMRequest(ctx context.Context, req *LLMRequest) context.Context {
+	return context.WithValue(ctx, llmRequestKey{}, req)
+}
+
+// llmRequestFromContext returns the captured request, if any
+func llmRequestFromContext(ctx context.Context) *LLMRequest {
+	req, _ := ctx.Value(llmRequestKey{}).(*LLMRequest)
+	return req
+}
+
+// retryTransport retries failed round trips and fails over between targets.
+// ReverseProxy only starts writing to the client after RoundTrip returns, so
+// streaming requests are never retried once the first byte has been sent.
+type retryTransport struct {
+	base    http.RoundTripper
+	policy  *retryPolicy
+	primary *url.URL
+	targets []*url.URL // primary followed by fallbacks
+}
+
+// newRetryTransport creates a transport for the primary target and its fallbacks
+func newRetryTransport(policy *retryPolicy, primary *url.URL, fallbacks []string) (*retryTransport, error) {
+	t := &retryTransport{
+		base:    http.DefaultTransport,
+		policy:  policy,
+		primary: primary,
+		targets: []*url.URL{primary},
+	}
+	for _, f := range fallbacks {
+		u, err := url.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback target %q: %w", f, err)
+		}
+		t.targets = append(t.targets, u)
+	}
+	return t, nil
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Buffer the body so it can be replayed for each attempt
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	captured := llmRequestFromContext(req.Context())
+	ctx := req.Context()
+
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		target := t.targets[(attempt-1)%len(t.targets)]
+		outreq := t.requestFor(req, target, body)
+
+		start := time.Now()
+		resp, err = t.base.RoundTrip(outreq)
+		record := RequestAttempt{
+			Number:    attempt,
+			Target:    target.String(),
+			StartTime: start,
+			Duration:  time.Since(start),
+		}
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			record.StatusCode = resp.StatusCode
+		}
+
+		retryable := ctx.Err() == nil && (err != nil || t.policy.statuses[resp.StatusCode])
+		if !retryable || attempt >= t.policy.maxAttempts {
+			recordAttempt(captured, record)
+			return resp, err
+		}
+
+		// Only back off when retrying the same target; failover is immediate
+		var wait time.Duration
+		nextTarget := t.targets[attempt%len(t.targets)]
+		if nextTarget == target {
+			wait = t.policy.backoff(attempt)
+		}
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if nextTarget == target && retryAfter > t.policy.maxBackoff {
+					// Upstream wants us to wait longer than allowed; give up
+					recordAttempt(captured, record)
+					return resp, nil
+				}
+				if nextTarget == target && retryAfter > wait {
+					wait = retryAfter
+				}
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		record.Backoff = wait
+		recordAttempt(captured, record)
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// requestFor clones the outgoing request and points it at the given target
+func (t *retryTransport) requestFor(req *http.Request, target *url.URL, body []byte) *http.Request {
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	if target != t.primary {
+		// The director already joined the primary's base path; swap it for the fallback's
+		rel := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(t.primary.Path, "/"))
+		out.URL.Scheme = target.Scheme
+		out.URL.Host = target.Host
+		out.URL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(rel, "/")
+		out.URL.RawPath = ""
+		out.Host = target.Host
+	}
+	return out
+}
+
+// recordAttempt appends an attempt to the captured request and notifies the
+// TUI. The request's target follows the attempt, so after a failover it names
+// the fallback that answered.
+func recordAttempt(req *LLMRequest, attempt RequestAttempt) {
+	if req == nil {
+		return
+	}
+	requestsMu.Lock()
+	req.Attempts = append(req.Attempts, attempt)
+	req.TargetURL = attempt.Target
+	if u, err := url.Parse(attempt.Target); err == nil && u.Host != "" {
+		req.Host = u.Host
+	}
+	requestsMu.Unlock()
+
+	if program != nil {
+		program.Send(requestUpdatedMsg{req: req})
+	}
+}
+
+// formatAttempts summarizes retry attempts on one line for the detail view
+func formatAttempts(attempts []RequestAttempt) string {
+	var parts []string
+	for _, a := range attempts {
+		host := a.Target
+		if u, err := url.Parse(a.Target); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		result := strconv.Itoa(a.StatusCode)
+		if a.Error != "" {
+			result = "error"
+		}
+		part := fmt.Sprintf("#%d %s %s", a.Number, host, result)
+		if a.Backoff > 0 {
+			part += fmt.Sprintf(" (wait %s)", formatDuration(a.Backoff))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " → ")
+}
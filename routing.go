@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RouteConfig selects an upstream target for requests on a listener.
+// All non-empty match fields must match for the route to apply.
+type RouteConfig struct {
+	Name        string `toml:"name"`         // Route name shown in the TUI (defaults to the target host)
+	Model       string `toml:"model"`        // Glob matched against the request model (e.g., "claude-*")
+	PathPrefix  string `toml:"path_prefix"`  // Request path prefix (e.g., "/api/")
+	Header      string `toml:"header"`       // Request header name to match
+	HeaderValue string `toml:"header_value"` // Glob for the header value (empty = header present)
+	Target      string `toml:"target"`       // Target URL for matching requests
+
+	FallbackTargets []string `toml:"fallback_targets"` // Failover targets for this route (uses the proxy's retry policy)
+}
+
+// validate checks that the route has a target and at least one match condition
+func (rc RouteConfig) validate() error {
+	if rc.Target == "" {
+		return fmt.Errorf("route %q: target URL cannot be empty", rc.displayName())
+	}
+	if _, err := url.Parse(rc.Target); err != nil {
+		return fmt.Errorf("route %q: invalid target URL: %w", rc.displayName(), err)
+	}
+	if rc.Model == "" && rc.PathPrefix == "" && rc.Header == "" {
+		return fmt.Errorf("route %q: at least one of model, path_prefix or header is required", rc.displayName())
+	}
+	if rc.Model != "" {
+		if _, err := path.Match(rc.Model, ""); err != nil {
+			return fmt.Errorf("route %q: invalid model glob: %w", rc.displayName(), err)
+		}
+	}
+	if rc.HeaderValue != "" {
+		if rc.Header == "" {
+			return fmt.Errorf("route %q: header_value requires header", rc.displayName())
+		}
+		if _, err := path.Match(rc.HeaderValue, ""); err != nil {
+			return fmt.Errorf("route %q: invalid header_value glob: %w", rc.displayName(), err)
+		}
+	}
+	return nil
+}
+
+// displayName returns the route name, falling back to the target host
+func (rc RouteConfig) displayName() string {
+	if rc.Name != "" {
+		return rc.Name
+	}
+	if u, err := url.Parse(rc.Target); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rc.Target
+}
+
+// matches reports whether the route applies to the request
+func (rc RouteConfig) matches(r *http.Request, model string) bool {
+	if rc.Model != "" && !globMatchFold(rc.Model, model) {
+		return false
+	}
+	if rc.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rc.PathPrefix) {
+		return false
+	}
+	if rc.Header != "" {
+		values, ok := r.Header[http.CanonicalHeaderKey(rc.Header)]
+		if !ok {
+			return false
+		}
+		if rc.HeaderValue != "" {
+			matched := false
+			for _, v := range values {
+				if globMatchFold(rc.HeaderValue, v) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// globMatchFold matches a glob pattern case-insensitively
+func globMatchFold(pattern, value string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && ok
+}
+
+// upstream is a resolved target and the reverse proxy that forwards to it
+type upstream struct {
+	route  string // Route name, empty for the proxy's default target
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+}
+
+// router picks the upstream for each request on a listener
+type router struct {
+	routes   []RouteConfig
+	upstream []*upstream // Parallel to routes
+	fallback *upstream   // Default target, nil if the proxy only has routes
+}
+
+// newRouter builds upstreams for the proxy's default target and each route
+func newRouter(cfg ProxyConfig) (*router, error) {
+	rt := &router{}
+	if cfg.Target != "" {
+		up, err := newUpstream("", cfg.Target, cfg.Retry, cfg.Retry.FallbackTargets)
+		if err != nil {
+			return nil, err
+		}
+		rt.fallback = up
+	}
+	for _, rc := range cfg.Routes {
+		if err := rc.validate(); err != nil {
+			return nil, err
+		}
+		up, err := newUpstream(rc.displayName(), rc.Target, cfg.Retry, rc.FallbackTargets)
+		if err != nil {
+			return nil, err
+		}
+		rt.routes = append(rt.routes, rc)
+		rt.upstream = append(rt.upstream, up)
+	}
+	if rt.fallback == nil && len(rt.routes) == 0 {
+		return nil, fmt.Errorf("proxy target URL cannot be empty")
+	}
+	return rt, nil
+}
+
+// match returns the first matching route's upstream, or the default target.
+// Returns nil when no route matches and there is no default target.
+func (rt *router) match(r *http.Request, model string) *upstream {
+	for i, rc := range rt.routes {
+		if rc.matches(r, model) {
+			return rt.upstream[i]
+		}
+	}
+	return rt.fallback
+}
+
+// newUpstream creates a reverse proxy for a target URL, wrapping the transport
+// with the retry policy when retries or fallbacks are configured
+func newUpstream(route, targetURL string, retry RetryConfig, fallbacks []string) (*upstream, error) {
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	policy, err := parseRetryPolicy(retry, len(fallbacks))
+	if err != nil {
+		return nil, err
+	}
+	if policy.maxAttempts > 1 {
+		transport, err := newRetryTransport(policy, target, fallbacks)
+		if err != nil {
+			return nil, err
+		}
+		proxy.Transport = transport
+	}
+
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		req.Host = target.Host
+	}
+
+	// Handle proxy errors (upstream unreachable, DNS failure, etc.)
+	// Write a 502 status so the request is properly marked as failed in the TUI.
+	// Don't log to stderr as it disrupts the TUI layout.
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	// Flush immediately for streaming responses (SSE)
+	proxy.FlushInterval = -1
+
+	return &upstream{route: route, target: target, proxy: proxy}, nil
+}
@@ -19,6 +19,12 @@ func (m *model) extractSearchableText(req *LLMRequest) string {
 	sb.WriteString(req.Model)
 	sb.WriteString(" ")
 
+	// Client labels, searchable as tag:x, run:x or conversation:x
+	if labels := tagSearchText(req.Tags, req.Run, req.Conversation); labels != "" {
+		sb.WriteString(labels)
+		sb.WriteString(" ")
+	}
+
 	// Extract request messages
 	if len(req.RequestBody) > 0 {
 		if isAnthropicEndpoint(req.Path) {
@@ -36,8 +42,7 @@ func (m *model) extractSearchableText(req *LLMRequest) string {
 				}
 			}
 		} else {
-			var openAIReq OpenAIRequest
-			if err := json.Unmarshal(req.RequestBody, &openAIReq); err == nil {
+			if openAIReq, err := decodeOpenAIRequest(req.Path, req.RequestBody); err == nil {
 				for _, msg := range openAIReq.Messages {
 					sb.WriteString(msg.Role)
 					sb.WriteString(" ")
@@ -90,8 +95,7 @@ func (m *model) extractSearchableText(req *LLMRequest) string {
 				}
 			}
 		} else {
-			var openAIResp OpenAIResponse
-			if err := json.Unmarshal(req.ResponseBody, &openAIResp); err == nil {
+			if openAIResp := decodeOpenAIResponse(req.Path, req.ResponseBody); openAIResp != nil {
 				for _, choice := range openAIResp.Choices {
 					switch c := choice.Message.Content.(type) {
 					case string:
@@ -134,10 +138,16 @@ func (m *model) filterRequests() {
 
 // getDisplayRequests returns the filtered and sorted list of requests for display
 func (m *model) getDisplayRequests() []*LLMRequest {
-	if m.searchQuery == "" {
-		return m.getSortedRequests(m.requests)
+	reqs := m.requests
+	if m.searchQuery != "" {
+		reqs = m.filteredRequests
+	}
+	// Thread mode keeps each conversation together, so it replaces sorting
+	if m.threadMode {
+		m.threads.update(m.requests)
+		return m.threads.order(reqs)
 	}
-	return m.getSortedRequests(m.filteredRequests)
+	return m.getSortedRequests(reqs)
 }
 
 // getSortedRequests returns a sorted copy of requests based on current sort settings
@@ -186,6 +196,8 @@ func (m *model) getSortedRequests(reqs []*LLMRequest) []*LLMRequest {
 			less = sorted[i].OutputTokens < sorted[j].OutputTokens
 		case SortByCost:
 			less = sorted[i].Cost < sorted[j].Cost
+		case SortByTags:
+			less = sorted[i].TagLabel() < sorted[j].TagLabel()
 		default:
 			less = sorted[i].ID < sorted[j].ID
 		}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Tape match modes for serve-tape
+const (
+	TapeMatchHash     = "hash"     // Exact method + path + body hash
+	TapeMatchKey      = "key"      // Normalized cache key from GenerateCacheKey
+	TapeMatchSequence = "sequence" // Recorded order, ignoring request content
+)
+
+// ServeTapeOptions configures the tape-backed mock upstream
+type ServeTapeOptions struct {
+	Listen     string
+	Match      string // TapeMatchHash, TapeMatchKey or TapeMatchSequence
+	MissStatus int    // Status code returned when no recorded response matches
+	MissBody   string // Optional body for misses (defaults to a provider-shaped error)
+}
+
+// TapeServer answers requests from recorded tape responses
+type TapeServer struct {
+	mu         sync.Mutex
+	match      string
+	recorded   []*LLMRequest            // Completed requests in recorded order
+	byKey      map[string][]*LLMRequest // Match key -> recorded requests in order
+	served     map[string]int           // Match key -> number of times served
+	next       int                      // Next index for sequence mode
+	missStatus int
+	missBody   []byte
+	hits       int
+	misses     int
+}
+
+// NewTapeServer indexes the tape's completed requests for the given match mode
+func NewTapeServer(tape *Tape, opts ServeTapeOptions) (*TapeServer, error) {
+	switch opts.Match {
+	case "":
+		opts.Match = TapeMatchHash
+	case TapeMatchHash, TapeMatchKey, TapeMatchSequence:
+	default:
+		return nil, fmt.Errorf("invalid match mode %q (expected hash|key|sequence)", opts.Match)
+	}
+	if opts.MissStatus == 0 {
+		opts.MissStatus = http.StatusNotFound
+	}
+
+	s := &TapeServer{
+		match:      opts.Match,
+		byKey:      make(map[string][]*LLMRequest),
+		served:     make(map[string]int),
+		missStatus: opts.MissStatus,
+	}
+	if opts.MissBody != "" {
+		s.missBody = []byte(opts.MissBody)
+	}
+
+	for _, req := range tape.Requests {
+		// Skip requests that never got a real answer
+		if req.Status == StatusPending || req.StatusCode == 0 || req.StatusCode == 499 {
+			continue
+		}
+		s.recorded = append(s.recorded, req)
+		key := s.matchKey(req.Method, req.Path, req.RequestBody)
+		s.byKey[key] = append(s.byKey[key], req)
+	}
+
+	return s, nil
+}
+
+// matchKey computes the lookup key for a request under the server's match mode
+func (s *TapeServer) matchKey(method, path string, body []byte) string {
+	switch s.match {
+	case TapeMatchKey:
+		return GenerateCacheKey(path, body)
+	case TapeMatchHash:
+		h := sha256.New()
+		h.Write([]byte(method + " " + path + "\n"))
+		h.Write(body)
+		return hex.EncodeToString(h.Sum(nil))
+	default:
+		return ""
+	}
+}
+
+// lookup finds the recorded request to answer with. Identical requests are
+// answered in recorded order; once exhausted the last response is repeated.
+func (s *TapeServer) lookup(r *http.Request, body []byte) *LLMRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.match == TapeMatchSequence {
+		if s.next >= len(s.recorded) {
+			return nil
+		}
+		req := s.recorded[s.next]
+		s.next++
+		return req
+	}
+
+	key := s.matchKey(r.Method, r.URL.Path, body)
+	candidates := s.byKey[key]
+	if len(candidates) == 0 {
+		return nil
+	}
+	idx := s.served[key]
+	if idx >= len(candidates) {
+		idx = len(candidates) - 1
+	}
+	s.served[key]++
+	return candidates[idx]
+}
+
+// Stats returns the number of hits and misses served so far
+func (s *TapeServer) Stats() (hits, misses int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits, s.misses
+}
+
+func (s *TapeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+	}
+
+	recorded := s.lookup(r, body)
+	s.mu.Lock()
+	if recorded == nil {
+		s.misses++
+	} else {
+		s.hits++
+	}
+	s.mu.Unlock()
+
+	if recorded == nil {
+		log.Printf("MISS %s %s", r.Method, r.URL.Path)
+		w.Header().Set("X-LLMProxy-Tape-Miss", "1")
+		if s.missBody != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(s.missStatus)
+			w.Write(s.missBody)
+			return
+		}
+		writeProviderError(w, r.URL.Path, s.missStatus,
+			fmt.Sprintf("llmproxy serve-tape: no recorded response matches %s %s (match=%s)", r.Method, r.URL.Path, s.match))
+		return
+	}
+
+	log.Printf("HIT  %s %s -> tape request #%d (%d)", r.Method, r.URL.Path, recorded.ID, recorded.StatusCode)
+	writeRecordedResponse(w, recorded)
+}
+
+// writeRecordedResponse replays a recorded response with its original status and
+// headers. SSE bodies are written event by event with a flush after each.
+func writeRecordedResponse(w http.ResponseWriter, recorded *LLMRequest) {
+	// Bodies are stored decompressed, so drop headers that describe the wire encoding
+	skipHeaders := map[string]bool{
+		"Content-Encoding":  true,
+		"Content-Length":    true,
+		"Transfer-Encoding": true,
+		"Connection":        true,
+		"Keep-Alive":        true,
+	}
+	for k, v := range recorded.ResponseHeaders {
+		if skipHeaders[k] {
+			continue
+		}
+		for _, val := range v {
+			w.Header().Add(k, val)
+		}
+	}
+	w.Header().Set("X-LLMProxy-Tape-Request", strconv.Itoa(recorded.ID))
+
+	if !isSSEData(recorded.ResponseBody) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(recorded.ResponseBody)))
+		w.WriteHeader(recorded.StatusCode)
+		w.Write(recorded.ResponseBody)
+		return
+	}
+
+	w.WriteHeader(recorded.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	remaining := recorded.ResponseBody
+	for len(remaining) > 0 {
+		event := remaining
+		if idx := bytes.Index(remaining, []byte("\n\n")); idx >= 0 {
+			event = remaining[:idx+2]
+		}
+		remaining = remaining[len(event):]
+		if _, err := w.Write(event); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// RunServeTapeCommand serves a tape file as a mock upstream until the process exits
+func RunServeTapeCommand(tapePath string, opts ServeTapeOptions) error {
+	tape, err := LoadTape(tapePath)
+	if err != nil {
+		return err
+	}
+
+	server, err := NewTapeServer(tape, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving %d recorded responses from %s on %s (match=%s)", len(server.recorded), tapePath, opts.Listen, server.match)
+	return http.ListenAndServe(opts.Listen, server)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestTape records the given requests to a tape file and loads it back
+func writeTestTape(t *testing.T, reqs ...*LLMRequest) *Tape {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.tape")
+	writer, err := NewTapeWriter(path)
+	if err != nil {
+		t.Fatalf("NewTapeWriter error: %v", err)
+	}
+	writer.WriteSessionStart(":8080", "https://api.openai.com")
+	for _, req := range reqs {
+		writer.WriteRequestStart(req)
+		writer.WriteRequestComplete(req)
+	}
+	writer.Close()
+
+	tape, err := LoadTape(path)
+	if err != nil {
+		t.Fatalf("LoadTape error: %v", err)
+	}
+	return tape
+}
+
+func tapeFixtureRequest(id int, body, response string) *LLMRequest {
+	return &LLMRequest{
+		ID:              id,
+		Method:          "POST",
+		Path:            "/v1/chat/completions",
+		Model:           "gpt-4o",
+		Status:          StatusComplete,
+		StatusCode:      200,
+		StartTime:       time.Now(),
+		RequestBody:     []byte(body),
+		ResponseBody:    []byte(response),
+		ResponseHeaders: map[string][]string{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+	}
+}
+
+func postTapeServer(t *testing.T, server *TapeServer, path, body string) (*http.Response, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+	resp := rec.Result()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestTapeServerMatchesByHash(t *testing.T) {
+	tape := writeTestTape(t,
+		tapeFixtureRequest(1, `{"model":"gpt-4o","messages":[{"role":"user","content":"one"}]}`, `{"answer":"one"}`),
+		tapeFixtureRequest(2, `{"model":"gpt-4o","messages":[{"role":"user","content":"two"}]}`, `{"answer":"two"}`),
+	)
+	server, err := NewTapeServer(tape, ServeTapeOptions{Match: TapeMatchHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := postTapeServer(t, server, "/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"two"}]}`)
+	if resp.StatusCode != 200 || body != `{"answer":"two"}` {
+		t.Errorf("hash hit = %d %s, want 200 {\"answer\":\"two\"}", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("Content-Encoding should be dropped for decompressed bodies")
+	}
+
+	// Whitespace changes the hash, so this misses
+	resp, body = postTapeServer(t, server, "/v1/chat/completions", `{"model": "gpt-4o","messages":[{"role":"user","content":"two"}]}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("miss status = %d, want 404", resp.StatusCode)
+	}
+	if !strings.Contains(body, `"error"`) {
+		t.Errorf("miss body should be a provider error, got %s", body)
+	}
+
+	hits, misses := server.Stats()
+	if hits != 1 || misses != 1 {
+		t.Errorf("Stats() = %d/%d, want 1/1", hits, misses)
+	}
+}
+
+func TestTapeServerMatchesByKey(t *testing.T) {
+	tape := writeTestTape(t,
+		tapeFixtureRequest(1, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, `{"answer":"hi"}`),
+	)
+	server, err := NewTapeServer(tape, ServeTapeOptions{Match: TapeMatchKey, MissStatus: 503, MissBody: `{"miss":true}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := postTapeServer(t, server, "/v1/chat/completions", `{ "messages": [{"role":"user","content":"hi"}], "model": "gpt-4o" }`)
+	if resp.StatusCode != 200 || body != `{"answer":"hi"}` {
+		t.Errorf("key hit = %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = postTapeServer(t, server, "/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"bye"}]}`)
+	if resp.StatusCode != 503 || body != `{"miss":true}` {
+		t.Errorf("custom miss = %d %s, want 503 {\"miss\":true}", resp.StatusCode, body)
+	}
+}
+
+func TestTapeServerSequenceReplaysSSE(t *testing.T) {
+	sse := "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: [DONE]\n\n"
+	first := tapeFixtureRequest(1, `{"stream":true}`, sse)
+	first.ResponseHeaders = map[string][]string{"Content-Type": {"text/event-stream"}}
+	second := tapeFixtureRequest(2, `{}`, `{"error":"overloaded"}`)
+	second.Status = StatusError
+	second.StatusCode = 529
+
+	server, err := NewTapeServer(writeTestTape(t, first, second), ServeTapeOptions{Match: TapeMatchSequence})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := postTapeServer(t, server, "/anything", `ignored`)
+	if resp.StatusCode != 200 || body != sse {
+		t.Errorf("first sequence response = %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", resp.Header.Get("Content-Type"))
+	}
+
+	resp, _ = postTapeServer(t, server, "/anything", `ignored`)
+	if resp.StatusCode != 529 {
+		t.Errorf("second sequence status = %d, want recorded 529", resp.StatusCode)
+	}
+
+	resp, _ = postTapeServer(t, server, "/anything", `ignored`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("exhausted sequence status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestNewTapeServerRejectsInvalidMatch(t *testing.T) {
+	if _, err := NewTapeServer(&Tape{}, ServeTapeOptions{Match: "fuzzy"}); err == nil {
+		t.Fatal("expected invalid match mode error")
+	}
+}
@@ -33,6 +33,7 @@ type SessionHistoryRequest struct {
 	StartTime             time.Time           `json:"start_time"`
 	DurationMs            int64               `json:"duration_ms"`
 	TTFTMs                int64               `json:"ttft_ms"`
+	GenerationMs          int64               `json:"generation_ms,omitempty"`
 	RequestHeaders        map[string][]string `json:"request_headers,omitempty"`
 	ResponseHeaders       map[string][]string `json:"response_headers,omitempty"`
 	RequestBody           string              `json:"request_body,omitempty"`
@@ -41,13 +42,31 @@ type SessionHistoryRequest struct {
 	ResponseBodyTruncated bool                `json:"response_body_truncated,omitempty"`
 	RequestSize           int                 `json:"request_size"`
 	ResponseSize          int                 `json:"response_size"`
+	DecodeError           string              `json:"decode_error,omitempty"`
 	IsStreaming           bool                `json:"is_streaming"`
+	WSMessages            int                 `json:"ws_messages,omitempty"`
 	CachedResponse        bool                `json:"cached_response"`
+	CacheKey              string              `json:"cache_key,omitempty"`
+	CacheKeyFields        []string            `json:"cache_key_fields,omitempty"`
+	CacheIgnoredFields    []string            `json:"cache_ignored_fields,omitempty"`
 	ProxyName             string              `json:"proxy_name,omitempty"`
 	ProxyListen           string              `json:"proxy_listen,omitempty"`
+	RouteName             string              `json:"route_name,omitempty"`
+	Deployment            string              `json:"deployment,omitempty"`
+	CorrelationID         string              `json:"correlation_id,omitempty"`
+	TraceID               string              `json:"trace_id,omitempty"`
+	ClientRequestID       string              `json:"client_request_id,omitempty"`
+	Tags                  []string            `json:"tags,omitempty"`
+	Run                   string              `json:"run,omitempty"`
+	Conversation          string              `json:"conversation,omitempty"`
+	TargetURL             string              `json:"target_url,omitempty"`
+	Attempts              []RequestAttempt    `json:"attempts,omitempty"`
+	InjectedFault         string              `json:"injected_fault,omitempty"`
+	BudgetExceeded        string              `json:"budget_exceeded,omitempty"`
 	EstimatedInputTokens  int                 `json:"estimated_input_tokens"`
 	InputTokens           int                 `json:"input_tokens"`
 	OutputTokens          int                 `json:"output_tokens"`
+	CachedInputTokens     int                 `json:"cached_input_tokens,omitempty"`
 	ProviderID            string              `json:"provider_id,omitempty"`
 	Cost                  float64             `json:"cost"`
 	CancelReason          string              `json:"cancel_reason,omitempty"`
@@ -61,6 +80,7 @@ type SessionHistorySnapshot struct {
 	PID          int                     `json:"pid"`
 	StartedAt    time.Time               `json:"started_at"`
 	UpdatedAt    time.Time               `json:"updated_at"`
+	EndedAt      *time.Time              `json:"ended_at,omitempty"` // Set when the proxy shut down cleanly
 	RequestCount int                     `json:"request_count"`
 	Requests     []SessionHistoryRequest `json:"requests"`
 }
@@ -72,6 +92,7 @@ type SessionHistory struct {
 	listenAddr  string
 	targetURL   string
 	startedAt   time.Time
+	endedAt     *time.Time
 	filePath    string
 	maxRequests int
 	order       []int
@@ -98,8 +119,12 @@ func StartSessionHistory(listenAddr, targetURL string) (string, error) {
 	return sessionID, nil
 }
 
-// StopSessionHistory detaches the runtime session history store.
+// StopSessionHistory marks the session as ended, flushes it to disk and
+// detaches the runtime session history store.
 func StopSessionHistory() {
+	if activeSessionHistory != nil {
+		_ = activeSessionHistory.Close()
+	}
 	activeSessionHistory = nil
 }
 
@@ -161,6 +186,20 @@ func (h *SessionHistory) UpsertRequest(req *LLMRequest) {
 	_ = h.persistLocked()
 }
 
+// Close records the session end time and writes the final snapshot.
+func (h *SessionHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.endedAt = &now
+	return h.persistLocked()
+}
+
 func (h *SessionHistory) persistLocked() error {
 	requests := make([]SessionHistoryRequest, 0, len(h.order))
 	for _, id := range h.order {
@@ -176,6 +215,7 @@ func (h *SessionHistory) persistLocked() error {
 		PID:          os.Getpid(),
 		StartedAt:    h.startedAt,
 		UpdatedAt:    time.Now(),
+		EndedAt:      h.endedAt,
 		RequestCount: len(requests),
 		Requests:     requests,
 	}
@@ -196,8 +236,10 @@ func (h *SessionHistory) persistLocked() error {
 }
 
 func toSessionHistoryRequest(req *LLMRequest) SessionHistoryRequest {
-	requestBody, requestBodyTruncated := truncateBodyForHistory(req.RequestBody)
-	responseBody, responseBodyTruncated := truncateBodyForHistory(req.ResponseBody)
+	req = currentRedactor().RedactRequest(req)
+	// Uploads and binary audio are summarized rather than stored as bytes
+	requestBody, requestBodyTruncated := truncateBodyForHistory(displayBody(req.RequestHeaders, req.RequestBody))
+	responseBody, responseBodyTruncated := truncateBodyForHistory(displayBody(req.ResponseHeaders, req.ResponseBody))
 
 	return SessionHistoryRequest{
 		ID:                    req.ID,
@@ -211,6 +253,7 @@ func toSessionHistoryRequest(req *LLMRequest) SessionHistoryRequest {
 		StartTime:             req.StartTime,
 		DurationMs:            req.Duration.Milliseconds(),
 		TTFTMs:                req.TTFT.Milliseconds(),
+		GenerationMs:          req.GenerationDuration.Milliseconds(),
 		RequestHeaders:        cloneStringSliceMap(req.RequestHeaders),
 		ResponseHeaders:       cloneStringSliceMap(req.ResponseHeaders),
 		RequestBody:           requestBody,
@@ -219,13 +262,31 @@ func toSessionHistoryRequest(req *LLMRequest) SessionHistoryRequest {
 		ResponseBodyTruncated: responseBodyTruncated,
 		RequestSize:           req.RequestSize,
 		ResponseSize:          req.ResponseSize,
+		DecodeError:           req.DecodeError,
 		IsStreaming:           req.IsStreaming,
+		WSMessages:            len(req.WSEvents),
 		CachedResponse:        req.CachedResponse,
+		CacheKey:              req.CacheKey,
+		CacheKeyFields:        req.CacheKeyFields,
+		CacheIgnoredFields:    req.CacheIgnoredFields,
 		ProxyName:             req.ProxyName,
 		ProxyListen:           req.ProxyListen,
+		RouteName:             req.RouteName,
+		Deployment:            req.Deployment,
+		CorrelationID:         req.CorrelationID,
+		TraceID:               req.TraceID,
+		ClientRequestID:       req.ClientRequestID,
+		Tags:                  req.Tags,
+		Run:                   req.Run,
+		Conversation:          req.Conversation,
+		TargetURL:             req.TargetURL,
+		Attempts:              append([]RequestAttempt(nil), req.Attempts...),
+		InjectedFault:         req.InjectedFault,
+		BudgetExceeded:        req.BudgetExceeded,
 		EstimatedInputTokens:  req.EstimatedInputTokens,
 		InputTokens:           req.InputTokens,
 		OutputTokens:          req.OutputTokens,
+		CachedInputTokens:     req.CachedInputTokens,
 		ProviderID:            req.ProviderID,
 		Cost:                  req.Cost,
 		CancelReason:          req.CancelReason,
@@ -318,6 +379,21 @@ func (s *SessionHistorySnapshot) FindRequest(id int) (SessionHistoryRequest, boo
 	return SessionHistoryRequest{}, false
 }
 
+// FindRequestByRef looks a request up by correlation ID, trace ID or client
+// request ID. The most recent match wins.
+func (s *SessionHistorySnapshot) FindRequestByRef(ref string) (SessionHistoryRequest, bool) {
+	if s == nil {
+		return SessionHistoryRequest{}, false
+	}
+	for i := len(s.Requests) - 1; i >= 0; i-- {
+		req := s.Requests[i]
+		if matchesRequestRef(ref, req.CorrelationID, req.TraceID, req.ClientRequestID) {
+			return req, true
+		}
+	}
+	return SessionHistoryRequest{}, false
+}
+
 func sessionHistoryFilePath(sessionID string) (string, error) {
 	if !isSafeSessionID(sessionID) {
 		return "", fmt.Errorf("invalid session ID %q", sessionID)
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	shutdownDrainTimeout = 5 * time.Second
+	shutdownCancelReason = "proxy shutdown: request was still in flight when the proxy stopped"
+)
+
+// proxyShuttingDown is set once shutdown starts, so requests cut off by it
+// are not reported as client disconnects
+var proxyShuttingDown atomic.Bool
+
+// pendingRequests returns the requests that have not finished yet
+func pendingRequests() []*LLMRequest {
+	requestsMu.RLock()
+	defer requestsMu.RUnlock()
+	var pending []*LLMRequest
+	for _, req := range requests {
+		if req.Status == StatusPending {
+			pending = append(pending, req)
+		}
+	}
+	return pending
+}
+
+// claimFinalize reports whether the caller is the first to finish the
+// request, so it is recorded once even when shutdown races its handler
+func claimFinalize(req *LLMRequest) bool {
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	if req.finalized {
+		return false
+	}
+	req.finalized = true
+	return true
+}
+
+// ShutdownProxies stops accepting connections on every proxy and waits up to
+// timeout for in-flight requests. Connections still open after that are
+// closed, and requests that never finished are marked as cut off by the shutdown.
+func ShutdownProxies(timeout time.Duration) {
+	proxyShuttingDown.Store(true)
+
+	var wg sync.WaitGroup
+	for _, inst := range RunningProxies() {
+		wg.Add(1)
+		go func(listen string) {
+			defer wg.Done()
+			StopProxyInstance(listen, timeout)
+		}(inst.ListenAddr)
+	}
+	wg.Wait()
+
+	// Server shutdown doesn't track upgraded connections; end WebSocket
+	// relays so their handlers record the session as complete
+	closeWebSockets()
+
+	// Handlers of force-closed connections finalize on their own; give them a moment
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for len(pendingRequests()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for _, req := range pendingRequests() {
+		if !claimFinalize(req) {
+			continue // Its handler is finishing it
+		}
+		requestsMu.Lock()
+		req.Status = StatusError
+		req.StatusCode = 499
+		req.Duration = time.Since(req.StartTime)
+		req.CancelReason = shutdownCancelReason
+		requestsMu.Unlock()
+		RecordSessionRequest(req)
+		if tapeWriter != nil {
+			tapeWriter.WriteRequestComplete(req)
+		}
+	}
+}
+
+// ShutdownSession stops the proxies, then closes the tape (writing
+// session_end), flushes session history and budget state and closes the cache.
+func ShutdownSession() {
+	if n := len(pendingRequests()); n > 0 {
+		fmt.Fprintf(os.Stderr, "Waiting up to %s for %d in-flight request(s)...\n", shutdownDrainTimeout, n)
+	}
+	ShutdownProxies(shutdownDrainTimeout)
+
+	if tapeWriter != nil {
+		tapeWriter.Close()
+		tapeWriter = nil
+	}
+	StopSessionHistory()
+	activeBudget.Flush()
+	CloseCache()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSlowProxy starts a proxy whose upstream answers after delay
+func startSlowProxy(t *testing.T, delay time.Duration) int {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the body lets the server notice when the proxy gives up
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"late"}}]}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("slow", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	return port
+}
+
+func sendAsync(port int) chan int {
+	done := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+			strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		if err != nil {
+			done <- 0
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	return done
+}
+
+func waitForPending(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(pendingRequests()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request never became pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	resetTestState()
+	defer proxyShuttingDown.Store(false)
+
+	port := startSlowProxy(t, 200*time.Millisecond)
+	done := sendAsync(port)
+	waitForPending(t)
+
+	ShutdownProxies(2 * time.Second)
+
+	if status := <-done; status != 200 {
+		t.Errorf("drained request status = %d, want 200", status)
+	}
+	req := waitForRequest(t, 1, time.Second)
+	if req.Status != StatusComplete {
+		t.Errorf("drained request status = %v, want complete", req.Status)
+	}
+	if _, err := http.Get(fmt.Sprintf("http://localhost:%d/health", port)); err == nil {
+		t.Error("proxy should stop accepting connections after shutdown")
+	}
+}
+
+func TestShutdownMarksUnfinishedRequests(t *testing.T) {
+	resetTestState()
+	defer proxyShuttingDown.Store(false)
+
+	port := startSlowProxy(t, 10*time.Second)
+	done := sendAsync(port)
+	waitForPending(t)
+
+	ShutdownProxies(100 * time.Millisecond)
+	<-done
+
+	req := waitForRequest(t, 1, time.Second)
+	if req.StatusCode != 499 || req.CancelReason != shutdownCancelReason {
+		t.Errorf("unfinished request = %d %q, want 499 with shutdown reason", req.StatusCode, req.CancelReason)
+	}
+	if len(pendingRequests()) != 0 {
+		t.Error("no request should be left pending after shutdown")
+	}
+}
+
+func TestShutdownAndHandlerFinalizeOnce(t *testing.T) {
+	resetTestState()
+	defer proxyShuttingDown.Store(false)
+
+	cutOff := &LLMRequest{ID: 1, Status: StatusPending, StartTime: time.Now()}
+	finishing := &LLMRequest{ID: 2, Status: StatusPending, StartTime: time.Now()}
+	requestsMu.Lock()
+	requests = append(requests, cutOff, finishing)
+	requestsMu.Unlock()
+	claimFinalize(finishing) // Its handler got there first
+
+	ShutdownProxies(0)
+
+	if cutOff.StatusCode != 499 || claimFinalize(cutOff) {
+		t.Errorf("cut off request = %d, and its handler could still finalize it", cutOff.StatusCode)
+	}
+	if finishing.StatusCode != 0 || finishing.CancelReason != "" {
+		t.Errorf("shutdown overwrote a request its handler was finishing: %d %q", finishing.StatusCode, finishing.CancelReason)
+	}
+}
+
+func TestSessionHistoryCloseRecordsEnd(t *testing.T) {
+	t.Setenv(sessionHistoryDirEnv, t.TempDir())
+
+	history, err := NewSessionHistory("sess-shutdown", ":8080", "https://api.openai.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.UpsertRequest(&LLMRequest{ID: 1, Status: StatusPending, StartTime: time.Now()})
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := LoadSessionHistory("sess-shutdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.EndedAt == nil {
+		t.Error("EndedAt should be set after Close")
+	}
+	if snapshot.RequestCount != 1 {
+		t.Errorf("RequestCount = %d, want 1", snapshot.RequestCount)
+	}
+}
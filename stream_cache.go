@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CacheChunk is one write of a streamed response, timed from the start of
+// the original request
+type CacheChunk struct {
+	Offset time.Duration `json:"offset"`
+	Size   int           `json:"size"`
+}
+
+// CacheReplayMode controls how fast cached responses are played back
+type CacheReplayMode string
+
+const (
+	CacheReplayInstant  CacheReplayMode = "instant"  // Everything at once
+	CacheReplayOriginal CacheReplayMode = "original" // Original TTFT and gaps between chunks
+	CacheReplayScaled   CacheReplayMode = "scaled"   // Original timing divided by ReplaySpeed
+)
+
+// parseCacheReplayMode validates a replay mode from flags or config
+func parseCacheReplayMode(raw string) (CacheReplayMode, error) {
+	switch mode := CacheReplayMode(raw); mode {
+	case "", CacheReplayInstant, CacheReplayOriginal, CacheReplayScaled:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown cache replay mode %q (want instant, original or scaled)", raw)
+}
+
+// replaySpeed returns how much faster than recorded to replay cached
+// responses, or 0 to replay instantly. Without a replay mode,
+// simulate_latency selects the original cadence.
+func (c CacheConfig) replaySpeed() float64 {
+	mode := c.Replay
+	if mode == "" && c.SimulateLatency {
+		mode = CacheReplayOriginal
+	}
+	switch mode {
+	case CacheReplayOriginal:
+		return 1
+	case CacheReplayScaled:
+		if c.ReplaySpeed > 0 {
+			return c.ReplaySpeed
+		}
+		return 1
+	}
+	return 0
+}
+
+// recordedWrite is one Write through the responseRecorder
+type recordedWrite struct {
+	at   time.Time
+	size int
+}
+
+// chunkTimings converts the recorder's writes to offsets from start
+func (r *responseRecorder) chunkTimings(start time.Time) []CacheChunk {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chunks := make([]CacheChunk, 0, len(r.writes))
+	for _, w := range r.writes {
+		chunks = append(chunks, CacheChunk{Offset: w.at.Sub(start), Size: w.size})
+	}
+	return chunks
+}
+
+// replayChunks returns the chunks to replay a cached stream in. Entries
+// without usable timings (compressed upstream streams, where write sizes
+// don't match the stored decompressed body) are split at event boundaries
+// and spread evenly between TTFT and the original duration.
+func (e *CacheEntry) replayChunks() []CacheChunk {
+	total := 0
+	for _, c := range e.Chunks {
+		total += c.Size
+	}
+	if len(e.Chunks) > 0 && total == len(e.ResponseBody) {
+		return e.Chunks
+	}
+
+	var sizes []int
+	for rest := e.ResponseBody; len(rest) > 0; {
+		n := len(rest)
+		if i := bytes.Index(rest, []byte("\n\n")); i >= 0 {
+			n = i + 2 // SSE events
+		} else if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			n = i + 1 // NDJSON lines
+		}
+		sizes = append(sizes, n)
+		rest = rest[n:]
+	}
+
+	chunks := make([]CacheChunk, len(sizes))
+	span := e.Duration - e.TTFT
+	for i, size := range sizes {
+		offset := e.TTFT
+		if len(sizes) > 1 && span > 0 {
+			offset += span * time.Duration(i) / time.Duration(len(sizes)-1)
+		}
+		chunks[i] = CacheChunk{Offset: offset, Size: size}
+	}
+	return chunks
+}
+
+// writeCachedHeaders copies a cached response's headers to w, leaving out
+// those that don't apply to the stored (decompressed) body or to this request
+func writeCachedHeaders(w http.ResponseWriter, entry *CacheEntry) {
+	skipHeaders := map[string]bool{
+		"Content-Encoding":  true, // Body is stored decompressed
+		"Content-Length":    true, // Set from the stored body, or left out for streams
+		"Transfer-Encoding": true, // Not chunked anymore
+		"Connection":        true, // Let Go handle this
+		"Keep-Alive":        true, // Let Go handle this
+	}
+	if name, _ := correlationHeader(); name != "" {
+		skipHeaders[http.CanonicalHeaderKey(name)] = true // Holds the original request's ID
+	}
+	for k, v := range entry.ResponseHeaders {
+		if skipHeaders[k] {
+			continue
+		}
+		for _, val := range v {
+			w.Header().Add(k, val)
+		}
+	}
+}
+
+// replayCachedResponse writes a cached response to the client. Streams are
+// written chunk by chunk with a flush after each, paced by speed (0 = no
+// delays); plain responses wait out the original duration at that speed.
+// It returns how many body bytes were written, the time to the first one,
+// and whether the client stayed until the end.
+func replayCachedResponse(w http.ResponseWriter, r *http.Request, entry *CacheEntry, speed float64) (written int, ttft time.Duration, complete bool) {
+	start := time.Now()
+	wait := func(offset time.Duration) bool {
+		if speed <= 0 {
+			return true
+		}
+		delay := time.Duration(float64(offset)/speed) - time.Since(start)
+		if delay <= 0 {
+			return true
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-r.Context().Done():
+			return false
+		}
+	}
+
+	writeCachedHeaders(w, entry)
+
+	if !entry.Streaming {
+		if !wait(entry.Duration) {
+			return 0, 0, false
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(entry.ResponseBody)))
+		w.WriteHeader(entry.StatusCode)
+		w.Write(entry.ResponseBody)
+		return len(entry.ResponseBody), 0, true
+	}
+
+	w.WriteHeader(entry.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush() // Headers go out before the first chunk, as upstream sent them
+	}
+	for _, chunk := range entry.replayChunks() {
+		if !wait(chunk.Offset) {
+			return written, ttft, false
+		}
+		if _, err := w.Write(entry.ResponseBody[written : written+chunk.Size]); err != nil {
+			return written, ttft, false
+		}
+		if written == 0 {
+			ttft = time.Since(start)
+		}
+		written += chunk.Size
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return written, ttft, true
+}
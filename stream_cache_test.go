@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheReplayChunks(t *testing.T) {
+	body := []byte("data: a\n\ndata: bb\n\ndata: [DONE]\n\n")
+
+	recorded := &CacheEntry{ResponseBody: body, Chunks: []CacheChunk{
+		{Offset: 100 * time.Millisecond, Size: 9},
+		{Offset: 300 * time.Millisecond, Size: len(body) - 9},
+	}}
+	if got := recorded.replayChunks(); len(got) != 2 || got[1].Offset != 300*time.Millisecond {
+		t.Errorf("recorded chunks = %+v", got)
+	}
+
+	// Sizes that don't add up to the body (compressed upstream) fall back
+	// to splitting at event boundaries
+	compressed := &CacheEntry{ResponseBody: body, TTFT: 100 * time.Millisecond, Duration: 500 * time.Millisecond,
+		Chunks: []CacheChunk{{Offset: time.Millisecond, Size: 12}}}
+	want := []CacheChunk{
+		{Offset: 100 * time.Millisecond, Size: 9},
+		{Offset: 300 * time.Millisecond, Size: 10},
+		{Offset: 500 * time.Millisecond, Size: 14},
+	}
+	if got := compressed.replayChunks(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("fallback chunks = %+v, want %+v", got, want)
+	}
+
+	for cfg, speed := range map[CacheConfig]float64{
+		{}:                      0,
+		{SimulateLatency: true}: 1,
+		{Replay: CacheReplayInstant, SimulateLatency: true}: 0,
+		{Replay: CacheReplayScaled, ReplaySpeed: 4}:         4,
+		{Replay: CacheReplayScaled}:                         1,
+	} {
+		if got := cfg.replaySpeed(); got != speed {
+			t.Errorf("%+v replaySpeed = %v, want %v", cfg, got, speed)
+		}
+	}
+	if _, err := parseCacheReplayMode("slow"); err == nil {
+		t.Error("unknown replay mode accepted")
+	}
+}
+
+func TestStreamingCacheReplayIntegration(t *testing.T) {
+	resetTestState()
+	if err := InitCache(CacheConfig{Mode: CacheModeMemory, TTL: time.Hour, Replay: CacheReplayOriginal}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { InitCache(CacheConfig{Mode: CacheModeNone}) })
+
+	events := []string{
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n",
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n",
+		"data: [DONE]\n\n",
+	}
+	upstreamCalls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, event := range events {
+			time.Sleep(80 * time.Millisecond)
+			fmt.Fprint(w, event)
+			flusher.Flush()
+		}
+	}))
+	defer upstream.Close()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-stream-cache", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// send streams a request and returns the body, when the first event
+	// arrived, and how long the whole stream took
+	send := func() (string, time.Duration, time.Duration) {
+		t.Helper()
+		start := time.Now()
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v1/chat/completions", port), "application/json",
+			bytes.NewReader([]byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		first, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		firstAt := time.Since(start)
+		rest, _ := io.ReadAll(reader)
+		return first + string(rest), firstAt, time.Since(start)
+	}
+
+	original, _, _ := send()
+	waitForRequest(t, 1, 2*time.Second)
+
+	replayed, firstAt, total := send()
+	cached := waitForRequest(t, 2, 2*time.Second)
+	if !cached.CachedResponse || upstreamCalls != 1 {
+		t.Fatalf("second stream wasn't served from cache (upstream calls %d)", upstreamCalls)
+	}
+	if replayed != original {
+		t.Errorf("replayed body = %q, want %q", replayed, original)
+	}
+	// The first event waits out the original TTFT and the rest keep their gaps
+	if firstAt < 60*time.Millisecond || total < 200*time.Millisecond {
+		t.Errorf("original replay took %v to first event, %v total", firstAt, total)
+	}
+	if !cached.IsStreaming || cached.TTFT < 60*time.Millisecond {
+		t.Errorf("cached request streaming=%v ttft=%v", cached.IsStreaming, cached.TTFT)
+	}
+
+	entry, ok := GetCache().Get(cached.CacheKey)
+	if !ok || !entry.Streaming || len(entry.Chunks) < len(events) {
+		t.Fatalf("cache entry = %+v", entry)
+	}
+	size := 0
+	for _, c := range entry.Chunks {
+		size += c.Size
+	}
+	if size != len(entry.ResponseBody) {
+		t.Errorf("chunk sizes add up to %d, body is %d", size, len(entry.ResponseBody))
+	}
+
+	if err := ReloadCache(CacheConfig{Mode: CacheModeMemory, TTL: time.Hour, Replay: CacheReplayInstant}); err != nil {
+		t.Fatal(err)
+	}
+	if replayed, _, total := send(); replayed != original || total > 150*time.Millisecond {
+		t.Errorf("instant replay took %v", total)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Headers a client sets to label its own traffic. They are recorded on the
+// request and removed before it is forwarded.
+const (
+	TagHeader          = "X-LLMProxy-Tag"          // Repeatable, comma-separated
+	RunHeader          = "X-LLMProxy-Run"          // e.g. one test-harness run
+	ConversationHeader = "X-LLMProxy-Conversation" // e.g. one agent's thread
+)
+
+// ClientTags are the labels a client attached to a request
+type ClientTags struct {
+	Tags         []string
+	Run          string
+	Conversation string
+}
+
+// takeClientTags reads the tag headers and deletes them from h so they never
+// reach the upstream
+func takeClientTags(h http.Header) ClientTags {
+	var tags ClientTags
+	seen := make(map[string]bool)
+	for _, value := range h.Values(TagHeader) {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				tags.Tags = append(tags.Tags, tag)
+			}
+		}
+	}
+	tags.Run = strings.TrimSpace(h.Get(RunHeader))
+	tags.Conversation = strings.TrimSpace(h.Get(ConversationHeader))
+	h.Del(TagHeader)
+	h.Del(RunHeader)
+	h.Del(ConversationHeader)
+	return tags
+}
+
+// applyClientTags copies the client's labels onto a request
+func applyClientTags(req *LLMRequest, tags ClientTags) {
+	req.Tags = tags.Tags
+	req.Run = tags.Run
+	req.Conversation = tags.Conversation
+}
+
+// formatTagLabel joins run, conversation and tags for display:
+// "run-7/conv-3 #retry #slow"
+func formatTagLabel(tags []string, run, conversation string) string {
+	var parts []string
+	if run != "" || conversation != "" {
+		parts = append(parts, strings.Trim(run+"/"+conversation, "/"))
+	}
+	for _, tag := range tags {
+		parts = append(parts, "#"+tag)
+	}
+	return strings.Join(parts, " ")
+}
+
+// TagLabel returns the request's client labels for display, "" if it has none
+func (r *LLMRequest) TagLabel() string {
+	return formatTagLabel(r.Tags, r.Run, r.Conversation)
+}
+
+// tagSearchText renders the labels as "tag:x run:y conversation:z" so a plain
+// substring search can target one kind
+func tagSearchText(tags []string, run, conversation string) string {
+	var parts []string
+	for _, tag := range tags {
+		parts = append(parts, "tag:"+tag)
+	}
+	if run != "" {
+		parts = append(parts, "run:"+run)
+	}
+	if conversation != "" {
+		parts = append(parts, "conversation:"+conversation)
+	}
+	return strings.Join(parts, " ")
+}
+
+// hasTagFold reports whether tags contains tag, ignoring case
+func hasTagFold(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// CostGroupBy is the dimension a cost breakdown is grouped by
+type CostGroupBy string
+
+const (
+	GroupByModel        CostGroupBy = "model"
+	GroupByTag          CostGroupBy = "tag"
+	GroupByRun          CostGroupBy = "run"
+	GroupByConversation CostGroupBy = "conversation"
+)
+
+// parseCostGroupBy validates a --group-by value
+func parseCostGroupBy(raw string) (CostGroupBy, bool) {
+	switch g := CostGroupBy(strings.ToLower(strings.TrimSpace(raw))); g {
+	case "", GroupByModel:
+		return GroupByModel, true
+	case GroupByTag, GroupByRun, GroupByConversation:
+		return g, true
+	}
+	return "", false
+}
+
+// costGroupKeys returns the groups a request counts toward. A request with
+// several tags counts once in each.
+func costGroupKeys(req *LLMRequest, groupBy CostGroupBy) []string {
+	switch groupBy {
+	case GroupByTag:
+		if len(req.Tags) == 0 {
+			return []string{"(untagged)"}
+		}
+		tags := append([]string(nil), req.Tags...)
+		sort.Strings(tags)
+		return tags
+	case GroupByRun:
+		if req.Run == "" {
+			return []string{"(no run)"}
+		}
+		return []string{req.Run}
+	case GroupByConversation:
+		if req.Conversation == "" {
+			return []string{"(no conversation)"}
+		}
+		return []string{req.Conversation}
+	}
+	if req.Model == "" {
+		return []string{"(unknown)"}
+	}
+	return []string{req.Model}
+}
+
+// anyRequestLabeled reports whether any request carries the label groupBy
+// names, so empty "By Tag" style sections can be left out
+func anyRequestLabeled(requests []*LLMRequest, groupBy CostGroupBy) bool {
+	for _, req := range requests {
+		if req.Status == StatusPending {
+			continue
+		}
+		switch groupBy {
+		case GroupByTag:
+			if len(req.Tags) > 0 {
+				return true
+			}
+		case GroupByRun:
+			if req.Run != "" {
+				return true
+			}
+		case GroupByConversation:
+			if req.Conversation != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTakeClientTags(t *testing.T) {
+	h := http.Header{}
+	h.Add(TagHeader, "retry, slow")
+	h.Add(TagHeader, "slow,eval")
+	h.Set(RunHeader, " run-7 ")
+	h.Set(ConversationHeader, "agent-3")
+	h.Set("Authorization", "Bearer x")
+
+	tags := takeClientTags(h)
+	if strings.Join(tags.Tags, ",") != "retry,slow,eval" || tags.Run != "run-7" || tags.Conversation != "agent-3" {
+		t.Errorf("tags = %+v", tags)
+	}
+	if h.Get(TagHeader) != "" || h.Get(RunHeader) != "" || h.Get(ConversationHeader) != "" || h.Get("Authorization") == "" {
+		t.Errorf("headers after strip = %v", h)
+	}
+	if label := formatTagLabel(tags.Tags, tags.Run, tags.Conversation); label != "run-7/agent-3 #retry #slow #eval" {
+		t.Errorf("label = %q", label)
+	}
+	if label := formatTagLabel(nil, "", "agent-3"); label != "agent-3" {
+		t.Errorf("conversation-only label = %q", label)
+	}
+}
+
+func TestAnalyzeRequestsCostsGroupBy(t *testing.T) {
+	requests := []*LLMRequest{
+		{Model: "gpt-4o", Status: StatusComplete, Cost: 1, Tags: []string{"eval", "retry"}, Run: "run-1"},
+		{Model: "gpt-4o", Status: StatusComplete, Cost: 2, Tags: []string{"eval"}, Run: "run-2"},
+		{Model: "claude", Status: StatusError, Cost: 4},
+		{Model: "claude", Status: StatusPending, Cost: 8, Tags: []string{"eval"}},
+	}
+
+	byTag := AnalyzeRequestsCosts(requests, GroupByTag)
+	got := map[string]float64{}
+	for _, g := range byTag.Groups {
+		got[g.Group] = g.Cost
+	}
+	want := map[string]float64{"eval": 3, "retry": 1, "(untagged)": 4}
+	if len(got) != len(want) {
+		t.Fatalf("tag groups = %v", got)
+	}
+	for k, v := range want {
+		if math.Abs(got[k]-v) > 1e-9 {
+			t.Errorf("tag %q cost = %v, want %v", k, got[k], v)
+		}
+	}
+	// Totals count each request once, however many tags it has
+	if byTag.TotalCost != 7 || byTag.TotalRequests != 3 || len(byTag.Models) != 2 {
+		t.Errorf("totals = %v over %d requests, %d models", byTag.TotalCost, byTag.TotalRequests, len(byTag.Models))
+	}
+
+	byRun := AnalyzeRequestsCosts(requests, GroupByRun)
+	if len(byRun.Groups) != 3 || byRun.Groups[0].Group != "(no run)" || byRun.Groups[1].Group != "run-2" {
+		t.Errorf("run groups = %+v", byRun.Groups)
+	}
+	if AnalyzeRequestsCosts(requests, GroupByModel).Groups != nil {
+		t.Error("model grouping should leave Groups empty")
+	}
+	if _, ok := parseCostGroupBy("Conversation"); !ok {
+		t.Error("group-by should be case-insensitive")
+	}
+	if _, ok := parseCostGroupBy("proxy"); ok {
+		t.Error("unknown group-by accepted")
+	}
+}
+
+func TestClientTagsProxyIntegration(t *testing.T) {
+	resetTestState()
+	t.Setenv(sessionHistoryDirEnv, t.TempDir())
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range []string{TagHeader, RunHeader, ConversationHeader} {
+			if r.Header.Get(h) != "" {
+				t.Errorf("%s was forwarded upstream", h)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer upstream.Close()
+
+	sessionID, err := StartSessionHistory(":0", upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer StopSessionHistory()
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-tags", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	httpReq, _ := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/v1/chat/completions", port),
+		bytes.NewReader([]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(TagHeader, "eval")
+	httpReq.Header.Set(RunHeader, "run-7")
+	httpReq.Header.Set(ConversationHeader, "agent-3")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if captured.TagLabel() != "run-7/agent-3 #eval" {
+		t.Errorf("captured labels = %q", captured.TagLabel())
+	}
+
+	// inspect filters on the labels stored in session history
+	var out bytes.Buffer
+	if err := RunInspectCommand(&out, InspectOptions{SessionID: sessionID, Run: "RUN-7", Tag: "eval"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Showing 1 of 1") || !strings.Contains(out.String(), "run-7/agent-3 #eval") {
+		t.Errorf("inspect output = %s", out.String())
+	}
+	out.Reset()
+	if err := RunInspectCommand(&out, InspectOptions{SessionID: sessionID, Conversation: "agent-4"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Showing 0 of 0") {
+		t.Errorf("conversation filter output = %s", out.String())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -13,11 +14,12 @@ import (
 type TapeEventType string
 
 const (
-	EventSessionStart   TapeEventType = "session_start"
-	EventRequestStart   TapeEventType = "request_start"
-	EventRequestUpdate  TapeEventType = "request_update"
+	EventSessionStart    TapeEventType = "session_start"
+	EventRequestStart    TapeEventType = "request_start"
+	EventRequestUpdate   TapeEventType = "request_update"
 	EventRequestComplete TapeEventType = "request_complete"
-	EventSessionEnd     TapeEventType = "session_end"
+	EventSessionEnd      TapeEventType = "session_end"
+	EventWSMessage       TapeEventType = "ws_message"
 )
 
 // TapeEvent represents a single event in a tape file
@@ -49,18 +51,47 @@ type TapeRequestData struct {
 	StartTime            time.Time           `json:"start_time"`
 	Duration             time.Duration       `json:"duration"`
 	TTFT                 time.Duration       `json:"ttft,omitempty"`
+	GenerationDuration   time.Duration       `json:"generation_duration,omitempty"`
 	RequestHeaders       map[string][]string `json:"request_headers,omitempty"`
 	ResponseHeaders      map[string][]string `json:"response_headers,omitempty"`
 	RequestBody          []byte              `json:"request_body,omitempty"`
 	ResponseBody         []byte              `json:"response_body,omitempty"`
 	RequestSize          int                 `json:"request_size"`
 	ResponseSize         int                 `json:"response_size"`
+	DecodeError          string              `json:"decode_error,omitempty"`
 	IsStreaming          bool                `json:"is_streaming"`
+	IsWebSocket          bool                `json:"websocket,omitempty"`
 	EstimatedInputTokens int                 `json:"estimated_input_tokens,omitempty"`
 	InputTokens          int                 `json:"input_tokens,omitempty"`
 	OutputTokens         int                 `json:"output_tokens,omitempty"`
+	CachedInputTokens    int                 `json:"cached_input_tokens,omitempty"`
 	ProviderID           string              `json:"provider_id,omitempty"`
 	Cost                 float64             `json:"cost,omitempty"`
+	ProxyName            string              `json:"proxy_name,omitempty"`
+	RouteName            string              `json:"route_name,omitempty"`
+	Deployment           string              `json:"deployment,omitempty"`
+	CorrelationID        string              `json:"correlation_id,omitempty"`
+	TraceID              string              `json:"trace_id,omitempty"`
+	ClientRequestID      string              `json:"client_request_id,omitempty"`
+	Tags                 []string            `json:"tags,omitempty"`
+	Run                  string              `json:"run,omitempty"`
+	Conversation         string              `json:"conversation,omitempty"`
+	TargetURL            string              `json:"target_url,omitempty"`
+	Attempts             []RequestAttempt    `json:"attempts,omitempty"`
+	InjectedFault        string              `json:"injected_fault,omitempty"`
+	BudgetExceeded       string              `json:"budget_exceeded,omitempty"`
+	CacheKey             string              `json:"cache_key,omitempty"`
+	CacheKeyFields       []string            `json:"cache_key_fields,omitempty"`
+	CacheIgnoredFields   []string            `json:"cache_ignored_fields,omitempty"`
+}
+
+// TapeWSMessageData contains one WebSocket message of a request
+type TapeWSMessageData struct {
+	RequestID int         `json:"request_id"`
+	Direction WSDirection `json:"direction"`
+	Type      string      `json:"type,omitempty"`
+	Text      string      `json:"text,omitempty"` // Text, JSON and close messages
+	Data      []byte      `json:"data,omitempty"` // Binary messages
 }
 
 // Tape represents a loaded tape with all events
@@ -89,6 +120,7 @@ type TapeWriter struct {
 	file     *os.File
 	encoder  *json.Encoder
 	sequence int
+	mu       sync.Mutex // Requests and WebSocket relays write concurrently
 }
 
 // NewTapeWriter creates a new tape writer
@@ -112,6 +144,8 @@ func (tw *TapeWriter) WriteEvent(eventType TapeEventType, data interface{}) erro
 		return fmt.Errorf("failed to marshal event data: %w", err)
 	}
 
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
 	tw.sequence++
 	event := TapeEvent{
 		Timestamp: time.Now(),
@@ -153,6 +187,11 @@ func (tw *TapeWriter) WriteRequestComplete(req *LLMRequest) error {
 	return tw.WriteEvent(EventRequestComplete, requestToTapeData(req))
 }
 
+// WriteWSMessage writes one WebSocket message of a request
+func (tw *TapeWriter) WriteWSMessage(requestID int, event WSEvent) error {
+	return tw.WriteEvent(EventWSMessage, wsEventToTapeData(requestID, event))
+}
+
 // WriteSessionEnd writes the session end event
 func (tw *TapeWriter) WriteSessionEnd() error {
 	return tw.WriteEvent(EventSessionEnd, map[string]interface{}{
@@ -168,6 +207,7 @@ func (tw *TapeWriter) Close() error {
 
 // requestToTapeData converts an LLMRequest to TapeRequestData
 func requestToTapeData(req *LLMRequest) TapeRequestData {
+	req = currentRedactor().RedactRequest(req)
 	return TapeRequestData{
 		ID:                   req.ID,
 		Method:               req.Method,
@@ -180,19 +220,60 @@ func requestToTapeData(req *LLMRequest) TapeRequestData {
 		StartTime:            req.StartTime,
 		Duration:             req.Duration,
 		TTFT:                 req.TTFT,
+		GenerationDuration:   req.GenerationDuration,
 		RequestHeaders:       req.RequestHeaders,
 		ResponseHeaders:      req.ResponseHeaders,
 		RequestBody:          req.RequestBody,
 		ResponseBody:         req.ResponseBody,
 		RequestSize:          req.RequestSize,
 		ResponseSize:         req.ResponseSize,
+		DecodeError:          req.DecodeError,
 		IsStreaming:          req.IsStreaming,
+		IsWebSocket:          req.IsWebSocket,
 		EstimatedInputTokens: req.EstimatedInputTokens,
 		InputTokens:          req.InputTokens,
 		OutputTokens:         req.OutputTokens,
+		CachedInputTokens:    req.CachedInputTokens,
 		ProviderID:           req.ProviderID,
 		Cost:                 req.Cost,
+		ProxyName:            req.ProxyName,
+		RouteName:            req.RouteName,
+		Deployment:           req.Deployment,
+		CorrelationID:        req.CorrelationID,
+		TraceID:              req.TraceID,
+		ClientRequestID:      req.ClientRequestID,
+		Tags:                 req.Tags,
+		Run:                  req.Run,
+		Conversation:         req.Conversation,
+		TargetURL:            req.TargetURL,
+		Attempts:             req.Attempts,
+		InjectedFault:        req.InjectedFault,
+		BudgetExceeded:       req.BudgetExceeded,
+		CacheKey:             req.CacheKey,
+		CacheKeyFields:       req.CacheKeyFields,
+		CacheIgnoredFields:   req.CacheIgnoredFields,
+	}
+}
+
+// wsEventToTapeData converts a WebSocket message to TapeWSMessageData
+func wsEventToTapeData(requestID int, event WSEvent) TapeWSMessageData {
+	data := TapeWSMessageData{RequestID: requestID, Direction: event.Direction, Type: event.Type}
+	if event.Binary {
+		data.Data = event.Data
+	} else {
+		data.Text = string(currentRedactor().RedactBody(event.Data))
+	}
+	return data
+}
+
+// tapeDataToWSEvent converts TapeWSMessageData back to a WebSocket message
+func tapeDataToWSEvent(data TapeWSMessageData, timestamp time.Time) WSEvent {
+	event := WSEvent{Time: timestamp, Direction: data.Direction, Type: data.Type, Data: []byte(data.Text)}
+	if data.Data != nil {
+		event.Data = data.Data
+		event.Binary = true
 	}
+	return event
 }
 
 // tapeDataToRequest converts TapeRequestData to LLMRequest
@@ -209,18 +290,38 @@ func tapeDataToRequest(data TapeRequestData) *LLMRequest {
 		StartTime:            data.StartTime,
 		Duration:             data.Duration,
 		TTFT:                 data.TTFT,
+		GenerationDuration:   data.GenerationDuration,
 		RequestHeaders:       data.RequestHeaders,
 		ResponseHeaders:      data.ResponseHeaders,
 		RequestBody:          data.RequestBody,
 		ResponseBody:         data.ResponseBody,
 		RequestSize:          data.RequestSize,
 		ResponseSize:         data.ResponseSize,
+		DecodeError:          data.DecodeError,
 		IsStreaming:          data.IsStreaming,
+		IsWebSocket:          data.IsWebSocket,
 		EstimatedInputTokens: data.EstimatedInputTokens,
 		InputTokens:          data.InputTokens,
 		OutputTokens:         data.OutputTokens,
+		CachedInputTokens:    data.CachedInputTokens,
 		ProviderID:           data.ProviderID,
 		Cost:                 data.Cost,
+		ProxyName:            data.ProxyName,
+		RouteName:            data.RouteName,
+		Deployment:           data.Deployment,
+		CorrelationID:        data.CorrelationID,
+		TraceID:              data.TraceID,
+		ClientRequestID:      data.ClientRequestID,
+		Tags:                 data.Tags,
+		Run:                  data.Run,
+		Conversation:         data.Conversation,
+		TargetURL:            data.TargetURL,
+		Attempts:             data.Attempts,
+		InjectedFault:        data.InjectedFault,
+		BudgetExceeded:       data.BudgetExceeded,
+		CacheKey:             data.CacheKey,
+		CacheKeyFields:       data.CacheKeyFields,
+		CacheIgnoredFields:   data.CacheIgnoredFields,
 	}
 }
 
@@ -267,8 +368,10 @@ func LoadTape(filename string) (*Tape, error) {
 			if err := json.Unmarshal(event.Data, &reqData); err == nil {
 				// Update or create request
 				if existing, ok := tape.RequestMap[reqData.ID]; ok {
-					// Update existing request
+					// Update existing request, keeping WebSocket messages recorded so far
+					wsEvents := existing.WSEvents
 					*existing = *tapeDataToRequest(reqData)
+					existing.WSEvents = wsEvents
 				} else {
 					// Create new request
 					req := tapeDataToRequest(reqData)
@@ -284,6 +387,19 @@ func LoadTape(filename string) (*Tape, error) {
 				})
 			}
 
+		case EventWSMessage:
+			var msgData TapeWSMessageData
+			if err := json.Unmarshal(event.Data, &msgData); err == nil {
+				if req, ok := tape.RequestMap[msgData.RequestID]; ok {
+					req.WSEvents = append(req.WSEvents, tapeDataToWSEvent(msgData, event.Timestamp))
+					tape.Timeline = append(tape.Timeline, TimelineEntry{
+						Time:    event.Timestamp,
+						Event:   &tape.Events[len(tape.Events)-1],
+						Request: req,
+					})
+				}
+			}
+
 		case EventSessionEnd:
 			tape.EndTime = event.Timestamp
 		}
@@ -353,8 +469,11 @@ func SaveSessionToTape(filename string, listenAddr, targetURL string) error {
 		pendingReq.ResponseHeaders = nil
 		pendingReq.ResponseBody = nil
 		pendingReq.ResponseSize = 0
+		pendingReq.DecodeError = ""
 		pendingReq.InputTokens = 0
 		pendingReq.OutputTokens = 0
+		pendingReq.CachedInputTokens = 0
+		pendingReq.GenerationDuration = 0
 		pendingReq.Cost = 0
 
 		// Write request start event at request start time
@@ -368,6 +487,18 @@ func SaveSessionToTape(filename string, listenAddr, targetURL string) error {
 		writer.sequence++
 		writer.encoder.Encode(startEvent)
 
+		// WebSocket messages at the times they were relayed
+		for _, wsEvent := range req.WSEvents {
+			msgData, _ := json.Marshal(wsEventToTapeData(req.ID, wsEvent))
+			writer.sequence++
+			writer.encoder.Encode(TapeEvent{
+				Timestamp: wsEvent.Time,
+				Type:      EventWSMessage,
+				Sequence:  writer.sequence,
+				Data:      msgData,
+			})
+		}
+
 		// If complete, write complete event with full data
 		if req.Status != StatusPending {
 			completeEvent := TapeEvent{
@@ -396,10 +527,24 @@ func (t *Tape) GetRequestsAtTime(targetTime time.Time) []*LLMRequest {
 		}
 
 		// Apply event to build state at this time
-		if entry.Event != nil {
+		if entry.Event == nil {
+			continue
+		}
+		switch entry.Event.Type {
+		case EventWSMessage:
+			var msgData TapeWSMessageData
+			if err := json.Unmarshal(entry.Event.Data, &msgData); err == nil {
+				if req, ok := requestStates[msgData.RequestID]; ok {
+					req.WSEvents = append(req.WSEvents, tapeDataToWSEvent(msgData, entry.Time))
+				}
+			}
+		default:
 			var reqData TapeRequestData
 			if err := json.Unmarshal(entry.Event.Data, &reqData); err == nil {
 				req := tapeDataToRequest(reqData)
+				if prev, ok := requestStates[req.ID]; ok {
+					req.WSEvents = prev.WSEvents
+				}
 				requestStates[req.ID] = req
 			}
 		}
@@ -475,4 +620,3 @@ func (t *Tape) StepBackward() bool {
 	}
 	return false
 }
-
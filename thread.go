@@ -0,0 +1,519 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Agent loops resend the whole conversation every turn. A request whose
+// messages start with an earlier request's messages, followed by the
+// assistant message that request got back, continues it; linking those
+// requests gives a thread per conversation.
+
+// threadMessage is one message reduced to what identifies it across resends.
+// Cache markers, thinking blocks and image bytes are left out because
+// clients move, drop or re-encode them between turns.
+type threadMessage struct {
+	role    string
+	content string
+}
+
+// threadConversation extracts the system prompt and messages a request sent.
+// ok is false for bodies without a message list.
+func threadConversation(req *LLMRequest) (system string, msgs []threadMessage, ok bool) {
+	if len(req.RequestBody) == 0 || isMediaEndpoint(req.Path) {
+		return "", nil, false
+	}
+
+	if isAnthropicEndpoint(req.Path) {
+		var body AnthropicRequest
+		if err := json.Unmarshal(req.RequestBody, &body); err != nil || len(body.Messages) == 0 {
+			return "", nil, false
+		}
+		for _, msg := range body.Messages {
+			msgs = append(msgs, threadMessage{role: msg.Role, content: threadContentText(msg.Content)})
+		}
+		return threadContentText(body.System), msgs, true
+	}
+
+	if isGeminiEndpoint(req.Path) {
+		var body struct {
+			Contents []struct {
+				Role  string `json:"role"`
+				Parts []any  `json:"parts"`
+			} `json:"contents"`
+			SystemInstruction *struct {
+				Parts []any `json:"parts"`
+			} `json:"systemInstruction"`
+		}
+		if err := json.Unmarshal(req.RequestBody, &body); err != nil || len(body.Contents) == 0 {
+			return "", nil, false
+		}
+		for _, content := range body.Contents {
+			role := content.Role
+			if role == "model" {
+				role = "assistant"
+			}
+			msgs = append(msgs, threadMessage{role: role, content: threadContentText(content.Parts)})
+		}
+		if body.SystemInstruction != nil {
+			system = threadContentText(body.SystemInstruction.Parts)
+		}
+		return system, msgs, true
+	}
+
+	body, err := decodeOpenAIRequest(req.Path, req.RequestBody)
+	if err != nil || len(body.Messages) == 0 {
+		return "", nil, false
+	}
+	for _, msg := range body.Messages {
+		msgs = append(msgs, threadMessage{role: msg.Role, content: openAIThreadText(msg)})
+	}
+	return "", msgs, true
+}
+
+// openAIThreadText flattens a chat message with its tool calls
+func openAIThreadText(msg OpenAIMessage) string {
+	var b strings.Builder
+	b.WriteString(threadContentText(msg.Content))
+	for _, tc := range msg.ToolCalls {
+		b.WriteString("\ncall:" + tc.Function.Name + " " + canonicalJSONText(tc.Function.Arguments))
+	}
+	if msg.ToolCallID != "" {
+		b.WriteString("\nresult:" + msg.ToolCallID)
+	}
+	return b.String()
+}
+
+// threadReply returns the assistant message a request got back, flattened
+// the way the client will resend it. ok is false while the reply is unknown:
+// the request hasn't completed or its response can't be parsed.
+func threadReply(req *LLMRequest) (reply string, ok bool) {
+	if req.Status != StatusComplete || len(req.ResponseBody) == 0 {
+		return "", false
+	}
+	body := req.ResponseBody
+
+	if isAnthropicEndpoint(req.Path) {
+		var resp AnthropicResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			assembled := reassembleAnthropicSSEResponse(body)
+			if assembled == nil {
+				return "", false
+			}
+			resp = *assembled
+		}
+		for i := range resp.Content {
+			if resp.Content[i].Type == "tool_use" && resp.Content[i].Input == nil {
+				resp.Content[i].Input = map[string]any{} // Resent as {}
+			}
+		}
+		// Round-trip the blocks so they flatten like the resent request's
+		var blocks any
+		data, _ := json.Marshal(resp.Content)
+		if err := json.Unmarshal(data, &blocks); err != nil {
+			return "", false
+		}
+		return threadContentText(blocks), true
+	}
+
+	if isGeminiEndpoint(req.Path) {
+		if isGeminiStreamData(body) {
+			if body = reassembleGeminiSSE(body); body == nil {
+				return "", false
+			}
+		}
+		var resp struct {
+			Candidates []struct {
+				Content struct {
+					Parts []any `json:"parts"`
+				} `json:"content"`
+			} `json:"candidates"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil || len(resp.Candidates) == 0 {
+			return "", false
+		}
+		return threadContentText(resp.Candidates[0].Content.Parts), true
+	}
+
+	resp := decodeOpenAIResponse(req.Path, body)
+	if resp == nil || len(resp.Choices) == 0 {
+		return "", false
+	}
+	return openAIThreadText(resp.Choices[0].Message), true
+}
+
+// threadContentText flattens OpenAI, Anthropic and Gemini content (a string
+// or a list of parts/blocks) into comparable text
+func threadContentText(content any) string {
+	switch c := content.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(c)
+	case []any:
+		var parts []string
+		for _, item := range c {
+			if text := threadContentText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case map[string]any:
+		if text, ok := c["text"].(string); ok {
+			return strings.TrimSpace(text)
+		}
+		blockType, _ := c["type"].(string)
+		switch blockType {
+		case "thinking", "redacted_thinking":
+			return ""
+		case "tool_use":
+			name, _ := c["name"].(string)
+			input, _ := json.Marshal(c["input"])
+			return "call:" + name + " " + string(input)
+		case "tool_result":
+			id, _ := c["tool_use_id"].(string)
+			return "result:" + id + " " + threadContentText(c["content"])
+		}
+		// Gemini function calls and responses
+		if call, ok := c["functionCall"].(map[string]any); ok {
+			name, _ := call["name"].(string)
+			args, _ := json.Marshal(call["args"])
+			return "call:" + name + " " + string(args)
+		}
+		if resp, ok := c["functionResponse"].(map[string]any); ok {
+			name, _ := resp["name"].(string)
+			out, _ := json.Marshal(resp["response"])
+			return "result:" + name + " " + string(out)
+		}
+		if blockType != "" {
+			return "[" + blockType + "]" // images, audio, documents
+		}
+	}
+	return ""
+}
+
+// canonicalJSONText re-encodes JSON text so key order and spacing don't
+// matter; anything else is returned trimmed
+func canonicalJSONText(s string) string {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return strings.TrimSpace(s)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+// threadKeys returns a running fingerprint of the conversation after each
+// message: keys[i] covers the system prompt and messages 0..i
+func threadKeys(system string, msgs []threadMessage) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(system))
+	prev := h.Sum64()
+
+	keys := make([]uint64, len(msgs))
+	var buf [8]byte
+	for i, msg := range msgs {
+		h.Reset()
+		binary.LittleEndian.PutUint64(buf[:], prev)
+		h.Write(buf[:])
+		h.Write([]byte(msg.role))
+		h.Write([]byte{0})
+		h.Write([]byte(msg.content))
+		prev = h.Sum64()
+		keys[i] = prev
+	}
+	return keys
+}
+
+// threadNode places one request in its thread
+type threadNode struct {
+	ID       int
+	Parent   int   // 0 for the first request of a thread
+	Root     int   // ID of the thread's first request
+	Prefix   int   // Leading messages the parent already sent
+	Children []int // Later turns continuing this one, by ID
+
+	req *LLMRequest // For its reply, once it has one
+}
+
+// threadIndex links requests into threads as they arrive. A turn is linked
+// as soon as it starts, to the earlier request whose reply it resent; by
+// then that request has usually finished.
+type threadIndex struct {
+	nodes map[int]*threadNode
+	byKey map[uint64][]int // Whole conversation of a request -> IDs that sent it
+}
+
+func newThreadIndex() *threadIndex {
+	return &threadIndex{
+		nodes: make(map[int]*threadNode),
+		byKey: make(map[uint64][]int),
+	}
+}
+
+// update adds any requests not seen yet, oldest first so parents come
+// before their continuations
+func (t *threadIndex) update(requests []*LLMRequest) {
+	var pending []*LLMRequest
+	for _, req := range requests {
+		if _, seen := t.nodes[req.ID]; !seen {
+			pending = append(pending, req)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	for _, req := range pending {
+		t.add(req)
+	}
+}
+
+func (t *threadIndex) add(req *LLMRequest) {
+	node := &threadNode{ID: req.ID, Root: req.ID, req: req}
+	t.nodes[req.ID] = node
+
+	system, msgs, ok := threadConversation(req)
+	if !ok {
+		return
+	}
+	keys := threadKeys(system, msgs)
+
+	// The longest earlier conversation this one extends with that
+	// conversation's reply is the previous turn
+	for n := len(msgs) - 1; n >= 1; n-- {
+		if msgs[n].role != "assistant" {
+			continue
+		}
+		parentID := t.parentFor(keys[n-1], msgs[n].content, req.ID)
+		if parentID == 0 {
+			continue
+		}
+		parent := t.nodes[parentID]
+		node.Parent = parentID
+		node.Root = parent.Root
+		node.Prefix = n
+		parent.Children = append(parent.Children, req.ID)
+		break
+	}
+	last := keys[len(keys)-1]
+	t.byKey[last] = append(t.byKey[last], req.ID)
+}
+
+// parentFor picks, among earlier requests that sent the conversation under
+// key, the one whose reply is the resent assistant message. Retries and
+// agents started with the same prompt share a conversation; the newest with
+// a matching reply wins, then the newest whose reply is unknown.
+func (t *threadIndex) parentFor(key uint64, reply string, before int) int {
+	candidates := t.byKey[key]
+	unknown := 0
+	for i := len(candidates) - 1; i >= 0; i-- {
+		id := candidates[i]
+		if id >= before {
+			continue
+		}
+		got, ok := threadReply(t.nodes[id].req)
+		if !ok {
+			if unknown == 0 {
+				unknown = id
+			}
+			continue
+		}
+		if got == reply {
+			return id
+		}
+	}
+	return unknown
+}
+
+// node returns the thread placement of a request, if it has been indexed
+func (t *threadIndex) node(id int) *threadNode {
+	return t.nodes[id]
+}
+
+// turnOf returns a request's position in its thread, counting turns in ID
+// order, and how many of requests the thread has
+func (t *threadIndex) turnOf(requests []*LLMRequest, id int) (turn, total int) {
+	node := t.nodes[id]
+	if node == nil {
+		return 0, 0
+	}
+	for _, req := range requests {
+		if other := t.nodes[req.ID]; other != nil && other.Root == node.Root {
+			total++
+			if req.ID <= id {
+				turn++
+			}
+		}
+	}
+	return turn, total
+}
+
+// order arranges requests thread by thread: threads in order of their first
+// request, each one depth-first with turns in ID order. Requests whose
+// parent isn't in the list start their own group.
+func (t *threadIndex) order(requests []*LLMRequest) []*LLMRequest {
+	byID := make(map[int]*LLMRequest, len(requests))
+	for _, req := range requests {
+		byID[req.ID] = req
+	}
+
+	var roots []*LLMRequest
+	for _, req := range requests {
+		node := t.nodes[req.ID]
+		if node == nil || node.Parent == 0 || byID[node.Parent] == nil {
+			roots = append(roots, req)
+		}
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return roots[i].ID < roots[j].ID })
+
+	ordered := make([]*LLMRequest, 0, len(requests))
+	var visit func(req *LLMRequest)
+	visit = func(req *LLMRequest) {
+		ordered = append(ordered, req)
+		node := t.nodes[req.ID]
+		if node == nil {
+			return
+		}
+		for _, childID := range node.Children {
+			if child := byID[childID]; child != nil {
+				visit(child)
+			}
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	return ordered
+}
+
+// branchDepth counts the forks above a request, for indenting the tree. A
+// plain back-and-forth loop stays at depth 0 however many turns it has.
+func (t *threadIndex) branchDepth(id int) int {
+	depth := 0
+	for node := t.nodes[id]; node != nil && node.Parent != 0; {
+		parent := t.nodes[node.Parent]
+		if parent == nil {
+			break
+		}
+		if len(parent.Children) > 1 {
+			depth++
+		}
+		node = parent
+	}
+	return depth
+}
+
+// ThreadStats totals one thread
+type ThreadStats struct {
+	Root         int
+	Turns        int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	WallClock    time.Duration // First request start to last request end
+}
+
+// threadStats totals the requests of the thread rooted at root
+func (t *threadIndex) threadStats(requests []*LLMRequest, root int) ThreadStats {
+	stats := ThreadStats{Root: root}
+	var first, last time.Time
+	for _, req := range requests {
+		node := t.nodes[req.ID]
+		if node == nil || node.Root != root {
+			continue
+		}
+		stats.Turns++
+		stats.InputTokens += req.InputTokens
+		stats.OutputTokens += req.OutputTokens
+		stats.Cost += req.Cost
+		if first.IsZero() || req.StartTime.Before(first) {
+			first = req.StartTime
+		}
+		if end := req.StartTime.Add(req.Duration); end.After(last) {
+			last = end
+		}
+	}
+	if !first.IsZero() && last.After(first) {
+		stats.WallClock = last.Sub(first)
+	}
+	return stats
+}
+
+// threadSummary describes the thread of the request under the list cursor:
+// "🧵 Thread #3 · 5 turns · 12.4k in / 2.1k out · $0.0412 · 1m 3s"
+func (m *model) threadSummary(displayRequests []*LLMRequest) string {
+	if m.cursor >= len(displayRequests) {
+		return ""
+	}
+	node := m.threads.node(displayRequests[m.cursor].ID)
+	if node == nil {
+		return ""
+	}
+	stats := m.threads.threadStats(m.requests, node.Root)
+	turns := fmt.Sprintf("%d turns", stats.Turns)
+	if stats.Turns == 1 {
+		turns = "1 turn"
+	}
+	parts := []string{
+		fmt.Sprintf("🧵 Thread #%d", stats.Root),
+		turns,
+		fmt.Sprintf("%s in / %s out", formatTokenCount(stats.InputTokens), formatTokenCount(stats.OutputTokens)),
+	}
+	if stats.Cost > 0 {
+		parts = append(parts, formatCost(stats.Cost))
+	}
+	if stats.WallClock > 0 {
+		parts = append(parts, formatDuration(stats.WallClock))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// threadLabel places the selected request in its thread for the detail
+// header ("🧵 #3 turn 2/5"), or returns "" if it's a lone request
+func (m *model) threadLabel() string {
+	m.threads.update(m.requests)
+	turn, total := m.threads.turnOf(m.requests, m.selected.ID)
+	if total < 2 {
+		return ""
+	}
+	return fmt.Sprintf("🧵 #%d turn %d/%d", m.threads.node(m.selected.ID).Root, turn, total)
+}
+
+// threadRowPrefix indents continuations under the turn they extend
+func (m *model) threadRowPrefix(req *LLMRequest) string {
+	node := m.threads.node(req.ID)
+	if node == nil || node.Parent == 0 {
+		return ""
+	}
+	return strings.Repeat("  ", m.threads.branchDepth(req.ID)) + "└ "
+}
+
+// hiddenThreadMessages returns how many leading messages of the selected
+// request the Messages tab skips, and the earlier turn that sent them
+func (m *model) hiddenThreadMessages() (hidden, parent int) {
+	if !m.newMessagesOnly || m.selected == nil {
+		return 0, 0
+	}
+	m.threads.update(m.requests)
+	node := m.threads.node(m.selected.ID)
+	if node == nil || node.Parent == 0 {
+		return 0, 0
+	}
+	return node.Prefix, node.Parent
+}
+
+// renderHiddenMessagesNote stands in for the messages hidden by
+// hiddenThreadMessages. It takes two lines.
+func renderHiddenMessagesNote(hidden, parent int) string {
+	note := fmt.Sprintf("⋯ %d earlier messages from #%d hidden (t to show all)", hidden, parent)
+	if hidden == 1 {
+		note = fmt.Sprintf("⋯ 1 earlier message from #%d hidden (t to show all)", parent)
+	}
+	return lipgloss.NewStyle().Foreground(dimColor).Italic(true).Render(note) + "\n\n"
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestThreadIndexAgentLoop(t *testing.T) {
+	const path = "/v1/chat/completions"
+	requests := []*LLMRequest{
+		{ID: 1, Path: path, RequestBody: []byte(`{"messages":[
+			{"role":"system","content":"You are an agent."},
+			{"role":"user","content":"List the files"}]}`)},
+		// Tool call arguments re-encoded with different key order and spacing
+		{ID: 2, Path: path, RequestBody: []byte(`{"messages":[
+			{"role":"system","content":"You are an agent."},
+			{"role":"user","content":"List the files"},
+			{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"ls","arguments":"{\"dir\": \".\", \"all\": true}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"a.go b.go"}]}`)},
+		{ID: 3, Path: path, RequestBody: []byte(`{"messages":[
+			{"role":"system","content":"You are an agent."},
+			{"role":"user","content":"List the files"},
+			{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"ls","arguments":"{\"all\":true,\"dir\":\".\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"a.go b.go"},
+			{"role":"assistant","content":"There are two files."},
+			{"role":"user","content":"Open a.go"}]}`)},
+		// Few-shot examples contain an assistant message but extend nothing
+		{ID: 4, Path: path, RequestBody: []byte(`{"messages":[
+			{"role":"user","content":"2+2"},
+			{"role":"assistant","content":"4"},
+			{"role":"user","content":"3+3"}]}`)},
+		// #1 followed by another user message, with no reply in between,
+		// isn't a continuation
+		{ID: 5, Path: path, RequestBody: []byte(`{"messages":[
+			{"role":"system","content":"You are an agent."},
+			{"role":"user","content":"List the files"},
+			{"role":"user","content":"Hurry up"}]}`)},
+	}
+
+	threads := newThreadIndex()
+	threads.update(requests)
+
+	want := map[int]struct{ parent, root, prefix int }{
+		1: {0, 1, 0},
+		2: {1, 1, 2},
+		3: {2, 1, 4},
+		4: {0, 4, 0},
+		5: {0, 5, 0},
+	}
+	for id, w := range want {
+		node := threads.node(id)
+		if node.Parent != w.parent || node.Root != w.root || node.Prefix != w.prefix {
+			t.Errorf("#%d: parent %d root %d prefix %d, want %+v", id, node.Parent, node.Root, node.Prefix, w)
+		}
+	}
+
+	// A retry of #1 becomes the turn later requests continue
+	retry := &LLMRequest{ID: 6, Path: path, RequestBody: requests[0].RequestBody}
+	next := &LLMRequest{ID: 7, Path: path, RequestBody: []byte(`{"messages":[
+		{"role":"system","content":"You are an agent."},
+		{"role":"user","content":"List the files"},
+		{"role":"assistant","content":"Which directory?"},
+		{"role":"user","content":"This one"}]}`)}
+	requests = append(requests, retry, next)
+	threads.update(requests)
+	if node := threads.node(7); node.Parent != 6 || node.Root != 6 {
+		t.Errorf("#7: parent %d root %d, want 6", node.Parent, node.Root)
+	}
+
+	ids := func(reqs []*LLMRequest) string {
+		var out []int
+		for _, req := range reqs {
+			out = append(out, req.ID)
+		}
+		return fmt.Sprint(out)
+	}
+	if got := ids(threads.order(requests)); got != "[1 2 3 4 5 6 7]" {
+		t.Errorf("order = %s", got)
+	}
+	// Continuations follow their parent whatever order they come in
+	reversed := []*LLMRequest{requests[6], requests[5], requests[2], requests[1], requests[0]}
+	if got := ids(threads.order(reversed)); got != "[1 2 3 6 7]" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestThreadConversationFormats(t *testing.T) {
+	requests := []*LLMRequest{
+		{ID: 1, Path: "/v1/messages", RequestBody: []byte(`{"system":"Be brief.","messages":[
+			{"role":"user","content":"What's the weather?"}]}`)},
+		// The client moved its cache_control marker and kept the thinking block
+		{ID: 2, Path: "/v1/messages", RequestBody: []byte(`{"system":[{"type":"text","text":"Be brief.","cache_control":{"type":"ephemeral"}}],"messages":[
+			{"role":"user","content":[{"type":"text","text":"What's the weather?"}]},
+			{"role":"assistant","content":[
+				{"type":"thinking","thinking":"need a tool","signature":"x"},
+				{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"Sunny"}]}]}`)},
+		// Different system prompt, same messages: a separate conversation
+		{ID: 3, Path: "/v1/messages", RequestBody: []byte(`{"system":"Be verbose.","messages":[
+			{"role":"user","content":"What's the weather?"},
+			{"role":"assistant","content":"Let me check."},
+			{"role":"user","content":"Thanks"}]}`)},
+		{ID: 4, Path: "/v1beta/models/gemini-2.0-flash:generateContent", RequestBody: []byte(`{"contents":[
+			{"role":"user","parts":[{"text":"Hi"}]}]}`)},
+		{ID: 5, Path: "/v1beta/models/gemini-2.0-flash:streamGenerateContent", RequestBody: []byte(`{"contents":[
+			{"role":"user","parts":[{"text":"Hi"}]},
+			{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"x"}}}]},
+			{"role":"user","parts":[{"functionResponse":{"name":"lookup","response":{"r":1}}}]}]}`)},
+	}
+
+	threads := newThreadIndex()
+	threads.update(requests)
+	for id, parent := range map[int]int{2: 1, 3: 0, 5: 4} {
+		if got := threads.node(id).Parent; got != parent {
+			t.Errorf("#%d parent = %d, want %d", id, got, parent)
+		}
+	}
+}
+
+func TestThreadLinksOnTheParentsReply(t *testing.T) {
+	const path = "/v1/chat/completions"
+	prompt := `{"messages":[{"role":"user","content":"Plan a trip"}]}`
+	turn := func(reply string) []byte {
+		return []byte(`{"messages":[{"role":"user","content":"Plan a trip"},{"role":"assistant","content":"` + reply + `"},{"role":"user","content":"Book it"}]}`)
+	}
+	answered := func(id int, path, body, response string) *LLMRequest {
+		return &LLMRequest{ID: id, Path: path, RequestBody: []byte(body), Status: StatusComplete, ResponseBody: []byte(response)}
+	}
+	requests := []*LLMRequest{
+		// Two agents start with the same prompt and get different replies
+		answered(1, path, prompt, `{"choices":[{"message":{"role":"assistant","content":"Go to Rome"}}]}`),
+		answered(2, path, prompt, `{"choices":[{"message":{"role":"assistant","content":"Go to Oslo"}}]}`),
+		{ID: 3, Path: path, RequestBody: turn("Go to Rome")},
+		{ID: 4, Path: path, RequestBody: turn("Go to Oslo")},
+		// A reply neither of them got
+		{ID: 5, Path: path, RequestBody: turn("Go to Lima")},
+		answered(6, "/v1/messages", `{"messages":[{"role":"user","content":"What's the weather?"}]}`,
+			`{"content":[{"type":"thinking","thinking":"need a tool"},{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}]}`),
+		{ID: 7, Path: "/v1/messages", RequestBody: []byte(`{"messages":[
+			{"role":"user","content":"What's the weather?"},
+			{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"Sunny"}]}]}`)},
+		answered(8, "/v1beta/models/gemini-2.0-flash:streamGenerateContent", `{"contents":[{"role":"user","parts":[{"text":"Hi"}]}]}`,
+			`[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"x"}}}]}}]}]`),
+		{ID: 9, Path: "/v1beta/models/gemini-2.0-flash:streamGenerateContent", RequestBody: []byte(`{"contents":[
+			{"role":"user","parts":[{"text":"Hi"}]},
+			{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"x"}}}]},
+			{"role":"user","parts":[{"functionResponse":{"name":"lookup","response":{"r":1}}}]}]}`)},
+	}
+
+	threads := newThreadIndex()
+	threads.update(requests)
+	for id, parent := range map[int]int{3: 1, 4: 2, 5: 0, 7: 6, 9: 8} {
+		if got := threads.node(id).Parent; got != parent {
+			t.Errorf("#%d parent = %d, want %d", id, got, parent)
+		}
+	}
+}
+
+func TestThreadStats(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := func(turns int) []byte {
+		msgs := `{"role":"user","content":"go"}`
+		for i := 1; i < turns; i++ {
+			msgs += `,{"role":"assistant","content":"ok"},{"role":"user","content":"go"}`
+		}
+		return []byte(`{"messages":[` + msgs + `]}`)
+	}
+	requests := []*LLMRequest{
+		{ID: 1, Path: "/v1/chat/completions", RequestBody: body(1), StartTime: start, Duration: 2 * time.Second, InputTokens: 10, OutputTokens: 5, Cost: 0.01},
+		{ID: 2, Path: "/v1/chat/completions", RequestBody: body(2), StartTime: start.Add(3 * time.Second), Duration: 4 * time.Second, InputTokens: 20, OutputTokens: 5, Cost: 0.02},
+		{ID: 3, Path: "/v1/embeddings", RequestBody: []byte(`{"input":"go"}`), StartTime: start, Duration: time.Minute},
+	}
+
+	threads := newThreadIndex()
+	threads.update(requests)
+	stats := threads.threadStats(requests, 1)
+	if stats.Turns != 2 || stats.InputTokens != 30 || stats.OutputTokens != 10 || stats.WallClock != 7*time.Second {
+		t.Errorf("stats = %+v", stats)
+	}
+	if turn, total := threads.turnOf(requests, 2); turn != 2 || total != 2 {
+		t.Errorf("turnOf(2) = %d/%d", turn, total)
+	}
+	if _, total := threads.turnOf(requests, 3); total != 1 {
+		t.Errorf("embedding request joined a thread of %d", total)
+	}
+}
@@ -51,6 +51,11 @@ type model struct {
 	searchIndexCache    map[int]string // Cache of searchable text per request ID
 	requestPreviewCache map[int]string // Cache of list preview snippets per request ID
 
+	// Agent-loop threads
+	threads         *threadIndex // Links turns that continue an earlier request's conversation
+	threadMode      bool         // List grouped into threads instead of sorted
+	newMessagesOnly bool         // Messages tab hides what the previous turn already sent
+
 	// Tape mode
 	tape             *Tape     // Loaded tape for playback
 	tapeMode         bool      // True when viewing a tape
@@ -83,6 +88,14 @@ type model struct {
 	copyMessage     string
 	copyMessageTime time.Time
 
+	// Config reload notice
+	reloadMessage     string
+	reloadMessageTime time.Time
+
+	// Budget warning shown in the list view
+	budgetBanner string
+	budgetHard   bool
+
 	// Image references for current request
 	imageRefs []ImageRef
 
@@ -113,6 +126,7 @@ func initialModel(listenAddr, targetURL, saveTapeFile, sessionID string) model {
 		sortDirection:       SortAsc,
 		searchIndexCache:    make(map[int]string),
 		requestPreviewCache: make(map[int]string),
+		threads:             newThreadIndex(),
 		saveInput:           newSaveInput(),
 		mouseEnabled:        true,
 	}
@@ -134,6 +148,7 @@ func initialTapeModel(tape *Tape) model {
 		sortDirection:       SortAsc,
 		searchIndexCache:    make(map[int]string),
 		requestPreviewCache: make(map[int]string),
+		threads:             newThreadIndex(),
 		saveInput:           newSaveInput(),
 		mouseEnabled:        true,
 	}
@@ -211,17 +226,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.commandMode = false
 				m.commandBuffer = ""
 			case "enter":
-				// Execute the command - jump to request ID
+				// Execute the command - jump to request ID, or to the request a
+				// correlation ID, trace ID or client request ID belongs to
 				if m.commandBuffer != "" {
-					if targetID, err := parseNumber(m.commandBuffer); err == nil && targetID > 0 {
-						// Find request with matching ID
-						displayRequests := m.getDisplayRequests()
-						for i, req := range displayRequests {
-							if req.ID == targetID {
-								m.cursor = i
-								m.followLatest = false
-								break
-							}
+					targetID, err := parseNumber(m.commandBuffer)
+					displayRequests := m.getDisplayRequests()
+					for i, req := range displayRequests {
+						if (err == nil && req.ID == targetID) ||
+							(err != nil && matchesRequestRef(m.commandBuffer, req.CorrelationID, req.TraceID, req.ClientRequestID)) {
+							m.cursor = i
+							m.followLatest = false
+							break
 						}
 					}
 				}
@@ -232,9 +247,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.commandBuffer = m.commandBuffer[:len(m.commandBuffer)-1]
 				}
 			default:
-				// Only accept digits for the goto command
-				if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
-					m.commandBuffer += key
+				// IDs are printable ASCII without spaces; pasted IDs arrive as one message
+				if msg.Type == tea.KeyRunes {
+					for _, r := range msg.Runes {
+						if r > ' ' && r < 0x7f {
+							m.commandBuffer += string(r)
+						}
+					}
 				}
 			}
 			return m, nil
@@ -302,7 +321,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 
 		case ":":
-			// Enter command mode for :N to jump to request ID
+			// Enter command mode for :N to jump to request ID (or :<correlation ID>)
 			if !m.showDetail {
 				m.commandMode = true
 				m.commandBuffer = ""
@@ -496,7 +515,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.showDetail = true
 				m.selected = displayRequests[m.cursor]
 				m.selectedID = m.selected.ID
-				m.activeTab = TabMessages
+				m.activeTab = detailStartTab(m.selected)
 				// Reset message navigation state for new request
 				m.currentMsgIndex = 0
 				m.messagePositions = nil
@@ -523,14 +542,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "tab", "l":
 			if m.showDetail {
-				m.activeTab = (m.activeTab + 1) % 4
+				m.activeTab = (m.activeTab + 1) % Tab(m.tabCount())
 				m.viewport.SetContent(m.renderTabContent())
 				m.viewport.GotoTop()
 			}
 
 		case "shift+tab", "h":
 			if m.showDetail {
-				m.activeTab = (m.activeTab + 3) % 4
+				m.activeTab = (m.activeTab + Tab(m.tabCount()) - 1) % Tab(m.tabCount())
 				m.viewport.SetContent(m.renderTabContent())
 				m.viewport.GotoTop()
 			}
@@ -563,6 +582,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.viewport.GotoTop()
 			}
 
+		case "5":
+			if m.showDetail && m.tabCount() > int(TabEvents) {
+				m.activeTab = TabEvents
+				m.viewport.SetContent(m.renderTabContent())
+				m.viewport.GotoTop()
+			}
+
 		case "c":
 			if m.showDetail {
 				switch m.activeTab {
@@ -573,7 +599,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					m.collapsedMessages[m.currentMsgIndex] = !m.collapsedMessages[m.currentMsgIndex]
 					m.viewport.SetContent(m.renderTabContent())
-				case TabOutput, TabRawInput, TabRawOutput:
+				case TabOutput, TabRawInput, TabRawOutput, TabEvents:
 					m.copyActiveTab()
 				}
 			} else {
@@ -652,6 +678,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.jumpToAdjacentRequest(-1)
 			}
 
+		case "t":
+			if m.showDetail {
+				// Show only the messages this turn added to its thread
+				m.newMessagesOnly = !m.newMessagesOnly
+				m.currentMsgIndex = 0
+				if m.activeTab == TabMessages {
+					m.viewport.SetContent(m.renderTabContent())
+					m.viewport.GotoTop()
+				}
+			} else {
+				// Toggle threaded list, keeping the cursor on the same request
+				var current *LLMRequest
+				if displayRequests := m.getDisplayRequests(); m.cursor < len(displayRequests) {
+					current = displayRequests[m.cursor]
+				}
+				m.threadMode = !m.threadMode
+				if current != nil {
+					for i, req := range m.getDisplayRequests() {
+						if req.ID == current.ID {
+							m.cursor = i
+							break
+						}
+					}
+				}
+			}
+
 		case "e":
 			// Export chat transcript to temp folder and copy path to clipboard
 			if m.showDetail {
@@ -663,7 +715,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
 			if m.showDetail {
 				// Handle tab clicks in detail view
-				for i := 0; i < 4; i++ {
+				for i := 0; i < m.tabCount(); i++ {
 					tabZoneID := fmt.Sprintf("tab-%d", i)
 					if zone.Get(tabZoneID).InBounds(msg) {
 						m.activeTab = Tab(i)
@@ -725,7 +777,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 
 				// Handle audio clicks in Output tab (audio output from speech models)
-				if m.activeTab == TabOutput {
+				// and Events tab (realtime audio)
+				if m.activeTab == TabOutput || m.activeTab == TabEvents {
+					// Generated images
+					for _, img := range m.imageRefs {
+						imgZoneID := fmt.Sprintf("img-%d", img.Index)
+						if zone.Get(imgZoneID).InBounds(msg) {
+							if err := openImage(img); err != nil {
+								m.copyMessage = fmt.Sprintf("✗ Failed to open image: %v", err)
+							} else {
+								m.copyMessage = fmt.Sprintf("✓ Opened Image %d", img.Index)
+							}
+							m.copyMessageTime = time.Now()
+							return m, nil
+						}
+					}
 					for _, audio := range m.audioRefs {
 						audioZoneID := fmt.Sprintf("audio-%d", audio.Index)
 						if zone.Get(audioZoneID).InBounds(msg) {
@@ -754,10 +820,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					{"sort-intok", SortByInputTokens},
 					{"sort-outtok", SortByOutputTokens},
 					{"sort-cost", SortByCost},
+					{"sort-tags", SortByTags},
 				}
 
 				for _, header := range sortHeaders {
 					if zone.Get(header.zoneID).InBounds(msg) {
+						m.threadMode = false // Sorting would split threads apart
 						m.toggleSort(header.field)
 						return m, nil
 					}
@@ -786,7 +854,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.showDetail = true
 						m.selected = displayRequests[m.cursor]
 						m.selectedID = m.selected.ID
-						m.activeTab = TabMessages
+						m.activeTab = detailStartTab(m.selected)
 						m.viewport.SetContent(m.renderTabContent())
 						m.viewport.GotoTop()
 					}
@@ -842,6 +910,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.saveMessage = fmt.Sprintf("✗ Error: %v", msg.err)
 		m.saveMessageTime = time.Now()
 
+	case configReloadedMsg:
+		switch {
+		case msg.err != nil && msg.summary == "":
+			m.reloadMessage = fmt.Sprintf("✗ Config reload failed, keeping previous config: %v", msg.err)
+		case msg.err != nil:
+			m.reloadMessage = fmt.Sprintf("✗ Config reloaded with errors: %s • %v", msg.summary, msg.err)
+		default:
+			m.reloadMessage = fmt.Sprintf("↻ Config reloaded: %s", msg.summary)
+		}
+		m.reloadMessageTime = time.Now()
+		m.listenAddr = msg.listenAddrs
+		m.targetURL = msg.targetURLs
+
 	case tapePlayMsg:
 		// Step-through playback mode (advance event by event)
 		if m.tapeMode && m.tapePlaying && m.tape != nil && !m.tapeRealtime {
@@ -937,6 +1018,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.copyMessage = ""
 		}
 
+		// Reload notices carry a diff, so keep them up longer
+		if m.reloadMessage != "" && time.Since(m.reloadMessageTime) > 8*time.Second {
+			m.reloadMessage = ""
+		}
+
+		// Refresh budget warnings
+		m.budgetBanner, m.budgetHard = formatBudgetBanner(activeBudget.Status())
+
 		cmds = append(cmds, tickCmd())
 	}
 
@@ -34,22 +34,81 @@ type LLMRequest struct {
 	ResponseSize    int
 	IsStreaming     bool
 
+	// Provider-reported generation time (Ollama eval_duration), for tokens/sec
+	GenerationDuration time.Duration
+
 	// Token usage and cost tracking
 	EstimatedInputTokens int     // Estimated from request body length / 4
 	InputTokens          int     // Actual from response usage.prompt_tokens
 	OutputTokens         int     // Actual from response usage.completion_tokens
+	CachedInputTokens    int     // Part of InputTokens served from the provider's prompt cache
 	ProviderID           string  // Detected provider (e.g., "openai", "anthropic")
 	Cost                 float64 // Calculated cost in USD
 
 	// Cache tracking
-	CachedResponse bool // True if this response came from cache
+	CachedResponse     bool     // True if this response came from cache
+	CacheKey           string   // Key the response is looked up and stored under
+	CacheKeyFields     []string // Request fields hashed into CacheKey
+	CacheIgnoredFields []string // Fields left out of CacheKey by cache_ignore_fields
+
+	// Set when the response's Content-Encoding couldn't be undone; the body
+	// holds the raw bytes, or the prefix that decoded before the error
+	DecodeError string
 
 	// Client disconnect diagnostics (499)
 	CancelReason string // Human-readable reason for client disconnect
 
+	// Set by whoever records the request as finished: its handler, or
+	// shutdown for requests cut off by it. Guarded by requestsMu.
+	finalized bool
+
 	// Multi-proxy tracking
 	ProxyName   string // Name of the proxy instance that handled this request
 	ProxyListen string // Listen address of the proxy instance
+
+	// Routing (set when the proxy has [[proxy.route]] rules)
+	RouteName string // Name of the route that matched, empty for the default target
+	TargetURL string // Upstream target the request was sent to
+
+	// Correlation: the proxy's own ID (session + request ID, sent back in a
+	// response header) and the IDs the client sent with the request
+	CorrelationID   string // e.g. sess-0a1b2c3d4e5f-42
+	TraceID         string // trace-id from an incoming traceparent header
+	ClientRequestID string // Incoming X-Request-Id header
+
+	// Labels from the X-LLMProxy-Tag/Run/Conversation request headers
+	Tags         []string
+	Run          string
+	Conversation string
+
+	// Azure OpenAI deployment from the path; Model is replaced by the
+	// response's model once it arrives
+	Deployment string
+
+	// Upstream attempts (set when a retry/failover policy is configured)
+	Attempts []RequestAttempt
+
+	// Fault injection (set when a [[proxy.fault]] rule fired)
+	InjectedFault string
+
+	// Budget enforcement (set when a hard cap rejected the request)
+	BudgetExceeded string
+
+	// WebSocket sessions (realtime APIs): every relayed message in order
+	IsWebSocket bool
+	WSEvents    []WSEvent
+}
+
+// ProxyLabel returns the proxy name, qualified with the route when one matched
+func (r *LLMRequest) ProxyLabel() string {
+	name := r.ProxyName
+	if name == "" {
+		name = "default"
+	}
+	if r.RouteName != "" {
+		return name + "/" + r.RouteName
+	}
+	return name
 }
 
 // OpenAI tool call types
@@ -118,9 +177,9 @@ type AnthropicContentBlock struct {
 	Text      string      `json:"text,omitempty"`
 	ID        string      `json:"id,omitempty"`        // for tool_use
 	Name      string      `json:"name,omitempty"`      // for tool_use
-	Input     interface{} `json:"input,omitempty"`      // for tool_use
-	Thinking  string      `json:"thinking,omitempty"`   // for thinking
-	Signature string      `json:"signature,omitempty"`  // for thinking
+	Input     interface{} `json:"input,omitempty"`     // for tool_use
+	Thinking  string      `json:"thinking,omitempty"`  // for thinking
+	Signature string      `json:"signature,omitempty"` // for thinking
 }
 
 // Anthropic message types
@@ -161,6 +220,7 @@ const (
 	TabOutput
 	TabRawInput
 	TabRawOutput
+	TabEvents // WebSocket requests only
 )
 
 // SortField represents fields the request list can be sorted by
@@ -177,6 +237,7 @@ const (
 	SortByInputTokens
 	SortByOutputTokens
 	SortByCost
+	SortByTags
 )
 
 // SortDirection represents ascending or descending sort
@@ -189,9 +250,9 @@ const (
 
 // ImageRef represents a reference to an image found in a request/response
 type ImageRef struct {
-	Index   int    // 1-based index for display
-	URL     string // URL or base64 data URL
-	IsBase64 bool  // True if the URL is a base64 data URL
+	Index    int    // 1-based index for display
+	URL      string // URL or base64 data URL
+	IsBase64 bool   // True if the URL is a base64 data URL
 }
 
 // AudioRef represents a reference to audio found in a request/response
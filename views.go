@@ -123,7 +123,7 @@ func (m model) renderSaveDialog() string {
 
 func (m *model) renderCostBreakdownPanel() string {
 	displayRequests := m.getDisplayRequests()
-	breakdown := AnalyzeRequestsCosts(displayRequests)
+	breakdown := AnalyzeRequestsCosts(displayRequests, GroupByModel)
 
 	// Title
 	title := lipgloss.NewStyle().
@@ -243,6 +243,47 @@ func (m *model) renderCostBreakdownPanel() string {
 		}
 	}
 
+	// By client label sections (only when requests carry the headers)
+	labelSections := []struct {
+		groupBy CostGroupBy
+		title   string
+	}{
+		{GroupByRun, "By Run"},
+		{GroupByConversation, "By Conversation"},
+		{GroupByTag, "By Tag"},
+	}
+	widestGroup := 0
+	for _, section := range labelSections {
+		if !anyRequestLabeled(displayRequests, section.groupBy) {
+			continue
+		}
+		groups := AnalyzeRequestsCosts(displayRequests, section.groupBy).Groups
+		groupHeader := strings.ToUpper(string(section.groupBy))
+		maxGroupLen := len(groupHeader)
+		for _, s := range groups {
+			maxGroupLen = max(maxGroupLen, min(len([]rune(s.Group)), 30))
+		}
+		widestGroup = max(widestGroup, maxGroupLen)
+
+		b.WriteString("\n")
+		b.WriteString(sectionStyle.Render(section.title))
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render(fmt.Sprintf("  %-*s %5s %10s %10s %10s",
+			maxGroupLen, groupHeader, "REQS", "IN TOK", "OUT TOK", "COST")))
+		b.WriteString("\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(borderColor).Render(
+			"  " + strings.Repeat("─", maxGroupLen+5+10+10+10+4)))
+		b.WriteString("\n")
+		for _, s := range groups {
+			line := fmt.Sprintf("  %-*s %5d %10s %10s ",
+				maxGroupLen, truncateForColumn(s.Group, maxGroupLen), s.RequestCount,
+				formatWithCommas(s.InputTokens), formatWithCommas(s.OutputTokens))
+			b.WriteString(valueStyle.Render(line))
+			b.WriteString(costStyle.Render(fmt.Sprintf("%10s", formatCost(s.Cost))))
+			b.WriteString("\n")
+		}
+	}
+
 	// Totals
 	b.WriteString("\n")
 	b.WriteString(lipgloss.NewStyle().Foreground(borderColor).Render(
@@ -268,6 +309,7 @@ func (m *model) renderCostBreakdownPanel() string {
 
 	// Calculate dialog width based on content
 	dialogWidth := maxModelLen + 5 + 10 + 10 + 10 + 4 + 8 // columns + padding
+	dialogWidth = max(dialogWidth, widestGroup+5+10+10+10+4+8)
 	if dialogWidth < 60 {
 		dialogWidth = 60
 	}
@@ -336,6 +378,26 @@ func (m *model) renderListView() string {
 		clearHint := lipgloss.NewStyle().Foreground(dimColor).Render(" (esc to clear)")
 		b.WriteString(searchIndicator + clearHint)
 	}
+	// Budget warning shares the search line
+	if !m.searchMode && m.budgetBanner != "" {
+		if m.searchQuery != "" {
+			b.WriteString("  ")
+		}
+		bannerStyle := lipgloss.NewStyle().Foreground(warningColor).Bold(true)
+		if m.budgetHard {
+			bannerStyle = lipgloss.NewStyle().Foreground(errorColor).Bold(true)
+		}
+		b.WriteString(bannerStyle.Render("⚠ " + m.budgetBanner))
+	}
+	// So do the totals of the thread under the cursor
+	if !m.searchMode && m.threadMode {
+		if summary := m.threadSummary(m.getDisplayRequests()); summary != "" {
+			if m.searchQuery != "" || m.budgetBanner != "" {
+				b.WriteString("  ")
+			}
+			b.WriteString(lipgloss.NewStyle().Foreground(accentColor).Render(summary))
+		}
+	}
 	b.WriteString("\n")
 
 	// Column headers with sort indicators (clickable)
@@ -404,6 +466,14 @@ func (m *model) renderListView() string {
 		b.WriteString(msgStyle.Render(m.copyMessage))
 		return b.String()
 	}
+	if m.reloadMessage != "" {
+		msgStyle := lipgloss.NewStyle().Foreground(successColor)
+		if strings.HasPrefix(m.reloadMessage, "✗") {
+			msgStyle = lipgloss.NewStyle().Foreground(errorColor)
+		}
+		b.WriteString(msgStyle.Render(truncateForColumn(m.reloadMessage, m.width)))
+		return b.String()
+	}
 
 	// Build help text based on mode
 	var help string
@@ -450,7 +520,7 @@ func (m *model) renderListView() string {
 			mouseIndicator = lipgloss.NewStyle().Foreground(warningColor).Render(" [SELECT]")
 		}
 
-		help = helpStyle.Render("space play • / search • c cost • [/] step • -/+ speed • f follow • q quit") + playIndicator + followIndicator + mouseIndicator + " " + progressBar + timeDisplay
+		help = helpStyle.Render("space play • / search • c cost • t threads • [/] step • -/+ speed • f follow • q quit") + playIndicator + followIndicator + mouseIndicator + " " + progressBar + timeDisplay
 	} else {
 		// Live mode help
 		followIndicator := ""
@@ -466,7 +536,7 @@ func (m *model) renderListView() string {
 		if !m.mouseEnabled {
 			mouseIndicator = lipgloss.NewStyle().Foreground(warningColor).Render(" [SELECT]")
 		}
-		help = helpStyle.Render("↑/↓ nav • / search • enter select • c cost • g/G top/bot • f follow • s save • Y copy-session • q quit") + followIndicator + numIndicator + mouseIndicator
+		help = helpStyle.Render("↑/↓ nav • / search • enter select • c cost • t threads • g/G top/bot • f follow • s save • Y copy-session • q quit") + followIndicator + numIndicator + mouseIndicator
 	}
 
 	// Calculate total cost across display requests
@@ -491,6 +561,11 @@ func (m *model) renderListView() string {
 			statusText = fmt.Sprintf("%d requests", len(m.requests))
 		}
 	}
+	if !m.tapeMode {
+		if usage := formatCacheUsage(GetCache().Usage()); usage != "" {
+			statusText += " • " + usage
+		}
+	}
 	count := statusBarStyle.Render(statusText)
 	footer := lipgloss.JoinHorizontal(lipgloss.Bottom, help, strings.Repeat(" ", max(0, m.width-lipgloss.Width(help)-lipgloss.Width(count)-2)), count)
 	b.WriteString(footer)
@@ -503,10 +578,21 @@ func (m model) isMultiProxy() bool {
 	return m.listenAddr == "multi"
 }
 
+// hasClientTags reports whether any request carries X-LLMProxy-Tag/Run/Conversation labels
+func (m model) hasClientTags() bool {
+	for _, req := range m.requests {
+		if len(req.Tags) > 0 || req.Run != "" || req.Conversation != "" {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	listColID       = 6
 	listColStatus   = 12
 	listColProxy    = 12
+	listColTags     = 18
 	listColModel    = 24
 	listColCode     = 6
 	listColSize     = 10
@@ -520,6 +606,7 @@ type listColumns struct {
 	id       int
 	status   int
 	proxy    int
+	tags     int // 0 unless some request has client labels
 	model    int
 	code     int
 	size     int
@@ -550,6 +637,10 @@ func (m model) listViewColumns() listColumns {
 	if m.isMultiProxy() {
 		baseRowWidth += cols.proxy + 1
 	}
+	if m.hasClientTags() {
+		cols.tags = listColTags
+		baseRowWidth += cols.tags + 1
+	}
 	cols.preview = m.listPreviewColumnWidth(baseRowWidth)
 	return cols
 }
@@ -563,7 +654,7 @@ func (m *model) renderSortableHeaders() string {
 		indicator := " "
 		style := lipgloss.NewStyle().Foreground(dimColor).Bold(true)
 
-		if m.sortField == field {
+		if m.sortField == field && !m.threadMode {
 			style = lipgloss.NewStyle().Foreground(accentColor).Bold(true)
 			if m.sortDirection == SortAsc {
 				indicator = "▲"
@@ -596,6 +687,11 @@ func (m *model) renderSortableHeaders() string {
 		headers = append(headers, renderHeader("PROXY", cols.proxy, SortByNone, "sort-proxy"))
 	}
 
+	// Add TAGS column once any request carries client labels
+	if cols.tags > 0 {
+		headers = append(headers, renderHeader("TAGS", cols.tags, SortByTags, "sort-tags"))
+	}
+
 	// Add request preview snippet column if there is enough width.
 	if cols.preview > 0 {
 		previewHeader := lipgloss.NewStyle().
@@ -676,7 +772,10 @@ func (m *model) renderRequestRow(req *LLMRequest, selected bool) string {
 			statusStyle = completeStyle
 		}
 	case StatusError:
-		if req.StatusCode == 499 {
+		if req.CancelReason == shutdownCancelReason {
+			statusText = "✗  SHUTDOWN"
+			statusStyle = lipgloss.NewStyle().Foreground(warningColor)
+		} else if req.StatusCode == 499 {
 			statusText = "✗  CANCELED"
 			statusStyle = lipgloss.NewStyle().Foreground(warningColor)
 		} else {
@@ -684,21 +783,45 @@ func (m *model) renderRequestRow(req *LLMRequest, selected bool) string {
 			statusStyle = errorStyle
 		}
 	}
+	if req.BudgetExceeded != "" {
+		statusText = "✗  BUDGET"
+		statusStyle = errorStyle
+	}
+
+	// Injected faults are flagged so they aren't mistaken for real outages
+	if req.InjectedFault != "" && req.Status != StatusPending {
+		statusText = "⚠  FAULT"
+		statusStyle = lipgloss.NewStyle().Foreground(warningColor).Bold(true)
+	}
 	statusStr := statusStyle.Render(fmt.Sprintf("%-*s", cols.status, statusText))
 
 	// Proxy column (only in multi-proxy mode)
 	var proxyStr string
 	if m.isMultiProxy() {
-		proxyName := req.ProxyName
-		if proxyName == "" {
+		proxyName := req.ProxyLabel()
+		if req.ProxyName == "" && req.RouteName == "" {
 			proxyName = "-"
 		}
 		proxyName = truncateForColumn(proxyName, cols.proxy)
 		proxyStr = lipgloss.NewStyle().Foreground(accentColor).Render(fmt.Sprintf("%-*s", cols.proxy, proxyName))
 	}
 
-	// Model column
-	modelName := truncateForColumn(req.Model, cols.model)
+	// Tags column (only when some request has client labels)
+	var tagsStr string
+	if cols.tags > 0 {
+		label := req.TagLabel()
+		if label == "" {
+			label = "-"
+		}
+		tagsStr = lipgloss.NewStyle().Foreground(accentColor).Render(fmt.Sprintf("%-*s", cols.tags, truncateForColumn(label, cols.tags)))
+	}
+
+	// Model column, indented under the previous turn in thread mode
+	modelLabel := req.Model
+	if m.threadMode {
+		modelLabel = m.threadRowPrefix(req) + modelLabel
+	}
+	modelName := truncateForColumn(modelLabel, cols.model)
 	modelStr := modelBadgeStyle.Render(fmt.Sprintf("%-*s", cols.model, modelName))
 
 	// Preview column (system prompt or first message snippet)
@@ -806,6 +929,9 @@ func (m *model) renderRequestRow(req *LLMRequest, selected bool) string {
 		)
 	}
 
+	if cols.tags > 0 {
+		row += " " + tagsStr
+	}
 	if cols.preview > 0 {
 		row += " " + previewStr
 	}
@@ -835,11 +961,26 @@ func (m model) renderDetailView() string {
 	// Header with request info
 	header := titleStyle.Render(fmt.Sprintf("Request #%d", m.selected.ID))
 	modelInfo := modelBadgeStyle.Render(m.selected.Model)
+	if m.selected.Deployment != "" && m.selected.Deployment != m.selected.Model {
+		modelInfo += lipgloss.NewStyle().Foreground(dimColor).Render(" via " + m.selected.Deployment)
+	}
 
 	// Proxy indicator (for multi-proxy mode)
 	var proxyInfo string
-	if m.selected.ProxyName != "" && m.selected.ProxyName != "default" {
-		proxyInfo = lipgloss.NewStyle().Foreground(accentColor).Render("@" + m.selected.ProxyName)
+	if (m.selected.ProxyName != "" && m.selected.ProxyName != "default") || m.selected.RouteName != "" {
+		proxyInfo = lipgloss.NewStyle().Foreground(accentColor).Render("@" + m.selected.ProxyLabel())
+	}
+
+	// Client labels (X-LLMProxy-Tag/Run/Conversation)
+	var tagInfo string
+	if label := m.selected.TagLabel(); label != "" {
+		tagInfo = lipgloss.NewStyle().Foreground(dimColor).Render(label)
+	}
+
+	// Agent-loop thread position
+	var threadInfo string
+	if label := m.threadLabel(); label != "" {
+		threadInfo = lipgloss.NewStyle().Foreground(accentColor).Render(label)
 	}
 
 	// Cache indicator
@@ -865,6 +1006,9 @@ func (m model) renderDetailView() string {
 		} else {
 			timingInfo = lipgloss.NewStyle().Foreground(dimColor).Render(formatDuration(m.selected.Duration))
 		}
+		if tps := tokensPerSecond(m.selected); tps > 0 {
+			timingInfo += lipgloss.NewStyle().Foreground(dimColor).Render(fmt.Sprintf(" | %.1f tok/s", tps))
+		}
 	}
 
 	// Build header line with all components
@@ -872,6 +1016,12 @@ func (m model) renderDetailView() string {
 	if proxyInfo != "" {
 		headerParts = append(headerParts, proxyInfo)
 	}
+	if tagInfo != "" {
+		headerParts = append(headerParts, "  ", tagInfo)
+	}
+	if threadInfo != "" {
+		headerParts = append(headerParts, "  ", threadInfo)
+	}
 	if cacheInfo != "" {
 		headerParts = append(headerParts, "  ", cacheInfo)
 	}
@@ -886,17 +1036,50 @@ func (m model) renderDetailView() string {
 
 	// Show cancel reason banner for 499 errors
 	if m.selected.StatusCode == 499 && m.selected.CancelReason != "" {
+		label := "⚠ 499 Client Closed: "
+		if m.selected.CancelReason == shutdownCancelReason {
+			label = "⚠ "
+		}
 		cancelBanner := lipgloss.NewStyle().
 			Foreground(warningColor).
 			Italic(true).
-			Render("⚠ 499 Client Closed: " + m.selected.CancelReason)
+			Render(label + m.selected.CancelReason)
 		b.WriteString(cancelBanner)
 		b.WriteString("\n")
 	}
+
+	// Show budget rejection banner
+	if m.selected.BudgetExceeded != "" {
+		budgetBanner := lipgloss.NewStyle().
+			Foreground(errorColor).
+			Bold(true).
+			Render("✗ Rejected by budget: " + m.selected.BudgetExceeded)
+		b.WriteString(budgetBanner)
+		b.WriteString("\n")
+	}
+
+	// Show injected fault banner
+	if m.selected.InjectedFault != "" {
+		faultBanner := lipgloss.NewStyle().
+			Foreground(warningColor).
+			Bold(true).
+			Render("⚠ Injected fault: " + m.selected.InjectedFault)
+		b.WriteString(faultBanner)
+		b.WriteString("\n")
+	}
+
+	// Show retry/failover attempts
+	if len(m.selected.Attempts) > 1 {
+		attemptsBanner := lipgloss.NewStyle().
+			Foreground(warningColor).
+			Render(fmt.Sprintf("↻ %d attempts: %s", len(m.selected.Attempts), formatAttempts(m.selected.Attempts)))
+		b.WriteString(attemptsBanner)
+		b.WriteString("\n")
+	}
 	b.WriteString("\n")
 
 	// Tabs
-	tabs := []string{"Messages", "Output", "Raw Input", "Raw Output"}
+	tabs := []string{"Messages", "Output", "Raw Input", "Raw Output", "Events"}[:m.tabCount()]
 	var tabRow []string
 	for i, tab := range tabs {
 		tabZoneID := fmt.Sprintf("tab-%d", i)
@@ -917,8 +1100,10 @@ func (m model) renderDetailView() string {
 
 	// Footer - show context-sensitive help
 	var help string
-	if m.activeTab == TabMessages {
-		help = helpStyle.Render("1-4/tab • J/K req • n/N msg • c/C collapse • click [Image] • e export • g/G top/end • ↑/↓ scroll • esc back")
+	if m.activeTab == TabEvents {
+		help = helpStyle.Render(fmt.Sprintf("1-%d/tab • J/K req • c copy • click [Audio] • e export • g/G top/end • ↑/↓ scroll • esc back", m.tabCount()))
+	} else if m.activeTab == TabMessages {
+		help = helpStyle.Render("1-4/tab • J/K req • n/N msg • c/C collapse • t new msgs • click [Image] • e export • g/G top/end • ↑/↓ scroll • esc back")
 	} else if m.activeTab == TabOutput {
 		help = helpStyle.Render("1-4/tab • J/K req • n/N msg • c copy • y copy both • e export • g/G top/end • ↑/↓ scroll • esc back")
 	} else {
@@ -958,6 +1143,11 @@ func (m *model) renderTabContent() string {
 		return ""
 	}
 
+	// The Events tab only exists for WebSocket requests
+	if m.activeTab == TabEvents && !m.selected.IsWebSocket {
+		m.activeTab = TabMessages
+	}
+
 	switch m.activeTab {
 	case TabMessages:
 		return m.renderMessagesTab()
@@ -967,10 +1157,28 @@ func (m *model) renderTabContent() string {
 		return m.renderRawRequest()
 	case TabRawOutput:
 		return m.renderRawResponse()
+	case TabEvents:
+		return m.renderEventsTab()
 	}
 	return ""
 }
 
+// tabCount returns the number of detail tabs for the selected request
+func (m *model) tabCount() int {
+	if m.selected != nil && m.selected.IsWebSocket {
+		return int(TabEvents) + 1
+	}
+	return int(TabEvents)
+}
+
+// detailStartTab returns the tab a request's detail view opens on
+func detailStartTab(req *LLMRequest) Tab {
+	if req != nil && req.IsWebSocket {
+		return TabEvents
+	}
+	return TabMessages
+}
+
 func formatMessagesInputTokenCount(req *LLMRequest) string {
 	if req == nil {
 		return "-"
@@ -989,6 +1197,10 @@ func (m *model) renderMessagesTab() string {
 		return contentStyle.Render("No request body")
 	}
 
+	if isMediaEndpoint(m.selected.Path) {
+		return m.renderMediaRequestTab()
+	}
+
 	if isAnthropicEndpoint(m.selected.Path) {
 		return m.renderAnthropicMessagesTab()
 	}
@@ -997,8 +1209,8 @@ func (m *model) renderMessagesTab() string {
 		return m.renderGeminiMessagesTab()
 	}
 
-	var req OpenAIRequest
-	if err := json.Unmarshal(m.selected.RequestBody, &req); err != nil {
+	req, err := decodeOpenAIRequest(m.selected.Path, m.selected.RequestBody)
+	if err != nil {
 		return errorStyle.Render(fmt.Sprintf("Failed to parse request: %v", err))
 	}
 
@@ -1050,10 +1262,20 @@ func (m *model) renderMessagesTab() string {
 	// Reset message positions
 	m.messagePositions = make([]int, len(req.Messages))
 
+	// Messages the previous turn of the thread already sent
+	hidden, parent := m.hiddenThreadMessages()
+	if hidden > 0 {
+		b.WriteString(renderHiddenMessagesNote(hidden, parent))
+		lineCount += 2
+	}
+
 	// Messages
 	for i, msg := range req.Messages {
 		// Track line position of this message
 		m.messagePositions[i] = lineCount
+		if i < hidden {
+			continue
+		}
 
 		roleColor := dimColor
 		roleIcon := "💬"
@@ -1302,6 +1524,10 @@ func (m *model) renderOutputTab() string {
 		return contentStyle.Render("No response body")
 	}
 
+	if isMediaEndpoint(m.selected.Path) {
+		return m.renderMediaOutputTab()
+	}
+
 	if isAnthropicEndpoint(m.selected.Path) {
 		return m.renderAnthropicOutputTab()
 	}
@@ -1311,20 +1537,19 @@ func (m *model) renderOutputTab() string {
 	}
 
 	var resp OpenAIResponse
-	if err := json.Unmarshal(m.selected.ResponseBody, &resp); err != nil {
+	if isMappedChatEndpoint(m.selected.Path) {
+		// Responses API, Ollama and Converse output is shown as a single choice
+		assembled := decodeOpenAIResponse(m.selected.Path, m.selected.ResponseBody)
+		if assembled == nil {
+			return renderJSONBody(m.selected.ResponseBody, "Response")
+		}
+		fillMissingUsage(assembled, m.selected)
+		resp = *assembled
+	} else if err := json.Unmarshal(m.selected.ResponseBody, &resp); err != nil {
 		// Try reassembling SSE streaming chunks into a structured response
 		if isSSEData(m.selected.ResponseBody) {
 			if assembled := reassembleSSEResponse(m.selected.ResponseBody); assembled != nil {
-				// Use extracted token counts from the request if SSE usage is missing
-				if assembled.Usage.PromptTokens == 0 && m.selected.InputTokens > 0 {
-					assembled.Usage.PromptTokens = m.selected.InputTokens
-				}
-				if assembled.Usage.CompletionTokens == 0 && m.selected.OutputTokens > 0 {
-					assembled.Usage.CompletionTokens = m.selected.OutputTokens
-				}
-				if assembled.Usage.TotalTokens == 0 {
-					assembled.Usage.TotalTokens = assembled.Usage.PromptTokens + assembled.Usage.CompletionTokens
-				}
+				fillMissingUsage(assembled, m.selected)
 				resp = *assembled
 			} else {
 				return renderJSONBody(m.selected.ResponseBody, "Response")
@@ -1390,6 +1615,16 @@ func (m *model) renderOutputTab() string {
 	if m.selected.Duration > 0 {
 		meta += fmt.Sprintf("\n%s %s", labelStyle.Render("Total Latency:"), formatDuration(m.selected.Duration))
 	}
+	if isOllamaEndpoint(m.selected.Path) {
+		if ollamaResp := parseOllamaResponse(m.selected.ResponseBody); ollamaResp != nil {
+			if timing := ollamaTimingSummary(ollamaResp); timing != "" {
+				meta += fmt.Sprintf("\n%s %s", labelStyle.Render("Ollama Timing:"), timing)
+			}
+		}
+	}
+	if tps := tokensPerSecond(m.selected); tps > 0 {
+		meta += fmt.Sprintf("\n%s %.1f tok/s", labelStyle.Render("Speed:"), tps)
+	}
 	if m.selected.Cost > 0 {
 		meta += fmt.Sprintf("\n%s %s", labelStyle.Render("Cost:"), formatCost(m.selected.Cost))
 	}
@@ -1523,6 +1758,20 @@ func (m *model) renderOutputTab() string {
 	return b.String()
 }
 
+// fillMissingUsage uses the token counts extracted for the request when a
+// reassembled stream has no usage of its own
+func fillMissingUsage(resp *OpenAIResponse, req *LLMRequest) {
+	if resp.Usage.PromptTokens == 0 && req.InputTokens > 0 {
+		resp.Usage.PromptTokens = req.InputTokens
+	}
+	if resp.Usage.CompletionTokens == 0 && req.OutputTokens > 0 {
+		resp.Usage.CompletionTokens = req.OutputTokens
+	}
+	if resp.Usage.TotalTokens == 0 {
+		resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	}
+}
+
 func (m model) renderRawRequest() string {
 	var b strings.Builder
 
@@ -1570,6 +1819,23 @@ func (m model) renderRawRequest() string {
 		}
 	}
 
+	// Cache key, and what went into it, to explain hits and misses
+	if m.selected.CacheKey != "" {
+		keyLabel := lipgloss.NewStyle().Foreground(dimColor)
+		b.WriteString("\n")
+		cacheKey := m.selected.CacheKey
+		if m.selected.CachedResponse {
+			cacheKey += lipgloss.NewStyle().Foreground(accentColor).Bold(true).Render("  (hit)")
+		}
+		b.WriteString(keyLabel.Render("Cache key: ") + cacheKey + "\n")
+		if len(m.selected.CacheKeyFields) > 0 {
+			b.WriteString(keyLabel.Render("Keyed on:  ") + strings.Join(m.selected.CacheKeyFields, ", ") + "\n")
+		}
+		if len(m.selected.CacheIgnoredFields) > 0 {
+			b.WriteString(keyLabel.Render("Ignored:   ") + strings.Join(m.selected.CacheIgnoredFields, ", ") + "\n")
+		}
+	}
+
 	b.WriteString("\n")
 	b.WriteString(lipgloss.NewStyle().Foreground(borderColor).Render(strings.Repeat("─", 40)))
 	b.WriteString("\n\n")
@@ -1587,7 +1853,7 @@ func (m model) renderRawRequest() string {
 	contentWidth := m.width - 8
 
 	// Replace long base64 strings with truncated placeholders to prevent lag
-	bodyToRender := truncateLongBase64Strings(m.selected.RequestBody)
+	bodyToRender := truncateLongBase64Strings(displayBody(m.selected.RequestHeaders, m.selected.RequestBody))
 
 	var prettyJSON bytes.Buffer
 	if err := json.Indent(&prettyJSON, bodyToRender, "", "  "); err != nil {
@@ -1662,11 +1928,17 @@ func (m model) renderRawResponse() string {
 	b.WriteString(labelStyle.Render(fmt.Sprintf("═══ Body (%s) ═══", formatBytes(len(m.selected.ResponseBody)))))
 	b.WriteString("\n\n")
 
+	if m.selected.DecodeError != "" {
+		b.WriteString(lipgloss.NewStyle().Foreground(warningColor).Bold(true).Render(
+			fmt.Sprintf("⚠ Content-Encoding %q could not be decoded (%s); the body below may be truncated or still encoded", http.Header(m.selected.ResponseHeaders).Get("Content-Encoding"), m.selected.DecodeError)))
+		b.WriteString("\n\n")
+	}
+
 	// Content width accounting for viewport padding
 	contentWidth := m.width - 8
 
 	// Replace long base64 strings with truncated placeholders to prevent lag
-	bodyToRender := truncateLongBase64Strings(m.selected.ResponseBody)
+	bodyToRender := truncateLongBase64Strings(displayBody(m.selected.ResponseHeaders, m.selected.ResponseBody))
 
 	var prettyJSON bytes.Buffer
 	if err := json.Indent(&prettyJSON, bodyToRender, "", "  "); err != nil {
@@ -1911,8 +2183,21 @@ func (m *model) renderAnthropicMessagesTab() string {
 
 	m.messagePositions = make([]int, len(msgs))
 
+	// Messages the previous turn of the thread already sent, system included
+	hidden, parent := m.hiddenThreadMessages()
+	if hidden > 0 {
+		if req.System != nil {
+			hidden++
+		}
+		b.WriteString(renderHiddenMessagesNote(hidden, parent))
+		lineCount += 2
+	}
+
 	for i, msg := range msgs {
 		m.messagePositions[i] = lineCount
+		if i < hidden {
+			continue
+		}
 
 		roleColor := dimColor
 		roleIcon := "💬"
@@ -2027,8 +2312,8 @@ func (m *model) renderGeminiMessagesTab() string {
 				Text string `json:"text"`
 			} `json:"parts"`
 		} `json:"contents"`
-		Model             string  `json:"model"`
-		GenerationConfig  struct {
+		Model            string `json:"model"`
+		GenerationConfig struct {
 			Temperature float64 `json:"temperature"`
 			MaxTokens   int     `json:"maxOutputTokens"`
 		} `json:"generationConfig"`
@@ -2042,6 +2327,10 @@ func (m *model) renderGeminiMessagesTab() string {
 	if err := json.Unmarshal(m.selected.RequestBody, &req); err != nil {
 		return errorStyle.Render(fmt.Sprintf("Failed to parse request: %v", err))
 	}
+	if req.Model == "" {
+		// Native Gemini requests carry the model in the URL path
+		req.Model = m.selected.Model
+	}
 
 	var b strings.Builder
 	contentWidth := m.width - 10
@@ -2066,6 +2355,13 @@ func (m *model) renderGeminiMessagesTab() string {
 	b.WriteString(metaBox.Render(meta))
 	b.WriteString("\n\n")
 
+	// Messages the previous turn of the thread already sent
+	hidden, parent := m.hiddenThreadMessages()
+	if hidden > 0 {
+		req.SystemInstruction = nil
+		req.Contents = req.Contents[min(hidden, len(req.Contents)):]
+	}
+
 	// System instruction
 	if req.SystemInstruction != nil {
 		for _, p := range req.SystemInstruction.Parts {
@@ -2085,6 +2381,9 @@ func (m *model) renderGeminiMessagesTab() string {
 
 	b.WriteString(labelStyle.Render("═══ Messages ═══"))
 	b.WriteString("\n\n")
+	if hidden > 0 {
+		b.WriteString(renderHiddenMessagesNote(hidden, parent))
+	}
 
 	// Render contents (messages)
 	for _, msg := range req.Contents {
@@ -2135,7 +2434,7 @@ func (m *model) renderGeminiMessagesTab() string {
 		}
 
 		effectiveBody := m.selected.ResponseBody
-		if isSSEData(m.selected.ResponseBody) {
+		if isGeminiStreamData(m.selected.ResponseBody) {
 			if decoded := reassembleGeminiSSE(m.selected.ResponseBody); decoded != nil {
 				effectiveBody = decoded
 			}
@@ -2185,23 +2484,28 @@ func (m *model) renderGeminiOutputTab() string {
 					Text             string `json:"text"`
 					Thought          bool   `json:"thought"`
 					ThoughtSignature string `json:"thoughtSignature"`
+					FunctionCall     *struct {
+						Name string      `json:"name"`
+						Args interface{} `json:"args"`
+					} `json:"functionCall"`
 				} `json:"parts"`
 				Role string `json:"role"`
 			} `json:"content"`
 			FinishReason string `json:"finishReason"`
 		} `json:"candidates"`
 		UsageMetadata struct {
-			PromptTokenCount     int `json:"promptTokenCount"`
-			CandidatesTokenCount int `json:"candidatesTokenCount"`
-			TotalTokenCount      int `json:"totalTokenCount"`
-			ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+			PromptTokenCount        int `json:"promptTokenCount"`
+			CandidatesTokenCount    int `json:"candidatesTokenCount"`
+			TotalTokenCount         int `json:"totalTokenCount"`
+			ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+			CachedContentTokenCount int `json:"cachedContentTokenCount"`
 		} `json:"usageMetadata"`
 		ModelVersion string `json:"modelVersion"`
 	}
 
 	effectiveBody := m.selected.ResponseBody
-	// Handle Gemini SSE streaming
-	if isSSEData(m.selected.ResponseBody) {
+	// Handle Gemini streaming (SSE or JSON array)
+	if isGeminiStreamData(m.selected.ResponseBody) {
 		if decoded := reassembleGeminiSSE(m.selected.ResponseBody); decoded != nil {
 			effectiveBody = decoded
 		}
@@ -2232,6 +2536,9 @@ func (m *model) renderGeminiOutputTab() string {
 	if resp.UsageMetadata.ThoughtsTokenCount > 0 {
 		meta += fmt.Sprintf("\nThoughts Tokens: %d", resp.UsageMetadata.ThoughtsTokenCount)
 	}
+	if resp.UsageMetadata.CachedContentTokenCount > 0 {
+		meta += fmt.Sprintf("\nCached Tokens: %d", resp.UsageMetadata.CachedContentTokenCount)
+	}
 	if m.selected.Cost > 0 {
 		meta += fmt.Sprintf("\nCost: $%.4f", m.selected.Cost)
 	}
@@ -2262,6 +2569,11 @@ func (m *model) renderGeminiOutputTab() string {
 					MaxWidth(contentWidth)
 				b.WriteString(textBox.Render(wrapText(part.Text, textWidth)))
 				b.WriteString("\n")
+			} else if part.FunctionCall != nil {
+				argsJSON, _ := json.Marshal(part.FunctionCall.Args)
+				call := ToolCall{Type: "function", Function: ToolCallFunction{Name: part.FunctionCall.Name, Arguments: string(argsJSON)}}
+				b.WriteString(m.renderToolCalls([]ToolCall{call}, contentWidth))
+				b.WriteString("\n")
 			}
 		}
 	}
@@ -2269,70 +2581,6 @@ func (m *model) renderGeminiOutputTab() string {
 	return b.String()
 }
 
-// reassembleGeminiSSE reassembles Gemini SSE streaming chunks into a single JSON response.
-func reassembleGeminiSSE(data []byte) []byte {
-	lines := strings.Split(string(data), "\n")
-
-	var lastChunk json.RawMessage
-	var allText strings.Builder
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "data: ") && !strings.HasPrefix(line, "data:") {
-			continue
-		}
-		jsonData := strings.TrimPrefix(line, "data: ")
-		jsonData = strings.TrimPrefix(jsonData, "data:")
-		jsonData = strings.TrimSpace(jsonData)
-		if jsonData == "" || jsonData == "[DONE]" {
-			continue
-		}
-
-		var chunk struct {
-			Candidates []struct {
-				Content struct {
-					Parts []struct {
-						Text string `json:"text"`
-					} `json:"parts"`
-				} `json:"content"`
-			} `json:"candidates"`
-		}
-		if json.Unmarshal([]byte(jsonData), &chunk) == nil {
-			for _, c := range chunk.Candidates {
-				for _, p := range c.Content.Parts {
-					allText.WriteString(p.Text)
-				}
-			}
-		}
-		lastChunk = json.RawMessage(jsonData)
-	}
-
-	if lastChunk == nil {
-		return nil
-	}
-
-	// Use the last chunk (which has final usageMetadata) but replace the text with accumulated text
-	var final map[string]interface{}
-	if json.Unmarshal(lastChunk, &final) != nil {
-		return nil
-	}
-
-	// Replace candidate text with accumulated text
-	if candidates, ok := final["candidates"].([]interface{}); ok && len(candidates) > 0 {
-		if candidate, ok := candidates[0].(map[string]interface{}); ok {
-			if content, ok := candidate["content"].(map[string]interface{}); ok {
-				content["parts"] = []map[string]interface{}{{"text": allText.String()}}
-			}
-		}
-	}
-
-	result, err := json.Marshal(final)
-	if err != nil {
-		return nil
-	}
-	return result
-}
-
 func (m *model) renderAnthropicOutputTab() string {
 	if len(m.selected.ResponseBody) == 0 {
 		if m.selected.Status == StatusPending {
@@ -2647,3 +2895,299 @@ func truncateID(id string) string {
 	}
 	return id[:8] + "..." + id[len(id)-8:]
 }
+
+// isWSStreamingEvent reports whether a realtime event is one of the
+// high-volume streaming kinds that the timeline folds into a single line
+func isWSStreamingEvent(event WSEvent) bool {
+	return strings.HasSuffix(event.Type, ".delta") || event.Type == "input_audio_buffer.append" || event.Binary
+}
+
+func (m *model) renderEventsTab() string {
+	req := m.selected
+	if len(req.WSEvents) == 0 {
+		if req.Status == StatusPending {
+			return contentStyle.Render("Waiting for WebSocket messages...")
+		}
+		return contentStyle.Render("No WebSocket messages")
+	}
+
+	contentWidth := m.width - 10
+	audioStyle := lipgloss.NewStyle().Foreground(accentColor).Bold(true).Underline(true)
+	dimStyle := lipgloss.NewStyle().Foreground(dimColor)
+	clientStyle := lipgloss.NewStyle().Foreground(primaryColor).Bold(true)
+	serverStyle := lipgloss.NewStyle().Foreground(successColor).Bold(true)
+
+	refs, audioStarts := realtimeAudio(req.WSEvents)
+	m.audioRefs = refs
+
+	audioLink := func(index int) string {
+		ref := refs[index-1]
+		return zone.Mark(fmt.Sprintf("audio-%d", index), audioStyle.Render(fmt.Sprintf("[▶ Audio %d - %s]", index, ref.Format)))
+	}
+
+	// Summary
+	var clientCount, serverCount int
+	for _, event := range req.WSEvents {
+		if event.Direction == WSFromClient {
+			clientCount++
+		} else {
+			serverCount++
+		}
+	}
+	last := req.WSEvents[len(req.WSEvents)-1].Time
+	summary := []string{
+		fmt.Sprintf("%s %d messages (%d client, %d server) over %s",
+			labelStyle.Render("WebSocket:"), len(req.WSEvents), clientCount, serverCount, formatDuration(last.Sub(req.StartTime))),
+	}
+	if req.InputTokens > 0 || req.OutputTokens > 0 {
+		summary = append(summary, fmt.Sprintf("%s in=%s out=%s  %s %s",
+			labelStyle.Render("Tokens:"), formatWithCommas(req.InputTokens), formatWithCommas(req.OutputTokens),
+			labelStyle.Render("Cost:"), formatCost(req.Cost)))
+	}
+	for _, ref := range refs {
+		line := audioLink(ref.Index)
+		if ref.IsOutput {
+			line += dimStyle.Render(" output")
+		} else {
+			line += dimStyle.Render(" input")
+		}
+		if ref.Transcript != "" {
+			line += " " + truncateLine(sanitizeForTerminal(ref.Transcript), contentWidth-30)
+		}
+		summary = append(summary, line)
+	}
+	summaryBox := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(accentColor).
+		Padding(0, 2).
+		Width(contentWidth)
+
+	var b strings.Builder
+	b.WriteString(summaryBox.Render(strings.Join(summary, "\n")))
+	b.WriteString("\n\n")
+
+	for i := 0; i < len(req.WSEvents); {
+		event := req.WSEvents[i]
+		arrow, who := "▲", clientStyle.Render("client")
+		if event.Direction == WSFromServer {
+			arrow, who = "▼", serverStyle.Render("server")
+		}
+		offset := dimStyle.Render(fmt.Sprintf("+%7.3fs", event.Time.Sub(req.StartTime).Seconds()))
+		prefix := fmt.Sprintf("%s %s %s ", offset, arrow, who)
+
+		// Fold runs of streaming events from the same side into one line
+		if isWSStreamingEvent(event) {
+			j := i
+			counts := make(map[string]int)
+			var order []string
+			var links []string
+			for ; j < len(req.WSEvents) && req.WSEvents[j].Direction == event.Direction && isWSStreamingEvent(req.WSEvents[j]); j++ {
+				t := req.WSEvents[j].Type
+				if counts[t] == 0 {
+					order = append(order, t)
+				}
+				counts[t]++
+				if index, ok := audioStarts[j]; ok {
+					links = append(links, audioLink(index))
+				}
+			}
+			parts := make([]string, len(order))
+			for k, t := range order {
+				parts[k] = fmt.Sprintf("%s ×%d", t, counts[t])
+			}
+			line := prefix + strings.Join(parts, ", ")
+			if len(links) > 0 {
+				line += " " + strings.Join(links, " ")
+			}
+			b.WriteString(line + "\n")
+			i = j
+			continue
+		}
+
+		var payload string
+		switch {
+		case event.Type == "close":
+			payload = string(event.Data)
+		default:
+			payload = string(truncateLongBase64Strings(event.Data))
+		}
+		typeLabel := event.Type
+		if typeLabel == "" {
+			typeLabel = "text"
+		}
+		if index, ok := audioStarts[i]; ok {
+			typeLabel += " " + audioLink(index)
+		}
+		room := contentWidth - lipgloss.Width(prefix) - lipgloss.Width(typeLabel) - 2
+		b.WriteString(prefix + typeLabel + "  " + dimStyle.Render(truncateLine(sanitizeForTerminal(payload), room)) + "\n")
+		i++
+	}
+
+	return b.String()
+}
+
+// truncateLine flattens s to one line and cuts it to width characters
+func truncateLine(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if width < 4 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// renderMediaLinks renders clickable image and audio placeholders
+func renderMediaLinks(images []ImageRef, audio []AudioRef) []string {
+	linkStyle := lipgloss.NewStyle().Foreground(accentColor).Bold(true).Underline(true)
+	var links []string
+	for _, img := range images {
+		links = append(links, zone.Mark(fmt.Sprintf("img-%d", img.Index), linkStyle.Render(fmt.Sprintf("[Image %d]", img.Index))))
+	}
+	for _, a := range audio {
+		links = append(links, zone.Mark(fmt.Sprintf("audio-%d", a.Index), linkStyle.Render(fmt.Sprintf("[Play Audio %d - %s]", a.Index, a.Format))))
+	}
+	return links
+}
+
+// renderMediaRequestTab shows the fields and uploaded files of an audio or
+// image request
+func (m *model) renderMediaRequestTab() string {
+	parts := parseMediaRequest(m.selected.RequestBody)
+	if parts == nil {
+		return renderJSONBody(m.selected.RequestBody, "Request")
+	}
+	m.messagePositions = nil
+
+	contentWidth := m.width - 10
+	textWidth := contentWidth - 6
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(primaryColor).
+		Padding(0, 2).
+		MarginBottom(1).
+		Width(contentWidth)
+
+	var fields []string
+	for _, p := range parts {
+		if p.IsFile() {
+			continue
+		}
+		value := sanitizeForTerminal(string(p.Data))
+		if p.Name == "prompt" || p.Name == "input" || p.Name == "instructions" {
+			value = "\n" + wrapText(value, textWidth)
+		}
+		fields = append(fields, labelStyle.Render(p.Name+":")+" "+value)
+	}
+
+	var b strings.Builder
+	if len(fields) > 0 {
+		b.WriteString(box.Render(strings.Join(fields, "\n")))
+		b.WriteString("\n")
+	}
+
+	images, audio := formFileRefs(parts, 0, 0)
+	m.imageRefs, m.audioRefs = images, audio
+	links := renderMediaLinks(images, audio)
+	var files []string
+	imageIdx, audioIdx := 0, 0
+	for _, p := range parts {
+		if !p.IsFile() {
+			continue
+		}
+		mimeType := filePartMimeType(p)
+		line := fmt.Sprintf("%s %s (%s, %s)", labelStyle.Render(p.Name+":"), p.Filename, mimeType, formatBytes(len(p.Data)))
+		switch {
+		case strings.HasPrefix(mimeType, "image/"):
+			line += "  " + links[imageIdx]
+			imageIdx++
+		case strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "video/"):
+			line += "  " + links[len(images)+audioIdx]
+			if seconds := wavDuration(p.Data); seconds > 0 {
+				line += " " + mediaDurationLabel(seconds)
+			}
+			audioIdx++
+		}
+		files = append(files, line)
+	}
+	if len(files) > 0 {
+		fileBox := box.BorderForeground(accentColor)
+		b.WriteString(fileBox.Render("📎 FILES\n\n" + strings.Join(files, "\n")))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderMediaOutputTab shows transcripts, generated images and speech audio
+func (m *model) renderMediaOutputTab() string {
+	m.messagePositions = nil
+	images, audio := mediaResponseRefs(m.selected)
+	m.imageRefs, m.audioRefs = images, audio
+	resp := parseMediaResponse(m.selected.ResponseBody)
+	if resp == nil && len(audio) == 0 {
+		return renderJSONBody(m.selected.ResponseBody, "Response")
+	}
+
+	contentWidth := m.width - 10
+	textWidth := contentWidth - 6
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(successColor).
+		Padding(0, 2).
+		MarginBottom(1).
+		Width(contentWidth)
+
+	var meta []string
+	meta = append(meta, labelStyle.Render("Model:")+" "+m.selected.Model)
+	if resp != nil {
+		if resp.Language != "" {
+			meta = append(meta, labelStyle.Render("Language:")+" "+resp.Language)
+		}
+		seconds := resp.Duration
+		if resp.Usage.Type == "duration" {
+			seconds = resp.Usage.Seconds
+		}
+		if label := mediaDurationLabel(seconds); label != "" {
+			meta = append(meta, labelStyle.Render("Audio:")+" "+label)
+		}
+		if len(resp.Data) > 0 {
+			meta = append(meta, fmt.Sprintf("%s %d", labelStyle.Render("Images:"), len(resp.Data)))
+		}
+	}
+	if m.selected.InputTokens > 0 || m.selected.OutputTokens > 0 {
+		meta = append(meta, fmt.Sprintf("%s in=%s out=%s", labelStyle.Render("Tokens:"),
+			formatWithCommas(m.selected.InputTokens), formatWithCommas(m.selected.OutputTokens)))
+	}
+	if m.selected.Cost > 0 {
+		meta = append(meta, labelStyle.Render("Cost:")+" "+formatCost(m.selected.Cost))
+	}
+
+	var b strings.Builder
+	b.WriteString(box.Render(strings.Join(meta, "\n")))
+	b.WriteString("\n")
+
+	if links := renderMediaLinks(images, audio); len(links) > 0 {
+		var lines []string
+		for i, link := range links {
+			line := link
+			if i < len(images) && resp != nil && i < len(resp.Data) && resp.Data[i].RevisedPrompt != "" {
+				line += "\n" + wrapText(sanitizeForTerminal(resp.Data[i].RevisedPrompt), textWidth)
+			}
+			lines = append(lines, line)
+		}
+		label := "🖼 IMAGES"
+		if len(audio) > 0 {
+			label = "🔊 AUDIO OUTPUT"
+		}
+		b.WriteString(box.BorderForeground(accentColor).Render(label + "\n\n" + strings.Join(lines, "\n\n")))
+		b.WriteString("\n")
+	}
+
+	if resp != nil && resp.Text != "" {
+		b.WriteString(box.BorderForeground(primaryColor).Render("📝 TRANSCRIPT\n\n" + wrapText(sanitizeForTerminal(resp.Text), textWidth)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
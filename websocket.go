@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket capture for realtime APIs (OpenAI Realtime, Gemini Live, ...).
+// httputil.ReverseProxy can relay upgraded connections but never shows us the
+// frames, so upgrades on realtime or LLM paths are relayed by hand and every
+// message is recorded with a timestamp.
+
+// WSDirection is who sent a WebSocket message
+type WSDirection string
+
+const (
+	WSFromClient WSDirection = "client"
+	WSFromServer WSDirection = "server"
+)
+
+// WSEvent is one WebSocket message
+type WSEvent struct {
+	Time      time.Time
+	Direction WSDirection
+	Type      string // "type" field of JSON messages; "binary" or "close" otherwise
+	Data      []byte // Text payload, raw bytes for binary messages, code and reason for close
+	Binary    bool
+}
+
+const (
+	wsOpText   = 1
+	wsOpBinary = 2
+	wsOpClose  = 8
+
+	// Messages larger than this are relayed but not captured
+	wsMaxCapturedMessage = 32 << 20
+
+	// Minimum interval between TUI refreshes for streams of audio deltas
+	wsUpdateInterval = 100 * time.Millisecond
+)
+
+// isWebSocketUpgrade returns true for WebSocket handshake requests
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, token := range strings.Split(r.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// isRealtimeEndpoint returns true for realtime WebSocket APIs
+func isRealtimeEndpoint(path string) bool {
+	return strings.HasSuffix(path, "/realtime") || strings.Contains(path, "BidiGenerateContent")
+}
+
+// wsFrameReader reassembles messages from a raw WebSocket byte stream as it is
+// relayed. Client frames are unmasked and fragmented messages are joined.
+type wsFrameReader struct {
+	buf       []byte
+	msg       []byte
+	opcode    byte
+	skipping  bool   // Current message is too large to capture
+	discard   uint64 // Bytes of an oversized frame still to pass over
+	onMessage func(opcode byte, payload []byte)
+}
+
+func (fr *wsFrameReader) Write(p []byte) (int, error) {
+	if fr.discard > 0 {
+		n := min(fr.discard, uint64(len(p)))
+		fr.discard -= n
+		p = p[n:]
+	}
+	fr.buf = append(fr.buf, p...)
+	consumed := 0
+	for {
+		n := fr.parseFrame(fr.buf[consumed:])
+		if n == 0 {
+			break
+		}
+		consumed += n
+	}
+	if consumed > 0 {
+		fr.buf = append([]byte(nil), fr.buf[consumed:]...)
+	}
+	return len(p), nil
+}
+
+// parseFrame handles one complete frame at the start of data and returns its
+// length, or 0 if the frame is not complete yet
+func (fr *wsFrameReader) parseFrame(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	fin := data[0]&0x80 != 0
+	opcode := data[0] & 0x0f
+	masked := data[1]&0x80 != 0
+	length := uint64(data[1] & 0x7f)
+	pos := 2
+
+	switch length {
+	case 126:
+		if len(data) < pos+2 {
+			return 0
+		}
+		length = uint64(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+	case 127:
+		if len(data) < pos+8 {
+			return 0
+		}
+		length = binary.BigEndian.Uint64(data[pos:])
+		pos += 8
+	}
+
+	var mask []byte
+	if masked {
+		if len(data) < pos+4 {
+			return 0
+		}
+		mask = data[pos : pos+4]
+		pos += 4
+	}
+
+	// Oversized frames are dropped from the capture without buffering them
+	if length > wsMaxCapturedMessage {
+		fr.skipping = true
+		fr.msg = nil
+		return fr.skip(data, pos, length)
+	}
+	if uint64(len(data)-pos) < length {
+		return 0
+	}
+
+	payload := make([]byte, length)
+	copy(payload, data[pos:pos+int(length)])
+	for i := range payload {
+		if masked {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	total := pos + int(length)
+
+	if opcode >= wsOpClose {
+		// Control frames may arrive between fragments; ping/pong aren't recorded
+		if opcode == wsOpClose {
+			fr.onMessage(opcode, payload)
+		}
+		return total
+	}
+	if opcode != 0 {
+		fr.opcode = opcode
+		fr.msg = nil
+		fr.skipping = false
+	}
+	if !fr.skipping {
+		fr.msg = append(fr.msg, payload...)
+		if len(fr.msg) > wsMaxCapturedMessage {
+			fr.skipping = true
+			fr.msg = nil
+		}
+	}
+	if fin {
+		if !fr.skipping {
+			fr.onMessage(fr.opcode, fr.msg)
+		}
+		fr.msg = nil
+		fr.skipping = false
+	}
+	return total
+}
+
+// skip passes over an oversized frame, leaving the part that hasn't arrived
+// yet to be discarded by later writes
+func (fr *wsFrameReader) skip(data []byte, pos int, length uint64) int {
+	available := uint64(len(data) - pos)
+	if available >= length {
+		return pos + int(length)
+	}
+	fr.discard = length - available
+	return len(data)
+}
+
+// realtimeUsage is the usage block of a Realtime API response.done event
+type realtimeUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	InputTokenDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+		AudioTokens  int `json:"audio_tokens"`
+	} `json:"input_token_details"`
+	OutputTokenDetails struct {
+		AudioTokens int `json:"audio_tokens"`
+	} `json:"output_token_details"`
+}
+
+// cost prices realtime usage. Audio tokens use the model's audio rates when
+// models.dev lists them; cached input uses the cache read rate.
+func (u realtimeUsage) cost(c *ModelCost) float64 {
+	if c == nil {
+		return 0
+	}
+	rate := func(specific, fallback float64) float64 {
+		if specific > 0 {
+			return specific
+		}
+		return fallback
+	}
+
+	cached := min(u.InputTokenDetails.CachedTokens, u.InputTokens)
+	audioIn := min(u.InputTokenDetails.AudioTokens, u.InputTokens-cached)
+	textIn := u.InputTokens - cached - audioIn
+	audioOut := min(u.OutputTokenDetails.AudioTokens, u.OutputTokens)
+	textOut := u.OutputTokens - audioOut
+
+	total := float64(textIn)*c.Input +
+		float64(audioIn)*rate(c.InputAudio, c.Input) +
+		float64(cached)*rate(c.CacheRead, c.Input) +
+		float64(textOut)*c.Output +
+		float64(audioOut)*rate(c.OutputAudio, c.Output)
+	return total / 1_000_000
+}
+
+// wsSession relays one WebSocket connection and records its messages
+type wsSession struct {
+	req        *LLMRequest
+	mu         sync.Mutex
+	lastUpdate time.Time
+	conns      []net.Conn
+}
+
+var (
+	wsSessions   = make(map[*wsSession]struct{})
+	wsSessionsMu sync.Mutex
+)
+
+// closeWebSockets closes every relayed WebSocket connection, for shutdown
+func closeWebSockets() {
+	wsSessionsMu.Lock()
+	defer wsSessionsMu.Unlock()
+	for s := range wsSessions {
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+	}
+}
+
+// record stores one message and updates usage from response.done events
+func (s *wsSession) record(direction WSDirection, opcode byte, payload []byte) {
+	event := WSEvent{Time: time.Now(), Direction: direction, Data: payload}
+	switch opcode {
+	case wsOpBinary:
+		event.Type = "binary"
+		event.Binary = true
+	case wsOpClose:
+		event.Type = "close"
+		event.Data = []byte(formatWSClose(payload))
+	default:
+		var msg struct {
+			Type    string `json:"type"`
+			Session struct {
+				Model string `json:"model"`
+			} `json:"session"`
+			Response struct {
+				Usage *realtimeUsage `json:"usage"`
+			} `json:"response"`
+		}
+		if json.Unmarshal(payload, &msg) == nil {
+			event.Type = msg.Type
+		}
+
+		s.mu.Lock()
+		if direction == WSFromServer && msg.Session.Model != "" {
+			s.req.Model = msg.Session.Model
+		}
+		if event.Type == "response.done" && msg.Response.Usage != nil {
+			usage := *msg.Response.Usage
+			s.req.InputTokens += usage.InputTokens
+			s.req.OutputTokens += usage.OutputTokens
+			s.req.CachedInputTokens += usage.InputTokenDetails.CachedTokens
+			s.req.Cost += usage.cost(GetModelCost(s.req.ProviderID, pricingModel(s.req)))
+			activeBudget.Record(s.req) // Finished when the session ends
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.req.WSEvents = append(s.req.WSEvents, event)
+	if direction == WSFromClient {
+		s.req.RequestSize += len(payload)
+	} else {
+		s.req.ResponseSize += len(payload)
+	}
+	notify := !isWSStreamingEvent(event) || time.Since(s.lastUpdate) >= wsUpdateInterval
+	if notify {
+		s.lastUpdate = time.Now()
+	}
+	s.mu.Unlock()
+
+	if tapeWriter != nil {
+		tapeWriter.WriteWSMessage(s.req.ID, event)
+	}
+	if notify && program != nil {
+		program.Send(requestUpdatedMsg{req: s.req})
+	}
+}
+
+// formatWSClose renders a close frame payload as "code reason"
+func formatWSClose(payload []byte) string {
+	if len(payload) < 2 {
+		return "no status"
+	}
+	code := binary.BigEndian.Uint16(payload[:2])
+	return strings.TrimSpace(fmt.Sprintf("%d %s", code, payload[2:]))
+}
+
+// dialUpstream opens a TCP or TLS connection to the target host
+func dialUpstream(r *http.Request, up *upstream) (net.Conn, error) {
+	host := up.target.Host
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if up.target.Scheme == "https" || up.target.Scheme == "wss" {
+		if up.target.Port() == "" {
+			host += ":443"
+		}
+		return tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: up.target.Hostname(), NextProtos: []string{"http/1.1"}})
+	}
+	if up.target.Port() == "" {
+		host += ":80"
+	}
+	return dialer.DialContext(r.Context(), "tcp", host)
+}
+
+// serveWebSocket relays a WebSocket upgrade to the upstream and records every
+// message on the request's timeline until either side closes
+func serveWebSocket(p *proxyRuntime, w http.ResponseWriter, r *http.Request, up *upstream, model string, tags ClientTags, startTime time.Time) {
+	target := up.target
+	if m := r.URL.Query().Get("model"); m != "" && model == "unknown" {
+		model = m // OpenAI Realtime: /v1/realtime?model=...
+	}
+
+	outReq := r.Clone(r.Context())
+	outReq.URL.Scheme = target.Scheme
+	outReq.URL.Host = target.Host
+	outReq.URL.Path = strings.TrimSuffix(target.Path, "/") + r.URL.Path
+	outReq.Host = target.Host
+	outReq.RequestURI = ""
+	// Without permessage-deflate the relayed frames stay readable
+	outReq.Header.Del("Sec-WebSocket-Extensions")
+
+	fullURL := target.Scheme + "://" + target.Host + outReq.URL.Path
+	if r.URL.RawQuery != "" {
+		fullURL += "?" + r.URL.RawQuery
+	}
+	providerID, _ := FindProviderByURL(fullURL)
+
+	reqHeaders := make(map[string][]string)
+	for k, v := range r.Header {
+		reqHeaders[k] = v
+	}
+
+	requestsMu.Lock()
+	requestID++
+	req := &LLMRequest{
+		ID:             requestID,
+		Method:         r.Method,
+		Path:           r.URL.Path,
+		Host:           target.Host,
+		URL:            fullURL,
+		Model:          model,
+		Status:         StatusPending,
+		StartTime:      startTime,
+		RequestHeaders: reqHeaders,
+		IsStreaming:    true,
+		IsWebSocket:    true,
+		ProviderID:     providerID,
+		ProxyName:      p.name,
+		ProxyListen:    p.listen,
+		RouteName:      up.route,
+		TargetURL:      target.String(),
+	}
+	applyClientTags(req, tags)
+	stampCorrelation(req, r, w.Header(), outReq.Header)
+	requests = append(requests, req)
+	requestsMu.Unlock()
+	RecordSessionRequest(req)
+	if program != nil {
+		program.Send(requestAddedMsg{req: req})
+	}
+	if tapeWriter != nil {
+		tapeWriter.WriteRequestStart(req)
+	}
+
+	finish := func(statusCode int, respHeaders map[string][]string, body []byte) {
+		activeBudget.Finish(req)
+		if !claimFinalize(req) {
+			return // Shutdown already marked it as cut off
+		}
+		requestsMu.Lock()
+		req.Duration = time.Since(startTime)
+		req.StatusCode = statusCode
+		req.ResponseHeaders = respHeaders
+		if body != nil {
+			req.ResponseBody = body
+			req.ResponseSize = len(body)
+		}
+		if statusCode == http.StatusSwitchingProtocols {
+			req.Status = StatusComplete
+		} else {
+			req.Status = StatusError
+		}
+		requestsMu.Unlock()
+		RecordSessionRequest(req)
+		if tapeWriter != nil {
+			tapeWriter.WriteRequestComplete(req)
+		}
+		if program != nil {
+			program.Send(requestUpdatedMsg{req: req})
+		}
+	}
+
+	// Hard budget caps apply to realtime sessions too
+	if budgetErr := activeBudget.Check(model, p.name); budgetErr != nil {
+		req.BudgetExceeded = budgetErr.Error()
+		body := writeProviderError(w, r.URL.Path, http.StatusPaymentRequired, "llmproxy: "+req.BudgetExceeded)
+		finish(http.StatusPaymentRequired, nil, body)
+		return
+	}
+
+	upstreamConn, err := dialUpstream(r, up)
+	if err != nil {
+		http.Error(w, "llmproxy: "+err.Error(), http.StatusBadGateway)
+		finish(http.StatusBadGateway, nil, []byte(err.Error()))
+		return
+	}
+	if err := outReq.Write(upstreamConn); err != nil {
+		upstreamConn.Close()
+		http.Error(w, "llmproxy: "+err.Error(), http.StatusBadGateway)
+		finish(http.StatusBadGateway, nil, []byte(err.Error()))
+		return
+	}
+
+	upstreamReader := bufio.NewReader(upstreamConn)
+	resp, err := http.ReadResponse(upstreamReader, outReq)
+	if err != nil {
+		upstreamConn.Close()
+		http.Error(w, "llmproxy: "+err.Error(), http.StatusBadGateway)
+		finish(http.StatusBadGateway, nil, []byte(err.Error()))
+		return
+	}
+	respHeaders := make(map[string][]string)
+	for k, v := range resp.Header {
+		respHeaders[k] = v
+	}
+	if name, _ := correlationHeader(); name != "" {
+		resp.Header.Set(name, req.CorrelationID) // the 101 is relayed as-is
+	}
+
+	// Upstream refused the upgrade: pass its answer through like any error
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer upstreamConn.Close()
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		w.Write(body)
+		finish(resp.StatusCode, respHeaders, decompressIfNeeded(body, resp.Header.Get("Content-Encoding")))
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstreamConn.Close()
+		http.Error(w, "llmproxy: connection can't be upgraded", http.StatusInternalServerError)
+		finish(http.StatusInternalServerError, respHeaders, nil)
+		return
+	}
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		upstreamConn.Close()
+		finish(http.StatusBadGateway, respHeaders, []byte(err.Error()))
+		return
+	}
+	if err := resp.Write(clientConn); err != nil {
+		clientConn.Close()
+		upstreamConn.Close()
+		finish(http.StatusBadGateway, respHeaders, []byte(err.Error()))
+		return
+	}
+	req.StatusCode = resp.StatusCode
+	req.ResponseHeaders = respHeaders
+	req.TTFT = time.Since(startTime)
+
+	session := &wsSession{req: req, conns: []net.Conn{clientConn, upstreamConn}}
+	wsSessionsMu.Lock()
+	wsSessions[session] = struct{}{}
+	wsSessionsMu.Unlock()
+
+	fromClient := &wsFrameReader{onMessage: func(op byte, payload []byte) { session.record(WSFromClient, op, payload) }}
+	fromServer := &wsFrameReader{onMessage: func(op byte, payload []byte) { session.record(WSFromServer, op, payload) }}
+
+	// Either side closing ends the relay; closing both conns unblocks the other copy
+	var closeOnce sync.Once
+	closeBoth := func() {
+		closeOnce.Do(func() {
+			clientConn.Close()
+			upstreamConn.Close()
+		})
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		io.Copy(upstreamConn, io.TeeReader(clientBuf.Reader, fromClient))
+	}()
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		io.Copy(clientConn, io.TeeReader(upstreamReader, fromServer))
+	}()
+	wg.Wait()
+
+	wsSessionsMu.Lock()
+	delete(wsSessions, session)
+	wsSessionsMu.Unlock()
+
+	finish(http.StatusSwitchingProtocols, respHeaders, nil)
+}
+
+// realtimeAudio collects audio from realtime events: server audio deltas are
+// joined per output item with their transcripts, and client
+// input_audio_buffer.append chunks are joined until each commit. The map
+// gives the event index where each clip starts.
+func realtimeAudio(events []WSEvent) ([]AudioRef, map[int]int) {
+	type clip struct {
+		pcm        []byte
+		transcript strings.Builder
+		isOutput   bool
+		start      int
+	}
+	var clips []*clip
+	byItem := make(map[string]*clip)
+	var input *clip
+	inputFormat, outputFormat := "pcm16", "pcm16"
+
+	for i, event := range events {
+		if event.Binary || event.Type == "" {
+			continue
+		}
+		var msg struct {
+			Type    string `json:"type"`
+			ItemID  string `json:"item_id"`
+			Delta   string `json:"delta"`
+			Audio   string `json:"audio"`
+			Session struct {
+				InputAudioFormat  string `json:"input_audio_format"`
+				OutputAudioFormat string `json:"output_audio_format"`
+			} `json:"session"`
+		}
+		if json.Unmarshal(event.Data, &msg) != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case "session.created", "session.updated", "session.update":
+			if msg.Session.InputAudioFormat != "" {
+				inputFormat = msg.Session.InputAudioFormat
+			}
+			if msg.Session.OutputAudioFormat != "" {
+				outputFormat = msg.Session.OutputAudioFormat
+			}
+		case "response.audio.delta", "response.output_audio.delta":
+			c := byItem[msg.ItemID]
+			if c == nil {
+				c = &clip{isOutput: true, start: i}
+				byItem[msg.ItemID] = c
+				clips = append(clips, c)
+			}
+			if data, err := base64.StdEncoding.DecodeString(msg.Delta); err == nil {
+				c.pcm = append(c.pcm, data...)
+			}
+		case "response.audio_transcript.delta", "response.output_audio_transcript.delta":
+			if c := byItem[msg.ItemID]; c != nil {
+				c.transcript.WriteString(msg.Delta)
+			}
+		case "input_audio_buffer.append":
+			if input == nil {
+				input = &clip{start: i}
+				clips = append(clips, input)
+			}
+			if data, err := base64.StdEncoding.DecodeString(msg.Audio); err == nil {
+				input.pcm = append(input.pcm, data...)
+			}
+		case "input_audio_buffer.commit", "input_audio_buffer.committed", "input_audio_buffer.clear", "input_audio_buffer.cleared":
+			input = nil
+		}
+	}
+
+	refs := make([]AudioRef, 0, len(clips))
+	starts := make(map[int]int)
+	for _, c := range clips {
+		format := inputFormat
+		if c.isOutput {
+			format = outputFormat
+		}
+		data := c.pcm
+		if format == "pcm16" {
+			// Realtime PCM is 24kHz mono; wrap it so system players can open it
+			data, format = pcm16ToWAV(data, 24000), "wav"
+		}
+		ref := AudioRef{
+			Index:      len(refs) + 1,
+			Data:       base64.StdEncoding.EncodeToString(data),
+			Format:     format,
+			Transcript: c.transcript.String(),
+			IsOutput:   c.isOutput,
+		}
+		starts[c.start] = ref.Index
+		refs = append(refs, ref)
+	}
+	return refs, starts
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encodeWSFrame builds a single WebSocket frame, masked as clients send them
+func encodeWSFrame(opcode byte, payload []byte, fin, masked bool) []byte {
+	var frame bytes.Buffer
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame.WriteByte(first)
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame.WriteByte(maskBit | byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame.WriteByte(maskBit | 126)
+		binary.Write(&frame, binary.BigEndian, uint16(len(payload)))
+	default:
+		frame.WriteByte(maskBit | 127)
+		binary.Write(&frame, binary.BigEndian, uint64(len(payload)))
+	}
+	if !masked {
+		frame.Write(payload)
+		return frame.Bytes()
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame.Write(mask)
+	for i, c := range payload {
+		frame.WriteByte(c ^ mask[i%4])
+	}
+	return frame.Bytes()
+}
+
+// readWSFrame reads one unfragmented, unmasked frame
+func readWSFrame(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var ext uint16
+		binary.Read(r, binary.BigEndian, &ext)
+		length = int(ext)
+	}
+	var mask []byte
+	if header[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		io.ReadFull(r, mask)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		if mask != nil {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return header[0] & 0x0f, payload, nil
+}
+
+func TestWSFrameReader(t *testing.T) {
+	type message struct {
+		opcode  byte
+		payload string
+	}
+	var got []message
+	fr := &wsFrameReader{onMessage: func(op byte, payload []byte) {
+		got = append(got, message{op, string(payload)})
+	}}
+
+	long := strings.Repeat("x", 300)
+	var stream bytes.Buffer
+	stream.Write(encodeWSFrame(wsOpText, []byte(`{"type":"a`), false, true))
+	stream.Write(encodeWSFrame(0x9, []byte("ping"), true, true)) // control frame between fragments
+	stream.Write(encodeWSFrame(0, []byte(`"}`), true, true))
+	stream.Write(encodeWSFrame(wsOpText, []byte(long), true, false))
+	stream.Write(encodeWSFrame(wsOpClose, append([]byte{0x03, 0xe8}, "bye"...), true, true))
+
+	// Feed the stream a few bytes at a time, as a TCP relay would
+	data := stream.Bytes()
+	for i := 0; i < len(data); i += 7 {
+		fr.Write(data[i:min(i+7, len(data))])
+	}
+
+	want := []message{{wsOpText, `{"type":"a"}`}, {wsOpText, long}, {wsOpClose, "\x03\xe8bye"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if s := formatWSClose([]byte("\x03\xe8bye")); s != "1000 bye" {
+		t.Errorf("formatWSClose = %q", s)
+	}
+}
+
+func TestRealtimeAudio(t *testing.T) {
+	pcm := base64.StdEncoding.EncodeToString([]byte{1, 2, 3, 4})
+	events := []WSEvent{
+		{Direction: WSFromClient, Type: "input_audio_buffer.append", Data: []byte(`{"type":"input_audio_buffer.append","audio":"` + pcm + `"}`)},
+		{Direction: WSFromClient, Type: "input_audio_buffer.append", Data: []byte(`{"type":"input_audio_buffer.append","audio":"` + pcm + `"}`)},
+		{Direction: WSFromClient, Type: "input_audio_buffer.commit", Data: []byte(`{"type":"input_audio_buffer.commit"}`)},
+		{Direction: WSFromServer, Type: "response.audio.delta", Data: []byte(`{"type":"response.audio.delta","item_id":"i1","delta":"` + pcm + `"}`)},
+		{Direction: WSFromServer, Type: "response.audio_transcript.delta", Data: []byte(`{"type":"response.audio_transcript.delta","item_id":"i1","delta":"Hello"}`)},
+	}
+	refs, starts := realtimeAudio(events)
+	if len(refs) != 2 {
+		t.Fatalf("got %d clips, want input and output: %+v", len(refs), refs)
+	}
+	input, _ := base64.StdEncoding.DecodeString(refs[0].Data)
+	if refs[0].IsOutput || refs[0].Format != "wav" || len(input) != 44+8 || string(input[:4]) != "RIFF" {
+		t.Errorf("input clip = %+v (%d bytes)", refs[0], len(input))
+	}
+	if !refs[1].IsOutput || refs[1].Transcript != "Hello" {
+		t.Errorf("output clip = %+v", refs[1])
+	}
+	if starts[0] != 1 || starts[3] != 2 {
+		t.Errorf("clip starts = %v", starts)
+	}
+}
+
+// startRealtimeUpstream serves a scripted realtime session: it waits for one
+// client message, then sends session.created, an audio delta, response.done
+// and a close frame
+func startRealtimeUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	pcm := base64.StdEncoding.EncodeToString([]byte{0, 0, 1, 1})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Sec-WebSocket-Extensions") != "" {
+			t.Errorf("compression extension was forwarded")
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+
+		if _, _, err := readWSFrame(buf.Reader); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, msg := range []string{
+			`{"type":"session.created","session":{"model":"gpt-4o-realtime-preview"}}`,
+			`{"type":"response.audio.delta","item_id":"i1","delta":"` + pcm + `"}`,
+			`{"type":"response.done","response":{"usage":{"input_tokens":100,"output_tokens":50,"input_token_details":{"cached_tokens":20,"audio_tokens":60},"output_token_details":{"audio_tokens":40}}}}`,
+		} {
+			conn.Write(encodeWSFrame(wsOpText, []byte(msg), true, false))
+		}
+		conn.Write(encodeWSFrame(wsOpClose, []byte{0x03, 0xe8}, true, false))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebSocketProxyIntegration(t *testing.T) {
+	resetTestState()
+	seedModelsDB(t, map[string]Provider{}, map[string]ModelCost{
+		"gpt-4o-realtime-preview": {Input: 5, Output: 20, CacheRead: 2.5, InputAudio: 40, OutputAudio: 80},
+	})
+	upstream := startRealtimeUpstream(t)
+
+	port := getFreePort(t)
+	if err := StartProxyInstance("test-ws", fmt.Sprintf(":%d", port), upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /v1/realtime?model=gpt-4o-realtime HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Extensions: permessage-deflate\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+
+	conn.Write(encodeWSFrame(wsOpText, []byte(`{"type":"response.create"}`), true, true))
+	var relayed []string
+	for {
+		op, payload, err := readWSFrame(reader)
+		if err != nil || op == wsOpClose {
+			break
+		}
+		relayed = append(relayed, string(payload))
+	}
+	if len(relayed) != 3 {
+		t.Fatalf("client received %d messages, want 3", len(relayed))
+	}
+
+	captured := waitForRequest(t, 1, 2*time.Second)
+	if !captured.IsWebSocket || captured.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("websocket = %v status = %d", captured.IsWebSocket, captured.StatusCode)
+	}
+	if captured.Model != "gpt-4o-realtime-preview" {
+		t.Errorf("model = %q, want the model from session.created", captured.Model)
+	}
+	var types []string
+	for _, event := range captured.WSEvents {
+		types = append(types, string(event.Direction)+":"+event.Type)
+	}
+	if want := "client:response.create server:session.created server:response.audio.delta server:response.done server:close"; strings.Join(types, " ") != want {
+		t.Errorf("events = %s", strings.Join(types, " "))
+	}
+	if captured.InputTokens != 100 || captured.OutputTokens != 50 || captured.CachedInputTokens != 20 {
+		t.Errorf("tokens = in %d out %d cached %d", captured.InputTokens, captured.OutputTokens, captured.CachedInputTokens)
+	}
+	// 20 text + 60 audio + 20 cached in, 10 text + 40 audio out
+	if want := (20*5 + 60*40 + 20*2.5 + 10*20 + 40*80) / 1_000_000; math.Abs(captured.Cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", captured.Cost, want)
+	}
+
+	// Messages survive a tape round trip
+	tapePath := filepath.Join(t.TempDir(), "ws.tape")
+	if err := SaveSessionToTape(tapePath, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	tape, err := LoadTape(tapePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tape.Requests) != 1 || !tape.Requests[0].IsWebSocket || len(tape.Requests[0].WSEvents) != len(captured.WSEvents) {
+		t.Fatalf("tape requests = %+v", tape.Requests)
+	}
+	if replayed := tape.GetRequestsAtTime(tape.EndTime.Add(time.Second)); len(replayed) != 1 || len(replayed[0].WSEvents) != len(captured.WSEvents) {
+		t.Errorf("replayed requests = %+v", replayed)
+	}
+}